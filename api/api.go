@@ -0,0 +1,9 @@
+// Package api contains the documentation of the HTTP and gRPC APIs
+package api
+
+import _ "embed" // for embedding the OpenAPI document
+
+// OpenAPI is the OpenAPI document describing the HTTP API
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/backup"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/export"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+const (
+	defaultImportBatchSize = 1000
+	defaultBackupDir       = "backups"
+	defaultBackupRetention = 24
+)
+
+func runImport(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	formatName := flags.String("format", string(export.CSV), "Format of the file: csv, ndjson or parquet")
+	batchSize := flags.Int("batch", defaultImportBatchSize, "Number of offers inserted per transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := export.Import(file, format, *batchSize, func(offers []database.Offer) error {
+		return db.InsertMultiple(context.Background(), offers)
+	})
+	fmt.Printf("Imported %d offers\n", count)
+	return err
+}
+
+func runExport(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	formatName := flags.String("format", string(export.CSV), "Format of the file: csv, ndjson or parquet")
+	output := flags.String("o", "", "File to write to instead of stdout")
+	category := flags.String("category", "", "Only export offers in this category")
+	supplier := flags.String("supplier", "", "Only export offers by this supplier")
+	updatedSince := flags.String(
+		"updated-since", "", "Only export offers updated at or after this time (RFC 3339)",
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	filter := database.OfferFilter{Category: *category, Supplier: *supplier}
+	if *updatedSince != "" {
+		filter.UpdatedSince, err = time.Parse(time.RFC3339, *updatedSince)
+		if err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	it, err := db.Iterate(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	count, err := export.Offers(w, format, it)
+	fmt.Fprintf(os.Stderr, "Exported %d offers\n", count)
+	return err
+}
+
+func runVacuum(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	return db.Vacuum()
+}
+
+func runCheck(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	problems, err := db.IntegrityCheck()
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems", len(problems))
+	}
+
+	fmt.Println("ok")
+	return nil
+}
+
+func runStats(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Offers\t%d\n", stats.Offers)
+	printGroupCounts(w, "Category", stats.ByCategory)
+	printGroupCounts(w, "Supplier", stats.BySupplier)
+	return w.Flush()
+}
+
+func printGroupCounts(w io.Writer, title string, counts []database.GroupCount) {
+	fmt.Fprintf(w, "\n%s\tOffers\n", title)
+	for _, count := range counts {
+		fmt.Fprintf(w, "%s\t%d\n", count.Name, count.Offers)
+	}
+}
+
+func runBackup(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	dir := flags.String("dir", defaultBackupDir, "Directory to write the backup to")
+	retention := flags.Int("retention", defaultBackupRetention, "Number of backups to keep in the directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// The interval is only used for scheduled backups
+	backups, err := backup.NewManager(db, backup.Config{Dir: *dir, Interval: time.Hour, Retention: *retention})
+	if err != nil {
+		return err
+	}
+
+	info, err := backups.Backup()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %d bytes to %s\nSHA-256 %s\n", info.Size, info.Path, info.SHA256)
+	return nil
+}
+
+// runRestore restores either the given backup file or the latest one in the backup directory
+// taken at or before a point in time
+func runRestore(_ *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	dir := flags.String("dir", defaultBackupDir, "Directory to look for backups in")
+	at := flags.String("at", "", "Restore the latest backup taken at or before this time (RFC 3339). Defaults to now")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var info backup.Info
+	var err error
+
+	switch {
+	case flags.NArg() == 1 && *at == "":
+		info, err = backup.Describe(flags.Arg(0))
+	case flags.NArg() == 0:
+		pointInTime := time.Now()
+		if *at != "" {
+			pointInTime, err = time.Parse(time.RFC3339, *at)
+			if err != nil {
+				return err
+			}
+		}
+		info, err = backup.Latest(*dir, pointInTime)
+	default:
+		flags.Usage()
+		return fmt.Errorf("expected either a backup file or a point in time")
+	}
+	if err != nil {
+		return err
+	}
+
+	err = backup.Restore(info, databasePath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s to %s\n", info.Path, databasePath)
+	return nil
+}
+
+// runSearch prints the offers for a product like the search endpoint would return them. Offers of
+// suspended suppliers and offers which are out of stock are left out.
+func runSearch(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error {
+	strategy := flags.String("ranking", string(ranking.ByPrice), "Order of the offers: price or reviewScore")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a product and a category")
+	}
+	if !ranking.Strategy(*strategy).Valid() {
+		return fmt.Errorf("unknown ranking %q", *strategy)
+	}
+
+	ctx := context.Background()
+	offers, err := db.Get(ctx, flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if offers, err = ranking.Orderable(ctx, db, offers); err != nil {
+		return err
+	}
+
+	reviewer := ranking.ReviewerFor(ctx, db, &review.Client{})
+	ranked, err := ranking.RankBy(reviewer, offers, ranking.Strategy(*strategy))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Supplier\tPrice\tReview score\n")
+	for _, offer := range ranked {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\n", offer.Supplier, offer.Price, offer.ReviewScore)
+	}
+	return w.Flush()
+}
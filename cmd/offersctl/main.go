@@ -0,0 +1,161 @@
+// offersctl is a command line tool to manage the offers database without running the service
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	defaultDatabasePath = "offers.db"
+
+	databasePath string
+	tenant       string
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// createDatabase allows the command to run against a database that doesn't exist yet
+	createDatabase bool
+	// withoutDatabase runs the command without opening the database. It's passed nil instead.
+	withoutDatabase bool
+	// run parses the arguments with the given flag set and executes the command
+	run func(db *database.OffersSQLiteDatabase, flags *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{
+		name:           "import",
+		usage:          "import [-format csv|ndjson|parquet] [-batch n] FILE",
+		summary:        "Import offers from a file, updating existing ones",
+		createDatabase: true,
+		run:            runImport,
+	},
+	{
+		name:    "export",
+		usage:   "export [-format csv|ndjson|parquet] [-o FILE] [-category c] [-supplier s] [-updated-since t]",
+		summary: "Export offers to a file or stdout",
+		run:     runExport,
+	},
+	{
+		name:    "vacuum",
+		usage:   "vacuum",
+		summary: "Rebuild the database file to reclaim unused space",
+		run:     runVacuum,
+	},
+	{
+		name:    "check",
+		usage:   "check",
+		summary: "Check the integrity of the database",
+		run:     runCheck,
+	},
+	{
+		name:    "stats",
+		usage:   "stats",
+		summary: "Print the number of offers per category and supplier",
+		run:     runStats,
+	},
+	{
+		name:    "backup",
+		usage:   "backup [-dir DIR] [-retention n]",
+		summary: "Take a backup of the database while it may be in use",
+		run:     runBackup,
+	},
+	{
+		name:            "restore",
+		usage:           "restore [-dir DIR] [-at TIME | FILE]",
+		summary:         "Replace the database with a verified backup. Stop the service first",
+		withoutDatabase: true,
+		run:             runRestore,
+	},
+	{
+		name:    "search",
+		usage:   "search [-ranking price|reviewScore] PRODUCT CATEGORY",
+		summary: "Search for offers for a product in a category",
+		run:     runSearch,
+	},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-db PATH] [-tenant ID] COMMAND [ARGS]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.StringVar(&databasePath, "db", defaultDatabasePath, "Path to the SQLite database file")
+	flag.StringVar(&tenant, "tenant", database.DefaultTenant, "Tenant whose offers are imported, exported, searched and counted")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := findCommand(flag.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if err := runCommand(cmd, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand opens the database and runs the command against the offers of the tenant
+//
+// Opening a database creates it if it doesn't exist. Most commands don't make sense against an
+// empty database, so they fail instead.
+func runCommand(cmd command, args []string) error {
+	var db *database.OffersSQLiteDatabase
+
+	if !cmd.withoutDatabase {
+		if !cmd.createDatabase {
+			if _, err := os.Stat(databasePath); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		db, err = database.InitSQLiteDatabase(databasePath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		db = db.ForTenant(tenant).(*database.OffersSQLiteDatabase)
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [-db PATH] [-tenant ID] %s\n", os.Args[0], cmd.usage)
+		flags.PrintDefaults()
+	}
+
+	return cmd.run(db, flags, args)
+}
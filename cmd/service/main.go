@@ -1,38 +1,292 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"log/slog"
+	"time"
 
+	"github.com/muffix/relayr-challenge/api"
+	"github.com/muffix/relayr-challenge/internal/alerts"
+	"github.com/muffix/relayr-challenge/internal/backup"
 	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/graphqlapi"
+	"github.com/muffix/relayr-challenge/internal/grpcapi"
 	"github.com/muffix/relayr-challenge/internal/httpapi"
 	"github.com/muffix/relayr-challenge/internal/review"
+	"github.com/muffix/relayr-challenge/internal/webhooks"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var (
-	databasePath = "offers.db"
-	defaultPort  = 8080
+	databasePath     = "offers.db"
+	defaultPort      = 8080
+	defaultGRPCPort  = 9090
+	defaultAdminPort = 8081
 
-	servicePort int
+	defaultMaxBodySize        = int64(1 << 20)
+	defaultMaxBatchBodySize   = int64(32 << 20)
+	defaultCompressionMinSize = 1024
+	defaultBackupInterval     = time.Hour
+	defaultBackupRetention    = 24
+	defaultQuickCheckInterval = time.Hour
+	defaultReviewCacheTTL     = 5 * time.Minute
+
+	servicePort          int
+	grpcPort             int
+	adminPort            int
+	logLevel             slog.Level
+	reviewCacheTTL       time.Duration
+	idempotencyRetention time.Duration
+	validateResponses    bool
+	maxBodySize          int64
+	maxBatchBodySize     int64
+	compressionMinSize   int
+	tenantsConfig        string
+	tlsConfig            httpapi.TLSConfig
+	readinessConfig      = httpapi.DefaultReadinessConfig()
+	minFreeDiskMiB       uint64
+	quickCheckInterval   time.Duration
+	backupConfig         backup.Config
+	databaseOptions      = database.DefaultOptions()
+	webhookConfig        = webhooks.DefaultConfig()
 )
 
 func processCommandlineArgs() {
 	flag.IntVar(&servicePort, "p", defaultPort, "Port to listen on to serve HTTP requests")
+	flag.IntVar(&grpcPort, "grpc-port", defaultGRPCPort, "Port to listen on to serve gRPC requests")
+	flag.IntVar(
+		&adminPort,
+		"admin-port",
+		defaultAdminPort,
+		"Port to listen on to serve the admin routes. Mustn't be exposed through the ingress. Disabled if 0",
+	)
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "Minimum level of logged messages. Requests are logged at info")
+	flag.DurationVar(
+		&reviewCacheTTL,
+		"review-cache-ttl",
+		defaultReviewCacheTTL,
+		"How long review scores are cached. Disabled if 0",
+	)
+	flag.DurationVar(
+		&idempotencyRetention,
+		"idempotency-retention",
+		httpapi.DefaultIdempotencyRetention,
+		"How long responses to requests with an Idempotency-Key header are kept for replays",
+	)
+	flag.BoolVar(
+		&validateResponses,
+		"validate-responses",
+		false,
+		"Log responses which don't match the OpenAPI document. Meant for testing, since it copies every response",
+	)
+	flag.Int64Var(
+		&maxBodySize,
+		"max-body-size",
+		defaultMaxBodySize,
+		"Maximum size of request bodies in bytes after decompression. Larger requests are rejected with 413",
+	)
+	flag.Int64Var(
+		&maxBatchBodySize,
+		"max-batch-body-size",
+		defaultMaxBatchBodySize,
+		"Maximum size of request bodies of batch imports in bytes after decompression",
+	)
+	flag.IntVar(
+		&compressionMinSize,
+		"compression-min-size",
+		defaultCompressionMinSize,
+		"Minimum size of responses in bytes which are compressed. Negative values disable compression",
+	)
+	flag.StringVar(
+		&tenantsConfig,
+		"tenants-config",
+		"",
+		"Path of a JSON file with the tenants, their API keys, currencies and rankings. Only the default tenant is served if empty",
+	)
+	flag.StringVar(&tlsConfig.CertFile, "tls-cert", "", "Path of the TLS certificate chain. Serves HTTPS if set")
+	flag.StringVar(&tlsConfig.KeyFile, "tls-key", "", "Path of the private key of the TLS certificate")
+	flag.StringVar(
+		&tlsConfig.ClientCAFile,
+		"tls-client-ca",
+		"",
+		"Path of the CAs which client certificates are verified with. Clients without a valid certificate are rejected. Clients aren't asked for certificates if empty",
+	)
+	flag.BoolVar(
+		&tlsConfig.ClientCertOptional,
+		"tls-client-cert-optional",
+		false,
+		"Accept clients without a certificate, while verifying the ones which present one",
+	)
+	flag.IntVar(
+		&tlsConfig.RedirectPort,
+		"https-redirect-port",
+		0,
+		"Port to listen on to redirect plain HTTP requests to HTTPS. Disabled if 0",
+	)
+	flag.DurationVar(
+		&readinessConfig.CacheTTL,
+		"readiness-cache-ttl",
+		readinessConfig.CacheTTL,
+		"How long results of readiness checks are reused before the dependencies are checked again",
+	)
+	flag.Uint64Var(
+		&minFreeDiskMiB,
+		"readiness-min-free-disk",
+		readinessConfig.MinFreeDiskSpace>>20,
+		"Free space in MiB on the volume of the database below which the service isn't ready",
+	)
+	flag.StringVar(
+		&backupConfig.Dir,
+		"backup-dir",
+		"",
+		"Directory for database backups. Backups are disabled if empty",
+	)
+	flag.DurationVar(&backupConfig.Interval, "backup-interval", defaultBackupInterval, "Time between backups")
+	flag.IntVar(&backupConfig.Retention, "backup-retention", defaultBackupRetention, "Number of backups to keep")
+	flag.DurationVar(
+		&databaseOptions.BusyTimeout,
+		"db-busy-timeout",
+		databaseOptions.BusyTimeout,
+		"How long to wait for a database lock held by another process",
+	)
+	flag.IntVar(
+		&databaseOptions.CacheSizeKiB,
+		"db-cache-size",
+		databaseOptions.CacheSizeKiB,
+		"Size of the page cache of each database connection in KiB",
+	)
+	flag.IntVar(
+		&databaseOptions.MaxReadConnections,
+		"db-max-read-connections",
+		databaseOptions.MaxReadConnections,
+		"Maximum number of database connections used for reads",
+	)
+	flag.DurationVar(
+		&databaseOptions.QueryTimeout,
+		"db-query-timeout",
+		databaseOptions.QueryTimeout,
+		"Maximum time of a database query or write. Unlimited if 0",
+	)
+	flag.DurationVar(
+		&quickCheckInterval,
+		"db-quick-check-interval",
+		defaultQuickCheckInterval,
+		"Time between quick checks of the database for corruption. Disabled if 0",
+	)
+	flag.IntVar(
+		&webhookConfig.MaxAttempts,
+		"webhook-max-attempts",
+		webhookConfig.MaxAttempts,
+		"Number of attempts to deliver a webhook event before it's dead-lettered",
+	)
+	flag.DurationVar(
+		&webhookConfig.InitialBackoff,
+		"webhook-initial-backoff",
+		webhookConfig.InitialBackoff,
+		"Time before the first retry of a webhook delivery. Doubles with every retry",
+	)
+	flag.DurationVar(
+		&webhookConfig.MaxBackoff,
+		"webhook-max-backoff",
+		webhookConfig.MaxBackoff,
+		"Maximum time between retries of a webhook delivery",
+	)
+	flag.DurationVar(&webhookConfig.Timeout, "webhook-timeout", webhookConfig.Timeout, "Timeout of webhook deliveries")
 	flag.Parse()
+
+	readinessConfig.MinFreeDiskSpace = minFreeDiskMiB << 20
 }
 
 func main() {
 	processCommandlineArgs()
 	service := httpapi.NewService(servicePort)
+	service.SetLogLevel(logLevel)
+	if adminPort != 0 {
+		service.SetAdminPort(adminPort)
+	}
+	service.SetIdempotencyRetention(idempotencyRetention)
+	service.SetMaxBodySize("", maxBodySize)
+	service.SetMaxBodySize("/api/v1/offer/batch", maxBatchBodySize)
+	service.SetCompressionMinSize(compressionMinSize)
+	service.SetReadinessConfig(readinessConfig)
+	if tenantsConfig != "" {
+		tenants, err := httpapi.LoadTenants(tenantsConfig)
+		if err != nil {
+			log.Fatalf("failed to load tenants: %v", err)
+		}
+		if err := service.SetTenants(tenants); err != nil {
+			log.Fatalf("failed to set up tenants: %v", err)
+		}
+	}
+	if tlsConfig.CertFile != "" {
+		if err := service.SetTLS(tlsConfig); err != nil {
+			log.Fatalf("failed to set up TLS: %v", err)
+		}
+	}
+	if err := service.SetOpenAPIValidation(api.OpenAPI, validateResponses); err != nil {
+		log.Fatalf("failed to set up request validation: %v", err)
+	}
 
-	db, err := database.InitSQLiteDatabase(databasePath)
+	db, err := database.InitSQLiteDatabaseWithOptions(databasePath, databaseOptions)
 	if err != nil {
 		log.Fatalf("failed to initialise database: %v", err)
 	}
 
+	var reviewer review.Reviewer = &review.Client{}
+	if reviewCacheTTL > 0 {
+		reviewer = review.NewCache(reviewer, reviewCacheTTL)
+	}
 	service.SetDatabase(db)
-	service.SetReviewer(&review.Client{})
+	service.SetReviewer(reviewer)
+	service.SetWebhookStore(db)
+
+	graphQL, err := graphqlapi.NewExecutor()
+	if err != nil {
+		log.Fatalf("failed to set up GraphQL: %v", err)
+	}
+	service.SetGraphQLExecutor(graphQL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if quickCheckInterval > 0 {
+		go db.RunQuickChecks(ctx, quickCheckInterval)
+	}
+
+	dispatcher, err := webhooks.NewDispatcher(db, webhookConfig)
+	if err != nil {
+		log.Fatalf("failed to set up webhooks: %v", err)
+	}
+	db.AddChangeListener(dispatcher.Notify)
+	go dispatcher.Run(ctx)
+
+	evaluator := alerts.NewEvaluator(db, db, alerts.LogNotifier{})
+	db.AddChangeListener(evaluator.Notify)
+	go evaluator.Run(ctx)
+	service.SetAlerts(db, evaluator)
+
+	if backupConfig.Dir != "" {
+		backups, err := backup.NewManager(db, backupConfig)
+		if err != nil {
+			log.Fatalf("failed to set up backups: %v", err)
+		}
+
+		go backups.Run(ctx)
+
+		service.SetBackupManager(backups)
+	}
+
+	grpcServer := grpcapi.NewServer(grpcPort)
+	grpcServer.SetDatabase(db)
+	grpcServer.SetReviewer(reviewer)
+	grpcServer.SetReadOnly(service.ReadOnly)
+	if err := grpcServer.Start(); err != nil {
+		log.Fatalf("failed to start gRPC server: %v", err)
+	}
+	defer grpcServer.Stop()
+
 	service.Start()
 }
@@ -0,0 +1,153 @@
+// Package alerts evaluates price alerts after offers change and notifies users about them
+package alerts
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+)
+
+// Store is where alerts are looked up and their state is kept
+type Store interface {
+	AlertsFor(productName, categoryName string) ([]database.Alert, error)
+	TransitionAlert(id int64, from, to database.AlertState, at time.Time) (bool, error)
+}
+
+// product identifies a product in a category
+type product struct {
+	name, category string
+}
+
+// Evaluator checks the alerts for products whose offers have changed
+//
+// An alert fires when the cheapest offer drops below its target price, and is resolved when no offer
+// is below the target price anymore. Notifications are only sent when the state changes.
+type Evaluator struct {
+	store    Store
+	prices   database.Offers
+	notifier Notifier
+
+	// pending holds the products to evaluate. Changes to the same product are coalesced.
+	mu      sync.Mutex
+	pending map[product]struct{}
+	wake    chan struct{}
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+}
+
+// NewEvaluator returns an evaluator for the alerts in the store against the offers in the database
+func NewEvaluator(store Store, prices database.Offers, notifier Notifier) *Evaluator {
+	return &Evaluator{
+		store:    store,
+		prices:   prices,
+		notifier: notifier,
+		pending:  make(map[product]struct{}),
+		wake:     make(chan struct{}, 1),
+		now:      time.Now,
+	}
+}
+
+// Notify schedules the products of the changes for evaluation
+//
+// Its signature matches database.ChangeListener. It doesn't wait for the evaluation. Alerts are
+// only available to the default tenant, so the offers of other tenants are ignored.
+func (e *Evaluator) Notify(changes []database.Change) {
+	for _, change := range changes {
+		if change.Tenant != database.DefaultTenant {
+			continue
+		}
+		e.Schedule(change.Offer.Product, change.Offer.Category)
+	}
+}
+
+// Schedule evaluates the alerts for a product in a category in the background
+func (e *Evaluator) Schedule(productName, categoryName string) {
+	e.mu.Lock()
+	e.pending[product{productName, categoryName}] = struct{}{}
+	e.mu.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run evaluates scheduled products until the context is cancelled
+func (e *Evaluator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		}
+
+		e.mu.Lock()
+		pending := e.pending
+		e.pending = make(map[product]struct{})
+		e.mu.Unlock()
+
+		for p := range pending {
+			if err := e.Evaluate(ctx, p.name, p.category); err != nil {
+				log.Printf("Error evaluating alerts for %s in %s: %v", p.name, p.category, err)
+			}
+		}
+	}
+}
+
+// Evaluate checks the alerts for a product in a category against its cheapest offer now. Only
+// offers which can be ordered are considered, so offers of suspended suppliers and offers which are
+// out of stock are ignored.
+func (e *Evaluator) Evaluate(ctx context.Context, productName, categoryName string) error {
+	alerts, err := e.store.AlertsFor(productName, categoryName)
+	if err != nil || len(alerts) == 0 {
+		return err
+	}
+
+	offers, err := e.prices.Get(ctx, productName, categoryName)
+	if err != nil {
+		return err
+	}
+	if offers, err = ranking.Orderable(ctx, e.prices, offers); err != nil {
+		return err
+	}
+
+	var cheapest *database.Offer
+	if len(offers) > 0 {
+		cheapest = &offers[0]
+	}
+
+	for _, alert := range alerts {
+		want := database.AlertResolved
+		if cheapest != nil && cheapest.Price < alert.TargetPrice {
+			want = database.AlertFiring
+		}
+		if alert.State == want {
+			continue
+		}
+
+		now := e.now().UTC()
+		changed, err := e.store.TransitionAlert(alert.ID, alert.State, want, now)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			// The alert was changed concurrently, e.g. updated through the API, which schedules
+			// another evaluation
+			continue
+		}
+
+		alert.State = want
+		alert.StateChangedAt = now
+		err = e.notifier.Notify(ctx, Notification{Alert: alert, Cheapest: cheapest, Time: now})
+		if err != nil {
+			log.Printf("Error sending notification for alert %d: %v", alert.ID, err)
+		}
+	}
+
+	return nil
+}
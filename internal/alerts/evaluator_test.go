@@ -0,0 +1,213 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// recordingNotifier keeps the notifications it's sent
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// states returns the states of the alerts in the notifications so far and forgets them
+func (n *recordingNotifier) states() []database.AlertState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var states []database.AlertState
+	for _, notification := range n.notifications {
+		states = append(states, notification.Alert.State)
+	}
+	n.notifications = nil
+	return states
+}
+
+func setupDatabase(t *testing.T) *database.OffersSQLiteDatabase {
+	db, err := database.InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	db := setupDatabase(t)
+	notifier := &recordingNotifier{}
+	evaluator := NewEvaluator(db, db, notifier)
+
+	alert, err := db.AddAlert(database.Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40})
+	if err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+
+	ctx := context.Background()
+	steps := []struct {
+		name   string
+		change func() error
+		want   []database.AlertState
+	}{
+		{"no offers", func() error { return nil }, nil},
+		{"above the target", func() error { return db.Insert(context.Background(), "Towel", "Must Haves", "a", 42) }, nil},
+		{"at the target", func() error { return db.Insert(context.Background(), "Towel", "Must Haves", "b", 40) }, nil},
+		{"below the target", func() error { return db.Insert(context.Background(), "Towel", "Must Haves", "b", 39) }, []database.AlertState{database.AlertFiring}},
+		{"still below the target", func() error { return db.Insert(context.Background(), "Towel", "Must Haves", "c", 30) }, nil},
+		{"other product", func() error { return db.Insert(context.Background(), "Guide", "Must Haves", "c", 1) }, nil},
+		{"one offer withdrawn", func() error { return db.Withdraw(context.Background(), "Towel", "Must Haves", "c") }, nil},
+		{"last cheap offer withdrawn", func() error { return db.Withdraw(context.Background(), "Towel", "Must Haves", "b") }, []database.AlertState{database.AlertResolved}},
+	}
+
+	for _, step := range steps {
+		if err = step.change(); err != nil {
+			t.Fatalf("%s: expected no error changing offers, got %v", step.name, err)
+		}
+		if err = evaluator.Evaluate(ctx, "Towel", "Must Haves"); err != nil {
+			t.Fatalf("%s: expected no error evaluating alerts, got %v", step.name, err)
+		}
+
+		got := notifier.states()
+		if len(got) != len(step.want) || (len(got) > 0 && got[0] != step.want[0]) {
+			t.Fatalf("%s: got notifications %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	if alert, err = db.Alert(alert.ID); err != nil || alert.State != database.AlertResolved || alert.StateChangedAt.IsZero() {
+		t.Fatalf("Got alert %+v and error %v, want it to be resolved", alert, err)
+	}
+}
+
+func TestEvaluator_Evaluate_suspendedSuppliers(t *testing.T) {
+	db := setupDatabase(t)
+	notifier := &recordingNotifier{}
+	evaluator := NewEvaluator(db, db, notifier)
+	ctx := context.Background()
+
+	if _, err := db.AddAlert(database.Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40}); err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Knockoffs", 30); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	suppliers, err := db.SuppliersByName(ctx, []string{"Hitchhiker Knockoffs"})
+	if err != nil {
+		t.Fatalf("Expected no error looking up the supplier, got %v", err)
+	}
+	_, err = db.UpdateSupplier(ctx, database.Supplier{ID: suppliers["Hitchhiker Knockoffs"].ID, Status: database.SupplierSuspended})
+	if err != nil {
+		t.Fatalf("Expected no error suspending the supplier, got %v", err)
+	}
+
+	if err = evaluator.Evaluate(ctx, "Towel", "Must Haves"); err != nil {
+		t.Fatalf("Expected no error evaluating alerts, got %v", err)
+	}
+	if got := notifier.states(); len(got) != 0 {
+		t.Fatalf("Expected no notifications for the offer of a suspended supplier, got %v", got)
+	}
+}
+
+func TestEvaluator_Evaluate_unavailableOffers(t *testing.T) {
+	db := setupDatabase(t)
+	notifier := &recordingNotifier{}
+	evaluator := NewEvaluator(db, db, notifier)
+	ctx := context.Background()
+
+	if _, err := db.AddAlert(database.Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40}); err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+	soldOut := 0
+	offer := database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 30, Stock: database.Stock{Quantity: &soldOut}}
+	if err := db.InsertMultiple(ctx, []database.Offer{offer}); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	if err := evaluator.Evaluate(ctx, "Towel", "Must Haves"); err != nil {
+		t.Fatalf("Expected no error evaluating alerts, got %v", err)
+	}
+	if got := notifier.states(); len(got) != 0 {
+		t.Fatalf("Expected no notifications for an offer which is out of stock, got %v", got)
+	}
+
+	// The alert fires once the offer is back in stock
+	restocked := 5
+	if _, err := db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Knockoffs", database.StockUpdate{Stock: database.Stock{Quantity: &restocked}}); err != nil {
+		t.Fatalf("Expected no error updating the stock, got %v", err)
+	}
+	if err := evaluator.Evaluate(ctx, "Towel", "Must Haves"); err != nil {
+		t.Fatalf("Expected no error evaluating alerts, got %v", err)
+	}
+	if got := notifier.states(); len(got) != 1 || got[0] != database.AlertFiring {
+		t.Fatalf("Expected the alert to fire, got %v", got)
+	}
+}
+
+func TestEvaluator_Run(t *testing.T) {
+	db := setupDatabase(t)
+	notifier := &recordingNotifier{}
+	evaluator := NewEvaluator(db, db, notifier)
+	db.AddChangeListener(evaluator.Notify)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evaluator.Run(ctx)
+
+	if _, err := db.AddAlert(database.Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40}); err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 30); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		notifier.mu.Lock()
+		fired := len(notifier.notifications) == 1
+		notifier.mu.Unlock()
+		if fired {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the alert to fire")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	notification := notifier.notifications[0]
+	if notification.Cheapest == nil || notification.Cheapest.Supplier != "Hitchhiker Essentials" {
+		t.Fatalf("Expected the cheapest offer in the notification, got %+v", notification)
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	notifier := LogNotifier{Logger: log.New(&buf, "", 0)}
+
+	err := notifier.Notify(context.Background(), Notification{
+		Alert:    database.Alert{ID: 1, Product: "Towel", Category: "Must Haves", TargetPrice: 40, State: database.AlertFiring},
+		Cheapest: &database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 39},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Alert 1 firing: Towel in Must Haves costs 39 at Hitchhiker Essentials (target price 40)"
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Fatalf("Got log %q, want %q", got, want)
+	}
+}
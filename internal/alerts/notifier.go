@@ -0,0 +1,49 @@
+package alerts
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// Notification is sent when an alert starts firing or is resolved
+type Notification struct {
+	// Alert is the alert in its new state
+	Alert database.Alert
+	// Cheapest is the cheapest offer for the product. It's nil if there are no offers.
+	Cheapest *database.Offer
+	Time     time.Time
+}
+
+// Notifier sends notifications about alerts to users
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes notifications to a log
+type LogNotifier struct {
+	// Logger is the log written to. The standard logger is used if it's nil.
+	Logger *log.Logger
+}
+
+// Notify logs the notification
+func (n LogNotifier) Notify(_ context.Context, notification Notification) error {
+	logf := log.Printf
+	if n.Logger != nil {
+		logf = n.Logger.Printf
+	}
+
+	alert := notification.Alert
+	if notification.Cheapest == nil {
+		logf("Alert %d %s: no offers for %s in %s (target price %g)",
+			alert.ID, alert.State, alert.Product, alert.Category, alert.TargetPrice)
+		return nil
+	}
+
+	logf("Alert %d %s: %s in %s costs %g at %s (target price %g)",
+		alert.ID, alert.State, alert.Product, alert.Category, notification.Cheapest.Price,
+		notification.Cheapest.Supplier, alert.TargetPrice)
+	return nil
+}
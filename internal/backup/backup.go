@@ -0,0 +1,282 @@
+// Package backup takes scheduled backups of the offers database and restores them
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	filePrefix        = "offers-"
+	fileExtension     = ".db"
+	checksumExtension = ".sha256"
+	tempExtension     = ".tmp"
+
+	// timeLayout is used in backup file names. It sorts lexically and contains no colons, which
+	// aren't allowed in file names everywhere.
+	timeLayout = "20060102T150405.000Z"
+)
+
+// Source is a database which can write a consistent copy of itself to a file
+type Source interface {
+	Backup(destPath string) error
+}
+
+// Config configures where backups are kept and how many
+type Config struct {
+	// Dir is the directory backups are written to
+	Dir string
+	// Interval is the time between scheduled backups
+	Interval time.Duration
+	// Retention is the number of backups that are kept. Older ones are deleted.
+	Retention int
+}
+
+// Info describes a backup file
+type Info struct {
+	Path   string    `json:"path"`
+	Time   time.Time `json:"time"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+}
+
+// Manager takes backups of a database and enforces the retention
+type Manager struct {
+	source Source
+	config Config
+
+	// mu makes sure that only one backup runs at a time
+	mu sync.Mutex
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+}
+
+// NewManager returns a manager taking backups of the source according to the config
+func NewManager(source Source, config Config) (*Manager, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("no backup directory configured")
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("backup interval must be positive, got %s", config.Interval)
+	}
+	if config.Retention < 1 {
+		return nil, fmt.Errorf("retention must keep at least one backup, got %d", config.Retention)
+	}
+
+	err := os.MkdirAll(config.Dir, 0o750)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating backup directory")
+	}
+
+	return &Manager{source: source, config: config, now: time.Now}, nil
+}
+
+// Run takes a backup every interval until the context is cancelled
+//
+// Errors are logged since there's nobody to return them to.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := m.Backup()
+			if err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+				continue
+			}
+			log.Printf("Backed up database to %s", info.Path)
+		}
+	}
+}
+
+// Backup takes a backup now, writes its checksum next to it and deletes backups past retention
+//
+// The backup is written to a temporary file first, so incomplete backups are never picked up.
+func (m *Manager) Backup() (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	backupTime := m.now().UTC()
+	path := filepath.Join(m.config.Dir, filePrefix+backupTime.Format(timeLayout)+fileExtension)
+	tempPath := path + tempExtension
+
+	err := m.source.Backup(tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return Info{}, errors.Wrap(err, "error backing up database")
+	}
+
+	checksum, size, err := fileChecksum(tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return Info{}, err
+	}
+
+	err = writeChecksumFile(path, checksum)
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		os.Remove(path + checksumExtension)
+		return Info{}, errors.Wrap(err, "error storing backup")
+	}
+
+	err = m.prune()
+	if err != nil {
+		log.Printf("Error deleting old backups: %v", err)
+	}
+
+	return Info{Path: path, Time: backupTime, Size: size, SHA256: checksum}, nil
+}
+
+// prune deletes the oldest backups until only the configured number is left
+func (m *Manager) prune() error {
+	backups, err := List(m.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > m.config.Retention {
+		oldest := backups[0]
+		backups = backups[1:]
+
+		err = os.Remove(oldest.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err = os.Remove(oldest.Path + checksumExtension)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List returns the backups in dir, oldest first
+//
+// Checksums aren't verified, so the SHA256 field holds the expected checksum. It's empty if the
+// checksum file is missing.
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading backup directory")
+	}
+
+	var backups []Info
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileExtension) {
+			continue
+		}
+
+		backupTime, err := time.Parse(
+			timeLayout,
+			strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileExtension),
+		)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		// A backup without a readable checksum is still listed, but can't be restored
+		path := filepath.Join(dir, name)
+		checksum, _ := readChecksumFile(path)
+
+		backups = append(backups, Info{Path: path, Time: backupTime, Size: info.Size(), SHA256: checksum})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// Latest returns the newest backup in dir taken at or before the given time
+func Latest(dir string, at time.Time) (Info, error) {
+	backups, err := List(dir)
+	if err != nil {
+		return Info{}, err
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !backups[i].Time.After(at) {
+			return backups[i], nil
+		}
+	}
+	return Info{}, fmt.Errorf("no backup taken at or before %s in %s", at.Format(time.RFC3339), dir)
+}
+
+// Describe returns the information about a single backup file
+func Describe(path string) (Info, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	checksum, err := readChecksumFile(path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{Path: path, Time: stat.ModTime().UTC(), Size: stat.Size(), SHA256: checksum}, nil
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum and the size of a file
+func fileChecksum(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "error opening file")
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "error reading file")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// writeChecksumFile writes the checksum of a backup in the format used by sha256sum
+func writeChecksumFile(backupPath, checksum string) error {
+	content := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(backupPath))
+	return os.WriteFile(backupPath+checksumExtension, []byte(content), 0o640)
+}
+
+// readChecksumFile returns the checksum stored for a backup
+func readChecksumFile(backupPath string) (string, error) {
+	content, err := os.ReadFile(backupPath + checksumExtension)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading checksum")
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file for %s", backupPath)
+	}
+	return fields[0], nil
+}
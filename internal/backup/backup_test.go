@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+func setupDatabase(t *testing.T, path string) *database.OffersSQLiteDatabase {
+	db, err := database.InitSQLiteDatabase(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42)
+	if err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	return db
+}
+
+// newTestManager returns a manager whose clock advances by a minute on every backup
+func newTestManager(t *testing.T, source Source, retention int) *Manager {
+	manager, err := NewManager(source, Config{
+		Dir:       filepath.Join(t.TempDir(), "backups"),
+		Interval:  time.Hour,
+		Retention: retention,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating the manager, got %v", err)
+	}
+
+	now := time.Date(2020, 10, 9, 18, 2, 21, 0, time.UTC)
+	manager.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return manager
+}
+
+func TestManager_BackupAndRetention(t *testing.T) {
+	db := setupDatabase(t, filepath.Join(t.TempDir(), "offers.db"))
+	manager := newTestManager(t, db, 2)
+
+	var infos []Info
+	for i := 0; i < 3; i++ {
+		info, err := manager.Backup()
+		if err != nil {
+			t.Fatalf("Expected no error taking a backup, got %v", err)
+		}
+		infos = append(infos, info)
+	}
+
+	backups, err := List(manager.config.Dir)
+	if err != nil {
+		t.Fatalf("Expected no error listing backups, got %v", err)
+	}
+
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be retained, got %d", len(backups))
+	}
+	if backups[0] != infos[1] || backups[1] != infos[2] {
+		t.Fatalf("Expected the two newest backups %v to be retained, got %v", infos[1:], backups)
+	}
+	if _, err = os.Stat(infos[0].Path + checksumExtension); !os.IsNotExist(err) {
+		t.Fatalf("Expected the checksum of the pruned backup to be deleted, got %v", err)
+	}
+
+	latest, err := Latest(manager.config.Dir, infos[1].Time.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Expected no error finding the latest backup, got %v", err)
+	}
+	if latest != infos[1] {
+		t.Fatalf("Expected backup %v at that point in time, got %v", infos[1], latest)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	db := setupDatabase(t, filepath.Join(dir, "offers.db"))
+	manager := newTestManager(t, db, 1)
+
+	info, err := manager.Backup()
+	if err != nil {
+		t.Fatalf("Expected no error taking a backup, got %v", err)
+	}
+
+	restoredPath := filepath.Join(dir, "restored.db")
+	err = Restore(info, restoredPath)
+	if err != nil {
+		t.Fatalf("Expected no error restoring, got %v", err)
+	}
+
+	restored := setupDatabase(t, restoredPath)
+	offers, err := restored.Get(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error reading the restored database, got %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("Expected the offer to be restored, got %v", offers)
+	}
+}
+
+func TestRestore_withBadChecksum(t *testing.T) {
+	dir := t.TempDir()
+	db := setupDatabase(t, filepath.Join(dir, "offers.db"))
+	manager := newTestManager(t, db, 1)
+
+	info, err := manager.Backup()
+	if err != nil {
+		t.Fatalf("Expected no error taking a backup, got %v", err)
+	}
+
+	info.SHA256 = "0123456789abcdef"
+	restoredPath := filepath.Join(dir, "restored.db")
+	if err = Restore(info, restoredPath); err == nil {
+		t.Fatal("Expected an error restoring a backup with a bad checksum, got none")
+	}
+	if _, err = os.Stat(restoredPath); !os.IsNotExist(err) {
+		t.Fatalf("Expected the database not to be replaced, got %v", err)
+	}
+}
+
+func TestRestore_withCorruptBackup(t *testing.T) {
+	dir := t.TempDir()
+	backupPath := filepath.Join(dir, "corrupt.db")
+
+	err := os.WriteFile(backupPath, []byte("I'm not a database"), 0o640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, _, err := fileChecksum(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(dir, "offers.db")
+	err = os.WriteFile(dbPath, []byte("the old database"), 0o640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Restore(Info{Path: backupPath, SHA256: checksum}, dbPath)
+	if err == nil {
+		t.Fatal("Expected an error restoring a corrupt backup, got none")
+	}
+
+	content, err := os.ReadFile(dbPath)
+	if err != nil || string(content) != "the old database" {
+		t.Fatalf("Expected the old database to be left alone, got %q and error %v", content, err)
+	}
+	if _, err = os.Stat(dbPath + ".restore" + tempExtension); !os.IsNotExist(err) {
+		t.Fatalf("Expected the temporary copy to be removed, got %v", err)
+	}
+}
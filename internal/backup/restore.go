@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// Restore replaces the database at dbPath with the backup
+//
+// The backup's checksum is verified and it's copied next to the database, where SQLite's
+// integrity check runs on the copy. Only if both succeed is the copy swapped in, so a broken backup
+// never replaces a database. Nothing may have the database open while it's restored.
+func Restore(backup Info, dbPath string) (err error) {
+	if backup.SHA256 == "" {
+		return fmt.Errorf("no checksum for backup %s", backup.Path)
+	}
+
+	checksum, _, err := fileChecksum(backup.Path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(checksum, backup.SHA256) {
+		return fmt.Errorf("checksum mismatch for backup %s: expected %s, got %s", backup.Path, backup.SHA256, checksum)
+	}
+
+	tempPath := dbPath + ".restore" + tempExtension
+	defer func() {
+		if err != nil {
+			os.Remove(tempPath)
+		}
+	}()
+
+	err = copyFile(backup.Path, tempPath)
+	if err != nil {
+		return errors.Wrap(err, "error copying backup")
+	}
+
+	problems, err := database.CheckIntegrity(tempPath)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup %s failed the integrity check: %s", backup.Path, strings.Join(problems, "; "))
+	}
+
+	// Journal files left behind by the old database must not be applied to the restored one
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		err = os.Remove(dbPath + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "error removing journal file")
+		}
+	}
+
+	return errors.Wrap(os.Rename(tempPath, dbPath), "error replacing database")
+}
+
+func copyFile(src, dest string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,151 @@
+// Package catalog compares the names suppliers give to products, to suggest which of them are
+// aliases of the same product
+package catalog
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultMinSimilarity is the similarity above which names are suggested as aliases unless another
+// one is requested
+const DefaultMinSimilarity = 0.8
+
+// containedSimilarity is the similarity of names where all words of one are part of the other,
+// e.g. "Towel" and "Bath Towel"
+const containedSimilarity = 0.9
+
+// Normalize returns the name in lower case without parenthesised or bracketed details such as
+// colours, punctuation and repeated spaces, e.g. "towel" for "Towel (blue)"
+func Normalize(name string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Similarity returns how similar the normalised names are, from 0 for different names to 1 for
+// names which are the same once they're normalised
+//
+// Names where all words of one are part of the other are similar. Otherwise, the similarity is
+// based on the edit distance, so that typos and plurals are similar as well.
+func Similarity(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if containsWords(wordsA, wordsB) || containsWords(wordsB, wordsA) {
+		return containedSimilarity
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// containsWords returns whether all words of part are in words
+func containsWords(words, part []string) bool {
+	for _, p := range part {
+		found := false
+		for _, w := range words {
+			if w == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// editDistance returns the Levenshtein distance of the strings
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// Product is the name of a product and how many offers it has
+type Product struct {
+	Name   string
+	Offers int
+}
+
+// Suggestion proposes to make a name an alias of a product
+type Suggestion struct {
+	Alias      string
+	Product    string
+	Similarity float64
+}
+
+// Suggest returns the pairs of products whose names are at least minSimilarity similar, most
+// similar first
+//
+// The product with more offers is suggested as the canonical one, since it's most likely the name
+// most suppliers use. If both have as many offers, it's the one with the shorter normalised name.
+// Every pair of names is compared, so this is meant for catalogs of up to a few thousand products.
+func Suggest(products []Product, minSimilarity float64) []Suggestion {
+	suggestions := []Suggestion{}
+	for i, a := range products {
+		for _, b := range products[i+1:] {
+			similarity := Similarity(a.Name, b.Name)
+			if similarity < minSimilarity {
+				continue
+			}
+
+			alias, product := a, b
+			if a.Offers > b.Offers || (a.Offers == b.Offers && len(Normalize(a.Name)) < len(Normalize(b.Name))) {
+				alias, product = b, a
+			}
+			suggestions = append(suggestions, Suggestion{alias.Name, product.Name, similarity})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Similarity == suggestions[j].Similarity {
+			return suggestions[i].Alias < suggestions[j].Alias
+		}
+		return suggestions[i].Similarity > suggestions[j].Similarity
+	})
+	return suggestions
+}
@@ -0,0 +1,70 @@
+package catalog
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{"Towel", "towel"},
+		{"towel (blue)", "towel"},
+		{"  Bath   Towel ", "bath towel"},
+		{"Towel [XL], blue", "towel blue"},
+		{"Babel-Fish", "babel fish"},
+		{"Handtuch für Anhalter", "handtuch für anhalter"},
+	}
+
+	for _, testCase := range testCases {
+		if got := Normalize(testCase.name); got != testCase.want {
+			t.Fatalf("%q: got %q, want %q", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"Towel", "towel (blue)", 1, 1},
+		{"Towel", "Bath Towel", 0.9, 0.9},
+		{"Towel", "Towels", 0.8, 0.9},
+		{"Towel", "Guide", 0, 0.5},
+		{"Towel", "", 0, 0},
+	}
+
+	for _, testCase := range testCases {
+		got := Similarity(testCase.a, testCase.b)
+		if got < testCase.min || got > testCase.max {
+			t.Fatalf("%q and %q: got %f, want between %f and %f", testCase.a, testCase.b, got, testCase.min, testCase.max)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	products := []Product{
+		{"Bath Towel", 1},
+		{"Towel", 3},
+		{"towel (blue)", 1},
+		{"Guide", 2},
+	}
+
+	got := Suggest(products, DefaultMinSimilarity)
+	want := []Suggestion{
+		{"towel (blue)", "Towel", 1},
+		{"Bath Towel", "Towel", 0.9},
+		{"Bath Towel", "towel (blue)", 0.9},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got suggestions %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Got suggestions %+v, want %+v", got, want)
+		}
+	}
+}
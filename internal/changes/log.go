@@ -0,0 +1,170 @@
+// Package changes keeps a bounded log of recent changes to offers and fans them out to subscribers
+package changes
+
+import (
+	"sync"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// Event is a change to an offer with a sequence number
+//
+// IDs start at 1 and increase by one with every event. They're only unique within a process.
+type Event struct {
+	ID uint64
+	database.Change
+}
+
+// Filter selects the events for a product and category. Empty fields match everything, except for
+// the tenant, which always has to match, so that subscribers never see the offers of other tenants.
+// Events about the offers of suspended suppliers never match, since they're hidden from customers.
+type Filter struct {
+	Tenant   string
+	Product  string
+	Category string
+}
+
+// Matches returns whether the event is about an offer selected by the filter
+func (f Filter) Matches(e Event) bool {
+	return f.Tenant == e.Tenant && !e.Suspended &&
+		(f.Product == "" || f.Product == e.Offer.Product) &&
+		(f.Category == "" || f.Category == e.Offer.Category)
+}
+
+// Log keeps the most recent events in memory and publishes new ones to subscribers
+type Log struct {
+	mu sync.Mutex
+
+	// events is a ring buffer. The oldest event is at index start.
+	events []Event
+	start  int
+	lastID uint64
+
+	subscribers map[*Subscription]struct{}
+}
+
+// NewLog returns a log which keeps up to capacity events
+func NewLog(capacity int) *Log {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Log{
+		events:      make([]Event, 0, capacity),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish appends the changes to the log and sends them to all subscribers
+//
+// Its signature matches database.ChangeListener. Publishing never blocks on slow subscribers.
+// Instead, subscribers whose buffer is full are closed, and they can resume from the log.
+func (l *Log) Publish(changes []database.Change) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, change := range changes {
+		l.lastID++
+		event := Event{ID: l.lastID, Change: change}
+		l.append(event)
+
+		for sub := range l.subscribers {
+			select {
+			case sub.events <- event:
+			default:
+				l.unsubscribe(sub, true)
+			}
+		}
+	}
+}
+
+// append adds the event to the ring buffer. Must be called with the lock held.
+func (l *Log) append(event Event) {
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, event)
+		return
+	}
+	l.events[l.start] = event
+	l.start = (l.start + 1) % len(l.events)
+}
+
+// Subscribe returns the events after lastID which are still in the log, and a subscription for
+// all later events
+//
+// Both are determined atomically, so no event is missed or sent twice. complete is false if events
+// after lastID have already been dropped from the log, or if lastID is unknown. Pass 0 to only
+// subscribe to new events. The subscription's channel buffers up to bufferSize events.
+func (l *Log) Subscribe(lastID uint64, bufferSize int) (backlog []Event, sub *Subscription, complete bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backlog, complete = l.since(lastID)
+
+	sub = &Subscription{
+		events: make(chan Event, bufferSize),
+		log:    l,
+	}
+	l.subscribers[sub] = struct{}{}
+
+	return backlog, sub, complete
+}
+
+// since returns the events after lastID. Must be called with the lock held.
+func (l *Log) since(lastID uint64) ([]Event, bool) {
+	if lastID == 0 {
+		return nil, true
+	}
+	if lastID > l.lastID {
+		// The ID was issued by another process, e.g. before a restart
+		return nil, false
+	}
+
+	var events []Event
+	for i := 0; i < len(l.events); i++ {
+		event := l.events[(l.start+i)%len(l.events)]
+		if event.ID > lastID {
+			events = append(events, event)
+		}
+	}
+
+	// The log is complete if it still contains the event after lastID, or if there is none
+	complete := lastID == l.lastID || (len(events) > 0 && events[0].ID == lastID+1)
+	return events, complete
+}
+
+// unsubscribe removes a subscriber and closes its channel. Must be called with the lock held.
+func (l *Log) unsubscribe(sub *Subscription, overflowed bool) {
+	if _, ok := l.subscribers[sub]; !ok {
+		return
+	}
+	delete(l.subscribers, sub)
+	sub.overflowed = overflowed
+	close(sub.events)
+}
+
+// Subscription receives the events published to a log
+type Subscription struct {
+	events     chan Event
+	log        *Log
+	overflowed bool
+}
+
+// Events returns the channel new events are sent on. It's closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Overflowed returns whether the subscription was ended because the subscriber didn't keep up
+//
+// Only call it after the events channel has been closed.
+func (s *Subscription) Overflowed() bool {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	return s.overflowed
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+	s.log.unsubscribe(s, false)
+}
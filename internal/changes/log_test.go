@@ -0,0 +1,148 @@
+package changes
+
+import (
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+func change(supplier string, price float32) database.Change {
+	return database.Change{
+		Type:   database.OfferInserted,
+		Tenant: database.DefaultTenant,
+		Offer:  database.Offer{Product: "Towel", Category: "Must Haves", Supplier: supplier, Price: price},
+	}
+}
+
+func ids(events []Event) []uint64 {
+	result := make([]uint64, len(events))
+	for i, event := range events {
+		result[i] = event.ID
+	}
+	return result
+}
+
+func TestLog_Subscribe(t *testing.T) {
+	log := NewLog(3)
+	log.Publish([]database.Change{change("a", 1), change("b", 2)})
+
+	testCases := []struct {
+		name         string
+		lastID       uint64
+		wantBacklog  int
+		wantComplete bool
+	}{
+		{"new subscriber", 0, 0, true},
+		{"missed one event", 1, 1, true},
+		{"up to date", 2, 0, true},
+		{"unknown ID", 5, 0, false},
+	}
+
+	for _, testCase := range testCases {
+		backlog, sub, complete := log.Subscribe(testCase.lastID, 1)
+		sub.Close()
+
+		if len(backlog) != testCase.wantBacklog || complete != testCase.wantComplete {
+			t.Fatalf("%s: got backlog %v and complete %t, want %d events and %t",
+				testCase.name, ids(backlog), complete, testCase.wantBacklog, testCase.wantComplete)
+		}
+	}
+}
+
+func TestLog_dropsOldEvents(t *testing.T) {
+	log := NewLog(3)
+	for i := 0; i < 5; i++ {
+		log.Publish([]database.Change{change("a", float32(i))})
+	}
+
+	backlog, sub, complete := log.Subscribe(2, 1)
+	defer sub.Close()
+	if got := ids(backlog); !complete || len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Fatalf("Got backlog %v and complete %t, want events 3 to 5", got, complete)
+	}
+
+	// Event 2 has been dropped
+	backlog, sub, complete = log.Subscribe(1, 1)
+	defer sub.Close()
+	if complete || len(backlog) != 3 {
+		t.Fatalf("Got backlog %v and complete %t, want the incomplete backlog", ids(backlog), complete)
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	log := NewLog(10)
+	_, sub, _ := log.Subscribe(0, 2)
+
+	log.Publish([]database.Change{change("a", 1)})
+	if event := <-sub.Events(); event.ID != 1 || event.Offer.Supplier != "a" {
+		t.Fatalf("Got event %+v, want the published change", event)
+	}
+
+	sub.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("Expected the channel to be closed")
+	}
+	if sub.Overflowed() {
+		t.Fatal("Expected a closed subscription not to overflow")
+	}
+
+	// Publishing to a closed subscription doesn't block or panic, and neither does closing again
+	log.Publish([]database.Change{change("b", 2)})
+	sub.Close()
+}
+
+func TestSubscription_overflows(t *testing.T) {
+	log := NewLog(10)
+	_, slow, _ := log.Subscribe(0, 1)
+	defer slow.Close()
+	_, fast, _ := log.Subscribe(0, 10)
+	defer fast.Close()
+
+	log.Publish([]database.Change{change("a", 1), change("b", 2), change("c", 3)})
+
+	if event := <-slow.Events(); event.ID != 1 {
+		t.Fatalf("Got event %+v, want the first one", event)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("Expected the slow subscription to be closed")
+	}
+	if !slow.Overflowed() {
+		t.Fatal("Expected the slow subscription to overflow")
+	}
+
+	if len(fast.Events()) != 3 || fast.Overflowed() {
+		t.Fatal("Expected the fast subscription to receive all events")
+	}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	event := Event{ID: 1, Change: change("a", 1)}
+
+	testCases := []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{Tenant: database.DefaultTenant}, true},
+		{Filter{Tenant: database.DefaultTenant, Product: "Towel"}, true},
+		{Filter{Tenant: database.DefaultTenant, Product: "Towel", Category: "Must Haves"}, true},
+		{Filter{Tenant: database.DefaultTenant, Category: "Gadgets"}, false},
+		{Filter{Tenant: database.DefaultTenant, Product: "Guide", Category: "Must Haves"}, false},
+		{Filter{Tenant: "acme", Product: "Towel"}, false},
+		{Filter{}, false},
+	}
+
+	for _, testCase := range testCases {
+		if got := testCase.filter.Matches(event); got != testCase.want {
+			t.Fatalf("%+v: got %t, want %t", testCase.filter, got, testCase.want)
+		}
+	}
+}
+
+func TestFilter_Matches_suspended(t *testing.T) {
+	event := Event{ID: 1, Change: change("a", 1)}
+	event.Suspended = true
+
+	if (Filter{Tenant: database.DefaultTenant}).Matches(event) {
+		t.Fatal("Expected the change of a suspended supplier not to match")
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	alertColumns          = "id, product, category, target_price, state, state_changed_at, created_at"
+	insertAlertStmt       = "INSERT INTO alerts (product, category, target_price, state, state_changed_at, created_at) VALUES (?, ?, ?, ?, 0, ?)"
+	getAlertQuery         = "SELECT " + alertColumns + " FROM alerts WHERE id=?"
+	listAlertsQuery       = "SELECT " + alertColumns + " FROM alerts ORDER BY id"
+	alertsForProductQuery = "SELECT " + alertColumns + " FROM alerts WHERE product=? AND category=? ORDER BY id"
+	updateAlertStmt       = "UPDATE alerts SET target_price=?, state=?, state_changed_at=? WHERE id=?"
+	transitionAlertStmt   = "UPDATE alerts SET state=?, state_changed_at=? WHERE id=? AND state=?"
+	deleteAlertStmt       = "DELETE FROM alerts WHERE id=?"
+)
+
+// ErrAlertNotFound is returned if an alert with the given ID doesn't exist
+var ErrAlertNotFound = errors.New("alert not found")
+
+// AlertState is whether the condition of an alert is met
+type AlertState string
+
+// The states of an alert
+const (
+	// AlertResolved means that no offer is below the target price
+	AlertResolved AlertState = "resolved"
+	// AlertFiring means that an offer is below the target price
+	AlertFiring AlertState = "firing"
+)
+
+// Alerts is an interface for storing price alerts
+type Alerts interface {
+	AddAlert(alert Alert) (Alert, error)
+	Alert(id int64) (Alert, error)
+	ListAlerts() ([]Alert, error)
+	AlertsFor(productName, categoryName string) ([]Alert, error)
+	UpdateAlert(id int64, targetPrice float32) (Alert, error)
+	TransitionAlert(id int64, from, to AlertState, at time.Time) (bool, error)
+	DeleteAlert(id int64) error
+}
+
+// Alert fires when the cheapest offer for a product in a category drops below the target price
+type Alert struct {
+	ID                int64
+	Product, Category string
+	TargetPrice       float32
+	State             AlertState
+	// StateChangedAt is the time the alert last started firing or was resolved. It's zero if the
+	// alert has never fired.
+	StateChangedAt time.Time
+	CreatedAt      time.Time
+}
+
+// AddAlert stores a new alert in the resolved state and returns it with its ID and creation time
+func (d *OffersSQLiteDatabase) AddAlert(alert Alert) (Alert, error) {
+	alert.State = AlertResolved
+	alert.StateChangedAt = time.Time{}
+	alert.CreatedAt = time.Now().UTC()
+
+	err := d.writes.write(func(db *sql.DB) error {
+		result, err := db.Exec(
+			insertAlertStmt,
+			alert.Product, alert.Category, alert.TargetPrice, alert.State, alert.CreatedAt.UnixNano(),
+		)
+		if err != nil {
+			return errors.Wrap(err, "error inserting alert")
+		}
+		alert.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return Alert{}, err
+	}
+	return alert, nil
+}
+
+// Alert returns the alert with the ID. Returns ErrAlertNotFound if it doesn't exist.
+func (d *OffersSQLiteDatabase) Alert(id int64) (Alert, error) {
+	alert, err := scanAlert(d.reader.QueryRow(getAlertQuery, id))
+	if err == sql.ErrNoRows {
+		return Alert{}, ErrAlertNotFound
+	}
+	return alert, err
+}
+
+// ListAlerts returns all alerts
+func (d *OffersSQLiteDatabase) ListAlerts() ([]Alert, error) {
+	return d.queryAlerts(listAlertsQuery)
+}
+
+// AlertsFor returns the alerts for a product in a category
+func (d *OffersSQLiteDatabase) AlertsFor(productName, categoryName string) ([]Alert, error) {
+	return d.queryAlerts(alertsForProductQuery, productName, categoryName)
+}
+
+func (d *OffersSQLiteDatabase) queryAlerts(query string, args ...interface{}) (alerts []Alert, err error) {
+	rows, err := d.reader.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying alerts")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+func scanAlert(row scanner) (Alert, error) {
+	var (
+		alert                     Alert
+		stateChangedAt, createdAt int64
+	)
+	err := row.Scan(
+		&alert.ID, &alert.Product, &alert.Category, &alert.TargetPrice, &alert.State, &stateChangedAt, &createdAt,
+	)
+	if err == sql.ErrNoRows {
+		return Alert{}, err
+	}
+	if err != nil {
+		return Alert{}, errors.Wrap(err, "error retrieving alert")
+	}
+
+	if stateChangedAt != 0 {
+		alert.StateChangedAt = time.Unix(0, stateChangedAt).UTC()
+	}
+	alert.CreatedAt = time.Unix(0, createdAt).UTC()
+	return alert, nil
+}
+
+// UpdateAlert changes the target price of an alert and resolves it, so that it's evaluated afresh
+//
+// Returns ErrAlertNotFound if the alert doesn't exist.
+func (d *OffersSQLiteDatabase) UpdateAlert(id int64, targetPrice float32) (Alert, error) {
+	alert, err := d.Alert(id)
+	if err != nil {
+		return Alert{}, err
+	}
+
+	now := time.Now().UTC()
+	if alert.State == AlertFiring {
+		alert.StateChangedAt = now
+	}
+	alert.TargetPrice = targetPrice
+	alert.State = AlertResolved
+
+	var stateChangedAt int64
+	if !alert.StateChangedAt.IsZero() {
+		stateChangedAt = alert.StateChangedAt.UnixNano()
+	}
+
+	err = d.writes.write(func(db *sql.DB) error {
+		result, err := db.Exec(updateAlertStmt, alert.TargetPrice, alert.State, stateChangedAt, id)
+		if err != nil {
+			return errors.Wrap(err, "error updating alert")
+		}
+		return requireAffectedRow(result, ErrAlertNotFound)
+	})
+	if err != nil {
+		return Alert{}, err
+	}
+	return alert, nil
+}
+
+// TransitionAlert changes the state of an alert if it's currently in the from state
+//
+// Returns whether the state was changed. Checking the current state in the same statement makes
+// sure that concurrent evaluations only report a transition once.
+func (d *OffersSQLiteDatabase) TransitionAlert(id int64, from, to AlertState, at time.Time) (changed bool, err error) {
+	err = d.writes.write(func(db *sql.DB) error {
+		result, err := db.Exec(transitionAlertStmt, to, at.UnixNano(), id, from)
+		if err != nil {
+			return errors.Wrap(err, "error changing alert state")
+		}
+		affected, err := result.RowsAffected()
+		changed = affected == 1
+		return err
+	})
+	return changed, err
+}
+
+// DeleteAlert deletes an alert. Returns ErrAlertNotFound if it doesn't exist.
+func (d *OffersSQLiteDatabase) DeleteAlert(id int64) error {
+	return d.writes.write(func(db *sql.DB) error {
+		result, err := db.Exec(deleteAlertStmt, id)
+		if err != nil {
+			return errors.Wrap(err, "error deleting alert")
+		}
+		return requireAffectedRow(result, ErrAlertNotFound)
+	})
+}
+
+// requireAffectedRow returns notFound if the statement didn't change any row
+func requireAffectedRow(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "error counting changed rows")
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOffersSQLiteDatabase_alerts(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	alert, err := db.AddAlert(Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40})
+	if err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+	if alert.ID == 0 || alert.State != AlertResolved || alert.CreatedAt.IsZero() || !alert.StateChangedAt.IsZero() {
+		t.Fatalf("Expected a new resolved alert, got %+v", alert)
+	}
+	if _, err = db.AddAlert(Alert{Product: "Guide", Category: "Must Haves", TargetPrice: 10}); err != nil {
+		t.Fatalf("Expected no error adding an alert, got %v", err)
+	}
+
+	got, err := db.Alert(alert.ID)
+	if err != nil || !reflect.DeepEqual(got, alert) {
+		t.Fatalf("Got alert %+v and error %v, want %+v", got, err, alert)
+	}
+
+	alerts, err := db.AlertsFor("Towel", "Must Haves")
+	if err != nil || len(alerts) != 1 || alerts[0].ID != alert.ID {
+		t.Fatalf("Got alerts %+v and error %v, want the alert for towels", alerts, err)
+	}
+
+	// Only the first of two identical transitions changes the state
+	firedAt := time.Date(2020, 10, 9, 18, 2, 21, 0, time.UTC)
+	for i, want := range []bool{true, false} {
+		changed, err := db.TransitionAlert(alert.ID, AlertResolved, AlertFiring, firedAt)
+		if err != nil || changed != want {
+			t.Fatalf("Got changed %t and error %v for transition %d, want %t", changed, err, i+1, want)
+		}
+	}
+	if got, _ = db.Alert(alert.ID); got.State != AlertFiring || !got.StateChangedAt.Equal(firedAt) {
+		t.Fatalf("Expected the alert to be firing since %s, got %+v", firedAt, got)
+	}
+
+	// Changing the target price resolves the alert
+	updated, err := db.UpdateAlert(alert.ID, 30)
+	if err != nil || updated.TargetPrice != 30 || updated.State != AlertResolved {
+		t.Fatalf("Got alert %+v and error %v, want the resolved alert with the new price", updated, err)
+	}
+	if got, _ = db.Alert(alert.ID); !reflect.DeepEqual(got, updated) {
+		t.Fatalf("Got alert %+v, want %+v", got, updated)
+	}
+
+	if err = db.DeleteAlert(alert.ID); err != nil {
+		t.Fatalf("Expected no error deleting an alert, got %v", err)
+	}
+	if err = db.DeleteAlert(alert.ID); err != ErrAlertNotFound {
+		t.Fatalf("Got error %v deleting a missing alert, want %v", err, ErrAlertNotFound)
+	}
+	if _, err = db.UpdateAlert(alert.ID, 30); err != ErrAlertNotFound {
+		t.Fatalf("Got error %v updating a missing alert, want %v", err, ErrAlertNotFound)
+	}
+
+	if alerts, err = db.ListAlerts(); err != nil || len(alerts) != 1 || alerts[0].Product != "Guide" {
+		t.Fatalf("Got alerts %+v and error %v, want the remaining one", alerts, err)
+	}
+}
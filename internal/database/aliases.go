@@ -0,0 +1,367 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	resolveAliasQuery         = "SELECT product FROM product_aliases WHERE tenant=? AND alias_key=?"
+	upsertAliasStmt           = "INSERT INTO product_aliases (tenant, alias_key, alias, product, created_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT(tenant, alias_key) DO UPDATE SET alias=EXCLUDED.alias, product=EXCLUDED.product, created_at=EXCLUDED.created_at"
+	listAliasesQuery          = "SELECT alias, product, created_at FROM product_aliases WHERE tenant=? ORDER BY product, alias_key"
+	aliasTargetsQuery         = "SELECT alias_key, product FROM product_aliases WHERE tenant=?"
+	repointAliasStmt          = "UPDATE product_aliases SET product=? WHERE tenant=? AND alias_key=?"
+	deleteAliasStmt           = "DELETE FROM product_aliases WHERE tenant=? AND alias_key=?"
+	productNamesQuery         = "SELECT DISTINCT product FROM offers WHERE tenant=?"
+	countOffersByProductQuery = "SELECT product, COUNT(*) FROM offers WHERE tenant=? GROUP BY product ORDER BY product"
+	productOffersQuery        = "SELECT category, supplier FROM offers WHERE tenant=? AND product=?"
+	// mergeOffersStmt moves the offers of a product to another one. If a supplier has offers for
+	// both, the one which was updated last is kept.
+	mergeOffersStmt   = "INSERT INTO offers (tenant, product, category, supplier, price, quantity, availability, lead_time_days, updated_at) SELECT tenant, ?, category, supplier, price, quantity, availability, lead_time_days, updated_at FROM offers WHERE tenant=? AND product=? ON CONFLICT(tenant, product, category, supplier) DO UPDATE SET price=EXCLUDED.price, quantity=EXCLUDED.quantity, availability=EXCLUDED.availability, lead_time_days=EXCLUDED.lead_time_days, updated_at=EXCLUDED.updated_at WHERE EXCLUDED.updated_at > offers.updated_at"
+	deleteProductStmt = "DELETE FROM offers WHERE tenant=? AND product=?"
+)
+
+var (
+	// ErrAliasNotFound is returned if an alias that should be deleted doesn't exist
+	ErrAliasNotFound = errors.New("alias not found")
+	// ErrInvalidAlias is returned if an alias or its product is empty, or if they're the same name
+	ErrInvalidAlias = errors.New("alias must be a different, non-empty name than its product")
+)
+
+// ProductAliases is implemented by databases which resolve the different names suppliers give to
+// the same product
+//
+// Offers for an alias are stored as offers for its product, and searches for an alias return the
+// offers for its product. Names are compared ignoring case and repeated spaces.
+type ProductAliases interface {
+	// AddProductAlias makes the name an alias of the product and returns how many offers for the
+	// alias were merged into the product
+	AddProductAlias(ctx context.Context, alias, product string) (ProductAlias, int, error)
+	ProductAliases(ctx context.Context) ([]ProductAlias, error)
+	// ResolveProduct returns the product the name is an alias of, or the name if it isn't an alias
+	ResolveProduct(ctx context.Context, name string) (string, error)
+	DeleteProductAlias(ctx context.Context, alias string) error
+	// ProductCounts returns the names of all products with offers and how many offers they have
+	ProductCounts(ctx context.Context) ([]GroupCount, error)
+}
+
+// ProductAlias is another name of a product
+type ProductAlias struct {
+	Alias     string
+	Product   string
+	CreatedAt time.Time
+}
+
+// aliasKey returns the name the way aliases are looked up, ignoring case and repeated spaces
+func aliasKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// rowQuerier is implemented by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// resolveProduct returns the product the name is an alias of, or the name if it isn't an alias
+func resolveProduct(ctx context.Context, db rowQuerier, tenant, name string) (string, error) {
+	var product string
+	err := db.QueryRowContext(ctx, resolveAliasQuery, tenant, aliasKey(name)).Scan(&product)
+	if err == sql.ErrNoRows {
+		return name, nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "error resolving product alias")
+	}
+	return product, nil
+}
+
+// AddProductAlias makes the name an alias of the product for the tenant
+//
+// If the product is an alias itself, the name becomes an alias of its product. Aliases of the name
+// become aliases of the product, too. Existing offers for the name are merged into the product in
+// the same transaction. Change listeners are notified about the offers which the product got or
+// whose price changed by the merge.
+func (d *OffersSQLiteDatabase) AddProductAlias(ctx context.Context, alias, product string) (ProductAlias, int, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	key := aliasKey(alias)
+	now := time.Now()
+	result := ProductAlias{Alias: alias, CreatedAt: now.UTC()}
+	var merged int64
+	var changes []Change
+
+	err := d.writes.writeContext(ctx, func(db *sql.DB) (err error) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "error beginning transaction")
+		}
+
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			if commitErr := tx.Commit(); commitErr != nil {
+				err = errors.Wrap(commitErr, "error committing transaction")
+			}
+		}()
+
+		result.Product, err = resolveProduct(ctx, tx, d.tenant, product)
+		if err != nil {
+			return err
+		}
+		if key == "" || aliasKey(result.Product) == "" || aliasKey(result.Product) == key {
+			return ErrInvalidAlias
+		}
+
+		_, err = tx.ExecContext(ctx, upsertAliasStmt, d.tenant, key, alias, result.Product, result.CreatedAt.UnixNano())
+		if err != nil {
+			return errors.Wrap(err, "error inserting alias")
+		}
+
+		if err = repointAliases(ctx, tx, d.tenant, key, result.Product); err != nil {
+			return err
+		}
+
+		changes, merged, err = mergeOffers(ctx, tx, d.tenant, key, result.Product, now)
+		return err
+	})
+	if err != nil {
+		return ProductAlias{}, 0, err
+	}
+
+	d.listeners.notify(changes)
+	return result, int(merged), nil
+}
+
+// repointAliases makes the aliases of the name with the key aliases of the product instead
+func repointAliases(ctx context.Context, tx *sql.Tx, tenant, key, product string) error {
+	rows, err := tx.QueryContext(ctx, aliasTargetsQuery, tenant)
+	if err != nil {
+		return errors.Wrap(err, "error querying aliases")
+	}
+	var repointed []string
+	for rows.Next() {
+		var rowKey, target string
+		if err = rows.Scan(&rowKey, &target); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "error retrieving row")
+		}
+		if aliasKey(target) == key {
+			repointed = append(repointed, rowKey)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "error reading aliases")
+	}
+
+	for _, rowKey := range repointed {
+		if _, err = tx.ExecContext(ctx, repointAliasStmt, product, tenant, rowKey); err != nil {
+			return errors.Wrap(err, "error updating alias")
+		}
+	}
+	return nil
+}
+
+// mergeOffers moves the offers for all names with the key to the product and returns the changes
+// to the offers of the product and how many offers were moved
+//
+// Moved offers are reported as updated offers of the product. Offers which the product already had
+// a newer one of the supplier for are left out, unless they changed the offer.
+func mergeOffers(ctx context.Context, tx *sql.Tx, tenant, key, product string, now time.Time) ([]Change, int64, error) {
+	rows, err := tx.QueryContext(ctx, productNamesQuery, tenant)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error querying products")
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, 0, errors.Wrap(err, "error retrieving row")
+		}
+		if aliasKey(name) == key {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, 0, errors.Wrap(err, "error reading products")
+	}
+
+	// The cheapest prices of the product before the merge, by category
+	minPrices := make(map[productKey]sql.NullFloat64)
+
+	var changes []Change
+	var merged int64
+	for _, name := range names {
+		moved, err := movedOffers(ctx, tx, tenant, name, product)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// The offers of the product before the merge, which are replaced if the moved ones are newer
+		previous := make([]*offerState, len(moved))
+		for i, offer := range moved {
+			key := productKey{tenant, product, offer.Category}
+			if _, ok := minPrices[key]; !ok {
+				if minPrices[key], _, err = minPrice(ctx, tx, key); err != nil {
+					return nil, 0, err
+				}
+			}
+			if previous[i], err = queryOfferState(ctx, tx, tenant, offer); err != nil {
+				return nil, 0, err
+			}
+		}
+
+		if _, err = tx.ExecContext(ctx, mergeOffersStmt, product, tenant, name); err != nil {
+			return nil, 0, errors.Wrap(err, "error merging offers")
+		}
+		result, err := tx.ExecContext(ctx, deleteProductStmt, tenant, name)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "error deleting merged offers")
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return nil, 0, err
+		}
+		merged += deleted
+
+		for i, offer := range moved {
+			current, err := queryOfferState(ctx, tx, tenant, offer)
+			if err != nil {
+				return nil, 0, err
+			}
+			if current == nil {
+				continue
+			}
+			offer.Price, offer.Stock = current.price, current.stock
+
+			change := Change{Type: OfferUpdated, Tenant: tenant, Offer: offer, PreviousPrice: offer.Price, Time: now}
+			if previous[i] != nil {
+				if previous[i].price == offer.Price && previous[i].stock.equal(offer.Stock) {
+					continue
+				}
+				change.PreviousPrice = previous[i].price
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	if err = markSuspended(ctx, tx, changes); err != nil {
+		return nil, 0, err
+	}
+	err = markCheapest(ctx, tx, changes, minPrices)
+	return changes, merged, err
+}
+
+// movedOffers returns the category and supplier of the offers for the name, as offers of the product
+func movedOffers(ctx context.Context, tx *sql.Tx, tenant, name, product string) ([]Offer, error) {
+	rows, err := tx.QueryContext(ctx, productOffersQuery, tenant, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying offers")
+	}
+	defer rows.Close()
+
+	var offers []Offer
+	for rows.Next() {
+		offer := Offer{Product: product}
+		if err = rows.Scan(&offer.Category, &offer.Supplier); err != nil {
+			return nil, errors.Wrap(err, "error retrieving row")
+		}
+		offers = append(offers, offer)
+	}
+	return offers, errors.Wrap(rows.Err(), "error reading offers")
+}
+
+// offerState is the price and stock of a stored offer
+type offerState struct {
+	price float32
+	stock Stock
+}
+
+// queryOfferState returns the price and stock of the offer, or nil if there is no such offer
+func queryOfferState(ctx context.Context, tx *sql.Tx, tenant string, offer Offer) (*offerState, error) {
+	var state offerState
+	var stock nullStock
+	err := tx.QueryRowContext(ctx, getOfferStateQuery, tenant, offer.Product, offer.Category, offer.Supplier).
+		Scan(append([]interface{}{&state.price}, stock.dest()...)...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying existing offer")
+	}
+	state.stock = stock.stock()
+	return &state, nil
+}
+
+// ProductAliases returns the aliases of the tenant, ordered by product
+func (d *OffersSQLiteDatabase) ProductAliases(ctx context.Context) (aliases []ProductAlias, err error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	rows, err := d.reader.QueryContext(ctx, listAliasesQuery, d.tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying aliases")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		alias := ProductAlias{}
+		var createdAt int64
+		if err = rows.Scan(&alias.Alias, &alias.Product, &createdAt); err != nil {
+			return nil, errors.Wrap(err, "error retrieving row")
+		}
+		alias.CreatedAt = time.Unix(0, createdAt).UTC()
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// ResolveProduct returns the product the name is an alias of for the tenant, or the name if it isn't
+// an alias
+func (d *OffersSQLiteDatabase) ResolveProduct(ctx context.Context, name string) (string, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return resolveProduct(ctx, d.reader, d.tenant, name)
+}
+
+// DeleteProductAlias deletes an alias. Returns ErrAliasNotFound if it doesn't exist.
+//
+// Offers which were merged into the product when the alias was added stay with the product.
+func (d *OffersSQLiteDatabase) DeleteProductAlias(ctx context.Context, alias string) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return d.writes.writeContext(ctx, func(db *sql.DB) error {
+		result, err := db.ExecContext(ctx, deleteAliasStmt, d.tenant, aliasKey(alias))
+		if err != nil {
+			return errors.Wrap(err, "error deleting alias")
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return ErrAliasNotFound
+		}
+		return nil
+	})
+}
+
+// ProductCounts returns the products of the tenant and how many offers they have, by name
+func (d *OffersSQLiteDatabase) ProductCounts(ctx context.Context) ([]GroupCount, error) {
+	return groupCounts(d.reader, countOffersByProductQuery, d.tenant)
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_AddProductAlias(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42)
+	if err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	err = db.InsertMultiple(ctx, []Offer{
+		{Product: "Bath Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+		{Product: "Bath Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 41},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+
+	alias, merged, err := db.AddProductAlias(ctx, "Bath Towel", "Towel")
+	if err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	if alias.Product != "Towel" || merged != 2 {
+		t.Fatalf("Expected 2 offers merged into Towel, got %d into %q", merged, alias.Product)
+	}
+
+	// Searches for the alias and the product return the same offers. The offer of the supplier
+	// which offered both keeps the price which was updated last.
+	for _, name := range []string{"Towel", "bath  towel"} {
+		offers, err := db.Get(ctx, name, "Must Haves")
+		if err != nil {
+			t.Fatalf("Expected no error retrieving offers, got %v", err)
+		}
+		if len(offers) != 2 || offers[0].Price != 40 || offers[1].Price != 41 || offers[1].Product != "Towel" {
+			t.Fatalf("Expected the merged offers for %q, got %v", name, offers)
+		}
+	}
+
+	// New offers for the alias are stored for the product
+	changes := recordChanges(t, db)
+	if err = db.Insert(ctx, "BATH TOWEL", "Must Haves", "Megadodo", 39); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if got := changes(); len(got) != 1 || got[0].Offer.Product != "Towel" || !got[0].Cheapest {
+		t.Fatalf("Expected the cheapest offer for Towel, got %+v", got)
+	}
+	if err = db.Withdraw(ctx, "Bath Towel", "Must Haves", "Megadodo"); err != nil {
+		t.Fatalf("Expected no error withdrawing an offer by its alias, got %v", err)
+	}
+
+	counts, err := db.ProductCounts(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error counting products, got %v", err)
+	}
+	if len(counts) != 1 || counts[0] != (GroupCount{"Towel", 2}) {
+		t.Fatalf("Expected only Towel with 2 offers, got %v", counts)
+	}
+}
+
+func TestOffersSQLiteDatabase_AddProductAlias_changes(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	err := db.InsertMultiple(ctx, []Offer{
+		{Product: "Bath Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+		{Product: "Bath Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 41},
+		{Product: "Bath Towel", Category: "Must Haves", Supplier: "Megadodo", Price: 50},
+	})
+	if err == nil {
+		err = db.InsertMultiple(ctx, []Offer{
+			{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+			{Product: "Towel", Category: "Must Haves", Supplier: "Megadodo", Price: 45},
+		})
+	}
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+
+	changes := recordChanges(t, db)
+	if _, _, err = db.AddProductAlias(ctx, "Bath Towel", "Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+
+	// The offers of Megadodo and Hitchhiker Essentials for Towel are newer, so they're kept unchanged
+	got := changes()
+	if len(got) != 1 {
+		t.Fatalf("Expected a change for the offer moved to Towel, got %+v", got)
+	}
+	if change := got[0]; change.Type != OfferUpdated || change.Offer.Product != "Towel" ||
+		change.Offer.Supplier != "Hitchhiker Knockoffs" || change.PreviousPrice != 40 || !change.Cheapest {
+		t.Fatalf("Expected the moved offer to be the cheapest for Towel, got %+v", change)
+	}
+
+	// Newer offers for the alias replace the ones for the product
+	if err = db.Insert(ctx, "Beach Towel", "Must Haves", "Megadodo", 39); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	changes()
+	if _, _, err = db.AddProductAlias(ctx, "Beach Towel", "Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	got = changes()
+	if len(got) != 1 || got[0].Offer.Price != 39 || got[0].PreviousPrice != 45 || !got[0].Cheapest {
+		t.Fatalf("Expected the cheaper offer of Megadodo replacing its old one, got %+v", got)
+	}
+}
+
+func TestOffersSQLiteDatabase_AddProductAlias_flattens(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if _, _, err := db.AddProductAlias(ctx, "towel (blue)", "Bath Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	// The product of the first alias becomes an alias itself
+	if _, _, err := db.AddProductAlias(ctx, "Bath Towel", "Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	// Aliases of aliases resolve to the product
+	alias, _, err := db.AddProductAlias(ctx, "Beach Towel", "bath towel")
+	if err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	if alias.Product != "Towel" {
+		t.Fatalf("Expected the alias of an alias to resolve to Towel, got %q", alias.Product)
+	}
+
+	aliases, err := db.ProductAliases(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error listing aliases, got %v", err)
+	}
+	if len(aliases) != 3 {
+		t.Fatalf("Expected 3 aliases, got %v", aliases)
+	}
+	for _, alias := range aliases {
+		if alias.Product != "Towel" {
+			t.Fatalf("Expected all aliases to be aliases of Towel, got %v", aliases)
+		}
+	}
+
+	if _, _, err = db.AddProductAlias(ctx, "towel", "Bath Towel"); err != ErrInvalidAlias {
+		t.Fatalf("Expected ErrInvalidAlias for a product that would be an alias of itself, got %v", err)
+	}
+}
+
+func TestOffersSQLiteDatabase_DeleteProductAlias(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if _, _, err := db.AddProductAlias(ctx, "Bath Towel", "Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	if err := db.DeleteProductAlias(ctx, "bath towel"); err != nil {
+		t.Fatalf("Expected no error deleting an alias, got %v", err)
+	}
+	if err := db.DeleteProductAlias(ctx, "Bath Towel"); err != ErrAliasNotFound {
+		t.Fatalf("Expected ErrAliasNotFound, got %v", err)
+	}
+
+	// Aliases belong to tenants
+	if _, _, err := db.AddProductAlias(ctx, "Bath Towel", "Towel"); err != nil {
+		t.Fatalf("Expected no error adding an alias, got %v", err)
+	}
+	acme := db.ForTenant("acme").(*OffersSQLiteDatabase)
+	if err := acme.DeleteProductAlias(ctx, "Bath Towel"); err != ErrAliasNotFound {
+		t.Fatalf("Expected ErrAliasNotFound for the alias of another tenant, got %v", err)
+	}
+}
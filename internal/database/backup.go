@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// Backup writes a consistent copy of the database to destPath using SQLite's online backup API
+//
+// The service can keep reading and writing while the backup runs. All pages are copied in one
+// step, so the copy reflects a single point in time. An existing file at destPath is overwritten.
+func (d *OffersSQLiteDatabase) Backup(destPath string) error {
+	ctx := context.Background()
+
+	srcConn, err := d.reader.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting source connection")
+	}
+	defer srcConn.Close()
+
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return errors.Wrap(err, "error opening backup database")
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting backup connection")
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			return backupConnection(destDriverConn, srcDriverConn)
+		})
+	})
+}
+
+func backupConnection(destDriverConn, srcDriverConn interface{}) (err error) {
+	destConn, ok := destDriverConn.(*sqlite3.SQLiteConn)
+	if !ok {
+		return fmt.Errorf("unexpected driver connection %T", destDriverConn)
+	}
+	srcConn, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+	if !ok {
+		return fmt.Errorf("unexpected driver connection %T", srcDriverConn)
+	}
+
+	backup, err := destConn.Backup("main", srcConn, "main")
+	if err != nil {
+		return errors.Wrap(err, "error starting backup")
+	}
+	defer func() {
+		if ferr := backup.Finish(); ferr != nil && err == nil {
+			err = errors.Wrap(ferr, "error finishing backup")
+		}
+	}()
+
+	// A negative number of pages copies the whole database in one step
+	done, err := backup.Step(-1)
+	if err != nil {
+		return errors.Wrap(err, "error copying pages")
+	}
+	if !done {
+		return fmt.Errorf("backup incomplete, %d pages remaining", backup.Remaining())
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	categoryColumns       = "id, name, parent_id, created_at"
+	insertCategoryStmt    = "INSERT INTO categories (tenant, name, parent_id, created_at) VALUES (?, ?, ?, ?)"
+	getCategoryQuery      = "SELECT " + categoryColumns + " FROM categories WHERE tenant=? AND id=?"
+	categoryByNameQuery   = "SELECT id FROM categories WHERE tenant=? AND name=?"
+	listCategoriesQuery   = "SELECT " + categoryColumns + " FROM categories WHERE tenant=? ORDER BY id"
+	countChildrenQuery    = "SELECT COUNT(*) FROM categories WHERE tenant=? AND parent_id=?"
+	moveCategoryStmt      = "UPDATE categories SET parent_id=? WHERE tenant=? AND id=?"
+	deleteCategoryStmt    = "DELETE FROM categories WHERE tenant=? AND id=?"
+	subtreeContainsQuery  = "WITH RECURSIVE subtree(id) AS (SELECT ? UNION ALL SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id WHERE c.tenant=?) SELECT COUNT(*) FROM subtree WHERE id=?"
+	subcategoryNamesQuery = "WITH RECURSIVE subtree(id, name) AS (SELECT id, name FROM categories WHERE tenant=? AND name=? UNION ALL SELECT c.id, c.name FROM categories c JOIN subtree s ON c.parent_id = s.id WHERE c.tenant=?) SELECT name FROM subtree"
+	// categoryPathQuery walks up from the category to the top level. depth is 0 for the category.
+	categoryPathQuery = "WITH RECURSIVE path(id, name, parent_id, created_at, depth) AS (SELECT " + categoryColumns + ", 0 FROM categories WHERE tenant=? AND name=? UNION ALL SELECT c.id, c.name, c.parent_id, c.created_at, p.depth + 1 FROM categories c JOIN path p ON c.id = p.parent_id WHERE c.tenant=?) SELECT " + categoryColumns + " FROM path ORDER BY depth DESC"
+)
+
+var (
+	// ErrCategoryNotFound is returned if a category with the given ID doesn't exist
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryExists is returned if a category with the same name already exists
+	ErrCategoryExists = errors.New("category already exists")
+	// ErrInvalidCategoryParent is returned if the parent of a category doesn't exist, or if it's the
+	// category itself or one of its subcategories
+	ErrInvalidCategoryParent = errors.New("parent must be an existing category outside of the category's subtree")
+	// ErrCategoryHasChildren is returned if a category which should be deleted still has subcategories
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+)
+
+// CategoryTree is implemented by databases which arrange the categories of offers in a tree
+//
+// Offers refer to categories by name. Categories which aren't part of the tree can still have
+// offers, they just don't have a parent or subcategories.
+type CategoryTree interface {
+	// AddCategory adds a category below the parent. A parent ID of 0 adds a top-level category.
+	AddCategory(ctx context.Context, name string, parentID int64) (Category, error)
+	Category(ctx context.Context, id int64) (Category, error)
+	ListCategories(ctx context.Context) ([]Category, error)
+	// MoveCategory moves the category and its subcategories below the parent. A parent ID of 0
+	// makes it a top-level category.
+	MoveCategory(ctx context.Context, id, parentID int64) (Category, error)
+	DeleteCategory(ctx context.Context, id int64) error
+	// Subcategories returns the name of the category followed by the names of all categories below
+	// it. It's only the name if the category isn't part of the tree.
+	Subcategories(ctx context.Context, name string) ([]string, error)
+	// CategoryPath returns the categories from the top level down to the category with the name. It's
+	// empty if the category isn't part of the tree.
+	CategoryPath(ctx context.Context, name string) ([]Category, error)
+}
+
+// Category is a node in the category tree
+type Category struct {
+	ID   int64
+	Name string
+	// ParentID is the ID of the parent category, or 0 for top-level categories
+	ParentID  int64
+	CreatedAt time.Time
+}
+
+// AddCategory adds a category of the tenant below the parent
+//
+// Returns ErrCategoryExists if the tenant already has a category with the name, and
+// ErrInvalidCategoryParent if the parent doesn't exist.
+func (d *OffersSQLiteDatabase) AddCategory(ctx context.Context, name string, parentID int64) (Category, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	category := Category{Name: name, ParentID: parentID, CreatedAt: time.Now().UTC()}
+	err := d.writes.writeTx(ctx, func(tx *sql.Tx) error {
+		var existing int64
+		err := tx.QueryRowContext(ctx, categoryByNameQuery, d.tenant, name).Scan(&existing)
+		if err == nil {
+			return ErrCategoryExists
+		}
+		if err != sql.ErrNoRows {
+			return errors.Wrap(err, "error querying category")
+		}
+
+		if parentID != 0 {
+			if _, err = getCategory(ctx, tx, d.tenant, parentID); err == ErrCategoryNotFound {
+				return ErrInvalidCategoryParent
+			} else if err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, insertCategoryStmt, d.tenant, name, parentID, category.CreatedAt.UnixNano())
+		if err != nil {
+			return errors.Wrap(err, "error inserting category")
+		}
+		category.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return Category{}, err
+	}
+	return category, nil
+}
+
+// Category returns the category of the tenant with the ID. Returns ErrCategoryNotFound if it
+// doesn't exist.
+func (d *OffersSQLiteDatabase) Category(ctx context.Context, id int64) (Category, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return getCategory(ctx, d.reader, d.tenant, id)
+}
+
+func getCategory(ctx context.Context, db rowQuerier, tenant string, id int64) (Category, error) {
+	category, err := scanCategory(db.QueryRowContext(ctx, getCategoryQuery, tenant, id))
+	if err == sql.ErrNoRows {
+		return Category{}, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// ListCategories returns all categories of the tenant, ordered by ID
+func (d *OffersSQLiteDatabase) ListCategories(ctx context.Context) ([]Category, error) {
+	return d.queryCategories(ctx, listCategoriesQuery, d.tenant)
+}
+
+// MoveCategory moves the category and its subcategories below the parent
+//
+// Offers aren't changed, since they refer to their category by name. Returns ErrCategoryNotFound if
+// the category doesn't exist, and ErrInvalidCategoryParent if the parent doesn't exist or is part of
+// the category's subtree.
+func (d *OffersSQLiteDatabase) MoveCategory(ctx context.Context, id, parentID int64) (Category, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	var category Category
+	err := d.writes.writeTx(ctx, func(tx *sql.Tx) (err error) {
+		category, err = getCategory(ctx, tx, d.tenant, id)
+		if err != nil {
+			return err
+		}
+
+		if parentID != 0 {
+			if _, err = getCategory(ctx, tx, d.tenant, parentID); err == ErrCategoryNotFound {
+				return ErrInvalidCategoryParent
+			} else if err != nil {
+				return err
+			}
+
+			// Moving a category below one of its subcategories would detach the subtree
+			var inSubtree int
+			if err = tx.QueryRowContext(ctx, subtreeContainsQuery, id, d.tenant, parentID).Scan(&inSubtree); err != nil {
+				return errors.Wrap(err, "error querying subcategories")
+			}
+			if inSubtree > 0 {
+				return ErrInvalidCategoryParent
+			}
+		}
+
+		if _, err = tx.ExecContext(ctx, moveCategoryStmt, parentID, d.tenant, id); err != nil {
+			return errors.Wrap(err, "error moving category")
+		}
+		category.ParentID = parentID
+		return nil
+	})
+	if err != nil {
+		return Category{}, err
+	}
+	return category, nil
+}
+
+// DeleteCategory deletes a category of the tenant
+//
+// Only categories without subcategories can be deleted. Its offers are kept, they just aren't part
+// of the tree anymore. Returns ErrCategoryNotFound if the category doesn't exist, and
+// ErrCategoryHasChildren if it has subcategories.
+func (d *OffersSQLiteDatabase) DeleteCategory(ctx context.Context, id int64) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return d.writes.writeTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getCategory(ctx, tx, d.tenant, id); err != nil {
+			return err
+		}
+
+		var children int
+		if err := tx.QueryRowContext(ctx, countChildrenQuery, d.tenant, id).Scan(&children); err != nil {
+			return errors.Wrap(err, "error counting subcategories")
+		}
+		if children > 0 {
+			return ErrCategoryHasChildren
+		}
+
+		_, err := tx.ExecContext(ctx, deleteCategoryStmt, d.tenant, id)
+		return errors.Wrap(err, "error deleting category")
+	})
+}
+
+// Subcategories returns the name of the category of the tenant followed by the names of all
+// categories below it
+func (d *OffersSQLiteDatabase) Subcategories(ctx context.Context, name string) (names []string, err error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	rows, err := d.reader.QueryContext(ctx, subcategoryNamesQuery, d.tenant, name, d.tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying subcategories")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		var subcategory string
+		if err = rows.Scan(&subcategory); err != nil {
+			return nil, errors.Wrap(err, "error retrieving row")
+		}
+		names = append(names, subcategory)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return []string{name}, nil
+	}
+	return names, nil
+}
+
+// CategoryPath returns the categories of the tenant from the top level down to the category with
+// the name
+func (d *OffersSQLiteDatabase) CategoryPath(ctx context.Context, name string) ([]Category, error) {
+	return d.queryCategories(ctx, categoryPathQuery, d.tenant, name, d.tenant)
+}
+
+func (d *OffersSQLiteDatabase) queryCategories(ctx context.Context, query string, args ...interface{}) (categories []Category, err error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	rows, err := d.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying categories")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func scanCategory(row scanner) (Category, error) {
+	var (
+		category  Category
+		createdAt int64
+	)
+	err := row.Scan(&category.ID, &category.Name, &category.ParentID, &createdAt)
+	if err == sql.ErrNoRows {
+		return Category{}, err
+	}
+	if err != nil {
+		return Category{}, errors.Wrap(err, "error retrieving category")
+	}
+
+	category.CreatedAt = time.Unix(0, createdAt).UTC()
+	return category, nil
+}
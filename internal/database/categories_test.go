@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_CategoryTree(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	essentials, err := db.AddCategory(ctx, "Essentials", 0)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+	mustHaves, err := db.AddCategory(ctx, "Must Haves", essentials.ID)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+	towels, err := db.AddCategory(ctx, "Towels", mustHaves.ID)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+
+	if _, err = db.AddCategory(ctx, "Must Haves", 0); err != ErrCategoryExists {
+		t.Fatalf("Expected ErrCategoryExists, got %v", err)
+	}
+	if _, err = db.AddCategory(ctx, "Guides", 42); err != ErrInvalidCategoryParent {
+		t.Fatalf("Expected ErrInvalidCategoryParent for an unknown parent, got %v", err)
+	}
+
+	names, err := db.Subcategories(ctx, "Essentials")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving subcategories, got %v", err)
+	}
+	if want := []string{"Essentials", "Must Haves", "Towels"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("Expected subcategories %v, got %v", want, names)
+	}
+	names, err = db.Subcategories(ctx, "Guides")
+	if err != nil || !reflect.DeepEqual(names, []string{"Guides"}) {
+		t.Fatalf("Expected only the category outside of the tree, got %v, %v", names, err)
+	}
+
+	path, err := db.CategoryPath(ctx, "Towels")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the path, got %v", err)
+	}
+	if len(path) != 3 || path[0].ID != essentials.ID || path[1].ID != mustHaves.ID || path[2].ID != towels.ID {
+		t.Fatalf("Expected the path from Essentials to Towels, got %v", path)
+	}
+
+	// Categories can't be moved into their own subtree
+	if _, err = db.MoveCategory(ctx, essentials.ID, towels.ID); err != ErrInvalidCategoryParent {
+		t.Fatalf("Expected ErrInvalidCategoryParent moving a category below its subcategory, got %v", err)
+	}
+	if _, err = db.MoveCategory(ctx, mustHaves.ID, mustHaves.ID); err != ErrInvalidCategoryParent {
+		t.Fatalf("Expected ErrInvalidCategoryParent moving a category below itself, got %v", err)
+	}
+
+	// Moving a category takes its subcategories along
+	moved, err := db.MoveCategory(ctx, mustHaves.ID, 0)
+	if err != nil {
+		t.Fatalf("Expected no error moving a category, got %v", err)
+	}
+	if moved.ParentID != 0 || moved.Name != "Must Haves" {
+		t.Fatalf("Expected Must Haves at the top level, got %v", moved)
+	}
+	path, err = db.CategoryPath(ctx, "Towels")
+	if err != nil || len(path) != 2 || path[0].ID != mustHaves.ID {
+		t.Fatalf("Expected the path from Must Haves to Towels, got %v, %v", path, err)
+	}
+
+	if err = db.DeleteCategory(ctx, mustHaves.ID); err != ErrCategoryHasChildren {
+		t.Fatalf("Expected ErrCategoryHasChildren, got %v", err)
+	}
+	if err = db.DeleteCategory(ctx, towels.ID); err != nil {
+		t.Fatalf("Expected no error deleting a category, got %v", err)
+	}
+	if _, err = db.Category(ctx, towels.ID); err != ErrCategoryNotFound {
+		t.Fatalf("Expected ErrCategoryNotFound for a deleted category, got %v", err)
+	}
+
+	categories, err := db.ListCategories(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error listing categories, got %v", err)
+	}
+	if len(categories) != 2 {
+		t.Fatalf("Expected 2 categories, got %v", categories)
+	}
+}
+
+func TestOffersSQLiteDatabase_MoveCategory_keepsOffers(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if err := db.Insert(ctx, "Towel", "Towels", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	essentials, err := db.AddCategory(ctx, "Essentials", 0)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+	towels, err := db.AddCategory(ctx, "Towels", 0)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+	if _, err = db.MoveCategory(ctx, towels.ID, essentials.ID); err != nil {
+		t.Fatalf("Expected no error moving a category, got %v", err)
+	}
+
+	offers, err := db.Get(ctx, "Towel", "Towels")
+	if err != nil || len(offers) != 1 {
+		t.Fatalf("Expected the offer in the moved category, got %v, %v", offers, err)
+	}
+}
+
+func TestOffersSQLiteDatabase_CategoryTree_tenants(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	category, err := db.AddCategory(ctx, "Must Haves", 0)
+	if err != nil {
+		t.Fatalf("Expected no error adding a category, got %v", err)
+	}
+
+	acme := db.ForTenant("acme").(*OffersSQLiteDatabase)
+	if _, err = acme.Category(ctx, category.ID); err != ErrCategoryNotFound {
+		t.Fatalf("Expected ErrCategoryNotFound for the category of another tenant, got %v", err)
+	}
+	if _, err = acme.AddCategory(ctx, "Towels", category.ID); err != ErrInvalidCategoryParent {
+		t.Fatalf("Expected ErrInvalidCategoryParent for a parent of another tenant, got %v", err)
+	}
+	// Tenants can use the same names
+	if _, err = acme.AddCategory(ctx, "Must Haves", 0); err != nil {
+		t.Fatalf("Expected no error adding a category for another tenant, got %v", err)
+	}
+}
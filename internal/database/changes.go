@@ -0,0 +1,78 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// ChangeType describes what happened to an offer
+type ChangeType string
+
+// The types of changes to offers
+const (
+	OfferInserted  ChangeType = "inserted"
+	OfferUpdated   ChangeType = "updated"
+	OfferWithdrawn ChangeType = "withdrawn"
+)
+
+// Change is a committed change to an offer
+type Change struct {
+	Type ChangeType
+	// Tenant is the tenant whose offer changed
+	Tenant string
+	Offer  Offer
+	// PreviousPrice is the price before an update or withdrawal. It's 0 for inserts.
+	PreviousPrice float32
+	// Cheapest is set if the inserted or updated offer is now the only cheapest offer for the
+	// product in its category and is cheaper than the cheapest offer before the write. Only offers
+	// which can be ordered are compared.
+	Cheapest bool
+	// Suspended is set if the supplier of the offer is suspended. Its offers are hidden from
+	// customers, so the change shouldn't be shown to them either.
+	Suspended bool
+	Time      time.Time
+}
+
+// ChangeListener is called with the changes of every committed write
+//
+// Listeners are called synchronously from the goroutine that wrote the offers, so they should
+// return quickly.
+type ChangeListener func(changes []Change)
+
+// ChangeNotifier is implemented by databases which report changes to offers
+type ChangeNotifier interface {
+	AddChangeListener(listener ChangeListener)
+}
+
+// changeListeners is a list of listeners which is safe for concurrent use
+type changeListeners struct {
+	mu        sync.RWMutex
+	listeners []ChangeListener
+}
+
+func (l *changeListeners) add(listener ChangeListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, listener)
+}
+
+func (l *changeListeners) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, listener := range l.listeners {
+		listener(changes)
+	}
+}
+
+// AddChangeListener registers a listener which is called after every committed write that changed
+// offers. Writes which don't change a price aren't reported.
+//
+// Listeners are shared by the handles of all tenants, so they're called with the changes of every
+// tenant.
+func (d *OffersSQLiteDatabase) AddChangeListener(listener ChangeListener) {
+	d.listeners.add(listener)
+}
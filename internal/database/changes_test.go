@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordChanges registers a listener and returns a function which returns and clears the changes
+// received so far. The times of the changes are checked and then zeroed to make them comparable.
+func recordChanges(t *testing.T, db *OffersSQLiteDatabase) func() []Change {
+	var received []Change
+	db.AddChangeListener(func(changes []Change) {
+		received = append(received, changes...)
+	})
+
+	return func() []Change {
+		result := received
+		received = nil
+		for i := range result {
+			if result[i].Time.IsZero() {
+				t.Fatalf("Expected change %+v to have a time", result[i])
+			}
+			result[i].Time = time.Time{}
+		}
+		return result
+	}
+}
+
+func TestOffersSQLiteDatabase_changeListeners(t *testing.T) {
+	db := setupFileDatabase(t)
+	changes := recordChanges(t, db)
+
+	towel := Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42}
+	guide := Offer{Product: "Guide", Category: "Must Haves", Supplier: "Megadodo", Price: 30}
+
+	if err := db.InsertMultiple(context.Background(), []Offer{towel, guide}); err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+	want := []Change{
+		{Type: OfferInserted, Tenant: DefaultTenant, Offer: towel, Cheapest: true},
+		{Type: OfferInserted, Tenant: DefaultTenant, Offer: guide, Cheapest: true},
+	}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	// Only changed prices are reported as updates
+	cheaperTowel := towel
+	cheaperTowel.Price = 41
+	if err := db.InsertMultiple(context.Background(), []Offer{cheaperTowel, guide}); err != nil {
+		t.Fatalf("Expected no error updating offers, got %v", err)
+	}
+	want = []Change{{Type: OfferUpdated, Tenant: DefaultTenant, Offer: cheaperTowel, PreviousPrice: 42, Cheapest: true}}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	// Writes without changes don't notify the listeners at all
+	if err := db.Insert(context.Background(), guide.Product, guide.Category, guide.Supplier, guide.Price); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if got := changes(); got != nil {
+		t.Fatalf("Expected no changes, got %+v", got)
+	}
+}
+
+func TestOffersSQLiteDatabase_Withdraw(t *testing.T) {
+	db := setupFileDatabase(t)
+	changes := recordChanges(t, db)
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs", 40); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	changes()
+
+	if err := db.Withdraw(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs"); err != nil {
+		t.Fatalf("Expected no error withdrawing an offer, got %v", err)
+	}
+
+	offers, err := db.Get(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error getting offers, got %v", err)
+	}
+	if len(offers) != 1 || offers[0].Supplier != "Hitchhiker Essentials" {
+		t.Fatalf("Expected only the remaining offer, got %v", offers)
+	}
+
+	withdrawn := Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40}
+	want := []Change{{Type: OfferWithdrawn, Tenant: DefaultTenant, Offer: withdrawn, PreviousPrice: 40}}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	if err = db.Withdraw(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs"); err != ErrNotFound {
+		t.Fatalf("Got error %v withdrawing a missing offer, want %v", err, ErrNotFound)
+	}
+	if got := changes(); got != nil {
+		t.Fatalf("Expected no changes, got %+v", got)
+	}
+}
+
+func TestOffersSQLiteDatabase_cheapestOffers(t *testing.T) {
+	db := setupFileDatabase(t)
+	changes := recordChanges(t, db)
+
+	offer := func(supplier string, price float32) Offer {
+		return Offer{Product: "Towel", Category: "Must Haves", Supplier: supplier, Price: price}
+	}
+
+	testCases := []struct {
+		name   string
+		offers []Offer
+		want   []bool
+	}{
+		{"first offer", []Offer{offer("a", 42)}, []bool{true}},
+		{"more expensive offer", []Offer{offer("b", 50)}, []bool{false}},
+		{"same price as the cheapest offer", []Offer{offer("c", 42)}, []bool{false}},
+		{"cheaper offer", []Offer{offer("b", 40)}, []bool{true}},
+		{"price increase of the cheapest offer", []Offer{offer("b", 60)}, []bool{false}},
+		{"only the cheapest offer of a batch", []Offer{offer("d", 30), offer("e", 20)}, []bool{false, true}},
+		{"tie in a batch", []Offer{offer("f", 10), offer("g", 10)}, []bool{false, false}},
+	}
+
+	for _, testCase := range testCases {
+		if err := db.InsertMultiple(context.Background(), testCase.offers); err != nil {
+			t.Fatalf("%s: expected no error inserting offers, got %v", testCase.name, err)
+		}
+
+		got := changes()
+		if len(got) != len(testCase.want) {
+			t.Fatalf("%s: got changes %+v, want %d", testCase.name, got, len(testCase.want))
+		}
+		for i, change := range got {
+			if change.Cheapest != testCase.want[i] {
+				t.Fatalf("%s: got cheapest %t for %+v, want %t", testCase.name, change.Cheapest, change.Offer, testCase.want[i])
+			}
+		}
+	}
+}
+
+func TestOffersSQLiteDatabase_suspendedSuppliers(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	suppliers, err := db.SuppliersByName(ctx, []string{"Hitchhiker Essentials"})
+	if err != nil {
+		t.Fatalf("Expected no error looking up suppliers, got %v", err)
+	}
+	essentials := suppliers["Hitchhiker Essentials"]
+	lastUpdated, err := db.LastUpdated(ctx, "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error getting the last update, got %v", err)
+	}
+	changes := recordChanges(t, db)
+
+	towel := Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42}
+	essentials.Status = SupplierSuspended
+	if _, err = db.UpdateSupplier(ctx, essentials); err != nil {
+		t.Fatalf("Expected no error suspending a supplier, got %v", err)
+	}
+	want := []Change{{Type: OfferUpdated, Tenant: DefaultTenant, Offer: towel, PreviousPrice: 42, Suspended: true}}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	// Suspending the supplier touches its offers, so that searches for them change
+	suspendedAt, err := db.LastUpdated(ctx, "Towel", "Must Haves")
+	if err != nil || !suspendedAt.After(lastUpdated) {
+		t.Fatalf("Expected the offers to be updated after %s, got %s, %v", lastUpdated, suspendedAt, err)
+	}
+
+	// Offers of suspended suppliers are never the cheapest, and don't count when others are compared
+	cheaper := towel
+	cheaper.Price = 30
+	knockoff := Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40}
+	if err = db.InsertMultiple(ctx, []Offer{cheaper, knockoff}); err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+	want = []Change{
+		{Type: OfferUpdated, Tenant: DefaultTenant, Offer: cheaper, PreviousPrice: 42, Suspended: true},
+		{Type: OfferInserted, Tenant: DefaultTenant, Offer: knockoff, Cheapest: true},
+	}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	// Resuming the supplier shows its offers again, which may be the cheapest
+	essentials.Status = SupplierActive
+	if _, err = db.UpdateSupplier(ctx, essentials); err != nil {
+		t.Fatalf("Expected no error resuming a supplier, got %v", err)
+	}
+	want = []Change{{Type: OfferUpdated, Tenant: DefaultTenant, Offer: cheaper, PreviousPrice: 30, Cheapest: true}}
+	if got := changes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got changes %+v, want %+v", got, want)
+	}
+
+	// Updates which keep the status don't change the offers
+	essentials.DisplayName = "Hitchhiker Essentials Ltd."
+	if _, err = db.UpdateSupplier(ctx, essentials); err != nil {
+		t.Fatalf("Expected no error updating a supplier, got %v", err)
+	}
+	if got := changes(); got != nil {
+		t.Fatalf("Expected no changes, got %+v", got)
+	}
+}
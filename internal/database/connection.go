@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClosed is returned for writes to a database which has been closed
+var ErrClosed = errors.New("database is closed")
+
+// Options tune the connections to the database
+type Options struct {
+	// BusyTimeout is how long a connection waits for a lock held by another process, e.g. offersctl,
+	// before failing with "database is locked"
+	BusyTimeout time.Duration
+	// CacheSizeKiB is the size of the page cache of every connection in KiB
+	CacheSizeKiB int
+	// MaxReadConnections limits the number of connections used for reads
+	MaxReadConnections int
+	// QueryTimeout limits the time of every query and write, including the time waiting for earlier
+	// writes. It's unlimited if it's 0. Iterating over offers isn't limited, since exports take as
+	// long as they take.
+	QueryTimeout time.Duration
+}
+
+// DefaultOptions returns the options used by InitSQLiteDatabase
+func DefaultOptions() Options {
+	return Options{
+		BusyTimeout:        5 * time.Second,
+		CacheSizeKiB:       2000,
+		MaxReadConnections: 4,
+	}
+}
+
+// dataSourceName adds the connection parameters to the path of the database
+//
+// The database is used in WAL mode, so reads don't block the writer and vice versa. Writers start
+// their transactions with an immediate lock. Otherwise, two transactions which both read before
+// writing can deadlock, and one of them fails without waiting for the busy timeout.
+func dataSourceName(dbPath string, options Options, writer bool) string {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", strconv.FormatInt(options.BusyTimeout.Milliseconds(), 10))
+	// Negative values are interpreted as KiB rather than pages by SQLite
+	params.Set("_cache_size", strconv.Itoa(-options.CacheSizeKiB))
+	if writer {
+		params.Set("_txlock", "immediate")
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + params.Encode()
+}
+
+// writeRequest is a function to run on the write connection and the channel for its result
+type writeRequest struct {
+	fn     func(db *sql.DB) error
+	result chan error
+}
+
+// writeQueue serialises all writes through a single connection
+//
+// SQLite only allows one writer at a time. Funnelling the writes of this process through one
+// connection means they never compete for the lock, so they don't fail with "database is locked".
+type writeQueue struct {
+	db       *sql.DB
+	requests chan writeRequest
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+func newWriteQueue(db *sql.DB) *writeQueue {
+	db.SetMaxOpenConns(1)
+
+	q := &writeQueue{
+		db:       db,
+		requests: make(chan writeRequest),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *writeQueue) run() {
+	defer close(q.done)
+
+	for {
+		select {
+		case <-q.closing:
+			return
+		case req := <-q.requests:
+			req.result <- req.fn(q.db)
+		}
+	}
+}
+
+// write runs fn on the write connection once all writes queued before it have finished
+func (q *writeQueue) write(fn func(db *sql.DB) error) error {
+	return q.writeContext(context.Background(), fn)
+}
+
+// writeContext is like write, but stops waiting for earlier writes when the context is done. fn
+// should use the context for its statements, so that they're cancelled, too.
+func (q *writeQueue) writeContext(ctx context.Context, fn func(db *sql.DB) error) error {
+	req := writeRequest{fn: fn, result: make(chan error, 1)}
+
+	select {
+	case <-q.closing:
+		return ErrClosed
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "error waiting for earlier writes")
+	case q.requests <- req:
+		return <-req.result
+	}
+}
+
+// writeTx is like writeContext, but runs fn in a transaction. The transaction is committed if fn
+// succeeds and rolled back otherwise.
+func (q *writeQueue) writeTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return q.writeContext(ctx, func(db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "error beginning transaction")
+		}
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return errors.Wrap(tx.Commit(), "error committing transaction")
+	})
+}
+
+// close waits for the running write to finish and stops the queue
+func (q *writeQueue) close() {
+	q.closeOnce.Do(func() {
+		close(q.closing)
+	})
+	<-q.done
+}
+
+// openConnections opens the pool of read connections and the write connection
+func openConnections(dbPath string, options Options) (reader, writer *sql.DB, err error) {
+	if options.MaxReadConnections < 1 {
+		return nil, nil, fmt.Errorf("need at least one read connection, got %d", options.MaxReadConnections)
+	}
+
+	writer, err = sql.Open("sqlite3", dataSourceName(dbPath, options, true))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error opening database for writing")
+	}
+
+	reader, err = sql.Open("sqlite3", dataSourceName(dbPath, options, false))
+	if err != nil {
+		writer.Close()
+		return nil, nil, errors.Wrap(err, "error opening database for reading")
+	}
+	reader.SetMaxOpenConns(options.MaxReadConnections)
+	reader.SetMaxIdleConns(options.MaxReadConnections)
+
+	return reader, writer, nil
+}
+
+// queryContext returns the context limited by the query timeout
+func (d *OffersSQLiteDatabase) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d.queryTimeout)
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInitSQLiteDatabase_usesWAL(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	var journalMode string
+	err := db.reader.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+	if err != nil {
+		t.Fatalf("Expected no error reading the journal mode, got %v", err)
+	}
+
+	if journalMode != "wal" {
+		t.Fatalf("Expected the database to use WAL, got %q", journalMode)
+	}
+}
+
+func TestDataSourceName(t *testing.T) {
+	options := DefaultOptions()
+
+	got := dataSourceName("file::memory:?mode=memory&cache=shared", options, true)
+	want := "file::memory:?mode=memory&cache=shared&_busy_timeout=5000&_cache_size=-2000&_journal_mode=WAL&_synchronous=NORMAL&_txlock=immediate"
+	if got != want {
+		t.Fatalf("Expected the data source name %q, got %q", want, got)
+	}
+
+	got = dataSourceName("offers.db", options, false)
+	want = "offers.db?_busy_timeout=5000&_cache_size=-2000&_journal_mode=WAL&_synchronous=NORMAL"
+	if got != want {
+		t.Fatalf("Expected the data source name %q, got %q", want, got)
+	}
+}
+
+// TestOffersSQLiteDatabase_concurrentAccess runs batch inserts and searches in parallel, which
+// must not fail because the database is locked
+func TestOffersSQLiteDatabase_concurrentAccess(t *testing.T) {
+	const (
+		workers    = 8
+		iterations = 50
+		batchSize  = 20
+	)
+
+	db := setupFileDatabase(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*iterations)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				batch := make([]Offer, batchSize)
+				for j := range batch {
+					batch[j] = Offer{
+						Product:  fmt.Sprintf("Towel %d", j),
+						Category: "Must Haves",
+						Supplier: fmt.Sprintf("Supplier %d", worker),
+						Price:    float32(i),
+					}
+				}
+				if err := db.InsertMultiple(context.Background(), batch); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if _, err := db.Get(context.Background(), fmt.Sprintf("Towel %d", i%batchSize), "Must Haves"); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Expected no error during concurrent access, got %v", err)
+	}
+
+	offers, err := db.Get(context.Background(), "Towel 0", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving offers, got %v", err)
+	}
+	if len(offers) != workers {
+		t.Fatalf("Expected one offer per supplier, got %d", len(offers))
+	}
+}
+
+func TestOffersSQLiteDatabase_writeAfterClose(t *testing.T) {
+	db, err := InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+
+	if err = db.Close(); err != nil {
+		t.Fatalf("Expected no error closing the database, got %v", err)
+	}
+
+	if err = db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != ErrClosed {
+		t.Fatalf("Expected ErrClosed writing to a closed database, got %v", err)
+	}
+}
+
+func TestOffersSQLiteDatabase_cancelledContext(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.Get(ctx, "Towel", ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled reading with a cancelled context, got %v", err)
+	}
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled writing with a cancelled context, got %v", err)
+	}
+
+	offers, err := db.Get(context.Background(), "Towel", "")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving offers, got %v", err)
+	}
+	if len(offers) != 0 {
+		t.Fatalf("Expected the cancelled write not to be committed, got %d offers", len(offers))
+	}
+}
+
+func TestOffersSQLiteDatabase_queryTimeout(t *testing.T) {
+	options := DefaultOptions()
+	options.QueryTimeout = time.Nanosecond
+	db, err := InitSQLiteDatabaseWithOptions(filepath.Join(t.TempDir(), "offers.db"), options)
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Get(context.Background(), "Towel", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWriteQueue_cancelWhileWaiting(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	// Block the queue with a write until the test is done
+	started, release := make(chan struct{}), make(chan struct{})
+	go db.writes.write(func(*sql.DB) error {
+		close(started)
+		<-release
+		return nil
+	})
+	defer close(release)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := db.writes.writeContext(ctx, func(*sql.DB) error {
+		t.Error("Expected the cancelled write not to run")
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded waiting for the queue, got %v", err)
+	}
+}
@@ -1,55 +1,115 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 const (
-	createOffersTableStmt = "CREATE TABLE IF NOT EXISTS offers (product TEXT NOT NULL, category TEXT NOT NULL, supplier TEXT NOT NULL, price REAL NOT NULL, PRIMARY KEY (product, category, supplier))"
-	insertOfferStmt       = "INSERT INTO offers (product, category, supplier, price) VALUES (?, ?, ?, ?) ON CONFLICT(product, category, supplier) DO UPDATE SET price=EXCLUDED.price"
-	getOfferQuery         = "SELECT product, category, supplier, price FROM offers WHERE product=? AND category=? ORDER BY price ASC"
+	insertOfferStmt  = "INSERT INTO offers (tenant, product, category, supplier, price, quantity, availability, lead_time_days, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(tenant, product, category, supplier) DO UPDATE SET price=EXCLUDED.price, quantity=EXCLUDED.quantity, availability=EXCLUDED.availability, lead_time_days=EXCLUDED.lead_time_days, updated_at=EXCLUDED.updated_at"
+	getOfferQuery    = "SELECT product, category, supplier, price, quantity, availability, lead_time_days FROM offers WHERE tenant=? AND product=? AND category=? ORDER BY price ASC"
+	lastUpdatedQuery = "SELECT COALESCE(MAX(updated_at), 0) FROM offers WHERE tenant=? AND product=? AND category=?"
+	getPriceQuery    = "SELECT price FROM offers WHERE tenant=? AND product=? AND category=? AND supplier=?"
+	minPriceQuery    = "SELECT price, COUNT(*) FROM offers WHERE tenant=? AND product=? AND category=? AND " + orderableOffers + " GROUP BY price ORDER BY price ASC LIMIT 1"
+	deleteOfferStmt  = "DELETE FROM offers WHERE tenant=? AND product=? AND category=? AND supplier=?"
 )
 
+// orderableOffers is the condition on offers which customers can order, like Stock.Available and
+// ranking.Orderable: the supplier isn't suspended, and the offer isn't out of stock.
+const orderableOffers = "supplier NOT IN (SELECT name FROM suppliers WHERE tenant=offers.tenant AND status='" + string(SupplierSuspended) + "')" +
+	" AND COALESCE(availability, CASE WHEN quantity <= 0 THEN '" + string(OutOfStock) + "' END, '') != '" + string(OutOfStock) + "'"
+
+// ErrNotFound is returned if an offer that should be changed doesn't exist
+var ErrNotFound = errors.New("offer not found")
+
 // Offers is an interface for a database client
 type Offers interface {
-	Insert(productName, categoryName, supplierName string, price float32) error
-	InsertMultiple(offers []Offer) error
-	Get(productName, categoryName string) ([]Offer, error)
+	// Ping checks that the database can be reached
+	Ping(ctx context.Context) error
+	// Health checks that the database can be read and reports its state
+	Health(ctx context.Context) (Health, error)
+	Insert(ctx context.Context, productName, categoryName, supplierName string, price float32) error
+	InsertMultiple(ctx context.Context, offers []Offer) error
+	Withdraw(ctx context.Context, productName, categoryName, supplierName string) error
+	Get(ctx context.Context, productName, categoryName string) ([]Offer, error)
+	LastUpdated(ctx context.Context, productName, categoryName string) (time.Time, error)
+	Iterate(ctx context.Context, filter OfferFilter) (OfferIterator, error)
 	Close() error
 }
 
 // OffersSQLiteDatabase is a database client using SQLite
-type OffersSQLiteDatabase sql.DB
+//
+// Reads use a pool of connections, while writes are queued and run one after the other on a
+// single connection.
+//
+// Every handle reads and writes the offers of a single tenant. InitSQLiteDatabase returns the
+// handle of the DefaultTenant, and ForTenant returns the handles of other tenants.
+type OffersSQLiteDatabase struct {
+	tenant string
+
+	path   string
+	reader *sql.DB
+	writer *sql.DB
+	writes *writeQueue
+
+	queryTimeout time.Duration
+
+	// The state below is shared by the handles of all tenants
+	listeners  *changeListeners
+	quickCheck *latestQuickCheck
+}
 
 // Offer is a struct representing an offer for a product by a supplier
 type Offer struct {
 	Product, Category, Supplier string
 	Price                       float32
+	Stock
 }
 
-// InitSQLiteDatabase opens the database and sets it up if needed.
+// InitSQLiteDatabase opens the database with the default options and sets it up if needed.
 // Returns a database handle.
 func InitSQLiteDatabase(dbPath string) (*OffersSQLiteDatabase, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return InitSQLiteDatabaseWithOptions(dbPath, DefaultOptions())
+}
+
+// InitSQLiteDatabaseWithOptions opens the database and sets it up if needed.
+// Returns a database handle.
+func InitSQLiteDatabaseWithOptions(dbPath string, options Options) (*OffersSQLiteDatabase, error) {
+	reader, writer, err := openConnections(dbPath, options)
 	if err != nil {
-		return nil, errors.Wrap(err, "error opening database")
+		return nil, err
 	}
 
-	err = execStatement(db, createOffersTableStmt)
+	err = migrate(writer)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating table")
+		reader.Close()
+		writer.Close()
+		return nil, errors.Wrap(err, "error migrating database")
 	}
 
-	return (*OffersSQLiteDatabase)(db), nil
+	return &OffersSQLiteDatabase{
+		tenant: DefaultTenant,
+
+		path:   dbPath,
+		reader: reader,
+		writer: writer,
+		writes: newWriteQueue(writer),
+
+		queryTimeout: options.QueryTimeout,
+
+		listeners:  &changeListeners{},
+		quickCheck: &latestQuickCheck{},
+	}, nil
 }
 
 // Insert inserts an offer into the database
 //
 // If an offer for an existing product, category and supplier exists, the offer is updated.
-func (d *OffersSQLiteDatabase) Insert(productName, categoryName, supplierName string, price float32) error {
-	return d.InsertMultiple([]Offer{
+func (d *OffersSQLiteDatabase) Insert(ctx context.Context, productName, categoryName, supplierName string, price float32) error {
+	return d.InsertMultiple(ctx, []Offer{
 		{
 			Product:  productName,
 			Category: categoryName,
@@ -61,12 +121,32 @@ func (d *OffersSQLiteDatabase) Insert(productName, categoryName, supplierName st
 
 // InsertMultiple inserts multiple offers into the database in a transaction
 //
-// If an offer for an existing product, category and supplier exists, the offer is updated.
-func (d *OffersSQLiteDatabase) InsertMultiple(offers []Offer) (err error) {
-	db := (*sql.DB)(d)
-	tx, err := db.Begin()
+// If an offer for an existing product, category and supplier exists, the offer is updated. Fields
+// of the stock which aren't set are kept, except for the availability, which is cleared when only
+// the quantity is set.
+// Change listeners are notified once the transaction has been committed.
+func (d *OffersSQLiteDatabase) InsertMultiple(ctx context.Context, offers []Offer) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	var changes []Change
+	err := d.writes.writeContext(ctx, func(db *sql.DB) (err error) {
+		changes, err = insertOffers(ctx, db, d.tenant, offers)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	d.listeners.notify(changes)
+	return nil
+}
+
+// insertOffers upserts the offers of the tenant in a transaction and returns the changes
+func insertOffers(ctx context.Context, db *sql.DB, tenant string, offers []Offer) (changes []Change, err error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "error beginning transaction")
+		return nil, errors.Wrap(err, "error beginning transaction")
 	}
 
 	// Make sure that we commit the transaction or rollback in case of an error
@@ -81,25 +161,200 @@ func (d *OffersSQLiteDatabase) InsertMultiple(offers []Offer) (err error) {
 		}
 	}()
 
-	stmt, err := tx.Prepare(insertOfferStmt)
+	stmt, err := tx.PrepareContext(ctx, insertOfferStmt)
+	if err != nil {
+		return nil, errors.Wrap(err, "error preparing insert statement")
+	}
+
+	stateStmt, err := tx.PrepareContext(ctx, getOfferStateQuery)
 	if err != nil {
-		return errors.Wrap(err, "error preparing insert statement")
+		return nil, errors.Wrap(err, "error preparing offer query")
+	}
+
+	// The products of the offers' names, which differ for aliases
+	products := make(map[string]string)
+
+	// The cheapest prices before the write, by product and category
+	minPrices := make(map[productKey]sql.NullFloat64)
+
+	now := time.Now()
+	updatedAt := now.UnixNano()
+	if err = registerSuppliers(ctx, tx, tenant, offers, now); err != nil {
+		return nil, err
 	}
 
 	for _, offer := range offers {
-		_, err = tx.Stmt(stmt).Exec(offer.Product, offer.Category, offer.Supplier, offer.Price)
+		product, ok := products[offer.Product]
+		if !ok {
+			product, err = resolveProduct(ctx, tx, tenant, offer.Product)
+			if err != nil {
+				return nil, err
+			}
+			products[offer.Product] = product
+		}
+		offer.Product = product
+
+		key := productKey{tenant, offer.Product, offer.Category}
+		if _, ok := minPrices[key]; !ok {
+			minPrices[key], _, err = minPrice(ctx, tx, key)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		change := Change{Type: OfferInserted, Tenant: tenant, Offer: offer, Time: now}
+
+		var previous nullStock
+		err = stateStmt.QueryRowContext(ctx, tenant, offer.Product, offer.Category, offer.Supplier).
+			Scan(append([]interface{}{&change.PreviousPrice}, previous.dest()...)...)
+		switch {
+		case err == sql.ErrNoRows:
+			err = nil
+		case err != nil:
+			return nil, errors.Wrap(err, "error querying existing offer")
+		default:
+			change.Type = OfferUpdated
+		}
+
+		previousStock := previous.stock()
+		offer.Stock = previousStock.apply(StockUpdate{Stock: offer.Stock})
+		change.Offer = offer
+
+		args := []interface{}{tenant, offer.Product, offer.Category, offer.Supplier, offer.Price}
+		args = append(append(args, offer.Stock.values()...), updatedAt)
+		_, err = tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
 		if err != nil {
-			return errors.Wrap(err, "error inserting offer")
+			return nil, errors.Wrap(err, "error inserting offer")
 		}
+
+		if change.Type == OfferInserted || change.PreviousPrice != offer.Price || !offer.Stock.equal(previousStock) {
+			changes = append(changes, change)
+		}
+	}
+
+	if err = markSuspended(ctx, tx, changes); err != nil {
+		return nil, err
 	}
+	err = markCheapest(ctx, tx, changes, minPrices)
+	return changes, err
+}
+
+// productKey identifies a product in a category of a tenant
+type productKey struct {
+	tenant, product, category string
+}
 
-	return
+// minPrice returns the cheapest price for a product and how many offers have it. Only offers which
+// can be ordered are considered. The price is null if there are no such offers.
+func minPrice(ctx context.Context, tx *sql.Tx, key productKey) (price sql.NullFloat64, offers int, err error) {
+	err = tx.QueryRowContext(ctx, minPriceQuery, key.tenant, key.product, key.category).Scan(&price, &offers)
+	if err == sql.ErrNoRows {
+		return sql.NullFloat64{}, 0, nil
+	}
+	return price, offers, errors.Wrap(err, "error querying cheapest price")
+}
+
+// markCheapest sets Cheapest on the changes whose offers undercut the cheapest price before the write.
+// Suspended must already be set, since only offers which can be ordered are ever the cheapest.
+func markCheapest(ctx context.Context, tx *sql.Tx, changes []Change, before map[productKey]sql.NullFloat64) error {
+	for i := range changes {
+		change := &changes[i]
+		if change.Suspended || !change.Offer.Available() {
+			continue
+		}
+		key := productKey{change.Tenant, change.Offer.Product, change.Offer.Category}
+
+		previous := before[key]
+		if previous.Valid && float64(change.Offer.Price) >= previous.Float64 {
+			continue
+		}
+
+		current, offers, err := minPrice(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		change.Cheapest = offers == 1 && float64(change.Offer.Price) == current.Float64
+	}
+	return nil
+}
+
+// Withdraw deletes the offer of a supplier for a product in a category. The product may be an alias.
+//
+// Returns ErrNotFound if there is no such offer. Change listeners are notified once the offer has
+// been deleted.
+func (d *OffersSQLiteDatabase) Withdraw(ctx context.Context, productName, categoryName, supplierName string) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	change := Change{
+		Type:   OfferWithdrawn,
+		Tenant: d.tenant,
+		Offer:  Offer{Product: productName, Category: categoryName, Supplier: supplierName},
+	}
+
+	err := d.writes.writeContext(ctx, func(db *sql.DB) (err error) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "error beginning transaction")
+		}
+
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			if commitErr := tx.Commit(); commitErr != nil {
+				err = errors.Wrap(commitErr, "error committing transaction")
+			}
+		}()
+
+		productName, err = resolveProduct(ctx, tx, d.tenant, productName)
+		if err != nil {
+			return err
+		}
+		change.Offer.Product = productName
+
+		err = tx.QueryRowContext(ctx, getPriceQuery, d.tenant, productName, categoryName, supplierName).Scan(&change.PreviousPrice)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "error querying existing offer")
+		}
+
+		_, err = tx.ExecContext(ctx, deleteOfferStmt, d.tenant, productName, categoryName, supplierName)
+		if err != nil {
+			return errors.Wrap(err, "error deleting offer")
+		}
+
+		changes := []Change{change}
+		err = markSuspended(ctx, tx, changes)
+		change.Suspended = changes[0].Suspended
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	change.Offer.Price = change.PreviousPrice
+	change.Time = time.Now()
+	d.listeners.notify([]Change{change})
+	return nil
 }
 
 // Get returns all offers for a given product in a category
-func (d *OffersSQLiteDatabase) Get(productName, categoryName string) ([]Offer, error) {
-	db := (*sql.DB)(d)
-	rows, err := db.Query(getOfferQuery, productName, categoryName)
+//
+// If the name is an alias, the offers for its product are returned.
+func (d *OffersSQLiteDatabase) Get(ctx context.Context, productName, categoryName string) ([]Offer, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	productName, err := resolveProduct(ctx, d.reader, d.tenant, productName)
+	if err != nil {
+		return []Offer{}, err
+	}
+
+	rows, err := d.reader.QueryContext(ctx, getOfferQuery, d.tenant, productName, categoryName)
 	if err != nil {
 		return []Offer{}, err
 	}
@@ -113,31 +368,53 @@ func (d *OffersSQLiteDatabase) Get(productName, categoryName string) ([]Offer, e
 
 	for rows.Next() {
 		offer := Offer{}
-		err = rows.Scan(&offer.Product, &offer.Category, &offer.Supplier, &offer.Price)
+		var stock nullStock
+		err = rows.Scan(append([]interface{}{&offer.Product, &offer.Category, &offer.Supplier, &offer.Price}, stock.dest()...)...)
 		if err != nil {
 			return []Offer{}, errors.Wrap(err, "error retrieving row")
 		}
+		offer.Stock = stock.stock()
 		offers = append(offers, offer)
 	}
+	// Cancelling the context ends the rows early, which is only reported here
+	if err = rows.Err(); err != nil {
+		return []Offer{}, errors.Wrap(err, "error reading offers")
+	}
 	return offers, nil
 }
 
-// Close closes the database connection
-func (d *OffersSQLiteDatabase) Close() error {
-	return (*sql.DB)(d).Close()
-}
+// LastUpdated returns the last time an offer for the product in the category was inserted or
+// updated. Returns the zero time if there are no offers. The product may be an alias.
+func (d *OffersSQLiteDatabase) LastUpdated(ctx context.Context, productName, categoryName string) (time.Time, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
 
-func execStatement(db *sql.DB, stmt string) error {
-	statement, err := db.Prepare(stmt)
+	productName, err := resolveProduct(ctx, d.reader, d.tenant, productName)
 	if err != nil {
-		return errors.Wrap(err, "error preparing statement")
+		return time.Time{}, err
 	}
 
-	_, err = statement.Exec()
-
+	var updatedAt int64
+	err = d.reader.QueryRowContext(ctx, lastUpdatedQuery, d.tenant, productName, categoryName).Scan(&updatedAt)
 	if err != nil {
-		return errors.Wrap(err, "error executing statement")
+		return time.Time{}, errors.Wrap(err, "error querying update time")
 	}
 
-	return nil
+	if updatedAt == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, updatedAt).UTC(), nil
+}
+
+// Close waits for running writes to finish and closes the database connections
+//
+// The connections are shared by the handles of all tenants, so closing any of them closes all.
+func (d *OffersSQLiteDatabase) Close() error {
+	d.writes.close()
+
+	err := d.reader.Close()
+	if werr := d.writer.Close(); werr != nil && err == nil {
+		err = werr
+	}
+	return err
 }
@@ -1,9 +1,10 @@
 package database
 
 import (
-	"database/sql"
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -27,23 +28,23 @@ func setupTestDatabase(t *testing.T, testData []Offer) Offers {
 }
 
 func insertDummyData(db Offers, testData []Offer) error {
-	return db.InsertMultiple(testData)
+	return db.InsertMultiple(context.Background(), testData)
 }
 
 func TestDatabase(t *testing.T) {
 	testData := []Offer{
-		{"Vogon Poetry", "Better not haves", "Hitchhiker Essentials", 1},
-		{"Babelfish", "Must Haves", "Hitchhiker Essentials", 1},
-		{"Towel", "Must Haves", "Hitchhiker Essentials, just more expensive", 43},
-		{"Towel", "Must Haves", "Hitchhiker Essentials, just more expensive", 44},
-		{"21 is only half the Truth", "Books", "Hitchhiker Essentials", 2},
-		{"Towel", "Must Haves", "Hitchhiker Essentials", 42},
+		{Product: "Vogon Poetry", Category: "Better not haves", Supplier: "Hitchhiker Essentials", Price: 1},
+		{Product: "Babelfish", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 1},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials, just more expensive", Price: 43},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials, just more expensive", Price: 44},
+		{Product: "21 is only half the Truth", Category: "Books", Supplier: "Hitchhiker Essentials", Price: 2},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
 	}
 
 	db := setupTestDatabase(t, testData)
 	defer db.Close()
 
-	offers, err := db.Get("Towel", "Must Haves")
+	offers, err := db.Get(context.Background(), "Towel", "Must Haves")
 	if err != nil {
 		t.Fatalf("Expected no error retrieving offers, got %v", err)
 	}
@@ -53,8 +54,8 @@ func TestDatabase(t *testing.T) {
 	}
 
 	expectedOffers := []Offer{
-		{"Towel", "Must Haves", "Hitchhiker Essentials", 42},
-		{"Towel", "Must Haves", "Hitchhiker Essentials, just more expensive", 44},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials, just more expensive", Price: 44},
 	}
 
 	if !reflect.DeepEqual(offers, expectedOffers) {
@@ -68,13 +69,13 @@ func TestOffersSQLiteDatabase_Insert(t *testing.T) {
 		t.Fatalf("Expected no error creating the database, got %s", err.Error())
 	}
 
-	err = db.Insert("mock", "mock", "mock", 0)
+	err = db.Insert(context.Background(), "mock", "mock", "mock", 0)
 	if err != nil {
 		t.Fatalf("Expected no error inserting an offer, got %s", err.Error())
 	}
 
-	database := (*sql.DB)(db)
-	rows, err := database.Query("SELECT * FROM offers")
+	database := db.reader
+	rows, err := database.Query("SELECT product, category, supplier, price FROM offers")
 	if err != nil {
 		t.Fatalf("Expected no error when querying offer, got %v", err)
 	}
@@ -95,7 +96,7 @@ func TestOffersSQLiteDatabase_Insert(t *testing.T) {
 		t.Fatalf("Expected no error when reconstructing, got %v", err)
 	}
 
-	want := Offer{"mock", "mock", "mock", 0}
+	want := Offer{Product: "mock", Category: "mock", Supplier: "mock", Price: 0}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("Expected the same offer back, but got %v", got)
 	}
@@ -105,7 +106,7 @@ func TestDatabase_withoutData(t *testing.T) {
 	db := setupTestDatabase(t, []Offer{})
 	defer db.Close()
 
-	offers, err := db.Get("Towel", "Must Haves")
+	offers, err := db.Get(context.Background(), "Towel", "Must Haves")
 	if err != nil {
 		t.Fatalf("Expected no error retrieving offers, got %v", err)
 	}
@@ -114,3 +115,28 @@ func TestDatabase_withoutData(t *testing.T) {
 		t.Fatalf("Expected to find no offers, got %d", len(offers))
 	}
 }
+
+func TestOffersSQLiteDatabase_LastUpdated(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	got, err := db.LastUpdated(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error without offers, got %v", err)
+	}
+	if !got.IsZero() {
+		t.Fatalf("Expected the zero time without offers, got %v", got)
+	}
+
+	before := time.Now()
+	if err = db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	got, err = db.LastUpdated(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving the update time, got %v", err)
+	}
+	if got.Before(before) || got.After(time.Now()) {
+		t.Fatalf("Expected the update time to be the time of the insert, got %v", got)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package database
+
+import "errors"
+
+// DiskSpace returns the space on the volume of the database
+//
+// It isn't supported on this platform and always returns an error.
+func (d *OffersSQLiteDatabase) DiskSpace() (DiskSpace, error) {
+	return DiskSpace{}, errors.New("disk space can't be read on this platform")
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin
+
+package database
+
+import (
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// DiskSpace returns the space on the volume of the database
+//
+// The free space is the space available to unprivileged users, which is what SQLite can use.
+func (d *OffersSQLiteDatabase) DiskSpace() (DiskSpace, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(filepath.Dir(d.path), &stat); err != nil {
+		return DiskSpace{}, errors.Wrap(err, "error reading disk space")
+	}
+
+	blockSize := uint64(stat.Bsize)
+	return DiskSpace{
+		Free:  stat.Bavail * blockSize,
+		Total: stat.Blocks * blockSize,
+	}, nil
+}
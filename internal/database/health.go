@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const healthQuery = "SELECT 1 FROM offers LIMIT 1"
+
+// Health describes the state of the database
+type Health struct {
+	// QuickCheck is the result of the latest scheduled quick check. It's zero until the first one
+	// has finished.
+	QuickCheck QuickCheckResult
+	// Readers and Writer are the statistics of the pools of read and write connections
+	Readers, Writer sql.DBStats
+}
+
+// QuickCheckResult is the result of SQLite's quick_check
+type QuickCheckResult struct {
+	Time     time.Time
+	Duration time.Duration
+	// Problems are the problems which were found. It's empty if the database is intact.
+	Problems []string
+	// Err is the error which prevented the check from finishing
+	Err error
+}
+
+// latestQuickCheck holds the result of the latest quick check
+type latestQuickCheck struct {
+	mu     sync.Mutex
+	result QuickCheckResult
+}
+
+func (c *latestQuickCheck) get() QuickCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result
+}
+
+func (c *latestQuickCheck) set(result QuickCheckResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.result = result
+}
+
+// Ping checks that connections for reading can be used
+//
+// The write connection isn't pinged, since it's busy for as long as a write runs, e.g. a bulk import
+// or a restore. ProbeWrite checks that writes can be committed.
+func (d *OffersSQLiteDatabase) Ping(ctx context.Context) error {
+	return errors.Wrap(d.reader.PingContext(ctx), "error pinging read connections")
+}
+
+// Health checks that the offers can be read and reports the result of the latest quick check
+// together with the statistics of the connection pools
+//
+// Returns an error if the database can't be read. Problems found by the quick check don't make the
+// database unhealthy, since restarting the service doesn't fix a corrupt file. They're reported by
+// LatestQuickCheck for the readiness instead. The health is reported in any case.
+func (d *OffersSQLiteDatabase) Health(ctx context.Context) (Health, error) {
+	health := Health{
+		QuickCheck: d.quickCheck.get(),
+		Readers:    d.reader.Stats(),
+		Writer:     d.writer.Stats(),
+	}
+
+	if err := d.Ping(ctx); err != nil {
+		return health, err
+	}
+
+	var one int
+	err := d.reader.QueryRowContext(ctx, healthQuery).Scan(&one)
+	if err != nil && err != sql.ErrNoRows {
+		return health, errors.Wrap(err, "error reading offers")
+	}
+	return health, nil
+}
+
+// LatestQuickCheck returns the result of the latest quick check. It's zero until the first one has
+// finished.
+func (d *OffersSQLiteDatabase) LatestQuickCheck() QuickCheckResult {
+	return d.quickCheck.get()
+}
+
+// QuickCheck runs SQLite's quick_check, which finds most kinds of corruption much faster than the
+// integrity check
+//
+// The result is reported by Health and LatestQuickCheck.
+func (d *OffersSQLiteDatabase) QuickCheck(ctx context.Context) QuickCheckResult {
+	start := time.Now()
+	problems, err := checkPragma(ctx, d.reader, "quick_check")
+	result := QuickCheckResult{Time: start, Duration: time.Since(start), Problems: problems, Err: err}
+
+	d.quickCheck.set(result)
+	return result
+}
+
+// RunQuickChecks runs the quick check right away and then in the interval until the context is done
+func (d *OffersSQLiteDatabase) RunQuickChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result := d.QuickCheck(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case result.Err != nil:
+			log.Printf("Error running the quick check of the database: %v", result.Err)
+		case len(result.Problems) > 0:
+			log.Printf("Quick check of the database found problems: %s", strings.Join(result.Problems, "; "))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
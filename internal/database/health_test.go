@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestOffersSQLiteDatabase_Health(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	if err := db.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected no error pinging the database, got %v", err)
+	}
+
+	health, err := db.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error checking the health, got %v", err)
+	}
+	if !health.QuickCheck.Time.IsZero() {
+		t.Fatalf("Expected no quick check before the first has run, got %+v", health.QuickCheck)
+	}
+	if health.Readers.MaxOpenConnections != DefaultOptions().MaxReadConnections {
+		t.Fatalf("Expected %d read connections at most, got %d",
+			DefaultOptions().MaxReadConnections, health.Readers.MaxOpenConnections)
+	}
+
+	result := db.QuickCheck(context.Background())
+	if result.Err != nil || len(result.Problems) > 0 {
+		t.Fatalf("Expected an intact database, got %+v", result)
+	}
+	health, err = db.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error checking the health, got %v", err)
+	}
+	if !health.QuickCheck.Time.Equal(result.Time) {
+		t.Fatalf("Expected the result of the latest quick check %+v, got %+v", result, health.QuickCheck)
+	}
+
+	// Problems found by the quick check are reported, but a restart doesn't fix them
+	db.quickCheck.set(QuickCheckResult{Problems: []string{"row 1 missing from index"}})
+	if health, err = db.Health(context.Background()); err != nil || len(health.QuickCheck.Problems) != 1 {
+		t.Fatalf("Expected the problems of a corrupt database without an error, got %+v, %v", health.QuickCheck, err)
+	}
+	if got := db.LatestQuickCheck(); len(got.Problems) != 1 {
+		t.Fatalf("Expected the problems of the latest quick check, got %+v", got)
+	}
+}
+
+func TestOffersSQLiteDatabase_HealthDuringWrites(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	// A long write holds the only write connection
+	started, release := make(chan struct{}), make(chan struct{})
+	go db.writes.write(func(db *sql.DB) error {
+		tx, err := db.Begin()
+		close(started)
+		if err != nil {
+			return err
+		}
+		<-release
+		return tx.Rollback()
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := db.Health(ctx); err != nil {
+		t.Fatalf("Expected the database to be healthy while a write runs, got %v", err)
+	}
+}
+
+func TestOffersSQLiteDatabase_HealthWithCancelledContext(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.Ping(ctx); err == nil {
+		t.Fatalf("Expected an error pinging with a cancelled context, got none")
+	}
+	if _, err := db.Health(ctx); err == nil {
+		t.Fatalf("Expected an error checking the health with a cancelled context, got none")
+	}
+}
+
+func TestOffersSQLiteDatabase_RunQuickChecks(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		db.RunQuickChecks(ctx, time.Hour)
+		close(done)
+	}()
+
+	// The first check runs right away
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		health, err := db.Health(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error checking the health, got %v", err)
+		}
+		if !health.QuickCheck.Time.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a quick check to run right away")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the quick checks to stop when the context is cancelled")
+	}
+}
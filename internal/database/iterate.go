@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const iterateOffersQuery = "SELECT product, category, supplier, price, quantity, availability, lead_time_days, updated_at FROM offers"
+
+// OfferFilter restricts the offers returned by Iterate. Zero values don't restrict the result.
+type OfferFilter struct {
+	Category string
+	Supplier string
+	// UpdatedSince only includes offers which were inserted or updated at or after that time
+	UpdatedSince time.Time
+}
+
+// OfferRecord is an offer together with the time it was last inserted or updated
+type OfferRecord struct {
+	Offer
+	UpdatedAt time.Time
+}
+
+// OfferIterator is a cursor over offers
+//
+// Call Next before every call to Record, and Err once Next returns false. The iterator must be
+// closed when it's no longer used.
+type OfferIterator interface {
+	Next() bool
+	Record() OfferRecord
+	Err() error
+	Close() error
+}
+
+// Iterate returns a cursor over all offers of the tenant matching the filter
+//
+// Rows are read from the database one by one as the cursor advances, so large result sets aren't
+// held in memory. The offers are ordered by product, category and supplier. Cancelling the context
+// stops the cursor, which Err then reports.
+func (d *OffersSQLiteDatabase) Iterate(ctx context.Context, filter OfferFilter) (OfferIterator, error) {
+	query, args := buildIterateQuery(d.tenant, filter)
+
+	rows, err := d.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying offers")
+	}
+
+	return &rowsOfferIterator{rows: rows}, nil
+}
+
+func buildIterateQuery(tenant string, filter OfferFilter) (string, []interface{}) {
+	conditions := []string{"tenant=?"}
+	args := []interface{}{tenant}
+
+	if filter.Category != "" {
+		conditions = append(conditions, "category=?")
+		args = append(args, filter.Category)
+	}
+	if filter.Supplier != "" {
+		conditions = append(conditions, "supplier=?")
+		args = append(args, filter.Supplier)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		conditions = append(conditions, "updated_at>=?")
+		args = append(args, filter.UpdatedSince.UnixNano())
+	}
+
+	query := iterateOffersQuery + " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY product, category, supplier"
+
+	return query, args
+}
+
+// rowsOfferIterator is an OfferIterator backed by database rows
+type rowsOfferIterator struct {
+	rows   *sql.Rows
+	record OfferRecord
+	err    error
+}
+
+func (it *rowsOfferIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+
+	var updatedAt int64
+	var stock nullStock
+	record := OfferRecord{}
+	err := it.rows.Scan(
+		&record.Product,
+		&record.Category,
+		&record.Supplier,
+		&record.Price,
+		&stock.quantity,
+		&stock.availability,
+		&stock.leadTimeDays,
+		&updatedAt,
+	)
+	if err != nil {
+		it.err = errors.Wrap(err, "error retrieving row")
+		return false
+	}
+
+	record.Stock = stock.stock()
+	record.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	it.record = record
+	return true
+}
+
+func (it *rowsOfferIterator) Record() OfferRecord {
+	return it.record
+}
+
+func (it *rowsOfferIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *rowsOfferIterator) Close() error {
+	return it.rows.Close()
+}
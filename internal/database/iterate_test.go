@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setupFileDatabase creates a database in a temporary file which isn't shared with other tests
+func setupFileDatabase(t *testing.T) *OffersSQLiteDatabase {
+	db, err := InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// collectOffers drains the iterator and returns the offers without their update times
+func collectOffers(t *testing.T, it OfferIterator) []Offer {
+	defer it.Close()
+
+	var offers []Offer
+	for it.Next() {
+		offers = append(offers, it.Record().Offer)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Expected no error iterating, got %v", err)
+	}
+	return offers
+}
+
+func TestOffersSQLiteDatabase_Iterate(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	err := db.InsertMultiple(context.Background(), []Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+		{Product: "Babelfish", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 1},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+		{Product: "21 is only half the Truth", Category: "Books", Supplier: "Hitchhiker Essentials", Price: 2},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		filter OfferFilter
+		want   []Offer
+	}{
+		{
+			name:   "no filter",
+			filter: OfferFilter{},
+			want: []Offer{
+				{Product: "21 is only half the Truth", Category: "Books", Supplier: "Hitchhiker Essentials", Price: 2},
+				{Product: "Babelfish", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 1},
+				{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+				{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+			},
+		},
+		{
+			name:   "category",
+			filter: OfferFilter{Category: "Books"},
+			want: []Offer{
+				{Product: "21 is only half the Truth", Category: "Books", Supplier: "Hitchhiker Essentials", Price: 2},
+			},
+		},
+		{
+			name:   "supplier and category",
+			filter: OfferFilter{Category: "Must Haves", Supplier: "Hitchhiker Knockoffs"},
+			want: []Offer{
+				{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+			},
+		},
+		{
+			name:   "updated in the future",
+			filter: OfferFilter{UpdatedSince: time.Now().Add(time.Hour)},
+			want:   nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		it, err := db.Iterate(context.Background(), testCase.filter)
+		if err != nil {
+			t.Fatalf("%s: expected no error iterating, got %v", testCase.name, err)
+		}
+
+		got := collectOffers(t, it)
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Fatalf("%s: expected %v, got %v", testCase.name, testCase.want, got)
+		}
+	}
+}
+
+func TestOffersSQLiteDatabase_IterateSetsUpdateTime(t *testing.T) {
+	db := setupFileDatabase(t)
+	before := time.Now()
+
+	err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42)
+	if err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	it, err := db.Iterate(context.Background(), OfferFilter{UpdatedSince: before})
+	if err != nil {
+		t.Fatalf("Expected no error iterating, got %v", err)
+	}
+	defer it.Close()
+
+	if !it.Next() {
+		t.Fatalf("Expected the new offer to be returned, got error %v", it.Err())
+	}
+
+	if updatedAt := it.Record().UpdatedAt; updatedAt.Before(before) || updatedAt.After(time.Now()) {
+		t.Fatalf("Expected the update time to be the time of the insert, got %v", updatedAt)
+	}
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	countOffersQuery           = "SELECT COUNT(*) FROM offers WHERE tenant=?"
+	countOffersByCategoryQuery = "SELECT category, COUNT(*) FROM offers WHERE tenant=? GROUP BY category ORDER BY category"
+	countOffersBySupplierQuery = "SELECT supplier, COUNT(*) FROM offers WHERE tenant=? GROUP BY supplier ORDER BY supplier"
+)
+
+// GroupCount is the number of offers sharing a value, e.g. the same category
+type GroupCount struct {
+	Name   string
+	Offers int
+}
+
+// Stats are statistics about the offers in the database
+type Stats struct {
+	Offers     int
+	ByCategory []GroupCount
+	BySupplier []GroupCount
+}
+
+// Vacuum rebuilds the database file, reclaiming unused space
+func (d *OffersSQLiteDatabase) Vacuum() error {
+	return d.writes.write(func(db *sql.DB) error {
+		_, err := db.Exec("VACUUM")
+		return errors.Wrap(err, "error vacuuming database")
+	})
+}
+
+// IntegrityCheck runs SQLite's integrity check on the database
+//
+// Returns the problems that were found. The result is empty if the database is intact.
+func (d *OffersSQLiteDatabase) IntegrityCheck() ([]string, error) {
+	return integrityCheck(d.reader)
+}
+
+// CheckIntegrity runs SQLite's integrity check on the database file at path without modifying it
+//
+// Returns the problems that were found. The result is empty if the database is intact.
+func CheckIntegrity(path string) ([]string, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening database")
+	}
+	defer db.Close()
+
+	return integrityCheck(db)
+}
+
+func integrityCheck(db *sql.DB) ([]string, error) {
+	return checkPragma(context.Background(), db, "integrity_check")
+}
+
+// checkPragma runs one of SQLite's integrity checks and returns the problems that were found
+func checkPragma(ctx context.Context, db *sql.DB, pragma string) (problems []string, err error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA "+pragma)
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking integrity")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		var result string
+		err = rows.Scan(&result)
+		if err != nil {
+			return nil, errors.Wrap(err, "error retrieving row")
+		}
+		// An intact database returns a single row saying "ok"
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Stats counts the offers of the tenant, in total and by category and supplier
+func (d *OffersSQLiteDatabase) Stats() (Stats, error) {
+	db := d.reader
+	stats := Stats{}
+
+	err := db.QueryRow(countOffersQuery, d.tenant).Scan(&stats.Offers)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "error counting offers")
+	}
+
+	stats.ByCategory, err = groupCounts(db, countOffersByCategoryQuery, d.tenant)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.BySupplier, err = groupCounts(db, countOffersBySupplierQuery, d.tenant)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func groupCounts(db *sql.DB, query, tenant string) (counts []GroupCount, err error) {
+	rows, err := db.Query(query, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "error counting offers")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		count := GroupCount{}
+		err = rows.Scan(&count.Name, &count.Offers)
+		if err != nil {
+			return nil, errors.Wrap(err, "error retrieving row")
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_Stats(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	err := db.InsertMultiple(context.Background(), []Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40},
+		{Product: "Babelfish", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 1},
+		{Product: "21 is only half the Truth", Category: "Books", Supplier: "Hitchhiker Essentials", Price: 2},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+
+	got, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Expected no error computing stats, got %v", err)
+	}
+
+	want := Stats{
+		Offers:     4,
+		ByCategory: []GroupCount{{"Books", 1}, {"Must Haves", 3}},
+		BySupplier: []GroupCount{{"Hitchhiker Essentials", 3}, {"Hitchhiker Knockoffs", 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected stats %v, got %v", want, got)
+	}
+}
+
+func TestOffersSQLiteDatabase_IntegrityCheckAndVacuum(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	if err := db.Vacuum(); err != nil {
+		t.Fatalf("Expected no error vacuuming, got %v", err)
+	}
+
+	problems, err := db.IntegrityCheck()
+	if err != nil {
+		t.Fatalf("Expected no error checking integrity, got %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("Expected no problems in a new database, got %v", problems)
+	}
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// migrations are the statements which bring the schema up to date, in order.
+//
+// The schema version is kept in SQLite's user_version pragma and is the number of migrations which
+// have been applied. Only ever append to this list. Databases created before versioning was
+// introduced already have the offers table, which is why the first statement must be idempotent.
+var migrations = []string{
+	"CREATE TABLE IF NOT EXISTS offers (product TEXT NOT NULL, category TEXT NOT NULL, supplier TEXT NOT NULL, price REAL NOT NULL, PRIMARY KEY (product, category, supplier))",
+	// updated_at holds the time of the last insert or update as nanoseconds since the Unix epoch.
+	// Offers imported before the column existed have the value 0.
+	"ALTER TABLE offers ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0",
+	"CREATE INDEX IF NOT EXISTS offers_updated_at ON offers (updated_at)",
+	// Webhook subscriptions. Empty product and category filters match all offers.
+	"CREATE TABLE webhooks (id INTEGER PRIMARY KEY AUTOINCREMENT, url TEXT NOT NULL, product TEXT NOT NULL, category TEXT NOT NULL, secret TEXT NOT NULL, created_at INTEGER NOT NULL)",
+	"CREATE TABLE webhook_deliveries (id INTEGER PRIMARY KEY AUTOINCREMENT, webhook_id INTEGER NOT NULL, event_id TEXT NOT NULL, attempt INTEGER NOT NULL, status_code INTEGER NOT NULL, error TEXT NOT NULL, duration INTEGER NOT NULL, created_at INTEGER NOT NULL)",
+	"CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id)",
+	"CREATE TABLE webhook_dead_letters (id INTEGER PRIMARY KEY AUTOINCREMENT, webhook_id INTEGER NOT NULL, event_id TEXT NOT NULL, payload BLOB NOT NULL, attempts INTEGER NOT NULL, last_error TEXT NOT NULL, created_at INTEGER NOT NULL)",
+	// Price alerts. state_changed_at is 0 until an alert fires for the first time.
+	"CREATE TABLE alerts (id INTEGER PRIMARY KEY AUTOINCREMENT, product TEXT NOT NULL, category TEXT NOT NULL, target_price REAL NOT NULL, state TEXT NOT NULL, state_changed_at INTEGER NOT NULL, created_at INTEGER NOT NULL)",
+	"CREATE INDEX alerts_product_category ON alerts (product, category)",
+	// The single row written by readiness probes to check that writes can be committed
+	"CREATE TABLE write_probes (id INTEGER PRIMARY KEY CHECK (id = 1), probed_at INTEGER NOT NULL)",
+	// Offers belong to tenants. SQLite can't change the primary key of a table, so it's rebuilt.
+	// Existing offers belong to the default tenant.
+	`CREATE TABLE offers_by_tenant (tenant TEXT NOT NULL, product TEXT NOT NULL, category TEXT NOT NULL, supplier TEXT NOT NULL, price REAL NOT NULL, updated_at INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (tenant, product, category, supplier));
+	INSERT INTO offers_by_tenant (tenant, product, category, supplier, price, updated_at) SELECT 'default', product, category, supplier, price, updated_at FROM offers;
+	DROP TABLE offers;
+	ALTER TABLE offers_by_tenant RENAME TO offers;
+	CREATE INDEX offers_updated_at ON offers (tenant, updated_at)`,
+	// Other names of products. alias_key is the alias in lower case without repeated spaces.
+	`CREATE TABLE product_aliases (tenant TEXT NOT NULL, alias_key TEXT NOT NULL, alias TEXT NOT NULL, product TEXT NOT NULL, created_at INTEGER NOT NULL, PRIMARY KEY (tenant, alias_key))`,
+	// The category tree. Offers refer to categories by name, so moving a category only changes its
+	// parent. parent_id is 0 for top-level categories.
+	`CREATE TABLE categories (id INTEGER PRIMARY KEY AUTOINCREMENT, tenant TEXT NOT NULL, name TEXT NOT NULL, parent_id INTEGER NOT NULL, created_at INTEGER NOT NULL, UNIQUE (tenant, name));
+	CREATE INDEX categories_parent_id ON categories (tenant, parent_id)`,
+	// Supplier profiles. Offers refer to suppliers by name, which can't be changed, and suppliers
+	// are added when they make their first offer. metadata is a JSON object of strings.
+	`CREATE TABLE suppliers (id INTEGER PRIMARY KEY AUTOINCREMENT, tenant TEXT NOT NULL, name TEXT NOT NULL, display_name TEXT NOT NULL, email TEXT NOT NULL, phone TEXT NOT NULL, status TEXT NOT NULL, metadata TEXT NOT NULL, created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL, UNIQUE (tenant, name));
+	INSERT INTO suppliers (tenant, name, display_name, email, phone, status, metadata, created_at, updated_at) SELECT tenant, supplier, supplier, '', '', 'active', '{}', MIN(updated_at), MIN(updated_at) FROM offers GROUP BY tenant, supplier`,
+	// The stock of offers. Every column is null unless the supplier reports it.
+	`ALTER TABLE offers ADD COLUMN quantity INTEGER;
+	ALTER TABLE offers ADD COLUMN availability TEXT;
+	ALTER TABLE offers ADD COLUMN lead_time_days INTEGER`,
+}
+
+// migrate applies all migrations which haven't been applied yet
+//
+// Each migration runs in its own transaction together with the update of the schema version.
+func migrate(db *sql.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		err = applyMigration(db, i)
+		if err != nil {
+			return errors.Wrapf(err, "error applying migration %d", i+1)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, index int) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "error beginning transaction")
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.Exec(migrations[index])
+	if err != nil {
+		return err
+	}
+
+	// PRAGMA statements don't support placeholders
+	_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", index+1))
+	return err
+}
+
+// schemaVersion returns the number of migrations that have been applied to the database
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return 0, errors.Wrap(err, "error reading schema version")
+	}
+	return version, nil
+}
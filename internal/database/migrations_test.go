@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate_fromUnversionedSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offers.db")
+
+	// Create a database the way it was done before the schema was versioned
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Expected no error opening the database, got %v", err)
+	}
+	_, err = legacy.Exec(migrations[0])
+	if err == nil {
+		_, err = legacy.Exec("INSERT INTO offers VALUES ('Towel', 'Must Haves', 'Hitchhiker Essentials', 42)")
+	}
+	if err != nil {
+		t.Fatalf("Expected no error setting up the legacy schema, got %v", err)
+	}
+	legacy.Close()
+
+	db, err := InitSQLiteDatabase(path)
+	if err != nil {
+		t.Fatalf("Expected no error migrating the database, got %v", err)
+	}
+	defer db.Close()
+
+	version, err := schemaVersion(db.writer)
+	if err != nil {
+		t.Fatalf("Expected no error reading the schema version, got %v", err)
+	}
+	if version != len(migrations) {
+		t.Fatalf("Expected schema version %d, got %d", len(migrations), version)
+	}
+
+	offers, err := db.Get(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving offers, got %v", err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("Expected the existing offer to survive the migration, got %d offers", len(offers))
+	}
+}
+
+func TestMigrate_isIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offers.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := InitSQLiteDatabase(path)
+		if err != nil {
+			t.Fatalf("Expected no error opening the database for the %d. time, got %v", i+1, err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const probeWriteStmt = "INSERT INTO write_probes (id, probed_at) VALUES (1, ?) ON CONFLICT(id) DO UPDATE SET probed_at=EXCLUDED.probed_at"
+
+// DiskSpace is the space on the volume of the database in bytes
+type DiskSpace struct {
+	Free, Total uint64
+}
+
+// ReadinessProber is implemented by databases which can check that they're ready to serve requests
+type ReadinessProber interface {
+	// PendingMigrations returns the number of migrations which haven't been applied yet
+	PendingMigrations() (int, error)
+	// ProbeWrite checks that a write can be committed before the context is done
+	ProbeWrite(ctx context.Context) error
+	// DiskSpace returns the space on the volume of the database
+	DiskSpace() (DiskSpace, error)
+	// LatestQuickCheck returns the result of the latest check for corruption
+	LatestQuickCheck() QuickCheckResult
+}
+
+// PendingMigrations returns the number of migrations which haven't been applied yet
+//
+// Returns an error if the schema is newer than this version of the service knows, e.g. because a
+// newer version has migrated the database during a rollout.
+func (d *OffersSQLiteDatabase) PendingMigrations() (int, error) {
+	version, err := schemaVersion(d.reader)
+	if err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("schema version %d is newer than the latest known version %d", version, len(migrations))
+	}
+	return len(migrations) - version, nil
+}
+
+// ProbeWrite checks that a write can be committed by updating the single row of the probe table
+//
+// The write is queued like all others, so it also fails if writes are stuck. It's given up when the
+// context is done, so that a stuck probe doesn't outlive the health check.
+func (d *OffersSQLiteDatabase) ProbeWrite(ctx context.Context) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return d.writes.writeContext(ctx, func(db *sql.DB) error {
+		_, err := db.ExecContext(ctx, probeWriteStmt, time.Now().UnixNano())
+		return errors.Wrap(err, "error writing probe")
+	})
+}
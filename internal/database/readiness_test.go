@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_PendingMigrations(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		t.Fatalf("Expected no error reading pending migrations, got %v", err)
+	}
+	if pending != 0 {
+		t.Fatalf("Expected no pending migrations after opening the database, got %d", pending)
+	}
+
+	// A newer version of the service migrated the database
+	if _, err = db.writer.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations)+1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.PendingMigrations(); err == nil {
+		t.Fatalf("Expected an error for a newer schema, got none")
+	}
+
+	if _, err = db.writer.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(migrations)-2)); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = db.PendingMigrations()
+	if err != nil {
+		t.Fatalf("Expected no error reading pending migrations, got %v", err)
+	}
+	if pending != 2 {
+		t.Fatalf("Expected 2 pending migrations, got %d", pending)
+	}
+}
+
+func TestOffersSQLiteDatabase_ProbeWrite(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := db.ProbeWrite(ctx); err != nil {
+			t.Fatalf("Expected no error probing writes, got %v", err)
+		}
+	}
+
+	var rows int
+	if err := db.reader.QueryRow("SELECT COUNT(*) FROM write_probes").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Fatalf("Expected the probe to keep a single row, got %d", rows)
+	}
+
+	// The probe is given up when the health check is
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := db.ProbeWrite(cancelled); err == nil {
+		t.Fatalf("Expected an error probing with a cancelled context, got none")
+	}
+
+	db.Close()
+	if err := db.ProbeWrite(ctx); err == nil {
+		t.Fatalf("Expected an error probing a closed database, got none")
+	}
+}
+
+func TestOffersSQLiteDatabase_DiskSpace(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	space, err := db.DiskSpace()
+	if err != nil {
+		t.Fatalf("Expected no error reading disk space, got %v", err)
+	}
+	if space.Total == 0 || space.Free > space.Total {
+		t.Fatalf("Expected free space within a non-empty volume, got %+v", space)
+	}
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	getOfferStateQuery = "SELECT price, quantity, availability, lead_time_days FROM offers WHERE tenant=? AND product=? AND category=? AND supplier=?"
+	updateStockStmt    = "UPDATE offers SET quantity=?, availability=?, lead_time_days=?, updated_at=? WHERE tenant=? AND product=? AND category=? AND supplier=?"
+)
+
+// Availability is whether a supplier can deliver an offer
+type Availability string
+
+// The availabilities of offers
+const (
+	InStock Availability = "in_stock"
+	// Backorder is the availability of offers which can be ordered, but are delivered once the
+	// supplier has restocked
+	Backorder  Availability = "backorder"
+	OutOfStock Availability = "out_of_stock"
+)
+
+// Valid returns whether the availability is known. The empty availability is valid and means the
+// supplier doesn't report it.
+func (a Availability) Valid() bool {
+	switch a {
+	case "", InStock, Backorder, OutOfStock:
+		return true
+	}
+	return false
+}
+
+// Stock is how much of an offer a supplier has and how soon it's delivered. Every field is optional
+// and only set if the supplier reports it.
+type Stock struct {
+	Quantity     *int
+	Availability Availability
+	// LeadTimeDays is the number of days until the offer is delivered
+	LeadTimeDays *int
+}
+
+// Status returns the availability of the offer. Without a reported availability, it's derived
+// from the quantity, and it's empty if the quantity isn't reported either.
+func (s Stock) Status() Availability {
+	switch {
+	case s.Availability != "":
+		return s.Availability
+	case s.Quantity == nil:
+		return ""
+	case *s.Quantity > 0:
+		return InStock
+	default:
+		return OutOfStock
+	}
+}
+
+// Available returns whether the offer can be ordered. Offers without a reported stock are assumed
+// to be available.
+func (s Stock) Available() bool {
+	return s.Status() != OutOfStock
+}
+
+// StockField is a field of the stock which can be cleared
+type StockField string
+
+// The fields of the stock
+const (
+	QuantityField     StockField = "quantity"
+	AvailabilityField StockField = "availability"
+	LeadTimeDaysField StockField = "leadTimeDays"
+)
+
+// StockUpdate changes the stock of an offer. The fields which are set in the stock replace the
+// stored ones, and the fields in Clear are removed.
+type StockUpdate struct {
+	Stock
+	Clear []StockField
+}
+
+// apply returns the stock with the update applied
+//
+// An availability which was reported before is cleared when the quantity changes without one, so
+// that the status is derived from the new quantity rather than sticking to the old availability.
+func (s Stock) apply(update StockUpdate) Stock {
+	for _, field := range update.Clear {
+		switch field {
+		case QuantityField:
+			s.Quantity = nil
+		case AvailabilityField:
+			s.Availability = ""
+		case LeadTimeDaysField:
+			s.LeadTimeDays = nil
+		}
+	}
+
+	if update.Quantity != nil {
+		s.Quantity = update.Quantity
+		s.Availability = update.Availability
+	}
+	if update.Availability != "" {
+		s.Availability = update.Availability
+	}
+	if update.LeadTimeDays != nil {
+		s.LeadTimeDays = update.LeadTimeDays
+	}
+	return s
+}
+
+// equal returns whether both stocks report the same
+func (s Stock) equal(other Stock) bool {
+	return equalInts(s.Quantity, other.Quantity) &&
+		s.Availability == other.Availability &&
+		equalInts(s.LeadTimeDays, other.LeadTimeDays)
+}
+
+func equalInts(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// values returns the column values of the stock, which are null for what isn't reported
+func (s Stock) values() []interface{} {
+	availability := sql.NullString{String: string(s.Availability), Valid: s.Availability != ""}
+	return []interface{}{s.Quantity, availability, s.LeadTimeDays}
+}
+
+// nullStock is a stock read from its nullable columns
+type nullStock struct {
+	quantity, leadTimeDays sql.NullInt64
+	availability           sql.NullString
+}
+
+// dest returns the scan destinations of the quantity, availability and lead time columns
+func (n *nullStock) dest() []interface{} {
+	return []interface{}{&n.quantity, &n.availability, &n.leadTimeDays}
+}
+
+func (n nullStock) stock() Stock {
+	stock := Stock{Availability: Availability(n.availability.String)}
+	if n.quantity.Valid {
+		quantity := int(n.quantity.Int64)
+		stock.Quantity = &quantity
+	}
+	if n.leadTimeDays.Valid {
+		leadTimeDays := int(n.leadTimeDays.Int64)
+		stock.LeadTimeDays = &leadTimeDays
+	}
+	return stock
+}
+
+// StockUpdater is implemented by databases which can change the stock of an offer without its price
+type StockUpdater interface {
+	// UpdateStock applies the update to the stock of the offer and returns the offer. The product
+	// may be an alias. Returns ErrNotFound if there is no such offer.
+	UpdateStock(ctx context.Context, productName, categoryName, supplierName string, update StockUpdate) (Offer, error)
+}
+
+// UpdateStock applies the update to the stock of the offer and returns the offer
+//
+// The product may be an alias. Returns ErrNotFound if there is no such offer. Change listeners are
+// notified if the stock changed.
+func (d *OffersSQLiteDatabase) UpdateStock(ctx context.Context, productName, categoryName, supplierName string, update StockUpdate) (Offer, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	offer := Offer{Category: categoryName, Supplier: supplierName}
+	var changes []Change
+	now := time.Now()
+
+	err := d.writes.writeTx(ctx, func(tx *sql.Tx) (err error) {
+		offer.Product, err = resolveProduct(ctx, tx, d.tenant, productName)
+		if err != nil {
+			return err
+		}
+
+		// Offers which can be ordered again may be the new cheapest offer
+		key := productKey{d.tenant, offer.Product, categoryName}
+		cheapest, _, err := minPrice(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		var previous nullStock
+		err = tx.QueryRowContext(ctx, getOfferStateQuery, d.tenant, offer.Product, categoryName, supplierName).
+			Scan(append([]interface{}{&offer.Price}, previous.dest()...)...)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "error querying existing offer")
+		}
+
+		offer.Stock = previous.stock().apply(update)
+		if offer.Stock.equal(previous.stock()) {
+			return nil
+		}
+
+		args := append(offer.Stock.values(), now.UnixNano(), d.tenant, offer.Product, categoryName, supplierName)
+		if _, err = tx.ExecContext(ctx, updateStockStmt, args...); err != nil {
+			return errors.Wrap(err, "error updating stock")
+		}
+
+		changes = []Change{{
+			Type:          OfferUpdated,
+			Tenant:        d.tenant,
+			Offer:         offer,
+			PreviousPrice: offer.Price,
+			Time:          now,
+		}}
+		if err = markSuspended(ctx, tx, changes); err != nil {
+			return err
+		}
+		return markCheapest(ctx, tx, changes, map[productKey]sql.NullFloat64{key: cheapest})
+	})
+	if err != nil {
+		return Offer{}, err
+	}
+
+	d.listeners.notify(changes)
+	return offer, nil
+}
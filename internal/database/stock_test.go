@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestOffersSQLiteDatabase_Stock(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	changes := recordChanges(t, db)
+
+	towel := Offer{
+		Product:  "Towel",
+		Category: "Must Haves",
+		Supplier: "Hitchhiker Essentials",
+		Price:    42,
+		Stock:    Stock{Quantity: intPtr(3), LeadTimeDays: intPtr(2)},
+	}
+	if err := db.InsertMultiple(ctx, []Offer{towel}); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	// Updating the price keeps the stock which isn't part of the update
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 40); err != nil {
+		t.Fatalf("Expected no error updating an offer, got %v", err)
+	}
+	offers, err := db.Get(ctx, "Towel", "Must Haves")
+	if err != nil || len(offers) != 1 {
+		t.Fatalf("Expected one offer, got %+v, %v", offers, err)
+	}
+	if got := offers[0]; got.Price != 40 || !got.Stock.equal(towel.Stock) || got.Status() != InStock {
+		t.Fatalf("Expected the updated price with the stock, got %+v", got)
+	}
+
+	offer, err := db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", StockUpdate{Stock: Stock{Quantity: intPtr(0)}})
+	if err != nil {
+		t.Fatalf("Expected no error updating the stock, got %v", err)
+	}
+	if offer.Price != 40 || *offer.Quantity != 0 || *offer.LeadTimeDays != 2 || offer.Available() {
+		t.Fatalf("Expected an unavailable offer with its price and lead time, got %+v", offer)
+	}
+
+	offer, err = db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", StockUpdate{Stock: Stock{Availability: Backorder}})
+	if err != nil || offer.Status() != Backorder || !offer.Available() {
+		t.Fatalf("Expected an offer on backorder, got %+v, %v", offer, err)
+	}
+
+	// Unchanged stock doesn't notify
+	if _, err = db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", StockUpdate{Stock: Stock{Availability: Backorder}}); err != nil {
+		t.Fatalf("Expected no error updating the stock, got %v", err)
+	}
+	if got := changes(); len(got) != 4 || got[3].Type != OfferUpdated || got[3].PreviousPrice != 40 {
+		t.Fatalf("Expected a change for the insert, the price and both stock updates, got %+v", got)
+	}
+
+	if _, err = db.UpdateStock(ctx, "Guide", "Must Haves", "Hitchhiker Essentials", StockUpdate{}); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a missing offer, got %v", err)
+	}
+}
+
+func TestOffersSQLiteDatabase_UpdateStock_transitions(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		update StockUpdate
+		want   Availability
+	}{
+		{"reported out of stock", StockUpdate{Stock: Stock{Availability: OutOfStock, LeadTimeDays: intPtr(5)}}, OutOfStock},
+		{"restocked", StockUpdate{Stock: Stock{Quantity: intPtr(10)}}, InStock},
+		{"reported in stock", StockUpdate{Stock: Stock{Availability: InStock}}, InStock},
+		{"sold out", StockUpdate{Stock: Stock{Quantity: intPtr(0)}}, OutOfStock},
+		{"quantity with availability", StockUpdate{Stock: Stock{Quantity: intPtr(0), Availability: Backorder}}, Backorder},
+		{"cleared", StockUpdate{Clear: []StockField{QuantityField, AvailabilityField}}, ""},
+	}
+
+	for _, step := range steps {
+		offer, err := db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", step.update)
+		if err != nil {
+			t.Fatalf("%s: expected no error updating the stock, got %v", step.name, err)
+		}
+		if offer.Status() != step.want || offer.LeadTimeDays == nil {
+			t.Fatalf("%s: expected status %q with the lead time, got %+v", step.name, step.want, offer)
+		}
+	}
+
+	offer, err := db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", StockUpdate{Clear: []StockField{LeadTimeDaysField}})
+	if err != nil || offer.LeadTimeDays != nil {
+		t.Fatalf("Expected the lead time to be cleared, got %+v, %v", offer, err)
+	}
+
+	// Offers which are reinserted with a quantity get their status from it, too
+	if _, err = db.UpdateStock(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", StockUpdate{Stock: Stock{Availability: OutOfStock}}); err != nil {
+		t.Fatalf("Expected no error updating the stock, got %v", err)
+	}
+	towel := Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42, Stock: Stock{Quantity: intPtr(3)}}
+	if err = db.InsertMultiple(ctx, []Offer{towel}); err != nil {
+		t.Fatalf("Expected no error updating the offer, got %v", err)
+	}
+	offers, err := db.Get(ctx, "Towel", "Must Haves")
+	if err != nil || len(offers) != 1 || offers[0].Status() != InStock {
+		t.Fatalf("Expected the offer to be in stock, got %+v, %v", offers, err)
+	}
+}
+
+func TestStock_Status(t *testing.T) {
+	testCases := []struct {
+		name  string
+		stock Stock
+		want  Availability
+	}{
+		{"unknown", Stock{}, ""},
+		{"in stock", Stock{Quantity: intPtr(1)}, InStock},
+		{"sold out", Stock{Quantity: intPtr(0)}, OutOfStock},
+		{"reported availability", Stock{Quantity: intPtr(0), Availability: Backorder}, Backorder},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := testCase.stock.Status(); got != testCase.want {
+				t.Fatalf("Expected %q, got %q", testCase.want, got)
+			}
+		})
+	}
+}
+
+func TestOffersSQLiteDatabase_cheapestOrderableOffers(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+	changes := recordChanges(t, db)
+
+	offer := func(supplier string, price float32, quantity int) Offer {
+		return Offer{
+			Product: "Towel", Category: "Must Haves", Supplier: supplier, Price: price,
+			Stock: Stock{Quantity: intPtr(quantity)},
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		offers []Offer
+		want   []bool
+	}{
+		{"out of stock offer", []Offer{offer("a", 30, 0)}, []bool{false}},
+		{"more expensive offer in stock", []Offer{offer("b", 42, 1)}, []bool{true}},
+		{"cheaper offer out of stock", []Offer{offer("c", 40, 0)}, []bool{false}},
+		{"offer in stock at the price of one out of stock", []Offer{offer("d", 40, 1)}, []bool{true}},
+	}
+
+	for _, testCase := range testCases {
+		if err := db.InsertMultiple(ctx, testCase.offers); err != nil {
+			t.Fatalf("%s: expected no error inserting offers, got %v", testCase.name, err)
+		}
+
+		got := changes()
+		if len(got) != len(testCase.want) {
+			t.Fatalf("%s: got changes %+v, want %d", testCase.name, got, len(testCase.want))
+		}
+		for i, change := range got {
+			if change.Cheapest != testCase.want[i] {
+				t.Fatalf("%s: got cheapest %t for %+v, want %t", testCase.name, change.Cheapest, change.Offer, testCase.want[i])
+			}
+		}
+	}
+
+	// An offer which is back in stock may be the new cheapest offer
+	if _, err := db.UpdateStock(ctx, "Towel", "Must Haves", "a", StockUpdate{Stock: Stock{Quantity: intPtr(5)}}); err != nil {
+		t.Fatalf("Expected no error updating the stock, got %v", err)
+	}
+	if got := changes(); len(got) != 1 || !got[0].Cheapest {
+		t.Fatalf("Expected the restocked offer to be the cheapest, got %+v", got)
+	}
+}
@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	supplierColumns      = "id, name, display_name, email, phone, status, metadata, created_at, updated_at"
+	insertSupplierStmt   = "INSERT INTO suppliers (tenant, name, display_name, email, phone, status, metadata, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	registerSupplierStmt = "INSERT INTO suppliers (tenant, name, display_name, email, phone, status, metadata, created_at, updated_at) VALUES (?, ?, ?, '', '', '" + string(SupplierActive) + "', '{}', ?, ?) ON CONFLICT(tenant, name) DO NOTHING"
+	getSupplierQuery     = "SELECT " + supplierColumns + " FROM suppliers WHERE tenant=? AND id=?"
+	supplierByNameQuery  = "SELECT id FROM suppliers WHERE tenant=? AND name=?"
+	listSuppliersQuery   = "SELECT " + supplierColumns + " FROM suppliers WHERE tenant=? ORDER BY id"
+	suppliersByNameQuery = "SELECT " + supplierColumns + " FROM suppliers WHERE tenant=? AND name IN "
+	updateSupplierStmt   = "UPDATE suppliers SET display_name=?, email=?, phone=?, status=?, metadata=?, updated_at=? WHERE tenant=? AND id=?"
+	countSupplierOffers  = "SELECT COUNT(*) FROM offers WHERE tenant=? AND supplier=?"
+	supplierStatusQuery  = "SELECT status FROM suppliers WHERE tenant=? AND name=?"
+	supplierOffersQuery  = "SELECT product, category, supplier, price, quantity, availability, lead_time_days FROM offers WHERE tenant=? AND supplier=?"
+	touchOffersStmt      = "UPDATE offers SET updated_at=? WHERE tenant=? AND supplier=?"
+	deleteSupplierStmt   = "DELETE FROM suppliers WHERE tenant=? AND id=?"
+)
+
+// maxSuppliersPerLookup is the number of names looked up in one query. SQLite limits the number of
+// placeholders in a statement.
+const maxSuppliersPerLookup = 500
+
+var (
+	// ErrSupplierNotFound is returned if a supplier with the given ID doesn't exist
+	ErrSupplierNotFound = errors.New("supplier not found")
+	// ErrSupplierExists is returned if a supplier with the same name already exists
+	ErrSupplierExists = errors.New("supplier already exists")
+	// ErrSupplierHasOffers is returned if a supplier which should be deleted still has offers
+	ErrSupplierHasOffers = errors.New("supplier has offers")
+)
+
+// SupplierStatus is whether the offers of a supplier are shown
+type SupplierStatus string
+
+// The states of a supplier
+const (
+	// SupplierActive is the status of suppliers whose offers are shown
+	SupplierActive SupplierStatus = "active"
+	// SupplierSuspended is the status of suppliers whose offers are hidden from searches
+	SupplierSuspended SupplierStatus = "suspended"
+)
+
+// Valid returns whether the status is known
+func (s SupplierStatus) Valid() bool {
+	return s == SupplierActive || s == SupplierSuspended
+}
+
+// SupplierProfiles is implemented by databases which keep a profile of every supplier
+//
+// Offers refer to suppliers by name. Suppliers get a profile with a stable ID when they make their
+// first offer, or when it's added before.
+type SupplierProfiles interface {
+	AddSupplier(ctx context.Context, supplier Supplier) (Supplier, error)
+	Supplier(ctx context.Context, id int64) (Supplier, error)
+	ListSuppliers(ctx context.Context) ([]Supplier, error)
+	// UpdateSupplier changes everything but the name of the supplier with the ID
+	UpdateSupplier(ctx context.Context, supplier Supplier) (Supplier, error)
+	DeleteSupplier(ctx context.Context, id int64) error
+	// SuppliersByName returns the profiles of the suppliers with the names, by name. Suppliers
+	// without a profile are left out.
+	SuppliersByName(ctx context.Context, names []string) (map[string]Supplier, error)
+}
+
+// Supplier is the profile of a supplier making offers
+type Supplier struct {
+	ID int64
+	// Name is the name of the supplier in its offers. It can't be changed.
+	Name        string
+	DisplayName string
+	Email       string
+	Phone       string
+	Status      SupplierStatus
+	Metadata    map[string]string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// registerSuppliers adds profiles for the suppliers of the offers which don't have one yet
+func registerSuppliers(ctx context.Context, tx *sql.Tx, tenant string, offers []Offer, now time.Time) error {
+	registered := make(map[string]bool)
+	for _, offer := range offers {
+		if registered[offer.Supplier] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, registerSupplierStmt, tenant, offer.Supplier, offer.Supplier, now.UnixNano(), now.UnixNano())
+		if err != nil {
+			return errors.Wrap(err, "error registering supplier")
+		}
+		registered[offer.Supplier] = true
+	}
+	return nil
+}
+
+// AddSupplier adds the profile of a supplier of the tenant
+//
+// The display name defaults to the name, the status to active. Returns ErrSupplierExists if the
+// tenant already has a supplier with the name, e.g. because it has made offers.
+func (d *OffersSQLiteDatabase) AddSupplier(ctx context.Context, supplier Supplier) (Supplier, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	if supplier.DisplayName == "" {
+		supplier.DisplayName = supplier.Name
+	}
+	if supplier.Status == "" {
+		supplier.Status = SupplierActive
+	}
+	supplier.CreatedAt = time.Now().UTC()
+	supplier.UpdatedAt = supplier.CreatedAt
+
+	metadata, err := encodeMetadata(supplier.Metadata)
+	if err != nil {
+		return Supplier{}, err
+	}
+
+	err = d.writes.writeTx(ctx, func(tx *sql.Tx) error {
+		var existing int64
+		err := tx.QueryRowContext(ctx, supplierByNameQuery, d.tenant, supplier.Name).Scan(&existing)
+		if err == nil {
+			return ErrSupplierExists
+		}
+		if err != sql.ErrNoRows {
+			return errors.Wrap(err, "error querying supplier")
+		}
+
+		result, err := tx.ExecContext(
+			ctx, insertSupplierStmt,
+			d.tenant, supplier.Name, supplier.DisplayName, supplier.Email, supplier.Phone, supplier.Status, metadata,
+			supplier.CreatedAt.UnixNano(), supplier.UpdatedAt.UnixNano(),
+		)
+		if err != nil {
+			return errors.Wrap(err, "error inserting supplier")
+		}
+		supplier.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return Supplier{}, err
+	}
+	return supplier, nil
+}
+
+// Supplier returns the supplier of the tenant with the ID. Returns ErrSupplierNotFound if it
+// doesn't exist.
+func (d *OffersSQLiteDatabase) Supplier(ctx context.Context, id int64) (Supplier, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return getSupplier(ctx, d.reader, d.tenant, id)
+}
+
+func getSupplier(ctx context.Context, db rowQuerier, tenant string, id int64) (Supplier, error) {
+	supplier, err := scanSupplier(db.QueryRowContext(ctx, getSupplierQuery, tenant, id))
+	if err == sql.ErrNoRows {
+		return Supplier{}, ErrSupplierNotFound
+	}
+	return supplier, err
+}
+
+// ListSuppliers returns all suppliers of the tenant, ordered by ID
+func (d *OffersSQLiteDatabase) ListSuppliers(ctx context.Context) ([]Supplier, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	suppliers := []Supplier{}
+	err := d.querySuppliers(ctx, listSuppliersQuery, func(supplier Supplier) {
+		suppliers = append(suppliers, supplier)
+	}, d.tenant)
+	return suppliers, err
+}
+
+// SuppliersByName returns the suppliers of the tenant with the names, by name
+func (d *OffersSQLiteDatabase) SuppliersByName(ctx context.Context, names []string) (map[string]Supplier, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	suppliers := make(map[string]Supplier, len(names))
+	for start := 0; start < len(names); start += maxSuppliersPerLookup {
+		batch := names[start:min(start+maxSuppliersPerLookup, len(names))]
+
+		args := make([]interface{}, 0, len(batch)+1)
+		args = append(args, d.tenant)
+		for _, name := range batch {
+			args = append(args, name)
+		}
+		query := suppliersByNameQuery + "(" + strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ") + ")"
+
+		err := d.querySuppliers(ctx, query, func(supplier Supplier) {
+			suppliers[supplier.Name] = supplier
+		}, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return suppliers, nil
+}
+
+// UpdateSupplier changes the profile of the supplier of the tenant with the ID. The name and the
+// creation time are kept.
+//
+// Changing the status shows or hides the offers of the supplier, so they're touched and change
+// listeners are notified about them. Returns ErrSupplierNotFound if the supplier doesn't exist.
+func (d *OffersSQLiteDatabase) UpdateSupplier(ctx context.Context, supplier Supplier) (Supplier, error) {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	metadata, err := encodeMetadata(supplier.Metadata)
+	if err != nil {
+		return Supplier{}, err
+	}
+
+	var updated Supplier
+	var changes []Change
+	err = d.writes.writeTx(ctx, func(tx *sql.Tx) (err error) {
+		updated, err = getSupplier(ctx, tx, d.tenant, supplier.ID)
+		if err != nil {
+			return err
+		}
+		previousStatus := updated.Status
+
+		updated.DisplayName = supplier.DisplayName
+		if updated.DisplayName == "" {
+			updated.DisplayName = updated.Name
+		}
+		updated.Email = supplier.Email
+		updated.Phone = supplier.Phone
+		updated.Status = supplier.Status
+		if updated.Status == "" {
+			updated.Status = SupplierActive
+		}
+		updated.Metadata = supplier.Metadata
+		updated.UpdatedAt = time.Now().UTC()
+
+		// The offers are shown or hidden with the status, so they change as well
+		var minPrices map[productKey]sql.NullFloat64
+		if updated.Status != previousStatus {
+			if changes, minPrices, err = supplierOffers(ctx, tx, d.tenant, updated.Name, updated.UpdatedAt); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(
+			ctx, updateSupplierStmt,
+			updated.DisplayName, updated.Email, updated.Phone, updated.Status, metadata, updated.UpdatedAt.UnixNano(),
+			d.tenant, updated.ID,
+		)
+		if err != nil {
+			return errors.Wrap(err, "error updating supplier")
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		// Touching the offers changes the validators of the searches for them
+		_, err = tx.ExecContext(ctx, touchOffersStmt, updated.UpdatedAt.UnixNano(), d.tenant, updated.Name)
+		if err != nil {
+			return errors.Wrap(err, "error updating offers")
+		}
+		for i := range changes {
+			changes[i].Suspended = updated.Status == SupplierSuspended
+		}
+		return markCheapest(ctx, tx, changes, minPrices)
+	})
+	if err != nil {
+		return Supplier{}, err
+	}
+
+	d.listeners.notify(changes)
+	return updated, nil
+}
+
+// supplierOffers returns an update of every offer of the supplier at the time, and the cheapest
+// prices of their products
+func supplierOffers(ctx context.Context, tx *sql.Tx, tenant, supplier string, at time.Time) (changes []Change, minPrices map[productKey]sql.NullFloat64, err error) {
+	rows, err := tx.QueryContext(ctx, supplierOffersQuery, tenant, supplier)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error querying offers")
+	}
+	for rows.Next() {
+		offer := Offer{}
+		var stock nullStock
+		err = rows.Scan(append([]interface{}{&offer.Product, &offer.Category, &offer.Supplier, &offer.Price}, stock.dest()...)...)
+		if err != nil {
+			rows.Close()
+			return nil, nil, errors.Wrap(err, "error retrieving row")
+		}
+		offer.Stock = stock.stock()
+		changes = append(changes, Change{Type: OfferUpdated, Tenant: tenant, Offer: offer, PreviousPrice: offer.Price, Time: at})
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "error reading offers")
+	}
+
+	minPrices = make(map[productKey]sql.NullFloat64)
+	for _, change := range changes {
+		key := productKey{tenant, change.Offer.Product, change.Offer.Category}
+		if _, ok := minPrices[key]; !ok {
+			if minPrices[key], _, err = minPrice(ctx, tx, key); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return changes, minPrices, nil
+}
+
+// markSuspended sets Suspended on the changes to offers of suspended suppliers. The changes are
+// made by one tenant.
+func markSuspended(ctx context.Context, tx *sql.Tx, changes []Change) error {
+	suspended := make(map[string]bool)
+	for i := range changes {
+		change := &changes[i]
+		key := change.Offer.Supplier
+		if _, ok := suspended[key]; !ok {
+			var status SupplierStatus
+			err := tx.QueryRowContext(ctx, supplierStatusQuery, change.Tenant, change.Offer.Supplier).Scan(&status)
+			if err != nil && err != sql.ErrNoRows {
+				return errors.Wrap(err, "error querying supplier")
+			}
+			suspended[key] = status == SupplierSuspended
+		}
+		change.Suspended = suspended[key]
+	}
+	return nil
+}
+
+// DeleteSupplier deletes the profile of a supplier of the tenant
+//
+// Only suppliers without offers can be deleted, since their offers would get a new ID otherwise.
+// Returns ErrSupplierNotFound if the supplier doesn't exist, and ErrSupplierHasOffers if it still
+// has offers.
+func (d *OffersSQLiteDatabase) DeleteSupplier(ctx context.Context, id int64) error {
+	ctx, cancel := d.queryContext(ctx)
+	defer cancel()
+
+	return d.writes.writeTx(ctx, func(tx *sql.Tx) error {
+		supplier, err := getSupplier(ctx, tx, d.tenant, id)
+		if err != nil {
+			return err
+		}
+
+		var offers int
+		if err = tx.QueryRowContext(ctx, countSupplierOffers, d.tenant, supplier.Name).Scan(&offers); err != nil {
+			return errors.Wrap(err, "error counting offers")
+		}
+		if offers > 0 {
+			return ErrSupplierHasOffers
+		}
+
+		_, err = tx.ExecContext(ctx, deleteSupplierStmt, d.tenant, id)
+		return errors.Wrap(err, "error deleting supplier")
+	})
+}
+
+func (d *OffersSQLiteDatabase) querySuppliers(ctx context.Context, query string, fn func(Supplier), args ...interface{}) (err error) {
+	rows, err := d.reader.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "error querying suppliers")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		supplier, err := scanSupplier(rows)
+		if err != nil {
+			return err
+		}
+		fn(supplier)
+	}
+	return rows.Err()
+}
+
+func scanSupplier(row scanner) (Supplier, error) {
+	var (
+		supplier             Supplier
+		metadata             string
+		createdAt, updatedAt int64
+	)
+	err := row.Scan(
+		&supplier.ID, &supplier.Name, &supplier.DisplayName, &supplier.Email, &supplier.Phone, &supplier.Status,
+		&metadata, &createdAt, &updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return Supplier{}, err
+	}
+	if err != nil {
+		return Supplier{}, errors.Wrap(err, "error retrieving supplier")
+	}
+
+	if err = json.Unmarshal([]byte(metadata), &supplier.Metadata); err != nil {
+		return Supplier{}, errors.Wrap(err, "error decoding supplier metadata")
+	}
+	supplier.CreatedAt = time.Unix(0, createdAt).UTC()
+	supplier.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	return supplier, nil
+}
+
+// encodeMetadata returns the metadata as a JSON object. Missing metadata is an empty object.
+func encodeMetadata(metadata map[string]string) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", errors.Wrap(err, "error encoding supplier metadata")
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_Suppliers(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	added, err := db.AddSupplier(ctx, Supplier{Name: "Megadodo", Email: "orders@megadodo.example"})
+	if err != nil {
+		t.Fatalf("Expected no error adding a supplier, got %v", err)
+	}
+	if added.ID == 0 || added.DisplayName != "Megadodo" || added.Status != SupplierActive {
+		t.Fatalf("Expected an active supplier named after its offers, got %+v", added)
+	}
+
+	// Suppliers get a profile with their first offer
+	if err = db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if err = db.Insert(ctx, "Guide", "Must Haves", "Hitchhiker Essentials", 30); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if _, err = db.AddSupplier(ctx, Supplier{Name: "Hitchhiker Essentials"}); err != ErrSupplierExists {
+		t.Fatalf("Expected ErrSupplierExists for a supplier with offers, got %v", err)
+	}
+
+	suppliers, err := db.SuppliersByName(ctx, []string{"Hitchhiker Essentials", "Megadodo", "Unknown"})
+	if err != nil {
+		t.Fatalf("Expected no error looking up suppliers, got %v", err)
+	}
+	if len(suppliers) != 2 || suppliers["Megadodo"].ID != added.ID || suppliers["Hitchhiker Essentials"].ID == 0 {
+		t.Fatalf("Expected the profiles of both known suppliers, got %+v", suppliers)
+	}
+
+	essentials := suppliers["Hitchhiker Essentials"]
+	updated, err := db.UpdateSupplier(ctx, Supplier{
+		ID:          essentials.ID,
+		DisplayName: "Hitchhiker Essentials Ltd.",
+		Status:      SupplierSuspended,
+		Metadata:    map[string]string{"region": "EU"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error updating a supplier, got %v", err)
+	}
+	if updated.Name != "Hitchhiker Essentials" || updated.Status != SupplierSuspended || !updated.CreatedAt.Equal(essentials.CreatedAt) {
+		t.Fatalf("Expected the suspended supplier with its name, got %+v", updated)
+	}
+	got, err := db.Supplier(ctx, essentials.ID)
+	if err != nil || got.DisplayName != "Hitchhiker Essentials Ltd." || got.Metadata["region"] != "EU" {
+		t.Fatalf("Expected the updated supplier, got %+v, %v", got, err)
+	}
+
+	if err = db.DeleteSupplier(ctx, essentials.ID); err != ErrSupplierHasOffers {
+		t.Fatalf("Expected ErrSupplierHasOffers, got %v", err)
+	}
+	if err = db.DeleteSupplier(ctx, added.ID); err != nil {
+		t.Fatalf("Expected no error deleting a supplier, got %v", err)
+	}
+	if _, err = db.Supplier(ctx, added.ID); err != ErrSupplierNotFound {
+		t.Fatalf("Expected ErrSupplierNotFound for a deleted supplier, got %v", err)
+	}
+
+	list, err := db.ListSuppliers(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected one supplier, got %+v, %v", list, err)
+	}
+}
+
+func TestOffersSQLiteDatabase_Suppliers_tenants(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	acme := db.ForTenant("acme").(*OffersSQLiteDatabase)
+	if err := acme.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 40); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	own, err := db.SuppliersByName(ctx, []string{"Hitchhiker Essentials"})
+	if err != nil {
+		t.Fatalf("Expected no error looking up suppliers, got %v", err)
+	}
+	other, err := acme.SuppliersByName(ctx, []string{"Hitchhiker Essentials"})
+	if err != nil {
+		t.Fatalf("Expected no error looking up suppliers, got %v", err)
+	}
+	if own["Hitchhiker Essentials"].ID == other["Hitchhiker Essentials"].ID {
+		t.Fatalf("Expected every tenant to have its own profile, got %+v and %+v", own, other)
+	}
+	if _, err = acme.Supplier(ctx, own["Hitchhiker Essentials"].ID); err != ErrSupplierNotFound {
+		t.Fatalf("Expected ErrSupplierNotFound for the supplier of another tenant, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package database
+
+// DefaultTenant is the tenant of the handle returned by InitSQLiteDatabase. Offers which were
+// stored before tenants were introduced belong to it.
+const DefaultTenant = "default"
+
+// TenantScoper is implemented by databases which keep the offers of every tenant apart
+type TenantScoper interface {
+	// ForTenant returns the offers of the tenant. Every query and write of the returned handle is
+	// restricted to the tenant, so it can't read or change the offers of other tenants.
+	ForTenant(tenant string) Offers
+}
+
+// ForTenant returns a handle which reads and writes the offers of the tenant
+//
+// The handle shares the connections, the write queue and the change listeners with d. None of its
+// methods take a tenant, so the tenant can't be changed for a single query.
+func (d *OffersSQLiteDatabase) ForTenant(tenant string) Offers {
+	scoped := *d
+	scoped.tenant = tenant
+	return &scoped
+}
+
+// Tenant returns the tenant whose offers the handle reads and writes
+func (d *OffersSQLiteDatabase) Tenant() string {
+	return d.tenant
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOffersSQLiteDatabase_ForTenant(t *testing.T) {
+	db := setupFileDatabase(t)
+	ctx := context.Background()
+	acme := db.ForTenant("acme")
+
+	if err := db.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if err := acme.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Essentials", 50); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	if err := acme.Insert(ctx, "Towel", "Must Haves", "Hitchhiker Knockoffs", 45); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	// The same offer of the same supplier is kept separately per tenant
+	offers, err := db.Get(ctx, "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving offers, got %v", err)
+	}
+	if len(offers) != 1 || offers[0].Price != 42 {
+		t.Fatalf("Expected only the offer of the default tenant, got %v", offers)
+	}
+	offers, err = acme.Get(ctx, "Towel", "Must Haves")
+	if err != nil {
+		t.Fatalf("Expected no error retrieving offers, got %v", err)
+	}
+	if len(offers) != 2 || offers[0].Price != 45 || offers[1].Price != 50 {
+		t.Fatalf("Expected only the offers of acme, got %v", offers)
+	}
+
+	it, err := acme.Iterate(ctx, OfferFilter{})
+	if err != nil {
+		t.Fatalf("Expected no error iterating, got %v", err)
+	}
+	if got := collectOffers(t, it); len(got) != 2 {
+		t.Fatalf("Expected to iterate over the 2 offers of acme, got %v", got)
+	}
+
+	stats, err := acme.(*OffersSQLiteDatabase).Stats()
+	if err != nil {
+		t.Fatalf("Expected no error counting offers, got %v", err)
+	}
+	if stats.Offers != 2 {
+		t.Fatalf("Expected to count the 2 offers of acme, got %d", stats.Offers)
+	}
+
+	// Offers of other tenants can't be withdrawn
+	if err = db.Withdraw(ctx, "Towel", "Must Haves", "Hitchhiker Knockoffs"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound withdrawing an offer of another tenant, got %v", err)
+	}
+	if err = acme.Withdraw(ctx, "Towel", "Must Haves", "Hitchhiker Essentials"); err != nil {
+		t.Fatalf("Expected no error withdrawing an offer, got %v", err)
+	}
+	if offers, _ = db.Get(ctx, "Towel", "Must Haves"); len(offers) != 1 {
+		t.Fatalf("Expected the offer of the default tenant to be kept, got %v", offers)
+	}
+}
+
+func TestOffersSQLiteDatabase_ForTenant_changes(t *testing.T) {
+	db := setupFileDatabase(t)
+	changes := recordChanges(t, db)
+	acme := db.ForTenant("acme")
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	changes()
+
+	// The cheapest offer is determined per tenant
+	if err := acme.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs", 50); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+	got := changes()
+	if len(got) != 1 || got[0].Tenant != "acme" || !got[0].Cheapest {
+		t.Fatalf("Expected the cheapest offer of acme, got %+v", got)
+	}
+}
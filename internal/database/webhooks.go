@@ -0,0 +1,272 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	webhookColumns        = "id, url, product, category, secret, created_at"
+	insertWebhookStmt     = "INSERT INTO webhooks (url, product, category, secret, created_at) VALUES (?, ?, ?, ?, ?)"
+	getWebhookQuery       = "SELECT " + webhookColumns + " FROM webhooks WHERE id=?"
+	listWebhooksQuery     = "SELECT " + webhookColumns + " FROM webhooks ORDER BY id"
+	matchingWebhooksQuery = "SELECT " + webhookColumns + " FROM webhooks WHERE (product='' OR product=?) AND (category='' OR category=?) ORDER BY id"
+	deleteWebhookStmt     = "DELETE FROM webhooks WHERE id=?"
+	deleteDeliveriesStmt  = "DELETE FROM webhook_deliveries WHERE webhook_id=?"
+	insertDeliveryStmt    = "INSERT INTO webhook_deliveries (webhook_id, event_id, attempt, status_code, error, duration, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	listDeliveriesQuery   = "SELECT id, webhook_id, event_id, attempt, status_code, error, duration, created_at FROM webhook_deliveries WHERE webhook_id=? ORDER BY id DESC LIMIT ?"
+	insertDeadLetterStmt  = "INSERT INTO webhook_dead_letters (webhook_id, event_id, payload, attempts, last_error, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	listDeadLettersQuery  = "SELECT id, webhook_id, event_id, payload, attempts, last_error, created_at FROM webhook_dead_letters ORDER BY id DESC LIMIT ?"
+)
+
+// ErrWebhookNotFound is returned if a webhook with the given ID doesn't exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhooks is an interface for storing webhook subscriptions and the log of their deliveries
+type Webhooks interface {
+	AddWebhook(webhook Webhook) (Webhook, error)
+	Webhook(id int64) (Webhook, error)
+	ListWebhooks() ([]Webhook, error)
+	MatchingWebhooks(productName, categoryName string) ([]Webhook, error)
+	DeleteWebhook(id int64) error
+	LogDelivery(delivery WebhookDelivery) error
+	Deliveries(webhookID int64, limit int) ([]WebhookDelivery, error)
+	AddDeadLetter(letter DeadLetter) error
+	DeadLetters(limit int) ([]DeadLetter, error)
+}
+
+// Webhook is a subscription to notifications about offers
+//
+// Empty Product and Category fields match all products and categories.
+type Webhook struct {
+	ID                     int64
+	URL, Product, Category string
+	Secret                 string
+	CreatedAt              time.Time
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook
+//
+// StatusCode is 0 and Error is set if no response was received.
+type WebhookDelivery struct {
+	ID         int64
+	WebhookID  int64
+	EventID    string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+	Time       time.Time
+}
+
+// DeadLetter is an event which couldn't be delivered to a webhook
+type DeadLetter struct {
+	ID        int64
+	WebhookID int64
+	EventID   string
+	Payload   []byte
+	Attempts  int
+	LastError string
+	Time      time.Time
+}
+
+// AddWebhook stores a webhook subscription and returns it with its ID and creation time
+func (d *OffersSQLiteDatabase) AddWebhook(webhook Webhook) (Webhook, error) {
+	webhook.CreatedAt = time.Now().UTC()
+
+	err := d.writes.write(func(db *sql.DB) error {
+		result, err := db.Exec(
+			insertWebhookStmt,
+			webhook.URL, webhook.Product, webhook.Category, webhook.Secret, webhook.CreatedAt.UnixNano(),
+		)
+		if err != nil {
+			return errors.Wrap(err, "error inserting webhook")
+		}
+		webhook.ID, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// Webhook returns the webhook with the ID. Returns ErrWebhookNotFound if it doesn't exist.
+func (d *OffersSQLiteDatabase) Webhook(id int64) (Webhook, error) {
+	webhook, err := scanWebhook(d.reader.QueryRow(getWebhookQuery, id))
+	if err == sql.ErrNoRows {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// ListWebhooks returns all webhooks
+func (d *OffersSQLiteDatabase) ListWebhooks() ([]Webhook, error) {
+	return d.queryWebhooks(listWebhooksQuery)
+}
+
+// MatchingWebhooks returns the webhooks whose filters match the product in the category
+func (d *OffersSQLiteDatabase) MatchingWebhooks(productName, categoryName string) ([]Webhook, error) {
+	return d.queryWebhooks(matchingWebhooksQuery, productName, categoryName)
+}
+
+func (d *OffersSQLiteDatabase) queryWebhooks(query string, args ...interface{}) (webhooks []Webhook, err error) {
+	rows, err := d.reader.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying webhooks")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row scanner) (Webhook, error) {
+	var (
+		webhook   Webhook
+		createdAt int64
+	)
+	err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Product, &webhook.Category, &webhook.Secret, &createdAt)
+	if err == sql.ErrNoRows {
+		return Webhook{}, err
+	}
+	if err != nil {
+		return Webhook{}, errors.Wrap(err, "error retrieving webhook")
+	}
+	webhook.CreatedAt = time.Unix(0, createdAt).UTC()
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery log. Dead letters are kept.
+//
+// Returns ErrWebhookNotFound if the webhook doesn't exist.
+func (d *OffersSQLiteDatabase) DeleteWebhook(id int64) error {
+	return d.writes.write(func(db *sql.DB) (err error) {
+		tx, err := db.Begin()
+		if err != nil {
+			return errors.Wrap(err, "error beginning transaction")
+		}
+
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+				return
+			}
+			if commitErr := tx.Commit(); commitErr != nil {
+				err = errors.Wrap(commitErr, "error committing transaction")
+			}
+		}()
+
+		result, err := tx.Exec(deleteWebhookStmt, id)
+		if err != nil {
+			return errors.Wrap(err, "error deleting webhook")
+		}
+		if err = requireAffectedRow(result, ErrWebhookNotFound); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(deleteDeliveriesStmt, id)
+		return errors.Wrap(err, "error deleting webhook deliveries")
+	})
+}
+
+// LogDelivery stores an attempt to deliver an event to a webhook
+func (d *OffersSQLiteDatabase) LogDelivery(delivery WebhookDelivery) error {
+	return d.writes.write(func(db *sql.DB) error {
+		_, err := db.Exec(
+			insertDeliveryStmt,
+			delivery.WebhookID, delivery.EventID, delivery.Attempt, delivery.StatusCode, delivery.Error,
+			int64(delivery.Duration), delivery.Time.UnixNano(),
+		)
+		return errors.Wrap(err, "error logging webhook delivery")
+	})
+}
+
+// Deliveries returns the latest delivery attempts for a webhook, newest first
+func (d *OffersSQLiteDatabase) Deliveries(webhookID int64, limit int) (deliveries []WebhookDelivery, err error) {
+	rows, err := d.reader.Query(listDeliveriesQuery, webhookID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying webhook deliveries")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			delivery            WebhookDelivery
+			duration, createdAt int64
+		)
+		err = rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Attempt, &delivery.StatusCode,
+			&delivery.Error, &duration, &createdAt,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "error retrieving webhook delivery")
+		}
+		delivery.Duration = time.Duration(duration)
+		delivery.Time = time.Unix(0, createdAt).UTC()
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// AddDeadLetter stores an event which couldn't be delivered
+func (d *OffersSQLiteDatabase) AddDeadLetter(letter DeadLetter) error {
+	return d.writes.write(func(db *sql.DB) error {
+		_, err := db.Exec(
+			insertDeadLetterStmt,
+			letter.WebhookID, letter.EventID, letter.Payload, letter.Attempts, letter.LastError, letter.Time.UnixNano(),
+		)
+		return errors.Wrap(err, "error storing dead letter")
+	})
+}
+
+// DeadLetters returns the latest events which couldn't be delivered, newest first
+func (d *OffersSQLiteDatabase) DeadLetters(limit int) (letters []DeadLetter, err error) {
+	rows, err := d.reader.Query(listDeadLettersQuery, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying dead letters")
+	}
+	defer func() {
+		if cerr := rows.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			letter    DeadLetter
+			createdAt int64
+		)
+		err = rows.Scan(
+			&letter.ID, &letter.WebhookID, &letter.EventID, &letter.Payload, &letter.Attempts, &letter.LastError,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "error retrieving dead letter")
+		}
+		letter.Time = time.Unix(0, createdAt).UTC()
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
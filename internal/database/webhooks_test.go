@@ -0,0 +1,98 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOffersSQLiteDatabase_webhooks(t *testing.T) {
+	db := setupFileDatabase(t)
+
+	all, err := db.AddWebhook(Webhook{URL: "http://localhost/all", Secret: "s1"})
+	if err != nil {
+		t.Fatalf("Expected no error adding a webhook, got %v", err)
+	}
+	towels, err := db.AddWebhook(Webhook{URL: "http://localhost/towels", Product: "Towel", Category: "Must Haves", Secret: "s2"})
+	if err != nil {
+		t.Fatalf("Expected no error adding a webhook, got %v", err)
+	}
+	if all.ID == 0 || towels.ID == all.ID || all.CreatedAt.IsZero() {
+		t.Fatalf("Expected IDs and creation times to be set, got %+v and %+v", all, towels)
+	}
+
+	got, err := db.Webhook(towels.ID)
+	if err != nil || !reflect.DeepEqual(got, towels) {
+		t.Fatalf("Got webhook %+v and error %v, want %+v", got, err, towels)
+	}
+
+	matching, err := db.MatchingWebhooks("Towel", "Must Haves")
+	if err != nil || len(matching) != 2 {
+		t.Fatalf("Got webhooks %+v and error %v, want both", matching, err)
+	}
+	matching, err = db.MatchingWebhooks("Guide", "Must Haves")
+	if err != nil || len(matching) != 1 || matching[0].ID != all.ID {
+		t.Fatalf("Got webhooks %+v and error %v, want the one without filters", matching, err)
+	}
+
+	delivery := WebhookDelivery{
+		WebhookID:  towels.ID,
+		EventID:    "event",
+		Attempt:    1,
+		StatusCode: 500,
+		Error:      "unexpected status code",
+		Duration:   time.Millisecond,
+		Time:       time.Date(2020, 10, 9, 18, 2, 21, 0, time.UTC),
+	}
+	if err = db.LogDelivery(delivery); err != nil {
+		t.Fatalf("Expected no error logging a delivery, got %v", err)
+	}
+	deliveries, err := db.Deliveries(towels.ID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Got deliveries %+v and error %v, want one", deliveries, err)
+	}
+	delivery.ID = deliveries[0].ID
+	if !reflect.DeepEqual(deliveries[0], delivery) {
+		t.Fatalf("Got delivery %+v, want %+v", deliveries[0], delivery)
+	}
+
+	letter := DeadLetter{
+		WebhookID: towels.ID,
+		EventID:   "event",
+		Payload:   []byte(`{}`),
+		Attempts:  5,
+		LastError: "unexpected status code",
+		Time:      delivery.Time,
+	}
+	if err = db.AddDeadLetter(letter); err != nil {
+		t.Fatalf("Expected no error adding a dead letter, got %v", err)
+	}
+
+	if err = db.DeleteWebhook(towels.ID); err != nil {
+		t.Fatalf("Expected no error deleting a webhook, got %v", err)
+	}
+	if err = db.DeleteWebhook(towels.ID); err != ErrWebhookNotFound {
+		t.Fatalf("Got error %v deleting a missing webhook, want %v", err, ErrWebhookNotFound)
+	}
+	if _, err = db.Webhook(towels.ID); err != ErrWebhookNotFound {
+		t.Fatalf("Got error %v getting a deleted webhook, want %v", err, ErrWebhookNotFound)
+	}
+	if deliveries, _ = db.Deliveries(towels.ID, 10); len(deliveries) != 0 {
+		t.Fatalf("Expected the delivery log to be deleted, got %+v", deliveries)
+	}
+
+	// Dead letters are kept to be able to replay them
+	letters, err := db.DeadLetters(10)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("Got dead letters %+v and error %v, want one", letters, err)
+	}
+	letter.ID = letters[0].ID
+	if !reflect.DeepEqual(letters[0], letter) {
+		t.Fatalf("Got dead letter %+v, want %+v", letters[0], letter)
+	}
+
+	webhooks, err := db.ListWebhooks()
+	if err != nil || len(webhooks) != 1 || webhooks[0].ID != all.ID {
+		t.Fatalf("Got webhooks %+v and error %v, want the remaining one", webhooks, err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package export converts offers to and from file formats other tools can consume
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pkg/errors"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// Format is a file format offers can be exported to and imported from
+type Format string
+
+// The supported export formats
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a row group is written out
+const parquetRowGroupSize = 10000
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case CSV, NDJSON, Parquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q", name)
+	}
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv"
+	case NDJSON:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension returns the file name extension of the format, without a leading dot
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Offers writes all offers from the iterator to w in the given format
+//
+// Offers are written as they're read, so memory usage doesn't depend on the number of offers.
+// Returns the number of offers written.
+func Offers(w io.Writer, format Format, it database.OfferIterator) (int, error) {
+	encoder, err := newEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for it.Next() {
+		err = encoder.encode(it.Record())
+		if err != nil {
+			return count, errors.Wrap(err, "error encoding offer")
+		}
+		count++
+	}
+	if err = it.Err(); err != nil {
+		return count, errors.Wrap(err, "error reading offers")
+	}
+
+	return count, errors.Wrap(encoder.close(), "error finishing export")
+}
+
+// encoder writes offers one by one in a specific format
+type encoder interface {
+	encode(record database.OfferRecord) error
+	close() error
+}
+
+func newEncoder(w io.Writer, format Format) (encoder, error) {
+	switch format {
+	case CSV:
+		return newCSVEncoder(w)
+	case NDJSON:
+		return &ndjsonEncoder{json.NewEncoder(w)}, nil
+	case Parquet:
+		return &parquetEncoder{
+			parquet.NewGenericWriter[parquetOffer](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvHeader is the first row of CSV exports
+var csvHeader = []string{"product", "category", "supplier", "price", "updatedAt"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	e := &csvEncoder{csv.NewWriter(w)}
+	return e, e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) encode(record database.OfferRecord) error {
+	return e.w.Write([]string{
+		record.Product,
+		record.Category,
+		record.Supplier,
+		strconv.FormatFloat(float64(record.Price), 'f', -1, 32),
+		record.UpdatedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonOffer is the representation of an offer in NDJSON exports
+type jsonOffer struct {
+	Product   string    `json:"product"`
+	Category  string    `json:"category"`
+	Supplier  string    `json:"supplier"`
+	Price     float32   `json:"price"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ndjsonEncoder struct {
+	e *json.Encoder
+}
+
+func (e *ndjsonEncoder) encode(record database.OfferRecord) error {
+	// Encode terminates every value with a newline, which is exactly what NDJSON needs
+	return e.e.Encode(jsonOffer{
+		Product:   record.Product,
+		Category:  record.Category,
+		Supplier:  record.Supplier,
+		Price:     record.Price,
+		UpdatedAt: record.UpdatedAt,
+	})
+}
+
+func (e *ndjsonEncoder) close() error {
+	return nil
+}
+
+// parquetOffer is the schema of Parquet exports
+type parquetOffer struct {
+	Product   string    `parquet:"product,dict"`
+	Category  string    `parquet:"category,dict"`
+	Supplier  string    `parquet:"supplier,dict"`
+	Price     float32   `parquet:"price"`
+	UpdatedAt time.Time `parquet:"updatedAt,timestamp(nanosecond)"`
+}
+
+type parquetEncoder struct {
+	w *parquet.GenericWriter[parquetOffer]
+}
+
+func (e *parquetEncoder) encode(record database.OfferRecord) error {
+	_, err := e.w.Write([]parquetOffer{{
+		Product:   record.Product,
+		Category:  record.Category,
+		Supplier:  record.Supplier,
+		Price:     record.Price,
+		UpdatedAt: record.UpdatedAt,
+	}})
+	return err
+}
+
+func (e *parquetEncoder) close() error {
+	return e.w.Close()
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+var testUpdatedAt = time.Date(2020, 10, 9, 18, 2, 21, 0, time.UTC)
+
+var testRecords = []database.OfferRecord{
+	{
+		Offer:     database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+		UpdatedAt: testUpdatedAt,
+	},
+	{
+		Offer:     database.Offer{Product: "Babelfish", Category: "Must Haves", Supplier: "Vogons, Inc.", Price: 1.5},
+		UpdatedAt: testUpdatedAt,
+	},
+}
+
+// sliceIterator is an OfferIterator over a slice of records
+type sliceIterator struct {
+	records []database.OfferRecord
+	index   int
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.index >= len(it.records) {
+		return false
+	}
+	it.index++
+	return true
+}
+func (it *sliceIterator) Record() database.OfferRecord { return it.records[it.index-1] }
+func (it *sliceIterator) Err() error                   { return nil }
+func (it *sliceIterator) Close() error                 { return nil }
+
+func exportTestRecords(t *testing.T, format Format) []byte {
+	var buf bytes.Buffer
+	count, err := Offers(&buf, format, &sliceIterator{records: testRecords})
+	if err != nil {
+		t.Fatalf("Expected no error exporting, got %v", err)
+	}
+	if count != len(testRecords) {
+		t.Fatalf("Expected %d exported offers, got %d", len(testRecords), count)
+	}
+	return buf.Bytes()
+}
+
+func TestOffers_CSV(t *testing.T) {
+	got := string(exportTestRecords(t, CSV))
+	want := "product,category,supplier,price,updatedAt\n" +
+		"Towel,Must Haves,Hitchhiker Essentials,42,2020-10-09T18:02:21Z\n" +
+		"Babelfish,Must Haves,\"Vogons, Inc.\",1.5,2020-10-09T18:02:21Z\n"
+
+	if got != want {
+		t.Fatalf("Expected CSV %q, got %q", want, got)
+	}
+}
+
+func TestOffers_NDJSON(t *testing.T) {
+	got := string(exportTestRecords(t, NDJSON))
+	want := `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42,"updatedAt":"2020-10-09T18:02:21Z"}` + "\n" +
+		`{"product":"Babelfish","category":"Must Haves","supplier":"Vogons, Inc.","price":1.5,"updatedAt":"2020-10-09T18:02:21Z"}` + "\n"
+
+	if got != want {
+		t.Fatalf("Expected NDJSON %q, got %q", want, got)
+	}
+}
+
+func TestOffers_Parquet(t *testing.T) {
+	data := exportTestRecords(t, Parquet)
+
+	got, err := parquet.Read[parquetOffer](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Expected no error reading the Parquet file, got %v", err)
+	}
+
+	want := []parquetOffer{
+		{"Towel", "Must Haves", "Hitchhiker Essentials", 42, testUpdatedAt},
+		{"Babelfish", "Must Haves", "Vogons, Inc.", 1.5, testUpdatedAt},
+	}
+	for i := range got {
+		got[i].UpdatedAt = got[i].UpdatedAt.UTC()
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("NDJSON"); err != nil || f != NDJSON {
+		t.Fatalf("Expected the NDJSON format, got %q and error %v", f, err)
+	}
+
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Fatal("Expected an error for an unsupported format, got none")
+	}
+}
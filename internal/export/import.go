@@ -0,0 +1,222 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/pkg/errors"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// Import reads offers in the given format from r and hands them to fn in batches
+//
+// Batches contain at most batchSize offers. Parquet files need random access, so r must be an
+// io.ReaderAt with a Size method or an io.Seeker, such as an *os.File. CSV files must start with a
+// header row naming the columns. Update times in the input are ignored.
+// Returns the number of offers passed to fn.
+func Import(r io.Reader, format Format, batchSize int, fn func([]database.Offer) error) (int, error) {
+	if batchSize < 1 {
+		return 0, fmt.Errorf("invalid batch size %d", batchSize)
+	}
+
+	decoder, err := newDecoder(r, format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	batch := make([]database.Offer, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		count += len(batch)
+		batch = make([]database.Offer, 0, batchSize)
+		return nil
+	}
+
+	for {
+		offer, err := decoder.decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, errors.Wrapf(err, "error decoding offer %d", count+len(batch)+1)
+		}
+
+		batch = append(batch, offer)
+		if len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, flush()
+}
+
+// decoder reads offers one by one in a specific format. It returns io.EOF after the last offer.
+type decoder interface {
+	decode() (database.Offer, error)
+}
+
+func newDecoder(r io.Reader, format Format) (decoder, error) {
+	switch format {
+	case CSV:
+		return newCSVDecoder(r)
+	case NDJSON:
+		return &ndjsonDecoder{json.NewDecoder(r)}, nil
+	case Parquet:
+		return newParquetDecoder(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading CSV header")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range csvHeader[:4] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the column %q", name)
+		}
+	}
+
+	return &csvDecoder{r: reader, columns: columns}, nil
+}
+
+func (d *csvDecoder) decode() (database.Offer, error) {
+	record, err := d.r.Read()
+	if err != nil {
+		return database.Offer{}, err
+	}
+
+	price, err := strconv.ParseFloat(record[d.columns["price"]], 32)
+	if err != nil {
+		return database.Offer{}, errors.Wrap(err, "invalid price")
+	}
+
+	return database.Offer{
+		Product:  record[d.columns["product"]],
+		Category: record[d.columns["category"]],
+		Supplier: record[d.columns["supplier"]],
+		Price:    float32(price),
+	}, nil
+}
+
+type ndjsonDecoder struct {
+	d *json.Decoder
+}
+
+func (d *ndjsonDecoder) decode() (database.Offer, error) {
+	offer := jsonOffer{}
+	err := d.d.Decode(&offer)
+	if err != nil {
+		return database.Offer{}, err
+	}
+
+	return database.Offer{
+		Product:  offer.Product,
+		Category: offer.Category,
+		Supplier: offer.Supplier,
+		Price:    offer.Price,
+	}, nil
+}
+
+// parquetReadBufferSize is the number of rows read from a Parquet file at once
+const parquetReadBufferSize = 256
+
+type parquetDecoder struct {
+	r *parquet.GenericReader[parquetOffer]
+
+	// rows holds the rows read from the file which haven't been decoded yet
+	rows []parquetOffer
+	buf  []parquetOffer
+	err  error
+}
+
+func newParquetDecoder(r io.Reader) (*parquetDecoder, error) {
+	readerAt, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil, fmt.Errorf("reading Parquet requires random access, which %T doesn't support", r)
+	}
+
+	size, err := sizeOf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := parquet.OpenFile(readerAt, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening Parquet file")
+	}
+
+	return &parquetDecoder{
+		r:   parquet.NewGenericReader[parquetOffer](file),
+		buf: make([]parquetOffer, parquetReadBufferSize),
+	}, nil
+}
+
+func (d *parquetDecoder) decode() (database.Offer, error) {
+	if len(d.rows) == 0 {
+		if d.err != nil {
+			return database.Offer{}, d.err
+		}
+
+		// Read may return rows and an error at the same time, so the error is kept for later
+		var n int
+		n, d.err = d.r.Read(d.buf)
+		if n == 0 && d.err == nil {
+			d.err = io.EOF
+		}
+		d.rows = d.buf[:n]
+		if n == 0 {
+			return database.Offer{}, d.err
+		}
+	}
+
+	row := d.rows[0]
+	d.rows = d.rows[1:]
+	return database.Offer{
+		Product:  row.Product,
+		Category: row.Category,
+		Supplier: row.Supplier,
+		Price:    row.Price,
+	}, nil
+}
+
+// sizeOf returns the size of a file-like reader
+func sizeOf(r io.Reader) (int64, error) {
+	switch f := r.(type) {
+	case interface{ Size() int64 }:
+		return f.Size(), nil
+	case io.Seeker:
+		return f.Seek(0, io.SeekEnd)
+	default:
+		return 0, fmt.Errorf("can't determine the size of %T", r)
+	}
+}
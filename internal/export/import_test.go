@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// importAll imports everything from r and returns the offers and the sizes of the batches
+func importAll(t *testing.T, data []byte, format Format, batchSize int) ([]database.Offer, []int) {
+	var offers []database.Offer
+	var batches []int
+
+	count, err := Import(bytes.NewReader(data), format, batchSize, func(batch []database.Offer) error {
+		offers = append(offers, batch...)
+		batches = append(batches, len(batch))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error importing %s, got %v", format, err)
+	}
+	if count != len(offers) {
+		t.Fatalf("Expected the count %d to match the %d imported offers", count, len(offers))
+	}
+	return offers, batches
+}
+
+func TestImport_roundTrip(t *testing.T) {
+	want := []database.Offer{testRecords[0].Offer, testRecords[1].Offer}
+
+	for _, format := range []Format{CSV, NDJSON, Parquet} {
+		got, _ := importAll(t, exportTestRecords(t, format), format, 10)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Expected %s import to return %v, got %v", format, want, got)
+		}
+	}
+}
+
+func TestImport_batches(t *testing.T) {
+	data := "category,product,price,supplier\n" +
+		"Must Haves,Towel,42,Hitchhiker Essentials\n" +
+		"Must Haves,Towel,40,Hitchhiker Knockoffs\n" +
+		"Must Haves,Babelfish,1,Hitchhiker Essentials\n"
+
+	offers, batches := importAll(t, []byte(data), CSV, 2)
+
+	if !reflect.DeepEqual(batches, []int{2, 1}) {
+		t.Fatalf("Expected batches of 2 and 1 offers, got %v", batches)
+	}
+
+	want := database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40}
+	if offers[1] != want {
+		t.Fatalf("Expected columns to be mapped by the header, got %v", offers[1])
+	}
+}
+
+func TestImport_withInvalidData(t *testing.T) {
+	testCases := []struct {
+		format Format
+		data   string
+	}{
+		{CSV, "product,category,price\nTowel,Must Haves,42\n"},
+		{CSV, "product,category,supplier,price\nTowel,Must Haves,Hitchhiker Essentials,cheap\n"},
+		{NDJSON, `{"product":"Towel","price":"cheap"}`},
+		{Parquet, "I'm not Parquet"},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Import(strings.NewReader(testCase.data), testCase.format, 10, func([]database.Offer) error {
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("Expected an error importing %q as %s, got none", testCase.data, testCase.format)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package graphqlapi executes GraphQL queries over categories, products, suppliers and offers
+package graphqlapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+// Request is a GraphQL request as sent by clients
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Executor executes queries against the schema
+type Executor struct {
+	schema graphql.Schema
+
+	maxDepth      int
+	maxComplexity int
+}
+
+// NewExecutor returns an executor with the default depth and complexity limits
+func NewExecutor() (*Executor, error) {
+	schema, err := newSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Executor{
+		schema:        schema,
+		maxDepth:      defaultMaxDepth,
+		maxComplexity: defaultMaxComplexity,
+	}, nil
+}
+
+// SetLimits sets the maximum depth and complexity of queries
+func (e *Executor) SetLimits(maxDepth, maxComplexity int) {
+	e.maxDepth = maxDepth
+	e.maxComplexity = maxComplexity
+}
+
+// Execute executes the request with the offers and review scores
+//
+// The result has no data if the request couldn't be executed at all, e.g. because it is invalid
+// or exceeds the limits.
+func (e *Executor) Execute(ctx context.Context, offers database.Offers, reviewer review.Reviewer, req Request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if validation := graphql.ValidateDocument(&e.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	cost, err := operationCost(e.schema, doc, req.OperationName, req.Variables)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if cost.depth > e.maxDepth {
+		err = fmt.Errorf("query has depth %d, which exceeds the maximum of %d", cost.depth, e.maxDepth)
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if cost.complexity > e.maxComplexity {
+		err = fmt.Errorf("query has complexity %d, which exceeds the maximum of %d", cost.complexity, e.maxComplexity)
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context: context.WithValue(ctx, resolversKey{}, &resolvers{
+			offers:  offers,
+			reviews: newReviewLoader(ranking.ReviewerFor(ctx, offers, reviewer)),
+		}),
+	})
+}
@@ -0,0 +1,282 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// countingReviewer scores suppliers by the length of their name and records every lookup
+type countingReviewer struct {
+	mu      sync.Mutex
+	lookups [][]string
+	err     error
+	// names are the names of the suppliers by ID
+	names map[string]string
+}
+
+func (r *countingReviewer) Suppliers(supplierNames []string) (map[string]float32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := append([]string(nil), supplierNames...)
+	sort.Strings(names)
+	r.lookups = append(r.lookups, names)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	scores := make(map[string]float32, len(supplierNames))
+	for _, supplier := range supplierNames {
+		name, ok := r.names[supplier]
+		if !ok {
+			name = supplier
+		}
+		scores[supplier] = float32(len(name))
+	}
+	return scores, nil
+}
+
+// newCountingReviewer returns a reviewer which knows the names of the suppliers in the database
+func newCountingReviewer(t *testing.T, db *database.OffersSQLiteDatabase) *countingReviewer {
+	suppliers, err := db.ListSuppliers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string, len(suppliers))
+	for _, supplier := range suppliers {
+		names[strconv.FormatInt(supplier.ID, 10)] = supplier.Name
+	}
+	return &countingReviewer{names: names}
+}
+
+// supplierIDs returns the sorted IDs of the suppliers with the names
+func supplierIDs(t *testing.T, db *database.OffersSQLiteDatabase, names ...string) []string {
+	suppliers, err := db.SuppliersByName(context.Background(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(suppliers))
+	for _, supplier := range suppliers {
+		ids = append(ids, strconv.FormatInt(supplier.ID, 10))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func newTestDatabase(t *testing.T) *database.OffersSQLiteDatabase {
+	db, err := database.InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.InsertMultiple(context.Background(), []database.Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: 20},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Sirius", Price: 20},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Towels Inc", Price: 10},
+		{Product: "Hat", Category: "Must Haves", Supplier: "Acme", Price: 5},
+		{Product: "Hat", Category: "Must Haves", Supplier: "Milliways", Price: 7},
+		{Product: "Guide", Category: "Books", Supplier: "Megadodo", Price: 42},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// execute executes the query and returns the data as JSON
+func execute(t *testing.T, executor *Executor, db database.Offers, reviewer *countingReviewer, req Request) string {
+	result := executor.Execute(context.Background(), db, reviewer, req)
+	if result.HasErrors() {
+		t.Fatalf("Got errors %v, want none", result.Errors)
+	}
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExecutor_batchesReviewLookups(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDatabase(t)
+	reviewer := newCountingReviewer(t, db)
+
+	got := execute(t, executor, db, reviewer, Request{Query: `{
+		category(name: "Must Haves") {
+			products {
+				name
+				offers { price supplier { name reviewScore } }
+				cheapestOffer { supplier { name } }
+			}
+		}
+	}`})
+
+	want := `{"category":{"products":[` +
+		`{"cheapestOffer":{"supplier":{"name":"Acme"}},"name":"Hat","offers":[` +
+		`{"price":5,"supplier":{"name":"Acme","reviewScore":4}},` +
+		`{"price":7,"supplier":{"name":"Milliways","reviewScore":9}}]},` +
+		`{"cheapestOffer":{"supplier":{"name":"Towels Inc"}},"name":"Towel","offers":[` +
+		`{"price":10,"supplier":{"name":"Towels Inc","reviewScore":10}},` +
+		`{"price":20,"supplier":{"name":"Sirius","reviewScore":6}},` +
+		`{"price":20,"supplier":{"name":"Acme","reviewScore":4}}]}]}}`
+	if got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+
+	// The scores are looked up by the IDs of the suppliers
+	wantLookups := [][]string{supplierIDs(t, db, "Acme", "Milliways", "Sirius", "Towels Inc")}
+	if !reflect.DeepEqual(reviewer.lookups, wantLookups) {
+		t.Fatalf("Got review lookups %v, want %v", reviewer.lookups, wantLookups)
+	}
+}
+
+func TestExecutor_supplierAndProduct(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDatabase(t)
+
+	got := execute(t, executor, db, newCountingReviewer(t, db), Request{
+		Query:     `query($name: String!) { supplier(name: $name) { reviewScore offers(first: 1) { product { name category { name } } } } }`,
+		Variables: map[string]interface{}{"name": "Acme"},
+	})
+	want := `{"supplier":{"offers":[{"product":{"category":{"name":"Must Haves"},"name":"Hat"}}],"reviewScore":4}}`
+	if got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+
+	got = execute(t, executor, db, &countingReviewer{}, Request{
+		Query: `{ product(name: "Guide", category: "Books") { offers(first: 5) { price } } }`,
+	})
+	if want = `{"product":{"offers":[{"price":42}]}}`; got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+}
+
+func TestExecutor_withReviewerError(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDatabase(t)
+
+	result := executor.Execute(context.Background(), db, &countingReviewer{err: errors.New("down")}, Request{
+		Query: `{ product(name: "Towel", category: "Must Haves") { name cheapestOffer { price } } }`,
+	})
+	if len(result.Errors) != 1 || result.Errors[0].Message != errReviews.Error() {
+		t.Fatalf("Got errors %v, want %q", result.Errors, errReviews)
+	}
+	if result.Data == nil {
+		t.Fatal("Expected the fields which could be resolved to be returned")
+	}
+}
+
+func TestExecutor_limits(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor.SetLimits(4, 115)
+	db := newTestDatabase(t)
+
+	testCases := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"within limits", `{ category(name: "Books") { products { offers { price } } } }`, false},
+		{"too deep", `{ category(name: "Books") { products { offers { supplier { name } } } } }`, true},
+		{"too deep with fragment", `fragment o on Offer { supplier { name } } { category(name: "Books") { products { offers { ...o } } } }`, true},
+		{"too complex", `{ category(name: "Books") { products { name offers { price } } } }`, true},
+		{"complexity limited by first", `{ category(name: "Books") { products(first: 2) { name offers(first: 2) { price } } } }`, false},
+		{"negative first", `{ category(name: "Books") { products(first: -1) { name offers(first: -1) { price } } } }`, true},
+		{"huge first", `{ category(name: "Books") { products(first: 2147483647) { name offers(first: 2147483647) { price } } } }`, true},
+		{"introspection isn't counted", `{ __schema { types { name fields { name type { name ofType { name } } } } } }`, false},
+		{"invalid", `{ category { name } }`, true},
+	}
+
+	for _, testCase := range testCases {
+		result := executor.Execute(context.Background(), db, &countingReviewer{}, Request{Query: testCase.query})
+		if result.HasErrors() != testCase.wantErr {
+			t.Fatalf("%s: got errors %v, want errors: %t", testCase.name, result.Errors, testCase.wantErr)
+		}
+		if testCase.wantErr && result.Data != nil {
+			t.Fatalf("%s: got data %v, want none", testCase.name, result.Data)
+		}
+	}
+
+	// Variables can't lower the complexity either
+	for _, first := range []interface{}{-1, -1.0, 1e300} {
+		result := executor.Execute(context.Background(), db, &countingReviewer{}, Request{
+			Query:     `query($first: Int) { category(name: "Books") { products(first: $first) { name offers(first: $first) { price } } } }`,
+			Variables: map[string]interface{}{"first": first},
+		})
+		if !result.HasErrors() || result.Data != nil {
+			t.Fatalf("first %v: got data %v and errors %v, want the query to be too complex", first, result.Data, result.Errors)
+		}
+	}
+}
+
+func TestExecutor_hidesUnavailableOffers(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDatabase(t)
+
+	_, err = db.UpdateStock(context.Background(), "Towel", "Must Haves", "Towels Inc", database.StockUpdate{Stock: database.Stock{Availability: database.OutOfStock}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := execute(t, executor, db, newCountingReviewer(t, db), Request{Query: `{
+		product(name: "Towel", category: "Must Haves") { cheapestOffer { price } offers { supplier { name } } }
+		supplier(name: "Towels Inc") { offers { price } }
+	}`})
+	want := `{"product":{"cheapestOffer":{"price":20},"offers":[{"supplier":{"name":"Sirius"}},{"supplier":{"name":"Acme"}}]},` +
+		`"supplier":{"offers":[]}}`
+	if got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+}
+
+func TestExecutor_hidesSuspendedSuppliers(t *testing.T) {
+	executor, err := NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDatabase(t)
+	ctx := context.Background()
+
+	suppliers, err := db.SuppliersByName(ctx, []string{"Towels Inc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.UpdateSupplier(ctx, database.Supplier{ID: suppliers["Towels Inc"].ID, Status: database.SupplierSuspended}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := execute(t, executor, db, newCountingReviewer(t, db), Request{Query: `{
+		product(name: "Towel", category: "Must Haves") { cheapestOffer { price } offers { supplier { name } } }
+		supplier(name: "Towels Inc") { offers { price } }
+	}`})
+	want := `{"product":{"cheapestOffer":{"price":20},"offers":[{"supplier":{"name":"Sirius"}},{"supplier":{"name":"Acme"}}]},` +
+		`"supplier":{"offers":[]}}`
+	if got != want {
+		t.Fatalf("Got %s, want %s", got, want)
+	}
+}
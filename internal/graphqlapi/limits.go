@@ -0,0 +1,201 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// defaultMaxDepth is the maximum nesting of fields in a query
+	defaultMaxDepth = 10
+	// defaultMaxComplexity is the maximum complexity of a query
+	defaultMaxComplexity = 1000
+	// defaultListSize is the number of items assumed for lists without a first argument when
+	// calculating the complexity of a query
+	defaultListSize = 10
+	// maxCost is the cost at which calculations saturate, so that very large lists can't overflow
+	// the complexity
+	maxCost = math.MaxInt32
+)
+
+// queryCost is the depth and the complexity of an operation
+//
+// Every field adds 1 to the complexity. The complexity of the fields selected on a list is
+// multiplied by the first argument or, if there is none or it's negative, by defaultListSize.
+// Introspection fields aren't counted.
+type queryCost struct {
+	depth, complexity int
+}
+
+// costCalculator calculates the cost of the operations of a document. The document must be valid.
+type costCalculator struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// operationCost returns the cost of the operation with the name, or the only operation if the
+// name is empty
+func operationCost(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) (queryCost, error) {
+	c := costCalculator{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+	}
+
+	var operations []*ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch d := definition.(type) {
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				operations = append(operations, d)
+			}
+		case *ast.FragmentDefinition:
+			c.fragments[d.Name.Value] = d
+		}
+	}
+
+	if len(operations) != 1 {
+		return queryCost{}, fmt.Errorf("must provide exactly one operation or an operation name")
+	}
+	if operations[0].Operation != ast.OperationTypeQuery {
+		return queryCost{}, fmt.Errorf("only queries are supported")
+	}
+
+	return c.selectionSet(schema.QueryType(), operations[0].SelectionSet), nil
+}
+
+func (c costCalculator) selectionSet(parent *graphql.Object, set *ast.SelectionSet) queryCost {
+	var cost queryCost
+	if set == nil {
+		return cost
+	}
+
+	for _, selection := range set.Selections {
+		var selected queryCost
+		switch s := selection.(type) {
+		case *ast.Field:
+			selected = c.field(parent, s)
+		case *ast.InlineFragment:
+			selected = c.selectionSet(c.typeCondition(parent, s.TypeCondition), s.SelectionSet)
+		case *ast.FragmentSpread:
+			if fragment, ok := c.fragments[s.Name.Value]; ok {
+				selected = c.selectionSet(c.typeCondition(parent, fragment.TypeCondition), fragment.SelectionSet)
+			}
+		}
+
+		cost.complexity = saturatingAdd(cost.complexity, selected.complexity)
+		if selected.depth > cost.depth {
+			cost.depth = selected.depth
+		}
+	}
+
+	return cost
+}
+
+func (c costCalculator) field(parent *graphql.Object, field *ast.Field) queryCost {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return queryCost{}
+	}
+
+	definition, ok := parent.Fields()[name]
+	if !ok {
+		return queryCost{}
+	}
+
+	fieldType, isList := unwrap(definition.Type)
+	var children queryCost
+	if object, ok := fieldType.(*graphql.Object); ok {
+		children = c.selectionSet(object, field.SelectionSet)
+	}
+
+	if isList {
+		children.complexity = saturatingMul(children.complexity, c.listSize(field))
+	}
+	return queryCost{depth: children.depth + 1, complexity: saturatingAdd(children.complexity, 1)}
+}
+
+// listSize returns the value of the first argument of the field, at most maxCost. It's
+// defaultListSize if there is no first argument or it's negative, since the whole list is returned
+// then.
+func (c costCalculator) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+
+		size := float64(defaultListSize)
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			// Values which are out of range are parsed as infinity
+			if parsed, err := strconv.ParseFloat(value.Value, 64); err == nil || math.IsInf(parsed, 0) {
+				size = parsed
+			}
+		case *ast.Variable:
+			switch variable := c.variables[value.Name.Value].(type) {
+			case int:
+				size = float64(variable)
+			case float64:
+				size = variable
+			}
+		}
+
+		switch {
+		case size < 0:
+			return defaultListSize
+		case size > maxCost:
+			return maxCost
+		default:
+			return int(size)
+		}
+	}
+	return defaultListSize
+}
+
+// saturatingAdd returns the sum of two costs, at most maxCost
+func saturatingAdd(a, b int) int {
+	if a > maxCost-b {
+		return maxCost
+	}
+	return a + b
+}
+
+// saturatingMul returns the product of two non-negative costs, at most maxCost
+func saturatingMul(a, b int) int {
+	if a != 0 && b > maxCost/a {
+		return maxCost
+	}
+	return a * b
+}
+
+func (c costCalculator) typeCondition(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition == nil {
+		return parent
+	}
+	if object, ok := c.schema.Type(condition.Name.Value).(*graphql.Object); ok {
+		return object
+	}
+	return parent
+}
+
+// unwrap returns the named type of a field and whether the field is a list
+func unwrap(t graphql.Type) (graphql.Type, bool) {
+	isList := false
+	for {
+		switch wrapper := t.(type) {
+		case *graphql.NonNull:
+			t = wrapper.OfType
+		case *graphql.List:
+			isList = true
+			t = wrapper.OfType
+		default:
+			return t, isList
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package graphqlapi
+
+import (
+	"sync"
+
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+// reviewLoader batches and caches the review score lookups of a single query
+//
+// Resolvers queue the suppliers they need with load and return a thunk. The executor resolves
+// all fields of a level before calling the thunks, so the first thunk which asks for scores sends
+// one request to the reviews engine for every supplier queued so far. Scores are cached for the
+// rest of the query, failed lookups aren't.
+type reviewLoader struct {
+	reviewer review.Reviewer
+
+	mu      sync.Mutex
+	scores  map[string]float32
+	pending map[string]bool
+}
+
+func newReviewLoader(reviewer review.Reviewer) *reviewLoader {
+	return &reviewLoader{
+		reviewer: reviewer,
+		scores:   make(map[string]float32),
+		pending:  make(map[string]bool),
+	}
+}
+
+// load queues the suppliers for the next lookup unless their scores are known already
+func (l *reviewLoader) load(suppliers ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, supplier := range suppliers {
+		if _, ok := l.scores[supplier]; !ok {
+			l.pending[supplier] = true
+		}
+	}
+}
+
+// Suppliers returns the review scores for the suppliers, looking up all queued suppliers at once
+//
+// It implements review.Reviewer, so the loader can be used for ranking offers.
+func (l *reviewLoader) Suppliers(suppliers []string) (map[string]float32, error) {
+	l.load(suppliers...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) > 0 {
+		batch := make([]string, 0, len(l.pending))
+		for supplier := range l.pending {
+			batch = append(batch, supplier)
+		}
+		l.pending = make(map[string]bool)
+
+		scores, err := l.reviewer.Suppliers(batch)
+		if err != nil {
+			return nil, err
+		}
+		// Suppliers without reviews have a score of 0, like in the search endpoint
+		for _, supplier := range batch {
+			l.scores[supplier] = scores[supplier]
+		}
+	}
+
+	result := make(map[string]float32, len(suppliers))
+	for _, supplier := range suppliers {
+		result[supplier] = l.scores[supplier]
+	}
+	return result, nil
+}
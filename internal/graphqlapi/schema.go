@@ -0,0 +1,343 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/graphql-go/graphql"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+)
+
+var (
+	errOffers  = errors.New("error getting offers")
+	errReviews = errors.New("error getting review scores")
+)
+
+// The values the resolvers pass to the resolvers of their fields
+type (
+	category struct {
+		name string
+	}
+
+	product struct {
+		name, category string
+		// offers is nil if the offers haven't been loaded yet
+		offers []database.Offer
+	}
+
+	supplier struct {
+		name string
+	}
+
+	offer struct {
+		product  product
+		supplier string
+		price    float32
+	}
+)
+
+// resolvers holds the dependencies of a single query
+type resolvers struct {
+	offers  database.Offers
+	reviews *reviewLoader
+}
+
+type resolversKey struct{}
+
+func resolversFrom(ctx context.Context) *resolvers {
+	return ctx.Value(resolversKey{}).(*resolvers)
+}
+
+// newSchema returns the schema with the types Category, Product, Supplier and Offer
+func newSchema() (graphql.Schema, error) {
+	var categoryType, productType, supplierType, offerType *graphql.Object
+
+	first := graphql.FieldConfigArgument{
+		"first": &graphql.ArgumentConfig{
+			Type:        graphql.Int,
+			Description: "Maximum number of items to return",
+		},
+	}
+
+	categoryType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(category).name, nil
+					},
+				},
+				"products": &graphql.Field{
+					Type:        nonNullList(productType),
+					Description: "Products with offers in the category, sorted by name",
+					Args:        first,
+					Resolve:     resolveCategoryProducts,
+				},
+			}
+		}),
+	})
+
+	productType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(product).name, nil
+					},
+				},
+				"category": &graphql.Field{
+					Type: graphql.NewNonNull(categoryType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return category{name: p.Source.(product).category}, nil
+					},
+				},
+				"offers": &graphql.Field{
+					Type:        nonNullList(offerType),
+					Description: "Offers sorted by price first, then by the review score of the supplier",
+					Args:        first,
+					Resolve:     resolveProductOffers,
+				},
+				"cheapestOffer": &graphql.Field{
+					Type:        offerType,
+					Description: "The first offer by price and review score, if there are any",
+					Resolve:     resolveCheapestOffer,
+				},
+			}
+		}),
+	})
+
+	supplierType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Supplier",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(supplier).name, nil
+					},
+				},
+				"reviewScore": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.Float),
+					Resolve: resolveReviewScore,
+				},
+				"offers": &graphql.Field{
+					Type:        nonNullList(offerType),
+					Description: "Offers by the supplier, sorted by product and category",
+					Args:        first,
+					Resolve:     resolveSupplierOffers,
+				},
+			}
+		}),
+	})
+
+	offerType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Offer",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"product": &graphql.Field{
+					Type: graphql.NewNonNull(productType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(offer).product, nil
+					},
+				},
+				"supplier": &graphql.Field{
+					Type: graphql.NewNonNull(supplierType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return supplier{name: p.Source.(offer).supplier}, nil
+					},
+				},
+				"price": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.Float),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(offer).price, nil
+					},
+				},
+			}
+		}),
+	})
+
+	name := &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"category": &graphql.Field{
+				Type: graphql.NewNonNull(categoryType),
+				Args: graphql.FieldConfigArgument{"name": name},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return category{name: p.Args["name"].(string)}, nil
+				},
+			},
+			"product": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: graphql.FieldConfigArgument{"name": name, "category": name},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return product{name: p.Args["name"].(string), category: p.Args["category"].(string)}, nil
+				},
+			},
+			"supplier": &graphql.Field{
+				Type: graphql.NewNonNull(supplierType),
+				Args: graphql.FieldConfigArgument{"name": name},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return supplier{name: p.Args["name"].(string)}, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func nonNullList(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+// limit returns the first items of a list if the first argument is set
+func limit(p graphql.ResolveParams, length int) int {
+	if first, ok := p.Args["first"].(int); ok && first >= 0 && first < length {
+		return first
+	}
+	return length
+}
+
+// iterate returns all offers which match the filter. Offers of suspended suppliers and offers which
+// are out of stock are left out.
+func iterate(ctx context.Context, offers database.Offers, filter database.OfferFilter) ([]database.Offer, error) {
+	it, err := offers.Iterate(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var result []database.Offer
+	for it.Next() {
+		result = append(result, it.Record().Offer)
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return ranking.Orderable(ctx, offers, result)
+}
+
+func resolveCategoryProducts(p graphql.ResolveParams) (interface{}, error) {
+	c := p.Source.(category)
+	offers, err := iterate(p.Context, resolversFrom(p.Context).offers, database.OfferFilter{Category: c.name})
+	if err != nil {
+		log.Printf("Error getting offers in category %q: %v", c.name, err)
+		return nil, errOffers
+	}
+
+	// Offers are sorted by product, so the offers of a product are next to each other
+	products := []product{}
+	for _, o := range offers {
+		if len(products) == 0 || products[len(products)-1].name != o.Product {
+			products = append(products, product{name: o.Product, category: c.name, offers: []database.Offer{}})
+		}
+		last := &products[len(products)-1]
+		last.offers = append(last.offers, o)
+	}
+
+	return products[:limit(p, len(products))], nil
+}
+
+// rankedOffers returns a thunk which returns the offers of a product by price and review score
+//
+// The review scores of all products resolved in the same level are looked up together.
+func rankedOffers(p graphql.ResolveParams) (func() ([]offer, error), error) {
+	r := resolversFrom(p.Context)
+	prod := p.Source.(product)
+
+	offers := prod.offers
+	if offers == nil {
+		var err error
+		if offers, err = r.offers.Get(p.Context, prod.name, prod.category); err == nil {
+			offers, err = ranking.Orderable(p.Context, r.offers, offers)
+		}
+		if err != nil {
+			log.Printf("Error getting offers for %q in %q: %v", prod.name, prod.category, err)
+			return nil, errOffers
+		}
+	}
+
+	for _, o := range offers {
+		r.reviews.load(o.Supplier)
+	}
+
+	return func() ([]offer, error) {
+		ranked, err := ranking.Rank(r.reviews, offers)
+		if err != nil {
+			log.Printf("Error getting review scores: %v", err)
+			return nil, errReviews
+		}
+
+		result := make([]offer, len(ranked))
+		for i, o := range ranked {
+			result[i] = offer{product: prod, supplier: o.Supplier, price: o.Price}
+		}
+		return result, nil
+	}, nil
+}
+
+func resolveProductOffers(p graphql.ResolveParams) (interface{}, error) {
+	ranked, err := rankedOffers(p)
+	if err != nil {
+		return nil, err
+	}
+	return func() (interface{}, error) {
+		offers, err := ranked()
+		if err != nil {
+			return nil, err
+		}
+		return offers[:limit(p, len(offers))], nil
+	}, nil
+}
+
+func resolveCheapestOffer(p graphql.ResolveParams) (interface{}, error) {
+	ranked, err := rankedOffers(p)
+	if err != nil {
+		return nil, err
+	}
+	return func() (interface{}, error) {
+		offers, err := ranked()
+		if err != nil || len(offers) == 0 {
+			return nil, err
+		}
+		return offers[0], nil
+	}, nil
+}
+
+func resolveReviewScore(p graphql.ResolveParams) (interface{}, error) {
+	reviews := resolversFrom(p.Context).reviews
+	name := p.Source.(supplier).name
+	reviews.load(name)
+
+	return func() (interface{}, error) {
+		scores, err := reviews.Suppliers([]string{name})
+		if err != nil {
+			log.Printf("Error getting review scores: %v", err)
+			return nil, errReviews
+		}
+		return scores[name], nil
+	}, nil
+}
+
+func resolveSupplierOffers(p graphql.ResolveParams) (interface{}, error) {
+	s := p.Source.(supplier)
+	offers, err := iterate(p.Context, resolversFrom(p.Context).offers, database.OfferFilter{Supplier: s.name})
+	if err != nil {
+		log.Printf("Error getting offers by supplier %q: %v", s.name, err)
+		return nil, errOffers
+	}
+
+	result := make([]offer, limit(p, len(offers)))
+	for i := range result {
+		o := offers[i]
+		result[i] = offer{product: product{name: o.Product, category: o.Category}, supplier: o.Supplier, price: o.Price}
+	}
+	return result, nil
+}
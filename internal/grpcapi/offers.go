@@ -0,0 +1,228 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/muffix/relayr-challenge/internal/changes"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/grpcapi/offerspb"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var changeTypes = map[database.ChangeType]offerspb.OfferChange_Type{
+	database.OfferInserted:  offerspb.OfferChange_TYPE_INSERTED,
+	database.OfferUpdated:   offerspb.OfferChange_TYPE_UPDATED,
+	database.OfferWithdrawn: offerspb.OfferChange_TYPE_WITHDRAWN,
+}
+
+// Search returns the offers for a product in a category, ranked by price and review score. Offers of
+// suspended suppliers and offers which are out of stock are hidden.
+func (s *Server) Search(ctx context.Context, req *offerspb.SearchRequest) (*offerspb.SearchResponse, error) {
+	if req.GetProduct() == "" || req.GetCategory() == "" {
+		return nil, status.Error(codes.InvalidArgument, "product and category are required")
+	}
+
+	offers, err := s.offers.Get(ctx, req.GetProduct(), req.GetCategory())
+	if err != nil {
+		log.Printf("Error getting offers: %v", err)
+		return nil, status.Error(codes.Internal, "error getting offers")
+	}
+	offers, err = ranking.Orderable(ctx, s.offers, offers)
+	if err != nil {
+		log.Printf("Error getting suppliers: %v", err)
+		return nil, status.Error(codes.Internal, "error getting offers")
+	}
+
+	ranked, err := ranking.Rank(ranking.ReviewerFor(ctx, s.offers, s.reviewer), offers)
+	if err != nil {
+		log.Printf("Error getting review scores: %v", err)
+		return nil, status.Error(codes.Unavailable, "error getting review scores")
+	}
+
+	resp := &offerspb.SearchResponse{
+		Product:  req.GetProduct(),
+		Category: req.GetCategory(),
+		Offers:   make([]*offerspb.RankedOffer, len(ranked)),
+	}
+	for i, offer := range ranked {
+		resp.Offers[i] = &offerspb.RankedOffer{
+			Supplier:    offer.Supplier,
+			ReviewScore: offer.ReviewScore,
+			Price:       offer.Price,
+		}
+	}
+	return resp, nil
+}
+
+// Upsert inserts the offers in one transaction
+func (s *Server) Upsert(ctx context.Context, req *offerspb.UpsertRequest) (*offerspb.UpsertResponse, error) {
+	offers := make([]database.Offer, len(req.GetOffers()))
+	for i, offer := range req.GetOffers() {
+		if err := validateOffer(offer); err != nil {
+			return nil, err
+		}
+		offers[i] = fromProto(offer)
+	}
+
+	if err := s.insert(ctx, offers); err != nil {
+		return nil, err
+	}
+	return &offerspb.UpsertResponse{ImportedOffersCount: int32(len(offers))}, nil
+}
+
+// UpsertStream inserts a stream of offers in batches
+//
+// Every batch is inserted in its own transaction, so the batches before an invalid offer or a
+// failed insert stay imported.
+func (s *Server) UpsertStream(stream grpc.ClientStreamingServer[offerspb.Offer, offerspb.UpsertResponse]) error {
+	var imported int32
+	batch := make([]database.Offer, 0, s.upsertBatchSize)
+
+	flush := func() error {
+		if err := s.insert(stream.Context(), batch); err != nil {
+			return err
+		}
+		imported += int32(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		offer, err := stream.Recv()
+		if err == io.EOF {
+			if err := flush(); err != nil {
+				return err
+			}
+			return stream.SendAndClose(&offerspb.UpsertResponse{ImportedOffersCount: imported})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := validateOffer(offer); err != nil {
+			return err
+		}
+		batch = append(batch, fromProto(offer))
+		if len(batch) == s.upsertBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Watch streams changes to offers until the client cancels the call
+//
+// Watches which fall behind or are still running when the server stops end with
+// codes.Unavailable. Clients can resume them with the ID of the last change they received.
+func (s *Server) Watch(req *offerspb.WatchRequest, stream grpc.ServerStreamingServer[offerspb.OfferChange]) error {
+	// Changes are reported for the product an alias belongs to
+	product := req.GetProduct()
+	if aliases, ok := s.offers.(database.ProductAliases); ok && product != "" {
+		var err error
+		if product, err = aliases.ResolveProduct(stream.Context(), product); err != nil {
+			log.Printf("Error resolving product: %v", err)
+			return status.Error(codes.Internal, "error resolving product")
+		}
+	}
+
+	// The gRPC API only serves the default tenant
+	filter := changes.Filter{
+		Tenant:   database.DefaultTenant,
+		Product:  product,
+		Category: req.GetCategory(),
+	}
+
+	backlog, sub, complete := s.changes.Subscribe(req.GetLastEventId(), watchBufferSize)
+	defer sub.Close()
+
+	if !complete {
+		if err := stream.Send(&offerspb.OfferChange{Type: offerspb.OfferChange_TYPE_RESET}); err != nil {
+			return err
+		}
+	}
+
+	send := func(event changes.Event) error {
+		if !filter.Matches(event) {
+			return nil
+		}
+		return stream.Send(toProto(event))
+	}
+
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				if sub.Overflowed() {
+					return status.Error(codes.Unavailable, "watch fell behind, resume it with the last event ID")
+				}
+				return nil
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is stopping, resume the watch with the last event ID")
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// insert inserts the offers and hides database errors from clients
+func (s *Server) insert(ctx context.Context, offers []database.Offer) error {
+	if len(offers) == 0 {
+		return nil
+	}
+	if err := s.offers.InsertMultiple(ctx, offers); err != nil {
+		log.Printf("Error inserting offers: %v", err)
+		return status.Error(codes.Internal, "error inserting offers")
+	}
+	return nil
+}
+
+func validateOffer(offer *offerspb.Offer) error {
+	if offer.GetProduct() == "" || offer.GetCategory() == "" || offer.GetSupplier() == "" {
+		return status.Error(codes.InvalidArgument, "product, category and supplier are required")
+	}
+	if offer.GetPrice() < 0 {
+		return status.Error(codes.InvalidArgument, "price must not be negative")
+	}
+	return nil
+}
+
+func fromProto(offer *offerspb.Offer) database.Offer {
+	return database.Offer{
+		Product:  offer.GetProduct(),
+		Category: offer.GetCategory(),
+		Supplier: offer.GetSupplier(),
+		Price:    offer.GetPrice(),
+	}
+}
+
+func toProto(event changes.Event) *offerspb.OfferChange {
+	return &offerspb.OfferChange{
+		Id:   event.ID,
+		Type: changeTypes[event.Type],
+		Offer: &offerspb.Offer{
+			Product:  event.Offer.Product,
+			Category: event.Offer.Category,
+			Supplier: event.Offer.Supplier,
+			Price:    event.Offer.Price,
+		},
+		PreviousPrice: event.PreviousPrice,
+		Time:          timestamppb.New(event.Time),
+	}
+}
@@ -0,0 +1,672 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: offers.proto
+
+package offerspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type OfferChange_Type int32
+
+const (
+	OfferChange_TYPE_UNSPECIFIED OfferChange_Type = 0
+	OfferChange_TYPE_INSERTED    OfferChange_Type = 1
+	OfferChange_TYPE_UPDATED     OfferChange_Type = 2
+	OfferChange_TYPE_WITHDRAWN   OfferChange_Type = 3
+	// TYPE_RESET is sent instead of changes which are no longer available for resuming. Clients
+	// should search for the offers again.
+	OfferChange_TYPE_RESET OfferChange_Type = 4
+)
+
+// Enum value maps for OfferChange_Type.
+var (
+	OfferChange_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_INSERTED",
+		2: "TYPE_UPDATED",
+		3: "TYPE_WITHDRAWN",
+		4: "TYPE_RESET",
+	}
+	OfferChange_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_INSERTED":    1,
+		"TYPE_UPDATED":     2,
+		"TYPE_WITHDRAWN":   3,
+		"TYPE_RESET":       4,
+	}
+)
+
+func (x OfferChange_Type) Enum() *OfferChange_Type {
+	p := new(OfferChange_Type)
+	*p = x
+	return p
+}
+
+func (x OfferChange_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (OfferChange_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_offers_proto_enumTypes[0].Descriptor()
+}
+
+func (OfferChange_Type) Type() protoreflect.EnumType {
+	return &file_offers_proto_enumTypes[0]
+}
+
+func (x OfferChange_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use OfferChange_Type.Descriptor instead.
+func (OfferChange_Type) EnumDescriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{7, 0}
+}
+
+// Offer is an offer for a product by a supplier
+type Offer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       string                 `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Category      string                 `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	Supplier      string                 `protobuf:"bytes,3,opt,name=supplier,proto3" json:"supplier,omitempty"`
+	Price         float32                `protobuf:"fixed32,4,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Offer) Reset() {
+	*x = Offer{}
+	mi := &file_offers_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Offer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Offer) ProtoMessage() {}
+
+func (x *Offer) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Offer.ProtoReflect.Descriptor instead.
+func (*Offer) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Offer) GetProduct() string {
+	if x != nil {
+		return x.Product
+	}
+	return ""
+}
+
+func (x *Offer) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Offer) GetSupplier() string {
+	if x != nil {
+		return x.Supplier
+	}
+	return ""
+}
+
+func (x *Offer) GetPrice() float32 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+type SearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Product       string                 `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Category      string                 `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_offers_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SearchRequest) GetProduct() string {
+	if x != nil {
+		return x.Product
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+type SearchResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Product  string                 `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Category string                 `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	// Offers are sorted by price first, then by review score
+	Offers        []*RankedOffer `protobuf:"bytes,3,rep,name=offers,proto3" json:"offers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_offers_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchResponse) GetProduct() string {
+	if x != nil {
+		return x.Product
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetOffers() []*RankedOffer {
+	if x != nil {
+		return x.Offers
+	}
+	return nil
+}
+
+// RankedOffer is an offer with the review score of its supplier
+type RankedOffer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Supplier      string                 `protobuf:"bytes,1,opt,name=supplier,proto3" json:"supplier,omitempty"`
+	ReviewScore   float32                `protobuf:"fixed32,2,opt,name=review_score,json=reviewScore,proto3" json:"review_score,omitempty"`
+	Price         float32                `protobuf:"fixed32,3,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RankedOffer) Reset() {
+	*x = RankedOffer{}
+	mi := &file_offers_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RankedOffer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RankedOffer) ProtoMessage() {}
+
+func (x *RankedOffer) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RankedOffer.ProtoReflect.Descriptor instead.
+func (*RankedOffer) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RankedOffer) GetSupplier() string {
+	if x != nil {
+		return x.Supplier
+	}
+	return ""
+}
+
+func (x *RankedOffer) GetReviewScore() float32 {
+	if x != nil {
+		return x.ReviewScore
+	}
+	return 0
+}
+
+func (x *RankedOffer) GetPrice() float32 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+type UpsertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Offers        []*Offer               `protobuf:"bytes,1,rep,name=offers,proto3" json:"offers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpsertRequest) Reset() {
+	*x = UpsertRequest{}
+	mi := &file_offers_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertRequest) ProtoMessage() {}
+
+func (x *UpsertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertRequest.ProtoReflect.Descriptor instead.
+func (*UpsertRequest) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpsertRequest) GetOffers() []*Offer {
+	if x != nil {
+		return x.Offers
+	}
+	return nil
+}
+
+type UpsertResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	ImportedOffersCount int32                  `protobuf:"varint,1,opt,name=imported_offers_count,json=importedOffersCount,proto3" json:"imported_offers_count,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *UpsertResponse) Reset() {
+	*x = UpsertResponse{}
+	mi := &file_offers_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpsertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpsertResponse) ProtoMessage() {}
+
+func (x *UpsertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpsertResponse.ProtoReflect.Descriptor instead.
+func (*UpsertResponse) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpsertResponse) GetImportedOffersCount() int32 {
+	if x != nil {
+		return x.ImportedOffersCount
+	}
+	return 0
+}
+
+// WatchRequest filters the changes to watch. Empty fields match all offers.
+type WatchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Product  string                 `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Category string                 `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	// last_event_id resumes a watch after the change with this ID. 0 only streams new changes.
+	LastEventId   uint64 `protobuf:"varint,3,opt,name=last_event_id,json=lastEventId,proto3" json:"last_event_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_offers_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchRequest) GetProduct() string {
+	if x != nil {
+		return x.Product
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetLastEventId() uint64 {
+	if x != nil {
+		return x.LastEventId
+	}
+	return 0
+}
+
+type OfferChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id can be passed as last_event_id to resume the watch. It's 0 for resets.
+	Id   uint64           `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type OfferChange_Type `protobuf:"varint,2,opt,name=type,proto3,enum=offers.v1.OfferChange_Type" json:"type,omitempty"`
+	// offer is the offer after the change. Withdrawn offers have their last price.
+	Offer *Offer `protobuf:"bytes,3,opt,name=offer,proto3" json:"offer,omitempty"`
+	// previous_price is the price before an update or withdrawal
+	PreviousPrice float32                `protobuf:"fixed32,4,opt,name=previous_price,json=previousPrice,proto3" json:"previous_price,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OfferChange) Reset() {
+	*x = OfferChange{}
+	mi := &file_offers_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OfferChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OfferChange) ProtoMessage() {}
+
+func (x *OfferChange) ProtoReflect() protoreflect.Message {
+	mi := &file_offers_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OfferChange.ProtoReflect.Descriptor instead.
+func (*OfferChange) Descriptor() ([]byte, []int) {
+	return file_offers_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *OfferChange) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *OfferChange) GetType() OfferChange_Type {
+	if x != nil {
+		return x.Type
+	}
+	return OfferChange_TYPE_UNSPECIFIED
+}
+
+func (x *OfferChange) GetOffer() *Offer {
+	if x != nil {
+		return x.Offer
+	}
+	return nil
+}
+
+func (x *OfferChange) GetPreviousPrice() float32 {
+	if x != nil {
+		return x.PreviousPrice
+	}
+	return 0
+}
+
+func (x *OfferChange) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_offers_proto protoreflect.FileDescriptor
+
+const file_offers_proto_rawDesc = "" +
+	"\n" +
+	"\foffers.proto\x12\toffers.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"o\n" +
+	"\x05Offer\x12\x18\n" +
+	"\aproduct\x18\x01 \x01(\tR\aproduct\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\x1a\n" +
+	"\bsupplier\x18\x03 \x01(\tR\bsupplier\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\x02R\x05price\"E\n" +
+	"\rSearchRequest\x12\x18\n" +
+	"\aproduct\x18\x01 \x01(\tR\aproduct\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\"v\n" +
+	"\x0eSearchResponse\x12\x18\n" +
+	"\aproduct\x18\x01 \x01(\tR\aproduct\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12.\n" +
+	"\x06offers\x18\x03 \x03(\v2\x16.offers.v1.RankedOfferR\x06offers\"b\n" +
+	"\vRankedOffer\x12\x1a\n" +
+	"\bsupplier\x18\x01 \x01(\tR\bsupplier\x12!\n" +
+	"\freview_score\x18\x02 \x01(\x02R\vreviewScore\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x02R\x05price\"9\n" +
+	"\rUpsertRequest\x12(\n" +
+	"\x06offers\x18\x01 \x03(\v2\x10.offers.v1.OfferR\x06offers\"D\n" +
+	"\x0eUpsertResponse\x122\n" +
+	"\x15imported_offers_count\x18\x01 \x01(\x05R\x13importedOffersCount\"h\n" +
+	"\fWatchRequest\x12\x18\n" +
+	"\aproduct\x18\x01 \x01(\tR\aproduct\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\"\n" +
+	"\rlast_event_id\x18\x03 \x01(\x04R\vlastEventId\"\xb4\x02\n" +
+	"\vOfferChange\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12/\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x1b.offers.v1.OfferChange.TypeR\x04type\x12&\n" +
+	"\x05offer\x18\x03 \x01(\v2\x10.offers.v1.OfferR\x05offer\x12%\n" +
+	"\x0eprevious_price\x18\x04 \x01(\x02R\rpreviousPrice\x12.\n" +
+	"\x04time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"e\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rTYPE_INSERTED\x10\x01\x12\x10\n" +
+	"\fTYPE_UPDATED\x10\x02\x12\x12\n" +
+	"\x0eTYPE_WITHDRAWN\x10\x03\x12\x0e\n" +
+	"\n" +
+	"TYPE_RESET\x10\x042\x87\x02\n" +
+	"\fOfferService\x12=\n" +
+	"\x06Search\x12\x18.offers.v1.SearchRequest\x1a\x19.offers.v1.SearchResponse\x12=\n" +
+	"\x06Upsert\x12\x18.offers.v1.UpsertRequest\x1a\x19.offers.v1.UpsertResponse\x12=\n" +
+	"\fUpsertStream\x12\x10.offers.v1.Offer\x1a\x19.offers.v1.UpsertResponse(\x01\x12:\n" +
+	"\x05Watch\x12\x17.offers.v1.WatchRequest\x1a\x16.offers.v1.OfferChange0\x01B>Z<github.com/muffix/relayr-challenge/internal/grpcapi/offerspbb\x06proto3"
+
+var (
+	file_offers_proto_rawDescOnce sync.Once
+	file_offers_proto_rawDescData []byte
+)
+
+func file_offers_proto_rawDescGZIP() []byte {
+	file_offers_proto_rawDescOnce.Do(func() {
+		file_offers_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_offers_proto_rawDesc), len(file_offers_proto_rawDesc)))
+	})
+	return file_offers_proto_rawDescData
+}
+
+var file_offers_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_offers_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_offers_proto_goTypes = []any{
+	(OfferChange_Type)(0),         // 0: offers.v1.OfferChange.Type
+	(*Offer)(nil),                 // 1: offers.v1.Offer
+	(*SearchRequest)(nil),         // 2: offers.v1.SearchRequest
+	(*SearchResponse)(nil),        // 3: offers.v1.SearchResponse
+	(*RankedOffer)(nil),           // 4: offers.v1.RankedOffer
+	(*UpsertRequest)(nil),         // 5: offers.v1.UpsertRequest
+	(*UpsertResponse)(nil),        // 6: offers.v1.UpsertResponse
+	(*WatchRequest)(nil),          // 7: offers.v1.WatchRequest
+	(*OfferChange)(nil),           // 8: offers.v1.OfferChange
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_offers_proto_depIdxs = []int32{
+	4, // 0: offers.v1.SearchResponse.offers:type_name -> offers.v1.RankedOffer
+	1, // 1: offers.v1.UpsertRequest.offers:type_name -> offers.v1.Offer
+	0, // 2: offers.v1.OfferChange.type:type_name -> offers.v1.OfferChange.Type
+	1, // 3: offers.v1.OfferChange.offer:type_name -> offers.v1.Offer
+	9, // 4: offers.v1.OfferChange.time:type_name -> google.protobuf.Timestamp
+	2, // 5: offers.v1.OfferService.Search:input_type -> offers.v1.SearchRequest
+	5, // 6: offers.v1.OfferService.Upsert:input_type -> offers.v1.UpsertRequest
+	1, // 7: offers.v1.OfferService.UpsertStream:input_type -> offers.v1.Offer
+	7, // 8: offers.v1.OfferService.Watch:input_type -> offers.v1.WatchRequest
+	3, // 9: offers.v1.OfferService.Search:output_type -> offers.v1.SearchResponse
+	6, // 10: offers.v1.OfferService.Upsert:output_type -> offers.v1.UpsertResponse
+	6, // 11: offers.v1.OfferService.UpsertStream:output_type -> offers.v1.UpsertResponse
+	8, // 12: offers.v1.OfferService.Watch:output_type -> offers.v1.OfferChange
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_offers_proto_init() }
+func file_offers_proto_init() {
+	if File_offers_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_offers_proto_rawDesc), len(file_offers_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_offers_proto_goTypes,
+		DependencyIndexes: file_offers_proto_depIdxs,
+		EnumInfos:         file_offers_proto_enumTypes,
+		MessageInfos:      file_offers_proto_msgTypes,
+	}.Build()
+	File_offers_proto = out.File
+	file_offers_proto_goTypes = nil
+	file_offers_proto_depIdxs = nil
+}
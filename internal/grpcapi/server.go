@@ -0,0 +1,159 @@
+// Package grpcapi serves the offer API over gRPC
+package grpcapi
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/muffix/relayr-challenge/internal/changes"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/grpcapi/offerspb"
+	"github.com/muffix/relayr-challenge/internal/review"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// changeLogCapacity is the number of changes kept for watches resuming with a last event ID
+	changeLogCapacity = 1000
+	// watchBufferSize is the number of changes buffered per watch. Watches which fall further
+	// behind are ended and can be resumed.
+	watchBufferSize = 64
+	// defaultUpsertBatchSize is the number of streamed offers inserted per transaction
+	defaultUpsertBatchSize = 500
+)
+
+// Server is the gRPC server for the offer API
+//
+// Like httpapi.Service, dependencies are added with setters after it has been created.
+type Server struct {
+	offerspb.UnimplementedOfferServiceServer
+
+	addr   string
+	server *grpc.Server
+	health *health.Server
+
+	offers   database.Offers
+	reviewer review.Reviewer
+	changes  *changes.Log
+
+	upsertBatchSize int
+	// readOnly returns whether writes are rejected. It's nil if they never are.
+	readOnly func() bool
+	// stopping is closed when the server is stopped, to end watches
+	stopping chan struct{}
+}
+
+// writeMethods are the methods which change offers
+var writeMethods = map[string]bool{
+	offerspb.OfferService_Upsert_FullMethodName:       true,
+	offerspb.OfferService_UpsertStream_FullMethodName: true,
+}
+
+// NewServer returns a server which listens on the port once it's started
+//
+// Besides the offer service, it serves the standard health and reflection services.
+func NewServer(port int) *Server {
+	s := &Server{
+		addr:            ":" + strconv.Itoa(port),
+		health:          health.NewServer(),
+		changes:         changes.NewLog(changeLogCapacity),
+		upsertBatchSize: defaultUpsertBatchSize,
+		stopping:        make(chan struct{}),
+	}
+	s.server = grpc.NewServer(
+		grpc.UnaryInterceptor(s.rejectUnaryWrites),
+		grpc.StreamInterceptor(s.rejectStreamWrites),
+	)
+
+	offerspb.RegisterOfferServiceServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+
+	return s
+}
+
+// SetDatabase is a setter for the database
+//
+// If the database reports changes to offers, they're streamed to watches.
+func (s *Server) SetDatabase(db database.Offers) {
+	s.offers = db
+	if notifier, ok := db.(database.ChangeNotifier); ok {
+		notifier.AddChangeListener(s.changes.Publish)
+	}
+}
+
+// SetReadOnly is a setter for whether writes are rejected, e.g. httpapi.Service.ReadOnly, so that
+// the read-only mode of the service applies to gRPC as well
+func (s *Server) SetReadOnly(readOnly func() bool) {
+	s.readOnly = readOnly
+}
+
+// rejectUnaryWrites is a unary interceptor which rejects writes with codes.Unavailable while the
+// server is read-only
+func (s *Server) rejectUnaryWrites(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.checkWritable(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// rejectStreamWrites is a stream interceptor which rejects writes with codes.Unavailable while the
+// server is read-only
+func (s *Server) rejectStreamWrites(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.checkWritable(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+func (s *Server) checkWritable(method string) error {
+	if writeMethods[method] && s.readOnly != nil && s.readOnly() {
+		return status.Error(codes.Unavailable, "service is read-only, try again later")
+	}
+	return nil
+}
+
+// SetReviewer is a setter for a client of a reviews engine
+func (s *Server) SetReviewer(r review.Reviewer) {
+	s.reviewer = r
+}
+
+// Start listens on the port and serves requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		log.Printf("Serving gRPC on port %s", s.addr)
+		if err := s.Serve(listener); err != nil {
+			log.Printf("Error from gRPC server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Serve serves requests on the listener until the server is stopped
+func (s *Server) Serve(listener net.Listener) error {
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(offerspb.OfferService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s.server.Serve(listener)
+}
+
+// Stop reports that the server isn't serving anymore and waits for running calls to finish
+//
+// Watches would only end when their clients cancel them, so they're ended with codes.Unavailable
+// and can be resumed on another instance.
+func (s *Server) Stop() {
+	s.health.Shutdown()
+	close(s.stopping)
+	s.server.GracefulStop()
+}
@@ -0,0 +1,338 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/grpcapi/offerspb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type mockReviewer struct {
+	err error
+}
+
+func (r mockReviewer) Suppliers(supplierNames []string) (map[string]float32, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	scores := make(map[string]float32, len(supplierNames))
+	for _, supplier := range supplierNames {
+		scores[supplier] = float32(len(supplier))
+	}
+	return scores, nil
+}
+
+// newTestServer serves a server with a temporary database over an in-memory listener and returns a connection to it
+func newTestServer(t *testing.T, reviewer mockReviewer) (*Server, *database.OffersSQLiteDatabase, *grpc.ClientConn) {
+	db, err := database.InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	server := NewServer(0)
+	server.SetDatabase(db)
+	server.SetReviewer(reviewer)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return server, db, conn
+}
+
+func TestServer_searchAndUpsert(t *testing.T) {
+	_, _, conn := newTestServer(t, mockReviewer{})
+	client := offerspb.NewOfferServiceClient(conn)
+	ctx := context.Background()
+
+	resp, err := client.Upsert(ctx, &offerspb.UpsertRequest{Offers: []*offerspb.Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: 20},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Towels Inc", Price: 10},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Sirius", Price: 20},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetImportedOffersCount() != 3 {
+		t.Fatalf("Got %d imported offers, want 3", resp.GetImportedOffersCount())
+	}
+
+	result, err := client.Search(ctx, &offerspb.SearchRequest{Product: "Towel", Category: "Must Haves"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Towels Inc", "Sirius", "Acme"}
+	if len(result.GetOffers()) != len(want) {
+		t.Fatalf("Got %d offers, want %d", len(result.GetOffers()), len(want))
+	}
+	for i, offer := range result.GetOffers() {
+		if offer.GetSupplier() != want[i] {
+			t.Fatalf("Got supplier %q at position %d, want %q", offer.GetSupplier(), i, want[i])
+		}
+	}
+}
+
+func TestServer_searchHidesSuspendedSuppliers(t *testing.T) {
+	_, db, conn := newTestServer(t, mockReviewer{})
+	client := offerspb.NewOfferServiceClient(conn)
+	ctx := context.Background()
+
+	err := db.InsertMultiple(ctx, []database.Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: 20},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Towels Inc", Price: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suppliers, err := db.SuppliersByName(ctx, []string{"Towels Inc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.UpdateSupplier(ctx, database.Supplier{ID: suppliers["Towels Inc"].ID, Status: database.SupplierSuspended}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Search(ctx, &offerspb.SearchRequest{Product: "Towel", Category: "Must Haves"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.GetOffers()) != 1 || result.GetOffers()[0].GetSupplier() != "Acme" {
+		t.Fatalf("Got offers %v, want only the offer of the active supplier", result.GetOffers())
+	}
+}
+
+func TestServer_searchHidesUnavailableOffers(t *testing.T) {
+	_, db, conn := newTestServer(t, mockReviewer{})
+	client := offerspb.NewOfferServiceClient(conn)
+	ctx := context.Background()
+
+	soldOut := 0
+	err := db.InsertMultiple(ctx, []database.Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: 20},
+		{Product: "Towel", Category: "Must Haves", Supplier: "Towels Inc", Price: 10, Stock: database.Stock{Quantity: &soldOut}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Search(ctx, &offerspb.SearchRequest{Product: "Towel", Category: "Must Haves"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.GetOffers()) != 1 || result.GetOffers()[0].GetSupplier() != "Acme" {
+		t.Fatalf("Got offers %v, want only the offer in stock", result.GetOffers())
+	}
+}
+
+func TestServer_withInvalidRequests(t *testing.T) {
+	_, _, conn := newTestServer(t, mockReviewer{err: errors.New("reviews engine down")})
+	client := offerspb.NewOfferServiceClient(conn)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"search without category", func() error {
+			_, err := client.Search(ctx, &offerspb.SearchRequest{Product: "Towel"})
+			return err
+		}, codes.InvalidArgument},
+		{"upsert without supplier", func() error {
+			_, err := client.Upsert(ctx, &offerspb.UpsertRequest{Offers: []*offerspb.Offer{{Product: "Towel", Category: "Must Haves"}}})
+			return err
+		}, codes.InvalidArgument},
+		{"upsert with negative price", func() error {
+			_, err := client.Upsert(ctx, &offerspb.UpsertRequest{Offers: []*offerspb.Offer{{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: -1}}})
+			return err
+		}, codes.InvalidArgument},
+		{"search with reviews engine down", func() error {
+			_, err := client.Search(ctx, &offerspb.SearchRequest{Product: "Towel", Category: "Must Haves"})
+			return err
+		}, codes.Unavailable},
+	}
+
+	for _, testCase := range testCases {
+		if got := status.Code(testCase.call()); got != testCase.want {
+			t.Fatalf("%s: got code %v, want %v", testCase.name, got, testCase.want)
+		}
+	}
+}
+
+func TestServer_UpsertStream(t *testing.T) {
+	server, db, conn := newTestServer(t, mockReviewer{})
+	server.upsertBatchSize = 2
+	client := offerspb.NewOfferServiceClient(conn)
+
+	stream, err := client.UpsertStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, supplier := range []string{"Acme", "Sirius", "Towels Inc"} {
+		if err = stream.Send(&offerspb.Offer{Product: "Towel", Category: "Must Haves", Supplier: supplier, Price: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetImportedOffersCount() != 3 {
+		t.Fatalf("Got %d imported offers, want 3", resp.GetImportedOffersCount())
+	}
+
+	offers, err := db.Get(context.Background(), "Towel", "Must Haves")
+	if err != nil || len(offers) != 3 {
+		t.Fatalf("Got offers %+v and error %v, want 3 offers", offers, err)
+	}
+}
+
+func TestServer_readOnly(t *testing.T) {
+	server, db, conn := newTestServer(t, mockReviewer{})
+	readOnly := true
+	server.SetReadOnly(func() bool { return readOnly })
+	client := offerspb.NewOfferServiceClient(conn)
+	ctx := context.Background()
+	towel := &offerspb.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Acme", Price: 10}
+
+	_, err := client.Upsert(ctx, &offerspb.UpsertRequest{Offers: []*offerspb.Offer{towel}})
+	if got := status.Code(err); got != codes.Unavailable {
+		t.Fatalf("Got code %v for an upsert, want %v", got, codes.Unavailable)
+	}
+
+	stream, err := client.UpsertStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(towel)
+	if _, err = stream.CloseAndRecv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Got code %v for a streamed upsert, want %v", status.Code(err), codes.Unavailable)
+	}
+
+	// Reads are still served
+	if _, err = client.Search(ctx, &offerspb.SearchRequest{Product: "Towel", Category: "Must Haves"}); err != nil {
+		t.Fatalf("Got error %v searching while read-only, want none", err)
+	}
+
+	offers, err := db.Get(ctx, "Towel", "Must Haves")
+	if err != nil || len(offers) != 0 {
+		t.Fatalf("Got offers %+v and error %v, want none while read-only", offers, err)
+	}
+
+	readOnly = false
+	if _, err = client.Upsert(ctx, &offerspb.UpsertRequest{Offers: []*offerspb.Offer{towel}}); err != nil {
+		t.Fatalf("Got error %v for an upsert after the read-only mode, want none", err)
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	_, db, conn := newTestServer(t, mockReviewer{})
+	client := offerspb.NewOfferServiceClient(conn)
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Acme", 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(context.Background(), "Hat", "Must Haves", "Acme", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Acme", 15); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Resuming after the first change replays the changes that match the filter
+	stream, err := client.Watch(ctx, &offerspb.WatchRequest{Product: "Towel", Category: "Must Haves", LastEventId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.GetType() != offerspb.OfferChange_TYPE_UPDATED || change.GetOffer().GetPrice() != 15 || change.GetPreviousPrice() != 20 {
+		t.Fatalf("Got change %v, want the update of the towel", change)
+	}
+	if change.GetId() != 3 {
+		t.Fatalf("Got ID %d, want 3", change.GetId())
+	}
+}
+
+func TestServer_WatchWithAlias(t *testing.T) {
+	_, db, conn := newTestServer(t, mockReviewer{})
+	client := offerspb.NewOfferServiceClient(conn)
+
+	if _, _, err := db.AddProductAlias(context.Background(), "Bath Towel", "Towel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(context.Background(), "Hat", "Must Haves", "Acme", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert(context.Background(), "Bath Towel", "Must Haves", "Acme", 20); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &offerspb.WatchRequest{Product: "bath towel", LastEventId: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.GetType() != offerspb.OfferChange_TYPE_INSERTED || change.GetOffer().GetProduct() != "Towel" {
+		t.Fatalf("Got change %v, want the insert of the towel", change)
+	}
+}
+
+func TestServer_health(t *testing.T) {
+	server, _, conn := newTestServer(t, mockReviewer{})
+	client := healthpb.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "offers.v1.OfferService"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("Got status %v, want %v", resp.GetStatus(), healthpb.HealthCheckResponse_SERVING)
+	}
+
+	server.health.Shutdown()
+	resp, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("Got status %v, want %v", resp.GetStatus(), healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
@@ -0,0 +1,255 @@
+package httpapi
+
+import (
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+// defaultReadOnlyRetryAfter is the Retry-After of rejected writes unless another one is requested
+const defaultReadOnlyRetryAfter = time.Minute
+
+// readOnlyMode is whether the service rejects writes, e.g. during maintenance of the database
+type readOnlyMode struct {
+	mu         sync.RWMutex
+	enabled    bool
+	since      time.Time
+	retryAfter time.Duration
+}
+
+// readOnlyState is the read-only mode as it's returned and accepted by the admin routes
+type readOnlyState struct {
+	Enabled bool `json:"enabled"`
+	// RetryAfterSeconds is sent in the Retry-After header of rejected writes
+	RetryAfterSeconds int        `json:"retryAfterSeconds,omitempty"`
+	Since             *time.Time `json:"since,omitempty"`
+}
+
+func (m *readOnlyMode) set(enabled bool, retryAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled && !m.enabled {
+		m.since = time.Now().UTC()
+	}
+	m.enabled, m.retryAfter = enabled, retryAfter
+}
+
+func (m *readOnlyMode) state() readOnlyState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.enabled {
+		return readOnlyState{}
+	}
+	since := m.since
+	return readOnlyState{
+		Enabled:           true,
+		RetryAfterSeconds: int(math.Ceil(m.retryAfter.Seconds())),
+		Since:             &since,
+	}
+}
+
+// readOnly is returned for writes while the service is read-only
+func readOnly() *apiError {
+	return &apiError{
+		status: http.StatusServiceUnavailable,
+		code:   codeReadOnly,
+		title:  "Service is read-only",
+		detail: "The service is in maintenance and doesn't accept changes. Try again later.",
+	}
+}
+
+// SetReadOnly makes the service reject writes with 503 until it's disabled again. Rejected
+// requests get a Retry-After header with the given duration.
+func (s *Service) SetReadOnly(enabled bool, retryAfter time.Duration) {
+	s.readOnly.set(enabled, retryAfter)
+}
+
+// ReadOnly returns whether the service rejects writes. Other APIs which write to the same database
+// check it, so that they're read-only, too.
+func (s *Service) ReadOnly() bool {
+	return s.readOnly.state().Enabled
+}
+
+// writable wraps the handler of a route which changes data, so that it's rejected while the
+// service is read-only
+func (s *Service) writable(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if state := s.readOnly.state(); state.Enabled {
+			w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfterSeconds))
+			s.respondError(w, r, readOnly())
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleReadOnly returns an http.HandlerFunc which returns whether the service is read-only
+func (s *Service) handleReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, s.readOnly.state(), http.StatusOK)
+	}
+}
+
+// handleSetReadOnly returns an http.HandlerFunc which enables or disables the read-only mode
+func (s *Service) handleSetReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := readOnlyState{}
+		if err := s.decode(w, r, &req); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if req.RetryAfterSeconds < 0 {
+			s.respondError(w, r, invalidRequest("retryAfterSeconds must not be negative."))
+			return
+		}
+
+		retryAfter := defaultReadOnlyRetryAfter
+		if req.RetryAfterSeconds > 0 {
+			retryAfter = time.Duration(req.RetryAfterSeconds) * time.Second
+		}
+		s.readOnly.set(req.Enabled, retryAfter)
+
+		s.respond(w, r, s.readOnly.state(), http.StatusOK)
+	}
+}
+
+// logLevelRequest is the body of requests and responses of the log level
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// SetLogLevel sets the minimum level of messages logged with log/slog, e.g. slog.LevelDebug to
+// log every request
+//
+// Messages logged with the log package are always written.
+func (s *Service) SetLogLevel(level slog.Level) {
+	s.logLevel.Set(level)
+	slog.SetLogLoggerLevel(level)
+}
+
+// handleLogLevel returns an http.HandlerFunc which returns the log level
+func (s *Service) handleLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, logLevelRequest{s.logLevel.Level().String()}, http.StatusOK)
+	}
+}
+
+// handleSetLogLevel returns an http.HandlerFunc which changes the log level, e.g. to debug
+func (s *Service) handleSetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := logLevelRequest{}
+		if err := s.decode(w, r, &req); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+			s.respondError(w, r, invalidRequest("level must be one of debug, info, warn and error."))
+			return
+		}
+		s.SetLogLevel(level)
+		slog.Info("Changed log level", "level", level)
+
+		s.respond(w, r, logLevelRequest{level.String()}, http.StatusOK)
+	}
+}
+
+// purgeResponse is the response to purging the cache of review scores
+type purgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// handleReviewCachePurge returns an http.HandlerFunc which drops the cached review scores
+func (s *Service) handleReviewCachePurge() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		purger, ok := s.reviewer.(review.Purger)
+		if !ok {
+			s.respondError(w, r, notConfigured("Review caches"))
+			return
+		}
+
+		s.respond(w, r, purgeResponse{purger.Purge()}, http.StatusOK)
+	}
+}
+
+// databaseStats are the statistics of the database published with the expvar variables
+type databaseStats struct {
+	Readers            sql.DBStats `json:"readers"`
+	Writer             sql.DBStats `json:"writer"`
+	LastQuickCheck     *time.Time  `json:"lastQuickCheck,omitempty"`
+	QuickCheckProblems []string    `json:"quickCheckProblems,omitempty"`
+	Error              string      `json:"error,omitempty"`
+}
+
+// serviceStats are the statistics of the service published with the expvar variables
+type serviceStats struct {
+	ReadOnly    bool               `json:"readOnly"`
+	LogLevel    string             `json:"logLevel"`
+	Database    *databaseStats     `json:"database,omitempty"`
+	ReviewCache *review.CacheStats `json:"reviewCache,omitempty"`
+	// Tenants are the statistics of the tenants which have been used since the service started
+	Tenants map[string]tenantStats `json:"tenants"`
+}
+
+// stats collects the statistics of the service
+func (s *Service) stats(r *http.Request) serviceStats {
+	stats := serviceStats{
+		ReadOnly: s.readOnly.state().Enabled,
+		LogLevel: s.logLevel.Level().String(),
+		Tenants:  s.tenantMetrics.snapshot(),
+	}
+
+	if s.offers != nil {
+		health, err := s.offers.Health(r.Context())
+		stats.Database = &databaseStats{
+			Readers:            health.Readers,
+			Writer:             health.Writer,
+			QuickCheckProblems: health.QuickCheck.Problems,
+		}
+		if !health.QuickCheck.Time.IsZero() {
+			stats.Database.LastQuickCheck = &health.QuickCheck.Time
+		}
+		if err != nil {
+			stats.Database.Error = err.Error()
+		}
+	}
+	if cache, ok := s.reviewer.(*review.Cache); ok {
+		cacheStats := cache.Stats()
+		stats.ReviewCache = &cacheStats
+	}
+	return stats
+}
+
+// handleVars returns an http.HandlerFunc which returns the variables published with expvar, such
+// as the memory statistics, together with the statistics of the service
+//
+// The statistics of the service are collected for every request instead of being published with
+// expvar, since expvar's variables are global.
+func (s *Service) handleVars() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := json.Marshal(s.stats(r))
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		expvar.Do(func(kv expvar.KeyValue) {
+			fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "%q: %s\n}\n", "service", stats)
+	}
+}
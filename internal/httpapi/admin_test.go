@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+// serveAdmin sends a request through the router of the admin listener
+func serveAdmin(service *Service, method, url, body string) *http.Response {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	service.adminRouter.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	resp := serveAdmin(service, "PUT", "http://testsite.local/admin/read-only", `{"enabled":true,"retryAfterSeconds":120}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	writes := []struct{ method, url, body string }{
+		{"POST", "/api/v1/offer", offerBody},
+		{"POST", "/api/v1/offer/batch", "[" + offerBody + "]"},
+		{"POST", "/api/v1/offer/withdraw", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials"}`},
+		{"DELETE", "/api/v1/webhooks/1", ""},
+		{"DELETE", "/api/v1/alerts/1", ""},
+	}
+	for _, write := range writes {
+		resp := serve(service, write.method, "http://testsite.local"+write.url, write.body)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("%s %s: got status code %d, want %d", write.method, write.url, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "120" {
+			t.Fatalf("%s %s: got Retry-After %q, want 120", write.method, write.url, got)
+		}
+
+		got := problem{}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != codeReadOnly {
+			t.Fatalf("%s %s: got code %q, want %q", write.method, write.url, got.Code, codeReadOnly)
+		}
+	}
+
+	// Reads are still served
+	if resp := serve(service, "GET", offerQueryURL, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d for a read, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = serveAdmin(service, "PUT", "http://testsite.local/admin/read-only", `{"enabled":false}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp := serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d after disabling the read-only mode, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestReadOnlyMode_state(t *testing.T) {
+	service := NewService(1234)
+	service.SetReadOnly(true, 90*time.Second)
+
+	resp := serveAdmin(service, "GET", "http://testsite.local/admin/read-only", "")
+	got := readOnlyState{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Enabled || got.RetryAfterSeconds != 90 || got.Since == nil {
+		t.Fatalf("Got read-only state %+v, want enabled with a Retry-After of 90s", got)
+	}
+
+	resp = serveAdmin(service, "PUT", "http://testsite.local/admin/read-only", `{"enabled":true,"retryAfterSeconds":-1}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	service := NewService(1234)
+	t.Cleanup(func() { service.SetLogLevel(slog.LevelInfo) })
+
+	resp := serveAdmin(service, "PUT", "http://testsite.local/admin/log-level", `{"level":"debug"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = serveAdmin(service, "GET", "http://testsite.local/admin/log-level", "")
+	got := logLevelRequest{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Level != "DEBUG" {
+		t.Fatalf("Got log level %q, want DEBUG", got.Level)
+	}
+
+	resp = serveAdmin(service, "PUT", "http://testsite.local/admin/log-level", `{"level":"verbose"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestReviewCachePurge(t *testing.T) {
+	service := NewService(1234)
+
+	resp := serveAdmin(service, "POST", "http://testsite.local/admin/reviews/cache/purge", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d without a cache, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	cache := review.NewCache(&mockReviewer{}, time.Hour)
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials"}); err != nil {
+		t.Fatal(err)
+	}
+	service.SetReviewer(cache)
+
+	resp = serveAdmin(service, "POST", "http://testsite.local/admin/reviews/cache/purge", "")
+	got := purgeResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Purged != 1 {
+		t.Fatalf("Got %d purged scores, want 1", got.Purged)
+	}
+}
+
+func TestVars(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	service.SetReviewer(review.NewCache(&mockReviewer{}, time.Hour))
+
+	resp := serveAdmin(service, "GET", "http://testsite.local/debug/vars", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	got := struct {
+		MemStats json.RawMessage `json:"memstats"`
+		Service  serviceStats    `json:"service"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.MemStats) == 0 {
+		t.Fatal("Got no memory statistics, want them")
+	}
+	if got.Service.Database == nil || got.Service.Database.Readers.MaxOpenConnections == 0 {
+		t.Fatalf("Got database statistics %+v, want the pool statistics", got.Service.Database)
+	}
+	if got.Service.ReviewCache == nil {
+		t.Fatal("Got no statistics of the review cache, want them")
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	service := NewService(1234)
+
+	if resp := serveAdmin(service, "GET", "http://testsite.local/debug/pprof/", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d for pprof, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp := serveAdmin(service, "GET", "http://testsite.local/debug/pprof/heap", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d for the heap profile, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Admin routes aren't served on the public router
+	if resp := serve(service, "POST", "http://testsite.local/admin/backup", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d for an admin route on the public router, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
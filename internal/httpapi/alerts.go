@@ -0,0 +1,179 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+type alertRequest struct {
+	Product     string  `json:"product"`
+	Category    string  `json:"category"`
+	TargetPrice float32 `json:"targetPrice"`
+}
+
+type alertUpdateRequest struct {
+	TargetPrice float32 `json:"targetPrice"`
+}
+
+type alertResponse struct {
+	ID             int64      `json:"id"`
+	Product        string     `json:"product"`
+	Category       string     `json:"category"`
+	TargetPrice    float32    `json:"targetPrice"`
+	State          string     `json:"state"`
+	StateChangedAt *time.Time `json:"stateChangedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func newAlertResponse(alert database.Alert) alertResponse {
+	response := alertResponse{
+		ID:          alert.ID,
+		Product:     alert.Product,
+		Category:    alert.Category,
+		TargetPrice: alert.TargetPrice,
+		State:       string(alert.State),
+		CreatedAt:   alert.CreatedAt,
+	}
+	if !alert.StateChangedAt.IsZero() {
+		response.StateChangedAt = &alert.StateChangedAt
+	}
+	return response
+}
+
+// withAlerts responds with 503 if no alert store has been set
+func (s *Service) withAlerts(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.alerts == nil {
+			s.respondError(w, r, notConfigured("Alerts"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// scheduleAlerts evaluates the alerts for the product, e.g. because an alert was added or changed
+func (s *Service) scheduleAlerts(alert database.Alert) {
+	if s.alertEvaluator != nil {
+		s.alertEvaluator.Schedule(alert.Product, alert.Category)
+	}
+}
+
+// handleAlertCreate returns an http.HandlerFunc which saves a new price alert
+func (s *Service) handleAlertCreate() http.HandlerFunc {
+	return s.withAlerts(func(w http.ResponseWriter, r *http.Request) {
+		request := alertRequest{}
+		err := s.decode(w, r, &request)
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.Product == "" || request.Category == "" || request.TargetPrice <= 0 {
+			s.respondError(w, r, invalidRequest("product, category and a positive targetPrice are required"))
+			return
+		}
+
+		// Changes are reported for the product, so the alert has to be for the product, too
+		product, err := s.resolveProduct(r, request.Product)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		alert, err := s.alerts.AddAlert(database.Alert{
+			Product:     product,
+			Category:    request.Category,
+			TargetPrice: request.TargetPrice,
+		})
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.scheduleAlerts(alert)
+		s.respond(w, r, newAlertResponse(alert), http.StatusCreated)
+	})
+}
+
+// handleAlertList returns an http.HandlerFunc which lists all alerts
+func (s *Service) handleAlertList() http.HandlerFunc {
+	return s.withAlerts(func(w http.ResponseWriter, r *http.Request) {
+		alerts, err := s.alerts.ListAlerts()
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]alertResponse, len(alerts))
+		for i, alert := range alerts {
+			response[i] = newAlertResponse(alert)
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// handleAlertGet returns an http.HandlerFunc which describes one alert and its state
+func (s *Service) handleAlertGet() http.HandlerFunc {
+	return s.withAlerts(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		alert, err := s.alerts.Alert(id)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newAlertResponse(alert), http.StatusOK)
+	})
+}
+
+// handleAlertUpdate returns an http.HandlerFunc which changes the target price of an alert
+//
+// The alert is resolved and evaluated again with the new target price.
+func (s *Service) handleAlertUpdate() http.HandlerFunc {
+	return s.withAlerts(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		request := alertUpdateRequest{}
+		err := s.decode(w, r, &request)
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.TargetPrice <= 0 {
+			s.respondError(w, r, invalidRequest("a positive targetPrice is required"))
+			return
+		}
+
+		alert, err := s.alerts.UpdateAlert(id, request.TargetPrice)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.scheduleAlerts(alert)
+		s.respond(w, r, newAlertResponse(alert), http.StatusOK)
+	})
+}
+
+// handleAlertDelete returns an http.HandlerFunc which deletes an alert
+func (s *Service) handleAlertDelete() http.HandlerFunc {
+	return s.withAlerts(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.alerts.DeleteAlert(id); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
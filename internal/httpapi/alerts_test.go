@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/alerts"
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+func TestAlertHandlers(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	evaluator := alerts.NewEvaluator(db, db, alerts.LogNotifier{})
+	service.SetAlerts(db, evaluator)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evaluator.Run(ctx)
+
+	resp := serve(service, "POST", "http://testsite.local/api/v1/alerts",
+		`{"product":"Towel","category":"Must Haves","targetPrice":40}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	created := alertResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == 0 || created.State != "resolved" || created.StateChangedAt != nil {
+		t.Fatalf("Expected a new resolved alert, got %+v", created)
+	}
+
+	resp = serve(service, "GET", "http://testsite.local/api/v1/alerts", "")
+	var list []alertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil || len(list) != 1 {
+		t.Fatalf("Got alerts %+v and error %v, want the new one", list, err)
+	}
+
+	resp = serve(service, "PUT", "http://testsite.local/api/v1/alerts/1", `{"targetPrice":35}`)
+	updated := alertResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil || updated.TargetPrice != 35 {
+		t.Fatalf("Got alert %+v and error %v, want the new target price", updated, err)
+	}
+
+	if resp = serve(service, "GET", "http://testsite.local/api/v1/alerts/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp = serve(service, "DELETE", "http://testsite.local/api/v1/alerts/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	for _, method := range []string{"GET", "DELETE"} {
+		if resp = serve(service, method, "http://testsite.local/api/v1/alerts/1", ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: got bad status code %d, want %d", method, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+	if resp = serve(service, "PUT", "http://testsite.local/api/v1/alerts/1", `{"targetPrice":35}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAlertHandlers_withAlias(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	service.SetAlerts(db, nil)
+	if _, _, err := db.AddProductAlias(context.Background(), "Bath Towel", "Towel"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(service, "POST", "http://testsite.local/api/v1/alerts",
+		`{"product":"bath towel","category":"Must Haves","targetPrice":40}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	if alerts, err := db.AlertsFor("Towel", "Must Haves"); err != nil || len(alerts) != 1 {
+		t.Fatalf("Got alerts %+v and error %v for changes to the product, want the alert", alerts, err)
+	}
+}
+
+func TestAlertHandlers_withInvalidRequests(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetAlerts(db, nil)
+
+	alert, err := db.AddAlert(database.Alert{Product: "Towel", Category: "Must Haves", TargetPrice: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alert.ID != 1 {
+		t.Fatalf("Expected the first alert to have ID 1, got %d", alert.ID)
+	}
+
+	testCases := []struct {
+		name, method, url, body string
+	}{
+		{"not JSON", "POST", "/api/v1/alerts", "I'm not JSON"},
+		{"missing product", "POST", "/api/v1/alerts", `{"category":"Must Haves","targetPrice":40}`},
+		{"missing target price", "POST", "/api/v1/alerts", `{"product":"Towel","category":"Must Haves"}`},
+		{"negative target price", "PUT", "/api/v1/alerts/1", `{"targetPrice":-1}`},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: got status code %d, want %d", testCase.name, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAlertHandlers_withoutStore(t *testing.T) {
+	service := NewService(1234)
+	if resp := serve(service, "GET", "http://testsite.local/api/v1/alerts", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
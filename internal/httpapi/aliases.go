@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/catalog"
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// productAliasRequest is the body of requests adding an alias
+type productAliasRequest struct {
+	Alias   string `json:"alias"`
+	Product string `json:"product"`
+}
+
+// productAliasResponse is an alias as it's returned by the admin routes
+type productAliasResponse struct {
+	Alias     string    `json:"alias"`
+	Product   string    `json:"product"`
+	CreatedAt time.Time `json:"createdAt"`
+	// MergedOffers is the number of offers for the alias which were moved to the product. It's only
+	// returned when the alias is added.
+	MergedOffers *int `json:"mergedOffers,omitempty"`
+}
+
+// aliasSuggestionResponse proposes to make a product an alias of another one
+type aliasSuggestionResponse struct {
+	Alias      string  `json:"alias"`
+	Product    string  `json:"product"`
+	Similarity float64 `json:"similarity"`
+}
+
+// resolveProduct returns the product the name is an alias of for the tenant of the request, so that
+// filters on changes to offers match the product the offers are stored for. The name is returned
+// as it is if it's empty or the database doesn't support aliases.
+func (s *Service) resolveProduct(r *http.Request, name string) (string, error) {
+	aliases, ok := s.offersFor(r).(database.ProductAliases)
+	if !ok || name == "" {
+		return name, nil
+	}
+	return aliases.ResolveProduct(r.Context(), name)
+}
+
+// adminTenant returns the tenant selected with the tenant query parameter of an admin route. It
+// responds with an error and returns false if the tenant isn't configured.
+func (s *Service) adminTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	tenant := r.URL.Query().Get("tenant")
+	if tenant == "" {
+		return database.DefaultTenant, true
+	}
+
+	s.tenantsMu.RLock()
+	_, ok := s.tenants.byID[tenant]
+	s.tenantsMu.RUnlock()
+	if !ok {
+		s.respondError(w, r, invalidRequest("tenant isn't a configured tenant."))
+		return "", false
+	}
+	return tenant, true
+}
+
+// productAliases returns the aliases of the tenant selected with the tenant query parameter. It
+// responds with an error and returns false if the database doesn't support aliases.
+func (s *Service) productAliases(w http.ResponseWriter, r *http.Request) (database.ProductAliases, bool) {
+	tenant, ok := s.adminTenant(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	offers := s.offers
+	if scoper, scoped := offers.(database.TenantScoper); scoped {
+		offers = scoper.ForTenant(tenant)
+	} else if tenant != database.DefaultTenant {
+		s.respondError(w, r, notConfigured("Tenants"))
+		return nil, false
+	}
+
+	aliases, ok := offers.(database.ProductAliases)
+	if !ok {
+		s.respondError(w, r, notConfigured("Product aliases"))
+		return nil, false
+	}
+	return aliases, true
+}
+
+// handleProductAliases returns an http.HandlerFunc which lists the aliases of products
+func (s *Service) handleProductAliases() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aliases, ok := s.productAliases(w, r)
+		if !ok {
+			return
+		}
+
+		list, err := aliases.ProductAliases(r.Context())
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]productAliasResponse, len(list))
+		for i, alias := range list {
+			response[i] = productAliasResponse{Alias: alias.Alias, Product: alias.Product, CreatedAt: alias.CreatedAt}
+		}
+		s.respond(w, r, response, http.StatusOK)
+	}
+}
+
+// handleAddProductAlias returns an http.HandlerFunc which makes a name an alias of a product. The
+// offers for the alias are merged into the product.
+func (s *Service) handleAddProductAlias() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aliases, ok := s.productAliases(w, r)
+		if !ok {
+			return
+		}
+
+		request := productAliasRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		alias, merged, err := aliases.AddProductAlias(r.Context(), request.Alias, request.Product)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.respond(w, r, productAliasResponse{
+			Alias:        alias.Alias,
+			Product:      alias.Product,
+			CreatedAt:    alias.CreatedAt,
+			MergedOffers: &merged,
+		}, http.StatusCreated)
+	}
+}
+
+// handleDeleteProductAlias returns an http.HandlerFunc which deletes the alias in the alias query
+// parameter
+func (s *Service) handleDeleteProductAlias() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aliases, ok := s.productAliases(w, r)
+		if !ok {
+			return
+		}
+
+		alias := r.URL.Query().Get("alias")
+		if alias == "" {
+			s.respondError(w, r, invalidRequest("the query parameter alias is required"))
+			return
+		}
+
+		if err := aliases.DeleteProductAlias(r.Context(), alias); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleProductAliasSuggestions returns an http.HandlerFunc which suggests products with similar
+// names as aliases of each other
+//
+// The optional query parameter minSimilarity sets how similar the names must be, between 0 and 1.
+func (s *Service) handleProductAliasSuggestions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		aliases, ok := s.productAliases(w, r)
+		if !ok {
+			return
+		}
+
+		minSimilarity := catalog.DefaultMinSimilarity
+		if value := r.URL.Query().Get("minSimilarity"); value != "" {
+			var err error
+			minSimilarity, err = strconv.ParseFloat(value, 64)
+			if err != nil || minSimilarity < 0 || minSimilarity > 1 {
+				s.respondError(w, r, invalidRequest("minSimilarity must be a number between 0 and 1"))
+				return
+			}
+		}
+
+		counts, err := aliases.ProductCounts(r.Context())
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		products := make([]catalog.Product, len(counts))
+		for i, count := range counts {
+			products[i] = catalog.Product{Name: count.Name, Offers: count.Offers}
+		}
+
+		suggestions := catalog.Suggest(products, minSimilarity)
+		response := make([]aliasSuggestionResponse, len(suggestions))
+		for i, suggestion := range suggestions {
+			response[i] = aliasSuggestionResponse{suggestion.Alias, suggestion.Product, suggestion.Similarity}
+		}
+		s.respond(w, r, response, http.StatusOK)
+	}
+}
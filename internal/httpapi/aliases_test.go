@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestProductAliases(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+	serve(service, "POST", "http://testsite.local/api/v1/offer",
+		`{"product":"Bath Towel","category":"Must Haves","supplier":"Hitchhiker Knockoffs","price":40}`)
+
+	resp := serveAdmin(service, "POST", "http://testsite.local/admin/products/aliases", `{"alias":"Bath Towel","product":"Towel"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	created := productAliasResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Product != "Towel" || created.MergedOffers == nil || *created.MergedOffers != 1 {
+		t.Fatalf("Got alias %+v, want one offer merged into Towel", created)
+	}
+
+	// Searches for the alias return the offers for the product
+	got := decodeSearch(t, serve(service, "GET", "http://testsite.local/api/v1/offers?product=Bath+Towel&category=Must+Haves", ""))
+	if len(got.Offers) != 2 {
+		t.Fatalf("Got offers %+v, want the offers for Towel", got.Offers)
+	}
+
+	resp = serveAdmin(service, "GET", "http://testsite.local/admin/products/aliases", "")
+	var aliases []productAliasResponse
+	if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 1 || aliases[0].Alias != "Bath Towel" {
+		t.Fatalf("Got aliases %+v, want Bath Towel", aliases)
+	}
+
+	resp = serveAdmin(service, "DELETE", "http://testsite.local/admin/products/aliases?alias=Bath+Towel", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	resp = serveAdmin(service, "DELETE", "http://testsite.local/admin/products/aliases?alias=Bath+Towel", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d deleting an unknown alias, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestProductAliases_invalid(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	testCases := []struct {
+		method, url, body string
+		status            int
+	}{
+		{"POST", "/admin/products/aliases", `{"alias":"towel","product":"Towel"}`, http.StatusBadRequest},
+		{"POST", "/admin/products/aliases", `{"alias":"","product":"Towel"}`, http.StatusBadRequest},
+		{"POST", "/admin/products/aliases", `{"alias":`, http.StatusBadRequest},
+		{"DELETE", "/admin/products/aliases", "", http.StatusBadRequest},
+		{"GET", "/admin/products/aliases?tenant=unknown", "", http.StatusBadRequest},
+		{"GET", "/admin/products/alias-suggestions?minSimilarity=2", "", http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		resp := serveAdmin(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != testCase.status {
+			t.Fatalf("%s %s: got status code %d, want %d", testCase.method, testCase.url, resp.StatusCode, testCase.status)
+		}
+	}
+
+	// Databases without aliases can't be used
+	resp := serveAdmin(newSearchTestService(), "GET", "http://testsite.local/admin/products/aliases", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d without aliases, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestProductAliasSuggestions(t *testing.T) {
+	service := newTenantTestService(t)
+	acme := map[string]string{apiKeyHeader: "acme-key"}
+
+	serveAs(service, "POST", "http://testsite.local/api/v1/offer/batch", `[
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42},
+		{"product":"Towel","category":"Must Haves","supplier":"Megadodo","price":43},
+		{"product":"towel (blue)","category":"Must Haves","supplier":"Hitchhiker Knockoffs","price":40},
+		{"product":"Guide","category":"Must Haves","supplier":"Megadodo","price":30}
+	]`, acme)
+
+	// Suggestions are made per tenant
+	resp := serveAdmin(service, "GET", "http://testsite.local/admin/products/alias-suggestions", "")
+	var suggestions []aliasSuggestionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&suggestions); err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 0 {
+		t.Fatalf("Got suggestions %+v for the default tenant, want none", suggestions)
+	}
+
+	resp = serveAdmin(service, "GET", "http://testsite.local/admin/products/alias-suggestions?tenant=acme", "")
+	if err := json.NewDecoder(resp.Body).Decode(&suggestions); err != nil {
+		t.Fatal(err)
+	}
+	want := aliasSuggestionResponse{"towel (blue)", "Towel", 1}
+	if len(suggestions) != 1 || suggestions[0] != want {
+		t.Fatalf("Got suggestions %+v, want %+v", suggestions, want)
+	}
+}
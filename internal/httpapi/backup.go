@@ -0,0 +1,23 @@
+package httpapi
+
+import (
+	"net/http"
+)
+
+// handleBackup returns an http.HandlerFunc which takes a backup of the database immediately
+func (s *Service) handleBackup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.backups == nil {
+			s.respondError(w, r, notConfigured("Backups"))
+			return
+		}
+
+		info, err := s.backups.Backup()
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.respond(w, r, info, http.StatusOK)
+	}
+}
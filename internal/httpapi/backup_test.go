@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/backup"
+)
+
+// fileSource is a backup source which writes a fixed file
+type fileSource struct{}
+
+func (fileSource) Backup(destPath string) error {
+	return os.WriteFile(destPath, []byte("backup"), 0o640)
+}
+
+func TestBackupHandler(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := backup.NewManager(fileSource{}, backup.Config{
+		Dir:       dir,
+		Interval:  time.Hour,
+		Retention: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(1234)
+	service.SetBackupManager(manager)
+
+	// Backups are only taken on the admin port
+	if resp := serve(service, "POST", "http://testsite.local/admin/backup", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d on the public router, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if files, err := os.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Fatalf("Expected no backup from the public router, got %v, %v", files, err)
+	}
+
+	resp := serveAdmin(service, "POST", "http://testsite.local/admin/backup", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	got := backup.Info{}
+	if err = json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Size != int64(len("backup")) || got.SHA256 == "" {
+		t.Fatalf("Expected information about the new backup, got %v", got)
+	}
+}
+
+func TestBackupHandler_withoutManager(t *testing.T) {
+	service := NewService(1234)
+
+	req := httptest.NewRequest("POST", "http://testsite.local/admin/backup", nil)
+	w := httptest.NewRecorder()
+	service.handleBackup()(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
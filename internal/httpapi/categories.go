@@ -0,0 +1,226 @@
+package httpapi
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+)
+
+// categoryRequest is the body of requests adding a category
+type categoryRequest struct {
+	Name string `json:"name"`
+	// ParentID is the ID of the parent category. Top-level categories don't have one.
+	ParentID int64 `json:"parentId"`
+}
+
+// categoryMoveRequest is the body of requests moving a category to another parent
+type categoryMoveRequest struct {
+	ParentID int64 `json:"parentId"`
+}
+
+type categoryResponse struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  int64     `json:"parentId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// categoryRef is a category in the breadcrumbs of a search
+type categoryRef struct {
+	ID   int64  `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+func newCategoryResponse(category database.Category) categoryResponse {
+	return categoryResponse{
+		ID:        category.ID,
+		Name:      category.Name,
+		ParentID:  category.ParentID,
+		CreatedAt: category.CreatedAt,
+	}
+}
+
+// categoryTree returns the category tree of the tenant of the request, if the database has one
+func (s *Service) categoryTree(r *http.Request) (database.CategoryTree, bool) {
+	tree, ok := s.offersFor(r).(database.CategoryTree)
+	return tree, ok
+}
+
+// withCategories responds with 503 if the database doesn't support a category tree
+func (s *Service) withCategories(h func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tree, ok := s.categoryTree(r)
+		if !ok {
+			s.respondError(w, r, notConfigured("Categories"))
+			return
+		}
+		h(w, r, tree)
+	}
+}
+
+// searchCategories returns the categories whose offers are returned for the search. It's only the
+// requested category unless its subcategories are included.
+func (s *Service) searchCategories(r *http.Request, request offerSearchRequest) ([]string, error) {
+	if !request.IncludeSubcategories {
+		return []string{request.Category}, nil
+	}
+
+	tree, ok := s.categoryTree(r)
+	if !ok {
+		return nil, notConfigured("Categories")
+	}
+	return tree.Subcategories(r.Context(), request.Category)
+}
+
+// getOffers returns the offers for the product of the search in all of the categories. Offers of
+// suspended suppliers and offers which don't match the availability filters are left out.
+func (s *Service) getOffers(r *http.Request, request offerSearchRequest, categories []string) ([]database.Offer, error) {
+	var offers []database.Offer
+	for _, category := range categories {
+		found, err := s.offersFor(r).Get(r.Context(), request.ProductName, category)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, found...)
+	}
+	return ranking.WithoutSuspended(r.Context(), s.offersFor(r), deliverable(offers, request))
+}
+
+// breadcrumbs returns the path from the top level down to the category. It's empty if the category
+// isn't part of the tree or the database doesn't have one.
+func (s *Service) breadcrumbs(r *http.Request, category string) ([]categoryRef, error) {
+	tree, ok := s.categoryTree(r)
+	if !ok {
+		return nil, nil
+	}
+
+	path, err := tree.CategoryPath(r.Context(), category)
+	if err != nil {
+		return nil, err
+	}
+	crumbs := make([]categoryRef, len(path))
+	for i, node := range path {
+		crumbs[i] = categoryRef{node.ID, node.Name}
+	}
+	return crumbs, nil
+}
+
+// categoryTag returns a part of the ETag which changes when categories are moved, since that
+// changes the response without changing any offer. It's empty for searches which don't depend on
+// the tree.
+func categoryTag(categories []string, crumbs []categoryRef) string {
+	if len(categories) == 1 && len(crumbs) == 0 {
+		return ""
+	}
+
+	h := fnv.New32a()
+	for _, category := range categories {
+		fmt.Fprintf(h, "%s\x00", category)
+	}
+	for _, crumb := range crumbs {
+		fmt.Fprintf(h, "%d\x00", crumb.ID)
+	}
+	return fmt.Sprintf("-%x", h.Sum32())
+}
+
+// handleCategoryCreate returns an http.HandlerFunc which adds a category to the tree
+func (s *Service) handleCategoryCreate() http.HandlerFunc {
+	return s.withCategories(func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree) {
+		request := categoryRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.Name == "" {
+			s.respondError(w, r, invalidRequest("a name is required"))
+			return
+		}
+
+		category, err := tree.AddCategory(r.Context(), request.Name, request.ParentID)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newCategoryResponse(category), http.StatusCreated)
+	})
+}
+
+// handleCategoryList returns an http.HandlerFunc which lists all categories of the tree
+func (s *Service) handleCategoryList() http.HandlerFunc {
+	return s.withCategories(func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree) {
+		categories, err := tree.ListCategories(r.Context())
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]categoryResponse, len(categories))
+		for i, category := range categories {
+			response[i] = newCategoryResponse(category)
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// handleCategoryGet returns an http.HandlerFunc which describes one category
+func (s *Service) handleCategoryGet() http.HandlerFunc {
+	return s.withCategories(func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		category, err := tree.Category(r.Context(), id)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newCategoryResponse(category), http.StatusOK)
+	})
+}
+
+// handleCategoryMove returns an http.HandlerFunc which moves a category and its subcategories to
+// another parent
+//
+// Categories can't be renamed, since offers, webhooks and alerts refer to them by name.
+func (s *Service) handleCategoryMove() http.HandlerFunc {
+	return s.withCategories(func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		request := categoryMoveRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		category, err := tree.MoveCategory(r.Context(), id, request.ParentID)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newCategoryResponse(category), http.StatusOK)
+	})
+}
+
+// handleCategoryDelete returns an http.HandlerFunc which deletes a category without subcategories
+func (s *Service) handleCategoryDelete() http.HandlerFunc {
+	return s.withCategories(func(w http.ResponseWriter, r *http.Request, tree database.CategoryTree) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := tree.DeleteCategory(r.Context(), id); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
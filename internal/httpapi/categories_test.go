@@ -0,0 +1,159 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// addCategory adds a category through the API and returns it
+func addCategory(t *testing.T, service *Service, body string) categoryResponse {
+	t.Helper()
+	resp := serve(service, "POST", "http://testsite.local/api/v1/categories", body)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	category := categoryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&category); err != nil {
+		t.Fatal(err)
+	}
+	return category
+}
+
+func TestCategoryHandlers(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	essentials := addCategory(t, service, `{"name":"Essentials"}`)
+	towels := addCategory(t, service, `{"name":"Towels","parentId":1}`)
+	if essentials.ParentID != 0 || towels.ParentID != essentials.ID {
+		t.Fatalf("Got categories %+v and %+v, want Towels below Essentials", essentials, towels)
+	}
+
+	resp := serve(service, "GET", "http://testsite.local/api/v1/categories", "")
+	var list []categoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil || len(list) != 2 {
+		t.Fatalf("Got categories %+v and error %v, want both", list, err)
+	}
+
+	resp = serve(service, "PUT", "http://testsite.local/api/v1/categories/2", `{}`)
+	moved := categoryResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&moved); err != nil || moved.ParentID != 0 {
+		t.Fatalf("Got category %+v and error %v, want a top-level category", moved, err)
+	}
+
+	if resp = serve(service, "GET", "http://testsite.local/api/v1/categories/2", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp = serve(service, "DELETE", "http://testsite.local/api/v1/categories/2", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		if resp = serve(service, method, "http://testsite.local/api/v1/categories/2", ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: got bad status code %d, want %d", method, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestCategoryHandlers_invalid(t *testing.T) {
+	service := newValidatingTestService(t)
+	addCategory(t, service, `{"name":"Essentials"}`)
+	addCategory(t, service, `{"name":"Towels","parentId":1}`)
+
+	testCases := []struct {
+		method, url, body string
+		status            int
+	}{
+		{"POST", "/api/v1/categories", `{"name":""}`, http.StatusBadRequest},
+		{"POST", "/api/v1/categories", `{"name":"Guides","parentId":42}`, http.StatusBadRequest},
+		{"POST", "/api/v1/categories", `{"name":"Towels"}`, http.StatusConflict},
+		{"PUT", "/api/v1/categories/1", `{"parentId":2}`, http.StatusBadRequest},
+		{"PUT", "/api/v1/categories/42", `{}`, http.StatusNotFound},
+		{"DELETE", "/api/v1/categories/1", "", http.StatusConflict},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != testCase.status {
+			t.Fatalf("%s %s: got status code %d, want %d", testCase.method, testCase.url, resp.StatusCode, testCase.status)
+		}
+	}
+
+	// Databases without a category tree can't be used
+	resp := serve(newSearchTestService(), "GET", "http://testsite.local/api/v1/categories", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d without a category tree, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	resp = queryOffers(newSearchTestService(), offerQueryURL+"&includeSubcategories=true", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d searching subcategories without a tree, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestOfferSearch_subcategories(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer/batch", `[
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42},
+		{"product":"Towel","category":"Towels","supplier":"Hitchhiker Knockoffs","price":40}
+	]`)
+	addCategory(t, service, `{"name":"Must Haves"}`)
+	addCategory(t, service, `{"name":"Towels","parentId":1}`)
+
+	testCases := []struct {
+		name        string
+		url         string
+		categories  []string
+		breadcrumbs []string
+	}{
+		{"category", offerQueryURL, []string{""}, []string{"Must Haves"}},
+		{"subcategories", offerQueryURL + "&includeSubcategories=true", []string{"Towels", "Must Haves"}, []string{"Must Haves"}},
+		{"subcategory", "http://testsite.local/api/v1/offers?product=Towel&category=Towels", []string{""}, []string{"Must Haves", "Towels"}},
+		{"outside of the tree", "http://testsite.local/api/v1/offers?product=Towel&category=Guides&includeSubcategories=true", nil, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := decodeSearch(t, serve(service, "GET", testCase.url, ""))
+			if len(got.Offers) != len(testCase.categories) {
+				t.Fatalf("Got offers %+v, want offers in %v", got.Offers, testCase.categories)
+			}
+			for i, offer := range got.Offers {
+				if offer.Category != testCase.categories[i] {
+					t.Fatalf("Got offers %+v, want offers in %v", got.Offers, testCase.categories)
+				}
+			}
+			if len(got.Breadcrumbs) != len(testCase.breadcrumbs) {
+				t.Fatalf("Got breadcrumbs %+v, want %v", got.Breadcrumbs, testCase.breadcrumbs)
+			}
+			for i, crumb := range got.Breadcrumbs {
+				if crumb.Name != testCase.breadcrumbs[i] {
+					t.Fatalf("Got breadcrumbs %+v, want %v", got.Breadcrumbs, testCase.breadcrumbs)
+				}
+			}
+		})
+	}
+
+	// The POST search can include subcategories, too
+	got := decodeSearch(t, serve(service, "POST", "http://testsite.local/api/v1/offer/search",
+		`{"product":"Towel","category":"Must Haves","includeSubcategories":true}`))
+	if len(got.Offers) != 2 {
+		t.Fatalf("Got offers %+v, want the offers in both categories", got.Offers)
+	}
+}
+
+func TestOfferQuery_movedCategoryChangesETag(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+	addCategory(t, service, `{"name":"Essentials"}`)
+	addCategory(t, service, `{"name":"Must Haves"}`)
+
+	etag := serve(service, "GET", offerQueryURL, "").Header.Get("ETag")
+	serve(service, "PUT", "http://testsite.local/api/v1/categories/2", `{"parentId":1}`)
+
+	resp := queryOffers(service, offerQueryURL, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d after moving the category, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := decodeSearch(t, resp); len(got.Breadcrumbs) != 2 {
+		t.Fatalf("Got breadcrumbs %+v, want Essentials and Must Haves", got.Breadcrumbs)
+	}
+}
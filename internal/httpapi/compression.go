@@ -0,0 +1,191 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressionMinSize is the minimum size of compressed responses. Compressing smaller
+// responses costs more than it saves.
+const defaultCompressionMinSize = 1024
+
+// contentEncodings are the supported encodings of responses in the order of preference
+var contentEncodings = []string{"gzip", "deflate"}
+
+// SetCompressionMinSize sets the minimum size of responses which are compressed. Responses aren't
+// compressed at all if it's negative.
+func (s *Service) SetCompressionMinSize(size int) {
+	s.compressionMinSize = size
+}
+
+// negotiateEncoding returns the supported encoding the client prefers as described in RFC 9110, or
+// an empty string if it doesn't accept any of them
+func negotiateEncoding(header string) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range contentEncodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressResponse is a middleware which compresses responses if the client accepts it
+//
+// Responses are only compressed once they reach the minimum size. Streams which are flushed before
+// that, like server-sent events, aren't compressed.
+func (s *Service) compressResponse(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.compressionMinSize < 0 || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		addVary(w.Header(), "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressingResponseWriter{ResponseWriter: w, encoding: encoding, minSize: s.compressionMinSize}
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressingResponseWriter buffers the start of a response until it's known whether it's large
+// enough to be compressed
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status     int
+	buffer     bytes.Buffer
+	decided    bool
+	compressor io.WriteCloser
+}
+
+func (w *compressingResponseWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+
+	// Responses without a body are sent right away
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.start(false)
+	}
+}
+
+func (w *compressingResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Encoding") != "" {
+			w.start(false)
+		} else {
+			w.buffer.Write(p)
+			if w.buffer.Len() >= w.minSize {
+				if err := w.start(true); err != nil {
+					return 0, err
+				}
+			}
+			return len(p), nil
+		}
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// start sends the header and the buffered start of the response
+func (w *compressingResponseWriter) start(compress bool) error {
+	w.decided = true
+
+	var target io.Writer = w.ResponseWriter
+	if compress {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+		if w.encoding == "gzip" {
+			w.compressor = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.compressor = zlib.NewWriter(w.ResponseWriter)
+		}
+		target = w.compressor
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	_, err := target.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// Flush sends everything written so far. Responses which are flushed before they reach the
+// minimum size aren't compressed.
+func (w *compressingResponseWriter) Flush() {
+	if !w.decided {
+		w.start(false)
+	}
+	if gz, ok := w.compressor.(interface{ Flush() error }); ok {
+		gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close sends the rest of the response
+func (w *compressingResponseWriter) Close() error {
+	if !w.decided {
+		// Nothing was written if neither the status nor a body is known. The server then sends the
+		// default response.
+		if w.status == 0 && w.buffer.Len() == 0 {
+			return nil
+		}
+		return w.start(false)
+	}
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController can reach it
+func (w *compressingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"*", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"br, identity", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := negotiateEncoding(tc.header); got != tc.want {
+			t.Fatalf("Got %q for Accept-Encoding %q, want %q", got, tc.header, tc.want)
+		}
+	}
+}
+
+// exportWithEncoding requests the export of the mock database with the Accept-Encoding header
+func exportWithEncoding(service *Service, acceptEncoding string) *http.Response {
+	req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers/export?format=ndjson", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestCompressResponse(t *testing.T) {
+	service := newSearchTestService()
+	plain, err := io.ReadAll(exportWithEncoding(service, "").Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service.SetCompressionMinSize(len(plain))
+	for _, encoding := range []string{"gzip", "deflate"} {
+		resp := exportWithEncoding(service, encoding)
+		if got := resp.Header.Get("Content-Encoding"); got != encoding {
+			t.Fatalf("Got Content-Encoding %q, want %q", got, encoding)
+		}
+		if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("Got Vary %q, want Accept-Encoding", got)
+		}
+
+		var body io.Reader
+		if encoding == "gzip" {
+			body, err = gzip.NewReader(resp.Body)
+		} else {
+			body, err = zlib.NewReader(resp.Body)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(plain) {
+			t.Fatalf("Got decompressed body %q, want %q", got, plain)
+		}
+	}
+
+	// Responses below the minimum size aren't compressed
+	service.SetCompressionMinSize(len(plain) + 1)
+	resp := exportWithEncoding(service, "gzip")
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Got Content-Encoding %q for a small response, want none", got)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(plain) {
+		t.Fatalf("Got body %q, want %q", got, plain)
+	}
+}
+
+func TestCompressResponse_keepsStatus(t *testing.T) {
+	service := newSearchTestService()
+	service.SetCompressionMinSize(0)
+
+	req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers?product=Towel", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if got := resp.Header.Get("Content-Type"); got != problemContentType {
+		t.Fatalf("Got content type %q, want %q", got, problemContentType)
+	}
+
+	body, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := problem{}
+	if err := json.NewDecoder(body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != codeInvalidRequest {
+		t.Fatalf("Got code %q, want %q", got.Code, codeInvalidRequest)
+	}
+}
+
+func TestCompressResponse_disabled(t *testing.T) {
+	service := newSearchTestService()
+	service.SetCompressionMinSize(-1)
+
+	resp := exportWithEncoding(service, "gzip")
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Fatalf("Got Content-Encoding %q, want none", got)
+	}
+	if got := resp.Header.Get("Vary"); got != "" {
+		t.Fatalf("Got Vary %q, want none", got)
+	}
+}
@@ -3,14 +3,11 @@ package httpapi
 import (
 	"context"
 	"fmt"
-)
-
-type customChecker struct{}
+	"strings"
 
-func (customChecker) Check(ctx context.Context) error {
-	// Fill in meaningful checks here.
-	return nil
-}
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/review"
+)
 
 type databaseChecker struct {
 	service *Service
@@ -18,19 +15,78 @@ type databaseChecker struct {
 
 // Check tests if we can read from the database without error
 //
-// Respects a potential timeout from the healthcheck config
+// The queries are cancelled when the timeout from the healthcheck config expires.
 func (c *databaseChecker) Check(ctx context.Context) error {
-	errorChannel := make(chan error)
+	_, err := c.service.offers.Health(ctx)
+	return err
+}
+
+// reviewsChecker tests if the reviews engine is reachable
+type reviewsChecker struct {
+	reviewer review.Reviewer
+}
+
+// Check pings the reviews engine. Reviewers which can't be pinged are asked for the scores of no
+// suppliers instead.
+func (c *reviewsChecker) Check(ctx context.Context) error {
+	if pinger, ok := c.reviewer.(review.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := c.reviewer.Suppliers([]string{})
+	return err
+}
+
+// migrationsChecker fails while migrations of the database are pending
+type migrationsChecker struct {
+	prober database.ReadinessProber
+}
+
+func (c *migrationsChecker) Check(context.Context) error {
+	pending, err := c.prober.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending", pending)
+	}
+	return nil
+}
 
-	go func() {
-		_, err := c.service.offers.Get("test", "test")
-		errorChannel <- err
-	}()
+// diskSpaceChecker fails if the volume of the database is almost full
+type diskSpaceChecker struct {
+	prober  database.ReadinessProber
+	minFree uint64
+}
 
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("cancelled while waiting for database")
-	case err := <-errorChannel:
+func (c *diskSpaceChecker) Check(context.Context) error {
+	space, err := c.prober.DiskSpace()
+	if err != nil {
 		return err
 	}
+	if space.Free < c.minFree {
+		return fmt.Errorf("%d bytes free, need at least %d", space.Free, c.minFree)
+	}
+	return nil
+}
+
+// writeProbeChecker tests if writes to the database can be committed
+type writeProbeChecker struct {
+	prober database.ReadinessProber
+}
+
+func (c *writeProbeChecker) Check(ctx context.Context) error {
+	return c.prober.ProbeWrite(ctx)
+}
+
+// quickCheckChecker fails while the latest quick check of the database found corruption
+type quickCheckChecker struct {
+	prober database.ReadinessProber
+}
+
+func (c *quickCheckChecker) Check(context.Context) error {
+	problems := c.prober.LatestQuickCheck().Problems
+	if len(problems) > 0 {
+		return fmt.Errorf("quick check found %d problems: %s", len(problems), strings.Join(problems, "; "))
+	}
+	return nil
 }
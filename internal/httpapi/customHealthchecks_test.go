@@ -2,7 +2,13 @@ package httpapi
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/etherlabsio/healthcheck"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/review"
 )
 
 func TestDatabaseChecker_Check(t *testing.T) {
@@ -32,3 +38,80 @@ func TestDatabaseChecker_WithCancelledContext(t *testing.T) {
 		t.Fatalf("Expected an error for a cancelled context, got none")
 	}
 }
+
+// mockProber is a database reporting fixed readiness
+type mockProber struct {
+	pending   int
+	space     database.DiskSpace
+	writeErr  error
+	probeRuns int
+	problems  []string
+}
+
+func (m *mockProber) PendingMigrations() (int, error)        { return m.pending, nil }
+func (m *mockProber) DiskSpace() (database.DiskSpace, error) { return m.space, nil }
+func (m *mockProber) LatestQuickCheck() database.QuickCheckResult {
+	return database.QuickCheckResult{Problems: m.problems}
+}
+func (m *mockProber) ProbeWrite(ctx context.Context) error {
+	m.probeRuns++
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	return ctx.Err()
+}
+
+func TestReadinessCheckers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		checker healthcheck.Checker
+		wantErr bool
+	}{
+		{"reviews", &reviewsChecker{&review.Client{}}, false},
+		{"reviews without ping", &reviewsChecker{&mockReviewer{}}, false},
+		{"failing reviews", &reviewsChecker{&mockErrorReviewer{}}, true},
+		{"migrations", &migrationsChecker{&mockProber{}}, false},
+		{"pending migrations", &migrationsChecker{&mockProber{pending: 1}}, true},
+		{"disk space", &diskSpaceChecker{&mockProber{space: database.DiskSpace{Free: 10, Total: 20}}, 10}, false},
+		{"full disk", &diskSpaceChecker{&mockProber{space: database.DiskSpace{Free: 9, Total: 20}}, 10}, true},
+		{"write probe", &writeProbeChecker{&mockProber{}}, false},
+		{"failing write probe", &writeProbeChecker{&mockProber{writeErr: errors.New("disk I/O error")}}, true},
+		{"quick check", &quickCheckChecker{&mockProber{}}, false},
+		{"corrupt database", &quickCheckChecker{&mockProber{problems: []string{"row 1 missing from index"}}}, true},
+	}
+
+	for _, tc := range testCases {
+		err := tc.checker.Check(context.Background())
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, want error: %t", tc.name, err, tc.wantErr)
+		}
+	}
+
+	// The write probe is given the context of the check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (&writeProbeChecker{&mockProber{}}).Check(ctx); err == nil {
+		t.Fatalf("Expected an error probing writes with a cancelled context, got none")
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	prober := &mockProber{}
+	check := readinessCheck{"writeProbe", &writeProbeChecker{prober}}
+
+	cache := newCheckCache(time.Hour)
+	first := cache.run(context.Background(), check)
+	second := cache.run(context.Background(), check)
+	if prober.probeRuns != 1 {
+		t.Fatalf("Expected the check to run once within its TTL, ran %d times", prober.probeRuns)
+	}
+	if first != second || first.Status != checkStatusOK {
+		t.Fatalf("Expected the cached result %v, got %v", first, second)
+	}
+
+	cache = newCheckCache(0)
+	cache.run(context.Background(), check)
+	if prober.probeRuns != 2 {
+		t.Fatalf("Expected the check to run again after its TTL, ran %d times", prober.probeRuns)
+	}
+}
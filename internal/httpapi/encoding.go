@@ -0,0 +1,198 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// errNotRepresentable is returned by encoders which can't represent a value, e.g. CSV for values
+// that aren't tables
+var errNotRepresentable = errors.New("value can't be represented in this format")
+
+// csvTable is implemented by responses which can be rendered as CSV
+type csvTable interface {
+	// csvRecords returns the header followed by the rows
+	csvRecords() [][]string
+}
+
+// encoder writes response bodies in one media type
+type encoder struct {
+	contentType string
+	// mediaTypes are the media types in Accept headers which select the encoder
+	mediaTypes []string
+	encode     func(w io.Writer, v interface{}) error
+}
+
+// encoders are the supported response formats in the order of preference. JSON is the default.
+var encoders = []encoder{
+	{"application/json", []string{"application/json"}, encodeJSON},
+	{"text/csv; charset=utf-8", []string{"text/csv"}, encodeCSV},
+	{"application/xml; charset=utf-8", []string{"application/xml", "text/xml"}, encodeXML},
+	{"application/msgpack", []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}, encodeMsgpack},
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func encodeCSV(w io.Writer, v interface{}) error {
+	table, ok := v.(csvTable)
+	if !ok {
+		return errNotRepresentable
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(table.csvRecords()); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// xmlList is the root element of lists, which XML documents can't have at the top level
+type xmlList struct {
+	XMLName xml.Name    `xml:"list"`
+	Items   interface{} `xml:"item"`
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if kind := reflect.ValueOf(v).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		v = xmlList{Items: v}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	err := xml.NewEncoder(w).Encode(v)
+	var unsupported *xml.UnsupportedTypeError
+	if errors.As(err, &unsupported) {
+		return errNotRepresentable
+	}
+	return err
+}
+
+func encodeMsgpack(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	// Use the same field names as in JSON
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// acceptedRange is a media range from an Accept header
+type acceptedRange struct {
+	mediaType string
+	quality   float64
+}
+
+// specificity ranks exact media types over type/* over */*
+func (a acceptedRange) specificity() int {
+	switch {
+	case a.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (a acceptedRange) matches(mediaType string) bool {
+	if a.mediaType == "*/*" || a.mediaType == mediaType {
+		return true
+	}
+	return strings.HasSuffix(a.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+}
+
+// parseAccept parses an Accept header as described in RFC 7231. Invalid ranges are ignored.
+func parseAccept(header string) []acceptedRange {
+	var ranges []acceptedRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptedRange{mediaType, quality})
+	}
+	return ranges
+}
+
+// negotiate returns the encoders acceptable to the client, the most preferred first
+//
+// The quality of a media type is taken from the most specific range matching it. Encoders with
+// the same quality are ordered by the server's preference. Without an Accept header, JSON is used.
+func negotiate(header string) []encoder {
+	if strings.TrimSpace(header) == "" {
+		return encoders[:1]
+	}
+	ranges := parseAccept(header)
+
+	type candidate struct {
+		encoder
+		quality float64
+	}
+	var candidates []candidate
+	for _, enc := range encoders {
+		best := acceptedRange{quality: 0}
+		bestSpecificity := -1
+		for _, mediaType := range enc.mediaTypes {
+			for _, r := range ranges {
+				if r.matches(mediaType) && (r.specificity() > bestSpecificity ||
+					(r.specificity() == bestSpecificity && r.quality > best.quality)) {
+					best, bestSpecificity = r, r.specificity()
+				}
+			}
+		}
+		if best.quality > 0 {
+			candidates = append(candidates, candidate{enc, best.quality})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	result := make([]encoder, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.encoder
+	}
+	return result
+}
+
+// addVary adds the header to the Vary header unless it's already listed
+func addVary(h http.Header, header string) {
+	for _, value := range h.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), header) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", header)
+}
+
+// notAcceptable is returned when the response can't be represented in any of the accepted types
+func notAcceptable() *apiError {
+	return &apiError{
+		status: http.StatusNotAcceptable,
+		code:   codeNotAcceptable,
+		title:  "Not acceptable",
+		detail: "The response can't be represented in any of the media types of the Accept header.",
+	}
+}
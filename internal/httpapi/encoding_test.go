@@ -0,0 +1,215 @@
+package httpapi
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		accept string
+		want   []string
+	}{
+		{"", []string{"application/json"}},
+		{"*/*", []string{"application/json", "text/csv; charset=utf-8", "application/xml; charset=utf-8", "application/msgpack"}},
+		{"text/csv", []string{"text/csv; charset=utf-8"}},
+		{"text/*", []string{"text/csv; charset=utf-8", "application/xml; charset=utf-8"}},
+		{"application/xml;q=0.5, application/json;q=0.9", []string{"application/json", "application/xml; charset=utf-8"}},
+		{"application/x-msgpack", []string{"application/msgpack"}},
+		{"*/*;q=0.1, text/csv", []string{"text/csv; charset=utf-8", "application/json", "application/xml; charset=utf-8", "application/msgpack"}},
+		{"*/*, application/json;q=0", []string{"text/csv; charset=utf-8", "application/xml; charset=utf-8", "application/msgpack"}},
+		{"image/png", []string{}},
+		{"not a media type", []string{}},
+	}
+
+	for _, tc := range testCases {
+		got := []string{}
+		for _, enc := range negotiate(tc.accept) {
+			got = append(got, enc.contentType)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Got %v for Accept %q, want %v", got, tc.accept, tc.want)
+		}
+	}
+}
+
+// searchWithAccept sends a search request with the given Accept header through the router
+func searchWithAccept(t *testing.T, accept string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "http://testsite.local/api/v1/offer/search", strings.NewReader(offerSearchBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	newSearchTestService().router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+// wantSearchResponse is the JSON response to the search in the mock database
+func wantSearchResponse(t *testing.T) offerSearchResponse {
+	t.Helper()
+
+	want := offerSearchResponse{}
+	if err := json.NewDecoder(searchWithAccept(t, "application/json").Body).Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+	return want
+}
+
+func TestSearchResponseFormats(t *testing.T) {
+	want := wantSearchResponse(t)
+
+	testCases := []struct {
+		accept      string
+		contentType string
+		decode      func(resp *http.Response) (offerSearchResponse, error)
+	}{
+		{"application/xml", "application/xml; charset=utf-8", func(resp *http.Response) (offerSearchResponse, error) {
+			got := offerSearchResponse{}
+			err := xml.NewDecoder(resp.Body).Decode(&got)
+			got.XMLName = xml.Name{}
+			return got, err
+		}},
+		{"application/msgpack", "application/msgpack", func(resp *http.Response) (offerSearchResponse, error) {
+			got := offerSearchResponse{}
+			dec := msgpack.NewDecoder(resp.Body)
+			dec.SetCustomStructTag("json")
+			err := dec.Decode(&got)
+			return got, err
+		}},
+	}
+
+	for _, tc := range testCases {
+		resp := searchWithAccept(t, tc.accept)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Got bad status code %d for %s, want %d", resp.StatusCode, tc.accept, http.StatusOK)
+		}
+		if got := resp.Header.Get("Content-Type"); got != tc.contentType {
+			t.Fatalf("Got content type %q, want %q", got, tc.contentType)
+		}
+		if got := resp.Header.Values("Vary"); !reflect.DeepEqual(got, []string{"Accept-Encoding", "Accept"}) {
+			t.Fatalf("Got Vary %v, want Accept-Encoding and Accept", got)
+		}
+
+		got, err := tc.decode(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Got response %v for %s, want %v", got, tc.accept, want)
+		}
+	}
+}
+
+func TestSearchResponseCSV(t *testing.T) {
+	want := wantSearchResponse(t)
+
+	resp := searchWithAccept(t, "text/csv")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Fatalf("Got content type %q, want text/csv", got)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(want.Offers)+1 {
+		t.Fatalf("Got %d records, want a header and %d offers", len(records), len(want.Offers))
+	}
+	if got := strings.Join(records[0], ","); got != "product,category,supplier,reviewScore,price,quantity,availability,leadTimeDays" {
+		t.Fatalf("Got header %q", got)
+	}
+	for i, o := range want.Offers {
+		wantRecord := []string{
+			"Towel",
+			"Must Haves",
+			o.Supplier,
+			strconv.FormatFloat(float64(o.ReviewScore), 'f', -1, 32),
+			strconv.FormatFloat(float64(o.Price), 'f', -1, 32),
+			"", "", "",
+		}
+		if !reflect.DeepEqual(records[i+1], wantRecord) {
+			t.Fatalf("Got record %v, want %v", records[i+1], wantRecord)
+		}
+	}
+}
+
+func TestSearchResponseCSV_stock(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer/batch", `[
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42,"quantity":3,"leadTimeDays":2},
+		{"product":"Towel","category":"Must Haves","supplier":"Megadodo","price":44,"availability":"backorder"}
+	]`)
+
+	resp := serveAs(service, "GET", offerQueryURL, "", map[string]string{"Accept": "text/csv"})
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"Towel", "Must Haves", "Hitchhiker Essentials", "3", "42", "3", "in_stock", "2"},
+		{"Towel", "Must Haves", "Megadodo", "3", "44", "", "backorder", ""},
+	}
+	if len(records) != len(want)+1 || !reflect.DeepEqual(records[1:], want) {
+		t.Fatalf("Got records %v, want a header and %v", records, want)
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		accept string
+		url    string
+	}{
+		{"unsupported type", "image/png", "http://testsite.local/api/v1/offers?product=Towel&category=Must+Haves"},
+		{"CSV for a non-tabular response", "text/csv", "http://testsite.local/version"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		req.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		newSearchTestService().router.ServeHTTP(w, req)
+		resp := w.Result()
+
+		if resp.StatusCode != http.StatusNotAcceptable {
+			t.Fatalf("%s: got status code %d, want %d", tc.name, resp.StatusCode, http.StatusNotAcceptable)
+		}
+		got := problem{}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != codeNotAcceptable {
+			t.Fatalf("%s: got code %q, want %q", tc.name, got.Code, codeNotAcceptable)
+		}
+	}
+}
+
+func TestNegotiationFallback(t *testing.T) {
+	// A type which can't represent the response is skipped in favour of the next acceptable one
+	req := httptest.NewRequest("GET", "http://testsite.local/version", nil)
+	req.Header.Set("Accept", "text/csv, application/json;q=0.5")
+	w := httptest.NewRecorder()
+	newSearchTestService().router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("Got content type %q, want application/json", got)
+	}
+}
@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/export"
+)
+
+// handleOfferExport returns an http.HandlerFunc which streams all offers matching the filters
+// given in the query string in the requested format
+func (s *Service) handleOfferExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		formatName := query.Get("format")
+		if formatName == "" {
+			formatName = string(export.CSV)
+		}
+		format, err := export.ParseFormat(formatName)
+		if err != nil {
+			s.respondError(w, r, invalidRequest(err.Error()))
+			return
+		}
+
+		filter := database.OfferFilter{
+			Category: query.Get("category"),
+			Supplier: query.Get("supplier"),
+		}
+		if updatedSince := query.Get("updatedSince"); updatedSince != "" {
+			filter.UpdatedSince, err = time.Parse(time.RFC3339, updatedSince)
+			if err != nil {
+				s.respondError(w, r, invalidRequest("updatedSince must be an RFC 3339 timestamp"))
+				return
+			}
+		}
+
+		it, err := s.offersFor(r).Iterate(r.Context(), filter)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		defer it.Close()
+
+		// Exports of many offers take as long as they take, so they mustn't be cut off by the
+		// server's write timeout
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf(`attachment; filename="offers.%s"`, format.Extension()),
+		)
+
+		// The status has been sent once the first bytes are written, so errors can only be logged
+		_, err = export.Offers(w, format, it)
+		if err != nil {
+			log.Printf("Error exporting offers: %v", err)
+		}
+	}
+}
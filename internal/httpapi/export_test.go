@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+func TestOfferExport(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+
+	req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers/export?format=csv", nil)
+	w := httptest.NewRecorder()
+	service.handleOfferExport()(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if got := resp.Header.Get("Content-Type"); got != "text/csv" {
+		t.Fatalf("Got content type %q, want text/csv", got)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "product,category,supplier,price,updatedAt\n" +
+		"Towel,Must Haves,\"Hitchhiker Essentials, just more expensive\",44,2020-10-09T18:02:21Z\n" +
+		"Towel,Must Haves,Hitchhiker Essentials,42,2020-10-09T18:02:21Z\n" +
+		"Towel,Must Haves,Hitchhiker Knockoffs,42,2020-10-09T18:02:21Z\n"
+	if string(body) != want {
+		t.Fatalf("Got export %q, want %q", body, want)
+	}
+}
+
+func TestOfferExport_withBadParameters(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+
+	for _, query := range []string{"format=xlsx", "updatedSince=yesterday"} {
+		req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers/export?"+query, nil)
+		w := httptest.NewRecorder()
+		service.handleOfferExport()(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Got bad status code %d for %q, want %d", w.Code, query, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestOfferExport_withDBError(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockErrorDB{})
+
+	req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers/export", nil)
+	w := httptest.NewRecorder()
+	service.handleOfferExport()(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Got bad status code %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+// slowIteratingDB is a mock of the database whose cursor takes a while for every offer
+type slowIteratingDB struct{ mockDB }
+
+func (mock *slowIteratingDB) Iterate(ctx context.Context, filter database.OfferFilter) (database.OfferIterator, error) {
+	it, err := mock.mockDB.Iterate(ctx, filter)
+	return &slowIterator{OfferIterator: it, ctx: ctx}, err
+}
+
+type slowIterator struct {
+	database.OfferIterator
+	ctx context.Context
+}
+
+func (it *slowIterator) Next() bool {
+	time.Sleep(10 * time.Millisecond)
+	return it.ctx.Err() == nil && it.OfferIterator.Next()
+}
+
+func (it *slowIterator) Err() error {
+	return it.ctx.Err()
+}
+
+func TestOfferExport_outlivesRequestTimeout(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&slowIteratingDB{})
+	service.server.WriteTimeout = 10 * time.Millisecond
+
+	resp := serve(service, "GET", "http://testsite.local/api/v1/offers/export?format=csv", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(body), "\n"); lines != 4 {
+		t.Fatalf("Got export %q, want the header and all three offers", body)
+	}
+}
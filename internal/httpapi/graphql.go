@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/muffix/relayr-challenge/internal/graphqlapi"
+)
+
+// handleGraphQL returns an http.HandlerFunc which executes GraphQL queries
+//
+// Queries are sent as JSON in the body of POST requests or in the query, operationName and
+// variables parameters of GET requests. Requests which can't be executed at all, e.g. because
+// they are invalid or exceed the limits, get a 400 response. Otherwise, the response is 200 and
+// errors of single fields are reported next to the data.
+func (s *Service) handleGraphQL() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.graphQL == nil {
+			s.respondError(w, r, notConfigured("GraphQL queries"))
+			return
+		}
+
+		request := graphqlapi.Request{}
+		if r.Method == http.MethodGet {
+			query := r.URL.Query()
+			request.Query = query.Get("query")
+			request.OperationName = query.Get("operationName")
+			if variables := query.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+					s.respondError(w, r, invalidRequest("variables must be a JSON object"))
+					return
+				}
+			}
+		} else if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		result := s.graphQL.Execute(r.Context(), s.offersFor(r), s.reviewer, request)
+		status := http.StatusOK
+		if result.Data == nil && result.HasErrors() {
+			status = http.StatusBadRequest
+		}
+		s.respond(w, r, result, status)
+	}
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/graphqlapi"
+)
+
+func newGraphQLTestService(t *testing.T) *Service {
+	executor, err := graphqlapi.NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+	service.SetReviewer(&mockReviewer{})
+	service.SetGraphQLExecutor(executor)
+	return service
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	service := newGraphQLTestService(t)
+	query := `{ product(name: "Towel", category: "Must Haves") { cheapestOffer { price supplier { name } } } }`
+
+	body, err := json.Marshal(graphqlapi.Request{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resp := range []*http.Response{
+		serve(service, "POST", "http://testsite.local/graphql", string(body)),
+		serve(service, "GET", "http://testsite.local/graphql?query="+url.QueryEscape(query), ""),
+	} {
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+
+		var result struct {
+			Data struct {
+				Product struct {
+					CheapestOffer struct {
+						Price    float32
+						Supplier struct{ Name string }
+					}
+				}
+			}
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if offer := result.Data.Product.CheapestOffer; offer.Price != 42 || offer.Supplier.Name != "Hitchhiker Essentials" {
+			t.Fatalf("Got cheapest offer %+v, want the one by Hitchhiker Essentials", offer)
+		}
+	}
+}
+
+func TestGraphQLHandler_withInvalidRequests(t *testing.T) {
+	service := newGraphQLTestService(t)
+
+	testCases := []struct {
+		name, method, url, body string
+	}{
+		{"not JSON", "POST", "/graphql", "I'm not JSON"},
+		{"invalid query", "POST", "/graphql", `{"query":"{ product { name } }"}`},
+		{"invalid variables", "GET", "/graphql?query=%7B__typename%7D&variables=nope", ""},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: got status code %d, want %d", testCase.name, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGraphQLHandler_withoutExecutor(t *testing.T) {
+	service := NewService(1234)
+	if resp := serve(service, "POST", "http://testsite.local/graphql", `{"query":"{ __typename }"}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
@@ -1,15 +1,18 @@
 package httpapi
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/etherlabsio/healthcheck"
 )
 
 type healthcheckResponse struct {
-	Status string            `json:"status,omitempty"`
-	Errors map[string]string `json:"errors,omitempty"`
+	Status string                 `json:"status,omitempty"`
+	Errors map[string]string      `json:"errors,omitempty"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
 }
 
 // handleLiveness returns an http.HandlerFunc which performs all checks that determine
@@ -24,7 +27,10 @@ func (s *Service) handleLiveness() http.HandlerFunc {
 
 		// Checkers will fail the status in case of an error.
 		// Since we're talking about a SQLite database, it makes sense to kill the container
-		// in this case and have it create a new, empty database.
+		// if it can't be read. If the database file is gone as well, the new container starts with
+		// an empty database, so the latest backup should be restored with `offersctl restore`.
+		// Writes and corruption aren't checked here, since a restart doesn't help if a long write
+		// holds the write connection or the file is corrupt. The readiness reports them.
 		healthcheck.WithChecker(
 			"database", &databaseChecker{service: s},
 		),
@@ -40,20 +46,44 @@ func (s *Service) handleLiveness() http.HandlerFunc {
 // whether the service is ready to take traffic.
 //
 // Checks here should fail e.g. when dependencies are down, but where destroying and recreating this
-// container won't help.
+// container won't help. They run concurrently and their results are cached, so that frequent
+// probes don't put load on the dependencies. The latency of every check is reported.
 func (s *Service) handleReadiness() http.HandlerFunc {
-	return healthcheck.HandlerFunc(
-		// WithTimeout allows you to set a max overall timeout.
-		healthcheck.WithTimeout(5*time.Second),
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
 
-		// Checkers will fail the status if they return an error
-		healthcheck.WithChecker(
-			"example", customChecker{},
-		),
+		checks := s.readinessChecks()
+		cache := s.readinessCache
+		results := make([]checkResult, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = cache.run(ctx, check)
+			}()
+		}
+		wg.Wait()
 
-		// Observers (as opposed to checkers) do not fail the status in case of an error.
-		// healthcheck.WithObserver(
-		// 	"example", customChecker{},
-		// ),
-	)
+		response := healthcheckResponse{Status: checkStatusOK}
+		status := http.StatusOK
+		for i, check := range checks {
+			if response.Checks == nil {
+				response.Checks = map[string]checkResult{}
+			}
+			response.Checks[check.name] = results[i]
+
+			if results[i].Error != "" {
+				if response.Errors == nil {
+					response.Errors = map[string]string{}
+				}
+				response.Errors[check.name] = results[i].Error
+				status = http.StatusServiceUnavailable
+				response.Status = http.StatusText(status)
+			}
+		}
+
+		s.respond(w, r, response, status)
+	}
 }
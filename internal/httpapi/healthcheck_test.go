@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -34,7 +35,52 @@ func TestReadiness(t *testing.T) {
 
 	want := healthcheckResponse{Status: "OK"}
 	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("Expected %s, got %s", want, got)
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestReadiness_dependencies(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	service.SetReviewer(&mockReviewer{})
+
+	resp := serve(service, "GET", "http://testsite.local/readiness", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	got := healthcheckResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"reviews", "migrations", "diskSpace", "writeProbe"} {
+		if result, ok := got.Checks[name]; !ok || result.Status != checkStatusOK || result.CheckedAt.IsZero() {
+			t.Fatalf("Expected a successful %s check, got %v", name, got.Checks)
+		}
+	}
+
+	// Failures are reported once the cached results have expired
+	config := DefaultReadinessConfig()
+	config.CacheTTL = 0
+	config.MinFreeDiskSpace = math.MaxUint64
+	service.SetReadinessConfig(config)
+	service.SetReviewer(&mockErrorReviewer{})
+
+	resp = serve(service, "GET", "http://testsite.local/readiness", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	got = healthcheckResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Errors["reviews"]; !ok {
+		t.Fatalf("Expected an error of the reviews check, got %v", got.Errors)
+	}
+	if _, ok := got.Errors["diskSpace"]; !ok {
+		t.Fatalf("Expected an error of the disk space check, got %v", got.Errors)
+	}
+	if got.Checks["writeProbe"].Status != checkStatusOK {
+		t.Fatalf("Expected the write probe to succeed, got %v", got.Checks["writeProbe"])
 	}
 }
 
@@ -82,7 +128,7 @@ func TestLiveness(t *testing.T) {
 		}
 
 		if !reflect.DeepEqual(got, testCase.want) {
-			t.Fatalf("Expected %s, got %s", testCase.want, got)
+			t.Fatalf("Expected %v, got %v", testCase.want, got)
 		}
 	}
 }
@@ -0,0 +1,335 @@
+package httpapi
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyRetention is how long responses to requests with an idempotency key are kept
+// unless another retention is set
+const DefaultIdempotencyRetention = 24 * time.Hour
+
+const (
+	// idempotencyKeyHeader is the request header clients use to mark retries of the same request
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader is set on responses that were replayed from the store
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencySweepInterval = time.Minute
+
+	// idempotencyMaxTenantBytes is roughly how much memory the stored responses of a tenant may use.
+	// The oldest responses are evicted beyond it.
+	idempotencyMaxTenantBytes = 32 << 20
+	// idempotencyEntryOverhead is the memory an entry uses besides its key and response
+	idempotencyEntryOverhead = 256
+)
+
+// unreplayedHeaders aren't stored with responses. Middlewares set them again for the replay, or
+// they describe how the response was encoded on the wire rather than the stored body.
+var unreplayedHeaders = []string{"Content-Encoding", "Content-Length", requestIDHeader, "Vary"}
+
+// idempotencyKey is an idempotency key of a tenant. Keys are scoped to the tenant, so that tenants
+// can't replay each other's responses.
+type idempotencyKey struct {
+	tenant, key string
+}
+
+// idempotencyEntry is a stored request fingerprint together with the response to it
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	createdAt   time.Time
+	done        bool
+
+	status int
+	header http.Header
+	body   []byte
+
+	// size is roughly the memory the entry uses, and element its place in the tenant's keys
+	size    int
+	element *list.Element
+}
+
+// tenantIdempotencyKeys are the keys of a tenant, oldest first, and the memory their entries use
+type tenantIdempotencyKeys struct {
+	order *list.List
+	bytes int
+}
+
+// idempotencyStore keeps responses to requests with an idempotency key in memory
+//
+// Entries expire after the retention period. Since the offers are stored in a database local to
+// the instance, keeping the keys local to the instance as well is consistent. Every tenant may only
+// use so much memory, so the oldest responses of a tenant are evicted when it keeps minting keys.
+type idempotencyStore struct {
+	mu             sync.Mutex
+	retention      time.Duration
+	maxTenantBytes int
+	entries        map[idempotencyKey]*idempotencyEntry
+	tenants        map[string]*tenantIdempotencyKeys
+	lastSweep      time.Time
+
+	// now returns the current time. It can be replaced in tests.
+	now func() time.Time
+}
+
+func newIdempotencyStore(retention time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		retention:      retention,
+		maxTenantBytes: idempotencyMaxTenantBytes,
+		entries:        make(map[idempotencyKey]*idempotencyEntry),
+		tenants:        make(map[string]*tenantIdempotencyKeys),
+		now:            time.Now,
+	}
+}
+
+// setRetention changes how long responses are kept
+func (s *idempotencyStore) setRetention(retention time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+}
+
+// begin looks up the entry for a key or reserves it for a new request
+//
+// Returns the existing entry and true if the key is known. Otherwise, a new, unfinished entry is
+// stored and returned with false.
+func (s *idempotencyStore) begin(key idempotencyKey, fingerprint [sha256.Size]byte) (idempotencyEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if entry, ok := s.entries[key]; ok {
+		if !s.expired(entry, now) {
+			return *entry, true
+		}
+		s.remove(key)
+	}
+
+	tenant, ok := s.tenants[key.tenant]
+	if !ok {
+		tenant = &tenantIdempotencyKeys{order: list.New()}
+		s.tenants[key.tenant] = tenant
+	}
+
+	entry := &idempotencyEntry{fingerprint: fingerprint, createdAt: now, size: idempotencyEntryOverhead + len(key.key)}
+	entry.element = tenant.order.PushBack(key)
+	tenant.bytes += entry.size
+	s.entries[key] = entry
+	s.evict(tenant)
+	return *entry, false
+}
+
+// finish stores the response for a key reserved by begin
+func (s *idempotencyStore) finish(key idempotencyKey, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	entry.done = true
+	entry.status = status
+	entry.header = header
+	entry.body = body
+
+	size := idempotencyEntryOverhead + len(key.key) + len(body)
+	for name, values := range header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	tenant := s.tenants[key.tenant]
+	tenant.bytes += size - entry.size
+	entry.size = size
+	s.evict(tenant)
+}
+
+// release forgets a key reserved by begin, so that the request can be retried
+func (s *idempotencyStore) release(key idempotencyKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+}
+
+func (s *idempotencyStore) expired(entry *idempotencyEntry, now time.Time) bool {
+	return now.Sub(entry.createdAt) > s.retention
+}
+
+// remove forgets a key
+//
+// Must be called with the lock held.
+func (s *idempotencyStore) remove(key idempotencyKey) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	delete(s.entries, key)
+
+	tenant := s.tenants[key.tenant]
+	tenant.order.Remove(entry.element)
+	tenant.bytes -= entry.size
+	if tenant.order.Len() == 0 {
+		delete(s.tenants, key.tenant)
+	}
+}
+
+// evict removes the oldest finished entries of the tenant until its entries fit into the limit.
+// Requests in progress are kept, so that they can't be run twice.
+//
+// Must be called with the lock held.
+func (s *idempotencyStore) evict(tenant *tenantIdempotencyKeys) {
+	element := tenant.order.Front()
+	for tenant.bytes > s.maxTenantBytes && element != nil {
+		key := element.Value.(idempotencyKey)
+		element = element.Next()
+		if s.entries[key].done {
+			s.remove(key)
+		}
+	}
+}
+
+// sweep removes expired entries. It only runs once per sweep interval to keep writes cheap.
+//
+// Must be called with the lock held.
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if s.expired(entry, now) {
+			s.remove(key)
+		}
+	}
+}
+
+// capturingResponseWriter passes writes through to the wrapped writer and keeps a copy
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *capturingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController can flush streamed responses
+func (w *capturingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// idempotent makes a handler safe to retry by clients which send an Idempotency-Key header
+//
+// The first response for a key is stored for the configured retention period and replayed for
+// later requests with the same key and payload. Reusing a key with a different payload results
+// in a conflict. Requests without the header are passed through unchanged.
+//
+// Server errors aren't stored since nothing has been applied in that case and a retry should be
+// allowed to succeed.
+func (s *Service) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := idempotencyKey{tenant: tenantOf(r.Context()).ID, key: r.Header.Get(idempotencyKeyHeader)}
+		if key.key == "" {
+			h(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		entry, exists := s.idempotencyKeys.begin(key, fingerprint)
+
+		if exists {
+			switch {
+			case entry.fingerprint != fingerprint:
+				s.respondError(w, r, &apiError{
+					status: http.StatusConflict,
+					code:   codeIdempotencyKeyReused,
+					title:  "Idempotency key reused",
+					detail: "The idempotency key was already used for a different request.",
+				})
+			case !entry.done:
+				s.respondError(w, r, &apiError{
+					status: http.StatusConflict,
+					code:   codeIdempotencyKeyInFlight,
+					title:  "Request in progress",
+					detail: "A request with this idempotency key is still being processed.",
+				})
+			default:
+				replayResponse(w, entry)
+			}
+			return
+		}
+
+		// A handler which panics hasn't finished, so it may be retried
+		finished := false
+		defer func() {
+			if !finished {
+				s.idempotencyKeys.release(key)
+			}
+		}()
+
+		capture := &capturingResponseWriter{ResponseWriter: w}
+		h(capture, r)
+		finished = true
+
+		if capture.status == 0 || capture.status >= http.StatusInternalServerError {
+			s.idempotencyKeys.release(key)
+			return
+		}
+		header := w.Header().Clone()
+		for _, name := range unreplayedHeaders {
+			header.Del(name)
+		}
+		s.idempotencyKeys.finish(key, capture.status, header, capture.body.Bytes())
+	}
+}
+
+// requestFingerprint hashes the parts of a request that have to match for a replay
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint
+}
+
+// replayResponse writes a stored response. Its headers replace the ones set by middlewares.
+func replayResponse(w http.ResponseWriter, entry idempotencyEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(entry.status)
+	_, _ = w.Write(entry.body)
+}
@@ -0,0 +1,251 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// countingDB is a mock of the database which counts the inserted offers
+type countingDB struct {
+	mockDB
+	inserted int
+}
+
+func (mock *countingDB) Insert(_ context.Context, _, _, _ string, _ float32) error {
+	mock.inserted++
+	return nil
+}
+
+func (mock *countingDB) InsertMultiple(_ context.Context, offers []database.Offer) error {
+	mock.inserted += len(offers)
+	return nil
+}
+
+// postWithIdempotencyKey sends the body to the handler and returns the status code and body
+func postWithIdempotencyKey(t *testing.T, h http.HandlerFunc, key, body string) (*http.Response, string) {
+	w, req := prepareTestRequest(body)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	h(w, req)
+	resp := w.Result()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(respBody)
+}
+
+func TestIdempotent_replaysResponse(t *testing.T) {
+	db := &countingDB{}
+	service := NewService(1234)
+	service.SetDatabase(db)
+	h := service.idempotent(service.handleOffer())
+
+	first, firstBody := postWithIdempotencyKey(t, h, "key", offerBody)
+	second, secondBody := postWithIdempotencyKey(t, h, "key", offerBody)
+
+	if first.StatusCode != http.StatusOK || second.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status codes %d and %d, want %d", first.StatusCode, second.StatusCode, http.StatusOK)
+	}
+
+	if firstBody != secondBody {
+		t.Fatalf("Expected the replayed body %q to equal the original %q", secondBody, firstBody)
+	}
+
+	if got := second.Header.Get(idempotencyReplayedHeader); got != "true" {
+		t.Fatalf("Expected the replayed response to be marked, got %q", got)
+	}
+
+	if db.inserted != 1 {
+		t.Fatalf("Expected the offer to be inserted once, got %d inserts", db.inserted)
+	}
+}
+
+func TestIdempotent_withDifferentPayload(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&countingDB{})
+	h := service.idempotent(service.handleOffer())
+
+	postWithIdempotencyKey(t, h, "key", offerBody)
+	resp, _ := postWithIdempotencyKey(t, h, "key", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":41}`)
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestIdempotent_withoutKey(t *testing.T) {
+	db := &countingDB{}
+	service := NewService(1234)
+	service.SetDatabase(db)
+	h := service.idempotent(service.handleOffer())
+
+	postWithIdempotencyKey(t, h, "", offerBody)
+	postWithIdempotencyKey(t, h, "", offerBody)
+
+	if db.inserted != 2 {
+		t.Fatalf("Expected the offer to be inserted twice without a key, got %d inserts", db.inserted)
+	}
+}
+
+func TestIdempotent_afterRetention(t *testing.T) {
+	db := &countingDB{}
+	service := NewService(1234)
+	service.SetDatabase(db)
+	service.SetIdempotencyRetention(time.Hour)
+	h := service.idempotent(service.handleOffer())
+
+	now := time.Now()
+	service.idempotencyKeys.now = func() time.Time { return now }
+	postWithIdempotencyKey(t, h, "key", offerBody)
+
+	now = now.Add(2 * time.Hour)
+	resp, _ := postWithIdempotencyKey(t, h, "key", offerBody)
+
+	if resp.Header.Get(idempotencyReplayedHeader) != "" {
+		t.Fatal("Expected an expired key not to be replayed")
+	}
+
+	if db.inserted != 2 {
+		t.Fatalf("Expected the offer to be inserted again after expiry, got %d inserts", db.inserted)
+	}
+}
+
+func TestIdempotent_withServerError(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockErrorDB{})
+	h := service.idempotent(service.handleOffer())
+
+	postWithIdempotencyKey(t, h, "key", offerBody)
+
+	db := &countingDB{}
+	service.SetDatabase(db)
+	resp, _ := postWithIdempotencyKey(t, h, "key", offerBody)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if db.inserted != 1 {
+		t.Fatalf("Expected a retry after a server error to be executed, got %d inserts", db.inserted)
+	}
+}
+
+func TestIdempotent_replaysCompressedResponse(t *testing.T) {
+	service := newValidatingTestService(t)
+	body := `{"name":"Megadodo","metadata":{"description":"` + strings.Repeat("Mostly harmless. ", 100) + `"}}`
+	post := func(encoding string) (*http.Response, []byte) {
+		headers := map[string]string{idempotencyKeyHeader: "key"}
+		if encoding != "" {
+			headers["Accept-Encoding"] = encoding
+		}
+		resp := serveAs(service, "POST", "http://testsite.local/api/v1/suppliers", body, headers)
+
+		var reader io.Reader = resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader = gz
+		}
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, decoded
+	}
+
+	first, want := post("gzip")
+	if first.StatusCode != http.StatusCreated || first.Header.Get("Content-Encoding") != "gzip" || len(want) < 1024 {
+		t.Fatalf("Got status code %d with %d bytes encoded as %q, want a compressed response of at least 1 KB",
+			first.StatusCode, len(want), first.Header.Get("Content-Encoding"))
+	}
+
+	for _, encoding := range []string{"", "gzip"} {
+		resp, got := post(encoding)
+		if resp.Header.Get(idempotencyReplayedHeader) != "true" || resp.Header.Get("Content-Encoding") != encoding {
+			t.Fatalf("%q: got a replay %q encoded as %q, want a replay encoded as %q", encoding,
+				resp.Header.Get(idempotencyReplayedHeader), resp.Header.Get("Content-Encoding"), encoding)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("%q: got the replayed body %q, want %q", encoding, got, want)
+		}
+		for _, name := range []string{requestIDHeader, "Content-Type", "Vary"} {
+			if values := resp.Header.Values(name); len(values) != 1 {
+				t.Fatalf("%q: got %s %v, want a single value", encoding, name, values)
+			}
+		}
+	}
+}
+
+func TestIdempotencyStore_evictsOldestEntries(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	store.maxTenantBytes = 3 * (idempotencyEntryOverhead + 100)
+	body := []byte(strings.Repeat("x", 100-len("key0")))
+
+	for i := 0; i < 4; i++ {
+		key := idempotencyKey{tenant: "acme", key: "key" + strconv.Itoa(i)}
+		store.begin(key, [32]byte{})
+		store.finish(key, http.StatusOK, nil, body)
+	}
+	// Other tenants keep their keys
+	globex := idempotencyKey{tenant: "globex", key: "key0"}
+	store.begin(globex, [32]byte{})
+
+	for key, want := range map[idempotencyKey]bool{
+		{"acme", "key0"}: false,
+		{"acme", "key1"}: true,
+		{"acme", "key3"}: true,
+		globex:           true,
+	} {
+		if _, ok := store.entries[key]; ok != want {
+			t.Fatalf("Got key %v stored: %t, want %t", key, ok, want)
+		}
+	}
+	if got := store.tenants["acme"].bytes; got != store.maxTenantBytes {
+		t.Fatalf("Got %d bytes for acme, want %d", got, store.maxTenantBytes)
+	}
+
+	// Requests in progress aren't evicted, even if the tenant is over its limit
+	store.maxTenantBytes = 0
+	inProgress := idempotencyKey{tenant: "acme", key: "in progress"}
+	store.begin(inProgress, [32]byte{})
+	if _, ok := store.entries[inProgress]; !ok || len(store.entries) != 2 {
+		t.Fatalf("Got entries %v, want only the requests in progress", store.entries)
+	}
+}
+
+func TestIdempotent_withPanic(t *testing.T) {
+	service := NewService(1234)
+	panicking := service.idempotent(func(http.ResponseWriter, *http.Request) { panic("don't panic") })
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected the panic to be passed on")
+			}
+		}()
+		postWithIdempotencyKey(t, panicking, "key", offerBody)
+	}()
+
+	// The key isn't stuck in progress
+	db := &countingDB{}
+	service.SetDatabase(db)
+	resp, _ := postWithIdempotencyKey(t, service.idempotent(service.handleOffer()), "key", offerBody)
+	if resp.StatusCode != http.StatusOK || db.inserted != 1 {
+		t.Fatalf("Got status code %d and %d inserts, want the retry to be executed", resp.StatusCode, db.inserted)
+	}
+}
@@ -1,16 +1,105 @@
 package httpapi
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
+	"log/slog"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
-// logRequest logs the method and URL of a request
-//
-// It's mostly a demo middleware trying to be useful.
-func (s *Service) logRequest(h http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s", r.Method, r.URL)
-		h(w, r)
+// logRequest is a middleware which logs the method and URL of every request at info level. Raising
+// the log level through the admin routes silences it.
+func (s *Service) logRequest(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Info("Request", "id", requestID(r.Context()), "method", r.Method, "url", r.URL.String())
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestIDHeader is the header with the ID of a request. Clients may send one. Otherwise, it's
+// generated. It's always returned in the response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of request IDs sent by clients
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID is a middleware which adds the ID of the request to its context and response
+func (s *Service) withRequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID of the request, or an empty string if it has none
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID checks that an ID sent by a client is safe to log and return
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
 	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating request ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// streamingRoutes are the paths of the routes whose responses are streamed for as long as clients
+// listen or as long as there's data. They aren't subject to the request timeout.
+var streamingRoutes = map[string]bool{
+	"/api/v1/offers/stream": true,
+	"/api/v1/offers/export": true,
+}
+
+// routePath returns the path template of the route matching the request, or an empty string if
+// there's none
+func routePath(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	path, _ := route.GetPathTemplate()
+	return path
+}
+
+// withRequestTimeout is a middleware which cancels the context of a request, and with it its
+// database queries, once the server's write timeout has passed. The response couldn't be sent
+// after that anyway.
+func (s *Service) withRequestTimeout(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.server.WriteTimeout
+		if timeout <= 0 || streamingRoutes[routePath(r)] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
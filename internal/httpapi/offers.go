@@ -1,91 +1,171 @@
 package httpapi
 
 import (
+	"encoding/xml"
 	"net/http"
-	"sort"
+	"strconv"
 
 	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
 )
 
 // offerSearchRequest is the struct representing the POST request body to the endpoint
 type offerSearchRequest struct {
 	ProductName string `json:"product"`
 	Category    string `json:"category"`
+	// IncludeSubcategories adds the offers in all categories below the category in the tree
+	IncludeSubcategories bool `json:"includeSubcategories"`
+	// IncludeUnavailable adds the offers which are out of stock
+	IncludeUnavailable bool `json:"includeUnavailable"`
+	// MaxLeadTimeDays leaves out the offers which take longer to deliver, if it's set
+	MaxLeadTimeDays *int `json:"maxLeadTimeDays"`
 }
 
 // offerSearchResponse is the struct representing responses to searches
 type offerSearchResponse struct {
-	Name     string      `json:"name"`
-	Category string      `json:"category"`
-	Offers   []offerData `json:"offers"`
+	XMLName  xml.Name `json:"-" xml:"search"`
+	Product  string   `json:"product" xml:"product"`
+	Category string   `json:"category" xml:"category"`
+	// Currency is the currency of the prices if it's configured for the tenant
+	Currency string `json:"currency,omitempty" xml:"currency,omitempty"`
+	// Breadcrumbs is the path from the top level down to the category if it's part of the tree
+	Breadcrumbs []categoryRef `json:"breadcrumbs,omitempty" xml:"breadcrumbs>category,omitempty"`
+	Offers      []offerData   `json:"offers" xml:"offers>offer"`
 }
 
 type offerData struct {
-	Supplier    string  `json:"supplier"`
-	ReviewScore float32 `json:"reviewScore"`
-	Price       float32 `json:"price"`
+	Supplier    string  `json:"supplier" xml:"supplier"`
+	ReviewScore float32 `json:"reviewScore" xml:"reviewScore"`
+	Price       float32 `json:"price" xml:"price"`
+	// Category is the category of the offer if subcategories are included in the search
+	Category string `json:"category,omitempty" xml:"category,omitempty"`
+	offerStock
 }
 
-// offerErrorResponse is the response in case an error occurs
-type offerErrorResponse struct {
-	Error string `json:"error"`
+// csvRecords returns one record per offer, preceded by a header. The stock columns are empty if the
+// supplier doesn't report them, and the currency is only added as a column if it's configured for
+// the tenant.
+func (r offerSearchResponse) csvRecords() [][]string {
+	header := []string{"product", "category", "supplier", "reviewScore", "price", "quantity", "availability", "leadTimeDays"}
+	if r.Currency != "" {
+		header = append(header, "currency")
+	}
+	records := [][]string{header}
+	for _, o := range r.Offers {
+		category := r.Category
+		if o.Category != "" {
+			category = o.Category
+		}
+		record := []string{
+			r.Product,
+			category,
+			o.Supplier,
+			strconv.FormatFloat(float64(o.ReviewScore), 'f', -1, 32),
+			strconv.FormatFloat(float64(o.Price), 'f', -1, 32),
+			formatOptionalInt(o.Quantity),
+			o.Availability,
+			formatOptionalInt(o.LeadTimeDays),
+		}
+		if r.Currency != "" {
+			record = append(record, r.Currency)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// formatOptionalInt returns the number as a CSV field, which is empty if it isn't set
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
 }
 
-// handleOfferSearch returns an http.HandlerFunc for the home page endpoint
+// handleOfferSearch returns an http.HandlerFunc for the offer search endpoint
+//
+// Offers of suspended suppliers are hidden, and so are offers which are out of stock unless they're
+// included.
 func (s *Service) handleOfferSearch() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request := offerSearchRequest{}
 		err := s.decode(w, r, &request)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusBadRequest)
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.ProductName == "" || request.Category == "" {
+			s.respondError(w, r, invalidRequest("product and category are required"))
+			return
+		}
+		if request.MaxLeadTimeDays != nil && *request.MaxLeadTimeDays < 0 {
+			s.respondError(w, r, invalidRequest("maxLeadTimeDays must not be negative"))
 			return
 		}
 
-		// Get the offers
-		offers, err := s.offers.Get(request.ProductName, request.Category)
+		categories, err := s.searchCategories(r, request)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusInternalServerError)
+			s.respondError(w, r, err)
 			return
 		}
 
-		// Get the scores
-		suppliers := make([]string, len(offers))
-		for i, offer := range offers {
-			suppliers[i] = offer.Supplier
-		}
-		reviewScores, err := s.reviewer.Suppliers(suppliers)
+		// Get the offers
+		offers, err := s.getOffers(r, request, categories)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusBadGateway)
+			s.respondError(w, r, err)
 			return
 		}
 
-		response := offerSearchResponse{
-			Name:     request.ProductName,
-			Category: request.Category,
+		crumbs, err := s.breadcrumbs(r, request.Category)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
 		}
 
-		for _, o := range offers {
-			response.Offers = append(response.Offers,
-				offerData{
-					o.Supplier,
-					reviewScores[o.Supplier],
-					o.Price,
-				},
-			)
+		response, err := s.searchResponse(r, request, offers)
+		if err != nil {
+			s.respondError(w, r, reviewsUnavailable(err))
+			return
 		}
-
-		// Sort the offers by price first, then by review score
-		sort.SliceStable(response.Offers, func(i, j int) bool {
-			if response.Offers[i].Price == response.Offers[j].Price {
-				return response.Offers[i].ReviewScore >= response.Offers[j].ReviewScore
-			}
-			return response.Offers[i].Price < response.Offers[j].Price
-		})
+		response.Breadcrumbs = crumbs
 
 		s.respond(w, r, response, http.StatusOK)
 	}
 }
 
+// searchResponse adds the review scores to the offers and ranks them as configured for the tenant
+// of the request
+func (s *Service) searchResponse(r *http.Request, request offerSearchRequest, offers []database.Offer) (offerSearchResponse, error) {
+	tenant := tenantOf(r.Context())
+	reviewer := ranking.ReviewerFor(r.Context(), s.offersFor(r), s.reviewer)
+	ranked, err := ranking.RankBy(reviewer, offers, tenant.Ranking)
+	if err != nil {
+		return offerSearchResponse{}, err
+	}
+
+	response := offerSearchResponse{
+		Product:  request.ProductName,
+		Category: request.Category,
+		Currency: tenant.Currency,
+		Offers:   []offerData{},
+	}
+
+	for _, o := range ranked {
+		data := offerData{
+			Supplier:    o.Supplier,
+			ReviewScore: o.ReviewScore,
+			Price:       o.Price,
+			offerStock:  newOfferStock(o.Stock),
+		}
+		if request.IncludeSubcategories {
+			data.Category = o.Category
+		}
+		response.Offers = append(response.Offers, data)
+	}
+
+	return response, nil
+}
+
 type offer struct {
 	Product  string `json:"product"`
 	Category string `json:"category"`
@@ -97,18 +177,33 @@ type offerResponse struct {
 	ImportedOffers int `json:"importedOffersCount"`
 }
 
+// model maps the request data to the database model
+func (o offerRequest) model() database.Offer {
+	return database.Offer{
+		Product:  o.Product,
+		Category: o.Category,
+		Supplier: o.Supplier,
+		Price:    o.Price,
+		Stock:    o.offerStock.model(),
+	}
+}
+
 func (s *Service) handleOffer() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		request := offerRequest{}
 		err := s.decode(w, r, &request)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusBadRequest)
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if err = request.validate(); err != nil {
+			s.respondError(w, r, err)
 			return
 		}
 
-		err = s.offers.Insert(request.Product, request.Category, request.Supplier, request.Price)
+		err = s.offersFor(r).InsertMultiple(r.Context(), []database.Offer{request.model()})
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusInternalServerError)
+			s.respondError(w, r, err)
 			return
 		}
 
@@ -124,27 +219,54 @@ func (s *Service) handleOfferBatch() http.HandlerFunc {
 		request := offerBatchRequest{}
 		err := s.decode(w, r, &request)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusBadRequest)
+			s.respondError(w, r, malformedBody(err))
 			return
 		}
 
 		// Map the request data to the database model
 		offerModels := make([]database.Offer, len(request))
 		for i, offer := range request {
-			offerModels[i] = database.Offer{
-				Product:  offer.Product,
-				Category: offer.Category,
-				Supplier: offer.Supplier,
-				Price:    offer.Price,
+			if err = offer.validate(); err != nil {
+				s.respondError(w, r, err)
+				return
 			}
+			offerModels[i] = offer.model()
 		}
 
-		err = s.offers.InsertMultiple(offerModels)
+		err = s.offersFor(r).InsertMultiple(r.Context(), offerModels)
 		if err != nil {
-			s.respond(w, r, offerErrorResponse{err.Error()}, http.StatusInternalServerError)
+			s.respondError(w, r, err)
 			return
 		}
 
 		s.respond(w, r, offerBatchResponse{len(request)}, http.StatusOK)
 	}
 }
+
+type offerWithdrawRequest struct {
+	Product  string `json:"product"`
+	Category string `json:"category"`
+	Supplier string `json:"supplier"`
+}
+type offerWithdrawResponse struct {
+	WithdrawnOffers int `json:"withdrawnOffersCount"`
+}
+
+func (s *Service) handleOfferWithdraw() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := offerWithdrawRequest{}
+		err := s.decode(w, r, &request)
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		err = s.offersFor(r).Withdraw(r.Context(), request.Product, request.Category, request.Supplier)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.respond(w, r, offerWithdrawResponse{1}, http.StatusOK)
+	}
+}
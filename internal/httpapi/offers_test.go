@@ -2,12 +2,15 @@ package httpapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/muffix/relayr-challenge/internal/database"
 )
@@ -20,31 +23,105 @@ const (
 // mockDB is a mock of the database which never errors, but returns some mock data
 type mockDB struct{}
 
-func (mock *mockDB) Insert(_, _, _ string, _ float32) error  { return nil }
-func (mock *mockDB) InsertMultiple(_ []database.Offer) error { return nil }
-func (mock *mockDB) Close() error                            { return nil }
-func (mock *mockDB) Get(_, _ string) ([]database.Offer, error) {
+func (mock *mockDB) Ping(ctx context.Context) error { return ctx.Err() }
+func (mock *mockDB) Health(ctx context.Context) (database.Health, error) {
+	return database.Health{}, ctx.Err()
+}
+func (mock *mockDB) Insert(_ context.Context, _, _, _ string, _ float32) error  { return nil }
+func (mock *mockDB) InsertMultiple(_ context.Context, _ []database.Offer) error { return nil }
+func (mock *mockDB) Withdraw(_ context.Context, _, _, _ string) error           { return nil }
+func (mock *mockDB) Close() error                                               { return nil }
+func (mock *mockDB) Get(_ context.Context, _, _ string) ([]database.Offer, error) {
 	return []database.Offer{
 		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials, just more expensive", Price: 44},
 		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
 		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 42},
 	}, nil
 }
+func (mock *mockDB) LastUpdated(_ context.Context, _, _ string) (time.Time, error) {
+	return mockUpdatedAt, nil
+}
+func (mock *mockDB) Iterate(_ context.Context, _ database.OfferFilter) (database.OfferIterator, error) {
+	offers, _ := mock.Get(context.Background(), "Towel", "Must Haves")
+	records := make([]database.OfferRecord, len(offers))
+	for i, offer := range offers {
+		records[i] = database.OfferRecord{Offer: offer, UpdatedAt: mockUpdatedAt}
+	}
+	return &sliceIterator{records: records}, nil
+}
+
+// mockUpdatedAt is the update time of all offers in the mock database
+var mockUpdatedAt = time.Date(2020, 10, 9, 18, 2, 21, 0, time.UTC)
+
+// sliceIterator is an OfferIterator over a slice of records
+type sliceIterator struct {
+	records []database.OfferRecord
+	index   int
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.index >= len(it.records) {
+		return false
+	}
+	it.index++
+	return true
+}
+func (it *sliceIterator) Record() database.OfferRecord { return it.records[it.index-1] }
+func (it *sliceIterator) Err() error                   { return nil }
+func (it *sliceIterator) Close() error                 { return nil }
 
 // mockErrorDB is a mock of the database which always errors
 type mockErrorDB struct{}
 
-func (mock *mockErrorDB) Insert(_, _, _ string, _ float32) error    { return fmt.Errorf("error") }
-func (mock *mockErrorDB) InsertMultiple(_ []database.Offer) error   { return fmt.Errorf("error") }
-func (mock *mockErrorDB) Close() error                              { return fmt.Errorf("error") }
-func (mock *mockErrorDB) Get(_, _ string) ([]database.Offer, error) { return nil, fmt.Errorf("error") }
+func (mock *mockErrorDB) Ping(_ context.Context) error { return fmt.Errorf("error") }
+func (mock *mockErrorDB) Health(_ context.Context) (database.Health, error) {
+	return database.Health{}, fmt.Errorf("error")
+}
+func (mock *mockErrorDB) Insert(_ context.Context, _, _, _ string, _ float32) error {
+	return fmt.Errorf("error")
+}
+func (mock *mockErrorDB) InsertMultiple(_ context.Context, _ []database.Offer) error {
+	return fmt.Errorf("error")
+}
+func (mock *mockErrorDB) Withdraw(_ context.Context, _, _, _ string) error {
+	return fmt.Errorf("error")
+}
+func (mock *mockErrorDB) Close() error { return fmt.Errorf("error") }
+func (mock *mockErrorDB) Get(_ context.Context, _, _ string) ([]database.Offer, error) {
+	return nil, fmt.Errorf("error")
+}
+func (mock *mockErrorDB) LastUpdated(_ context.Context, _, _ string) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("error")
+}
+func (mock *mockErrorDB) Iterate(_ context.Context, _ database.OfferFilter) (database.OfferIterator, error) {
+	return nil, fmt.Errorf("error")
+}
+
+// mockReviewer scores Hitchhiker Knockoffs 1 and every other supplier 3
+type mockReviewer struct {
+	// db resolves the IDs of suppliers to their names. Without it, suppliers are looked up by name.
+	db *database.OffersSQLiteDatabase
+}
 
-type mockReviewer struct{}
+// name returns the name of the supplier with the ID in any of the tenants of the tests
+func (m *mockReviewer) name(supplier string) string {
+	id, err := strconv.ParseInt(supplier, 10, 64)
+	if m.db == nil || err != nil {
+		return supplier
+	}
+	for _, tenant := range []string{database.DefaultTenant, "acme", "globex"} {
+		profiles := m.db.ForTenant(tenant).(database.SupplierProfiles)
+		if found, err := profiles.Supplier(context.Background(), id); err == nil {
+			return found.Name
+		}
+	}
+	return supplier
+}
 
-func (m *mockReviewer) Suppliers(supplierNames []string) (map[string]float32, error) {
+func (m *mockReviewer) Suppliers(supplierIDs []string) (map[string]float32, error) {
 	scores := make(map[string]float32)
-	for _, sup := range supplierNames {
-		if sup == "Hitchhiker Knockoffs" {
+	for _, sup := range supplierIDs {
+		if m.name(sup) == "Hitchhiker Knockoffs" {
 			scores[sup] = 1
 		} else {
 			scores[sup] = 3
@@ -86,15 +163,19 @@ func offerErrorScenario(t *testing.T, h http.HandlerFunc, requestBody string, ex
 		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, expectedStatusCode)
 	}
 
+	if contentType := resp.Header.Get("Content-Type"); contentType != problemContentType {
+		t.Fatalf("Got content type %q, want %q", contentType, problemContentType)
+	}
+
 	// decode JSON and check contents
-	got := offerErrorResponse{}
+	got := problem{}
 	err := json.NewDecoder(resp.Body).Decode(&got)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if got.Error == "" {
-		t.Fatal("Expected error message in response, got nothing")
+	if got.Code == "" || got.Title == "" || got.Status != expectedStatusCode {
+		t.Fatalf("Expected a problem with a code, a title and the status, got %+v", got)
 	}
 }
 
@@ -185,7 +266,7 @@ func TestOfferSearch(t *testing.T) {
 		offerSearchBody,
 		&offerSearchResponse{},
 		&offerSearchResponse{
-			Name:     "Towel",
+			Product:  "Towel",
 			Category: "Must Haves",
 			Offers: []offerData{
 				{
@@ -219,6 +300,20 @@ func TestOfferSearch_withInvalidBody(t *testing.T) {
 	)
 }
 
+func TestOfferSearch_withMissingFields(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+	service.SetReviewer(&mockReviewer{})
+
+	for _, body := range []string{
+		`{"product":"Towel"}`,
+		`{"category":"Must Haves"}`,
+		`{"product":"","category":"Must Haves"}`,
+	} {
+		offerErrorScenario(t, service.handleOfferSearch(), body, http.StatusBadRequest)
+	}
+}
+
 func TestOfferSearch_withDBError(t *testing.T) {
 	service := NewService(1234)
 	service.SetDatabase(&mockErrorDB{})
@@ -241,3 +336,34 @@ func TestOfferSearch_withReviewerError(t *testing.T) {
 		http.StatusBadGateway,
 	)
 }
+
+// notFoundDB is a mock of the database which doesn't know any offer to withdraw
+type notFoundDB struct{ mockDB }
+
+func (mock *notFoundDB) Withdraw(_ context.Context, _, _, _ string) error {
+	return database.ErrNotFound
+}
+
+func TestWithdrawHandler(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+	offerSuccessScenario(
+		t,
+		service.handleOfferWithdraw(),
+		offerBody,
+		&offerWithdrawResponse{},
+		&offerWithdrawResponse{WithdrawnOffers: 1},
+	)
+}
+
+func TestWithdrawHandler_withErrors(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+	offerErrorScenario(t, service.handleOfferWithdraw(), "I'm not JSON", http.StatusBadRequest)
+
+	service.SetDatabase(&notFoundDB{})
+	offerErrorScenario(t, service.handleOfferWithdraw(), offerBody, http.StatusNotFound)
+
+	service.SetDatabase(&mockErrorDB{})
+	offerErrorScenario(t, service.handleOfferWithdraw(), offerBody, http.StatusInternalServerError)
+}
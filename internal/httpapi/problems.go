@@ -0,0 +1,209 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// problemContentType is the media type of error responses as described in RFC 7807
+const problemContentType = "application/problem+json"
+
+// The codes of errors returned to clients. They're part of the API, so clients can rely on them
+// to handle errors. Never change an existing code.
+const (
+	codeMalformedBody          = "malformed_body"
+	codeBodyTooLarge           = "body_too_large"
+	codeUnsupportedEncoding    = "unsupported_content_encoding"
+	codeInvalidRequest         = "invalid_request"
+	codeOfferNotFound          = "offer_not_found"
+	codeWebhookNotFound        = "webhook_not_found"
+	codeAlertNotFound          = "alert_not_found"
+	codeAliasNotFound          = "alias_not_found"
+	codeCategoryNotFound       = "category_not_found"
+	codeCategoryExists         = "category_exists"
+	codeCategoryHasChildren    = "category_has_children"
+	codeSupplierNotFound       = "supplier_not_found"
+	codeSupplierExists         = "supplier_exists"
+	codeSupplierHasOffers      = "supplier_has_offers"
+	codeIdempotencyKeyReused   = "idempotency_key_reused"
+	codeIdempotencyKeyInFlight = "idempotency_key_in_flight"
+	codeNotConfigured          = "not_configured"
+	codeReviewsUnavailable     = "reviews_unavailable"
+	codeDatabaseUnavailable    = "database_unavailable"
+	codeTimeout                = "timeout"
+	codeReadOnly               = "read_only"
+	codeUnauthorized           = "unauthorized"
+	codeDefaultTenantOnly      = "default_tenant_only"
+	codeNotAcceptable          = "not_acceptable"
+	codeInternal               = "internal_error"
+)
+
+// problem is the body of an error response as described in RFC 7807
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// apiError is an error which is returned to clients as a problem
+//
+// Only the code, the title and the detail are shown to clients. The cause may contain internal
+// details such as SQL errors, so it's only logged.
+type apiError struct {
+	status int
+	code   string
+	title  string
+	detail string
+	cause  error
+}
+
+func (e *apiError) Error() string {
+	if e.cause == nil {
+		return e.detail
+	}
+	return e.detail + ": " + e.cause.Error()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.cause
+}
+
+// malformedBody is returned when the request body can't be decoded
+func malformedBody(cause error) *apiError {
+	return &apiError{
+		status: http.StatusBadRequest,
+		code:   codeMalformedBody,
+		title:  "Malformed request body",
+		detail: "The request body must be valid JSON of the documented schema.",
+		cause:  cause,
+	}
+}
+
+// invalidRequest is returned when the request is well-formed, but its values aren't valid. The
+// detail is shown to clients, so it must not contain internal details.
+func invalidRequest(detail string) *apiError {
+	return &apiError{
+		status: http.StatusBadRequest,
+		code:   codeInvalidRequest,
+		title:  "Invalid request",
+		detail: detail,
+	}
+}
+
+// notConfigured is returned when an optional feature the request needs isn't set up
+func notConfigured(feature string) *apiError {
+	return &apiError{
+		status: http.StatusServiceUnavailable,
+		code:   codeNotConfigured,
+		title:  "Feature not configured",
+		detail: feature + " are not configured on this server.",
+	}
+}
+
+// reviewsUnavailable is returned when the reviews engine can't be reached
+func reviewsUnavailable(cause error) *apiError {
+	return &apiError{
+		status: http.StatusBadGateway,
+		code:   codeReviewsUnavailable,
+		title:  "Reviews engine unavailable",
+		detail: "The review scores of the suppliers couldn't be retrieved.",
+		cause:  cause,
+	}
+}
+
+// classify maps errors from the database to the errors returned to clients
+func classify(err error) *apiError {
+	var apiErr *apiError
+	var tooLarge *http.MaxBytesError
+	switch {
+	// Checked first, since the error of reading a body that's too large is wrapped in malformedBody
+	case errors.As(err, &tooLarge):
+		return bodyTooLarge(tooLarge.Limit)
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, database.ErrNotFound):
+		return &apiError{http.StatusNotFound, codeOfferNotFound, "Offer not found", "No offer matches the request.", err}
+	case errors.Is(err, database.ErrWebhookNotFound):
+		return &apiError{http.StatusNotFound, codeWebhookNotFound, "Webhook not found", "No webhook has this ID.", err}
+	case errors.Is(err, database.ErrAlertNotFound):
+		return &apiError{http.StatusNotFound, codeAlertNotFound, "Alert not found", "No alert has this ID.", err}
+	case errors.Is(err, database.ErrAliasNotFound):
+		return &apiError{http.StatusNotFound, codeAliasNotFound, "Alias not found", "No product has this alias.", err}
+	case errors.Is(err, database.ErrInvalidAlias):
+		return invalidRequest("The alias must be a different, non-empty name than its product.")
+	case errors.Is(err, database.ErrCategoryNotFound):
+		return &apiError{http.StatusNotFound, codeCategoryNotFound, "Category not found", "No category has this ID.", err}
+	case errors.Is(err, database.ErrCategoryExists):
+		return &apiError{http.StatusConflict, codeCategoryExists, "Category exists", "A category with this name already exists.", err}
+	case errors.Is(err, database.ErrCategoryHasChildren):
+		return &apiError{
+			http.StatusConflict, codeCategoryHasChildren, "Category has subcategories",
+			"Only categories without subcategories can be deleted. Move or delete the subcategories first.", err,
+		}
+	case errors.Is(err, database.ErrInvalidCategoryParent):
+		return invalidRequest("The parent must be an existing category outside of the category's subtree.")
+	case errors.Is(err, database.ErrSupplierNotFound):
+		return &apiError{http.StatusNotFound, codeSupplierNotFound, "Supplier not found", "No supplier has this ID.", err}
+	case errors.Is(err, database.ErrSupplierExists):
+		return &apiError{
+			http.StatusConflict, codeSupplierExists, "Supplier exists",
+			"A supplier with this name already exists. Suppliers are added with their first offer, so change its profile instead.", err,
+		}
+	case errors.Is(err, database.ErrSupplierHasOffers):
+		return &apiError{
+			http.StatusConflict, codeSupplierHasOffers, "Supplier has offers",
+			"Only suppliers without offers can be deleted. Withdraw the offers or suspend the supplier instead.", err,
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return &apiError{
+			http.StatusServiceUnavailable, codeTimeout, "Request timed out",
+			"The request took too long or was cancelled. Try again later.", err,
+		}
+	case errors.Is(err, database.ErrClosed):
+		return &apiError{
+			http.StatusServiceUnavailable, codeDatabaseUnavailable, "Database unavailable",
+			"The database is unavailable. Try again later.", err,
+		}
+	default:
+		return &apiError{
+			http.StatusInternalServerError, codeInternal, "Internal error",
+			"The request couldn't be processed. Report the request ID if the problem persists.", err,
+		}
+	}
+}
+
+// respondError responds with the problem describing the error
+//
+// The causes of server errors are logged together with the request ID, which clients can report.
+func (s *Service) respondError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := classify(err)
+	id := requestID(r.Context())
+
+	if apiErr.status >= http.StatusInternalServerError && apiErr.cause != nil {
+		log.Printf("Request %s: %s %s failed with %s: %v", id, r.Method, r.URL.Path, apiErr.code, apiErr.cause)
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(apiErr.status)
+	err = json.NewEncoder(w).Encode(problem{
+		Type:      "/problems/" + apiErr.code,
+		Title:     apiErr.title,
+		Status:    apiErr.status,
+		Detail:    apiErr.detail,
+		Instance:  r.URL.Path,
+		Code:      apiErr.code,
+		RequestID: id,
+	})
+	if err != nil {
+		log.Printf("Request %s: error writing problem: %v", id, err)
+	}
+}
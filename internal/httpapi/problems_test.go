@@ -0,0 +1,142 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/pkg/errors"
+)
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"offer not found", errors.Wrap(database.ErrNotFound, "withdrawing"), http.StatusNotFound, codeOfferNotFound},
+		{"webhook not found", database.ErrWebhookNotFound, http.StatusNotFound, codeWebhookNotFound},
+		{"alert not found", database.ErrAlertNotFound, http.StatusNotFound, codeAlertNotFound},
+		{"database closed", fmt.Errorf("inserting: %w", database.ErrClosed), http.StatusServiceUnavailable, codeDatabaseUnavailable},
+		{"timeout", errors.Wrap(context.DeadlineExceeded, "querying"), http.StatusServiceUnavailable, codeTimeout},
+		{"API error", invalidRequest("nope"), http.StatusBadRequest, codeInvalidRequest},
+		{"reviews engine", reviewsUnavailable(errors.New("timeout")), http.StatusBadGateway, codeReviewsUnavailable},
+		{"anything else", errors.New("no such table: offers"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, testCase := range testCases {
+		got := classify(testCase.err)
+		if got.status != testCase.wantStatus || got.code != testCase.wantCode {
+			t.Fatalf("%s: got status %d and code %q, want %d and %q",
+				testCase.name, got.status, got.code, testCase.wantStatus, testCase.wantCode)
+		}
+	}
+}
+
+func TestRespondError_hidesInternalDetails(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&mockErrorDB{})
+
+	req := httptest.NewRequest("POST", "http://testsite.local/api/v1/offer", strings.NewReader(offerBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, "my-request")
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+	resp := w.Result()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+	if id := resp.Header.Get(requestIDHeader); id != "my-request" {
+		t.Fatalf("Got request ID header %q, want %q", id, "my-request")
+	}
+
+	got := problem{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := problem{
+		Type:      "/problems/internal_error",
+		Title:     "Internal error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "The request couldn't be processed. Report the request ID if the problem persists.",
+		Instance:  "/api/v1/offer",
+		Code:      codeInternal,
+		RequestID: "my-request",
+	}
+	if got != want {
+		t.Fatalf("Got problem %+v, want %+v", got, want)
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	service := NewService(1234)
+
+	testCases := []struct {
+		name, header string
+		wantKept     bool
+	}{
+		{"no ID", "", false},
+		{"valid ID", "abc-123", true},
+		{"ID with spaces", "abc 123", false},
+		{"too long ID", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, testCase := range testCases {
+		req := httptest.NewRequest("GET", "http://testsite.local/version", nil)
+		if testCase.header != "" {
+			req.Header.Set(requestIDHeader, testCase.header)
+		}
+		w := httptest.NewRecorder()
+		service.router.ServeHTTP(w, req)
+
+		id := w.Result().Header.Get(requestIDHeader)
+		if id == "" || (id == testCase.header) != testCase.wantKept {
+			t.Fatalf("%s: got request ID %q for header %q", testCase.name, id, testCase.header)
+		}
+	}
+}
+
+// blockingDB blocks reads until their context is done
+type blockingDB struct {
+	mockDB
+}
+
+func (mock *blockingDB) Get(ctx context.Context, _, _ string) ([]database.Offer, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+func (mock *blockingDB) LastUpdated(ctx context.Context, _, _ string) (time.Time, error) {
+	<-ctx.Done()
+	return time.Time{}, ctx.Err()
+}
+
+func TestWithRequestTimeout(t *testing.T) {
+	service := NewService(1234)
+	service.SetDatabase(&blockingDB{})
+	service.SetReviewer(&mockReviewer{})
+	service.server.WriteTimeout = 10 * time.Millisecond
+
+	req := httptest.NewRequest("GET", offerQueryURL, nil)
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	got := problem{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != codeTimeout {
+		t.Fatalf("Got code %q, want %q", got.Code, codeTimeout)
+	}
+}
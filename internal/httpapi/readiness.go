@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/etherlabsio/healthcheck"
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+const (
+	// readinessTimeout is the maximum time of all readiness checks together
+	readinessTimeout = 5 * time.Second
+
+	checkStatusOK     = "OK"
+	checkStatusFailed = "failed"
+)
+
+// ReadinessConfig configures the readiness checks
+type ReadinessConfig struct {
+	// CacheTTL is how long the result of a check is reused, so that frequent probes don't put load on
+	// the dependencies
+	CacheTTL time.Duration
+	// MinFreeDiskSpace is the number of bytes which must be free on the volume of the database
+	MinFreeDiskSpace uint64
+}
+
+// DefaultReadinessConfig returns the configuration used unless another one is set
+func DefaultReadinessConfig() ReadinessConfig {
+	return ReadinessConfig{
+		CacheTTL:         5 * time.Second,
+		MinFreeDiskSpace: 100 << 20,
+	}
+}
+
+// SetReadinessConfig sets the configuration of the readiness checks. Cached results are discarded.
+func (s *Service) SetReadinessConfig(config ReadinessConfig) {
+	s.readinessConfig = config
+	s.readinessCache = newCheckCache(config.CacheTTL)
+}
+
+// checkResult is the outcome of a readiness check
+type checkResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// readinessCheck is a named check of a dependency
+type readinessCheck struct {
+	name    string
+	checker healthcheck.Checker
+}
+
+// readinessChecks returns the checks of the dependencies which have been set
+func (s *Service) readinessChecks() []readinessCheck {
+	var checks []readinessCheck
+	if s.reviewer != nil {
+		checks = append(checks, readinessCheck{"reviews", &reviewsChecker{s.reviewer}})
+	}
+	if prober, ok := s.offers.(database.ReadinessProber); ok {
+		checks = append(checks,
+			readinessCheck{"migrations", &migrationsChecker{prober}},
+			readinessCheck{"diskSpace", &diskSpaceChecker{prober, s.readinessConfig.MinFreeDiskSpace}},
+			readinessCheck{"writeProbe", &writeProbeChecker{prober}},
+			readinessCheck{"quickCheck", &quickCheckChecker{prober}},
+		)
+	}
+	return checks
+}
+
+// checkCache keeps the latest result of every check
+//
+// Only one instance of a check runs at a time. Concurrent probes wait for it and share its result.
+type checkCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedCheck
+}
+
+type cachedCheck struct {
+	// lock is held while the check runs. It's a channel, so that waiting for it can be cancelled.
+	lock   chan struct{}
+	result checkResult
+}
+
+func newCheckCache(ttl time.Duration) *checkCache {
+	return &checkCache{ttl: ttl, entries: map[string]*cachedCheck{}}
+}
+
+func (c *checkCache) entry(name string) *cachedCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[name]
+	if !ok {
+		e = &cachedCheck{lock: make(chan struct{}, 1)}
+		c.entries[name] = e
+	}
+	return e
+}
+
+// run returns the cached result of the check, or runs it if the result has expired
+func (c *checkCache) run(ctx context.Context, check readinessCheck) checkResult {
+	e := c.entry(check.name)
+
+	select {
+	case e.lock <- struct{}{}:
+	case <-ctx.Done():
+		return checkResult{Status: checkStatusFailed, Error: "timed out waiting for a running check", CheckedAt: time.Now()}
+	}
+	defer func() { <-e.lock }()
+
+	if !e.result.CheckedAt.IsZero() && time.Since(e.result.CheckedAt) < c.ttl {
+		return e.result
+	}
+
+	start := time.Now()
+	err := check.checker.Check(ctx)
+	e.result = checkResult{
+		Status:    checkStatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		e.result.Status, e.result.Error = checkStatusFailed, err.Error()
+	}
+	return e.result
+}
@@ -0,0 +1,116 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// defaultMaxBodySize is the maximum size of request bodies unless a route has its own limit
+	defaultMaxBodySize = 1 << 20
+	// defaultMaxBatchBodySize is the maximum size of bodies of batch imports
+	defaultMaxBatchBodySize = 32 << 20
+)
+
+// bodyLimits are the maximum sizes of request bodies by the path templates of the routes. The
+// empty path holds the default of all other routes.
+type bodyLimits map[string]int64
+
+func defaultBodyLimits() bodyLimits {
+	return bodyLimits{
+		"":                    defaultMaxBodySize,
+		"/api/v1/offer/batch": defaultMaxBatchBodySize,
+	}
+}
+
+// forRoute returns the limit of the route matching the request
+func (l bodyLimits) forRoute(r *http.Request) int64 {
+	if limit, ok := l[routePath(r)]; ok {
+		return limit
+	}
+	return l[""]
+}
+
+// SetMaxBodySize sets the maximum size of request bodies of the route with the path, e.g.
+// /api/v1/offer/batch. The empty path sets the default of all other routes.
+//
+// The limit applies to decompressed bodies, so that small compressed bodies can't exhaust memory.
+func (s *Service) SetMaxBodySize(path string, limit int64) {
+	s.bodyLimits[path] = limit
+}
+
+// bodyTooLarge is returned when the request body exceeds the limit of the route
+func bodyTooLarge(limit int64) *apiError {
+	return &apiError{
+		status: http.StatusRequestEntityTooLarge,
+		code:   codeBodyTooLarge,
+		title:  "Request body too large",
+		detail: fmt.Sprintf("The request body must not be larger than %d bytes.", limit),
+	}
+}
+
+// unsupportedEncoding is returned when the request body is compressed in an unknown format
+func unsupportedEncoding() *apiError {
+	return &apiError{
+		status: http.StatusUnsupportedMediaType,
+		code:   codeUnsupportedEncoding,
+		title:  "Unsupported content encoding",
+		detail: "Request bodies must be uncompressed or compressed with gzip or deflate.",
+	}
+}
+
+// readCloser reads from a decompressor and closes the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// readRequestBody is a middleware which decompresses request bodies and limits their size
+//
+// Bodies with a Content-Encoding of gzip or deflate are decompressed. Reading more than the limit
+// of the route fails with an *http.MaxBytesError, which is returned to clients as 413.
+func (s *Service) readRequestBody(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.bodyLimits.forRoute(r)
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+
+		var body io.Reader
+		var err error
+		switch encoding {
+		case "", "identity":
+			// Don't read bodies which are known to be too large
+			if r.ContentLength > limit {
+				s.respondError(w, r, bodyTooLarge(limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			h.ServeHTTP(w, r)
+			return
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(r.Body)
+		case "deflate":
+			// HTTP's deflate is the zlib format (RFC 9110)
+			body, err = zlib.NewReader(r.Body)
+		default:
+			s.respondError(w, r, unsupportedEncoding())
+			return
+		}
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		// The headers now describe the decompressed body
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		r.GetBody = nil
+		r.Body = http.MaxBytesReader(w, readCloser{body, r.Body}, limit)
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compress compresses the body with the content encoding
+func compress(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	if _, err := io.WriteString(w, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveEncoded sends a request with a body in the content encoding through the router
+func serveEncoded(service *Service, url, encoding string, body []byte) *http.Response {
+	req := httptest.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestCompressedRequestBodies(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		resp := serveEncoded(service, "http://testsite.local/api/v1/offer", encoding, compress(t, encoding, offerBody))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Got bad status code %d for %s, want %d", resp.StatusCode, encoding, http.StatusOK)
+		}
+	}
+
+	offers, err := service.offers.Get(context.Background(), "Towel", "Must Haves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) != 1 {
+		t.Fatalf("Got %d offers, want 1", len(offers))
+	}
+}
+
+func TestRequestBodyErrors(t *testing.T) {
+	service := newValidatingTestService(t)
+	service.SetMaxBodySize("", 64)
+	service.SetMaxBodySize("/api/v1/offer/batch", 256)
+
+	batch := "[" + strings.Repeat(offerBody+",", 2) + offerBody + "]"
+
+	testCases := []struct {
+		name       string
+		url        string
+		encoding   string
+		body       []byte
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			"too large", "http://testsite.local/api/v1/offer", "",
+			[]byte(offerBody), http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+		},
+		{
+			"too large after decompression", "http://testsite.local/api/v1/offer", "gzip",
+			compress(t, "gzip", offerBody), http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+		},
+		{
+			"route limit", "http://testsite.local/api/v1/offer/batch", "deflate",
+			compress(t, "deflate", batch), http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+		},
+		{
+			"unsupported encoding", "http://testsite.local/api/v1/offer", "br",
+			[]byte(offerBody), http.StatusUnsupportedMediaType, codeUnsupportedEncoding,
+		},
+		{
+			"corrupt gzip", "http://testsite.local/api/v1/offer", "gzip",
+			[]byte(offerBody), http.StatusBadRequest, codeMalformedBody,
+		},
+	}
+
+	for _, tc := range testCases {
+		resp := serveEncoded(service, tc.url, tc.encoding, tc.body)
+		if resp.StatusCode != tc.wantStatus {
+			t.Fatalf("%s: got status code %d, want %d", tc.name, resp.StatusCode, tc.wantStatus)
+		}
+
+		got := problem{}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != tc.wantCode {
+			t.Fatalf("%s: got code %q, want %q", tc.name, got.Code, tc.wantCode)
+		}
+	}
+
+	// Bodies within the limit of the route are accepted
+	service.SetMaxBodySize("/api/v1/offer/batch", 1024)
+	resp := serveEncoded(service, "http://testsite.local/api/v1/offer/batch", "gzip", compress(t, "gzip", batch))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -1,23 +1,126 @@
 package httpapi
 
+import "net/http/pprof"
+
 // routes is the function where routes and their handlers are added. It is meant to be used as the
 // one place for all the routes to make it easy to see what's happening.
 func (s *Service) routes() {
+	s.router.Use(s.withRequestID, s.withTenant, s.logRequest, s.withRequestTimeout, s.compressResponse, s.readRequestBody, s.validateOpenAPI)
+
 	// These are the three default routes that we must keep
 	s.router.HandleFunc("/version", s.handleVersion())
 	s.router.HandleFunc("/liveness", s.handleLiveness())
 	s.router.HandleFunc("/readiness", s.handleReadiness())
 
 	// New routes go here
-	s.router.HandleFunc("/", s.logRequest(s.handleHomePage()))
+	s.router.HandleFunc("/", s.handleHomePage())
 
 	s.router.HandleFunc("/api/v1/offer/search", s.handleOfferSearch()).
 		Headers("Content-Type", "application/json").
 		Methods("POST")
-	s.router.HandleFunc("/api/v1/offer", s.handleOffer()).
+	s.router.HandleFunc("/api/v1/offer", s.writable(s.idempotent(s.handleOffer()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/offer/batch", s.writable(s.idempotent(s.handleOfferBatch()))).
 		Headers("Content-Type", "application/json").
 		Methods("POST")
-	s.router.HandleFunc("/api/v1/offer/batch", s.handleOfferBatch()).
+	s.router.HandleFunc("/api/v1/offer/withdraw", s.writable(s.idempotent(s.handleOfferWithdraw()))).
 		Headers("Content-Type", "application/json").
 		Methods("POST")
+	s.router.HandleFunc("/api/v1/offer/stock", s.writable(s.idempotent(s.handleOfferStock()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/offers", s.handleOfferQuery()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/offers/export", s.handleOfferExport()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/offers/stream", s.handleOfferStream()).
+		Methods("GET")
+
+	s.router.HandleFunc("/api/v1/webhooks", s.writable(s.idempotent(s.handleWebhookCreate()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/webhooks", s.handleWebhookList()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/webhooks/{id:[0-9]+}", s.handleWebhookGet()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/webhooks/{id:[0-9]+}", s.writable(s.handleWebhookDelete())).
+		Methods("DELETE")
+	s.router.HandleFunc("/api/v1/webhooks/{id:[0-9]+}/deliveries", s.handleWebhookDeliveries()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/alerts", s.writable(s.idempotent(s.handleAlertCreate()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/alerts", s.handleAlertList()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/alerts/{id:[0-9]+}", s.handleAlertGet()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/alerts/{id:[0-9]+}", s.writable(s.idempotent(s.handleAlertUpdate()))).
+		Headers("Content-Type", "application/json").
+		Methods("PUT")
+	s.router.HandleFunc("/api/v1/alerts/{id:[0-9]+}", s.writable(s.handleAlertDelete())).
+		Methods("DELETE")
+	s.router.HandleFunc("/api/v1/categories", s.writable(s.idempotent(s.handleCategoryCreate()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/categories", s.handleCategoryList()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/categories/{id:[0-9]+}", s.handleCategoryGet()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/categories/{id:[0-9]+}", s.writable(s.idempotent(s.handleCategoryMove()))).
+		Headers("Content-Type", "application/json").
+		Methods("PUT")
+	s.router.HandleFunc("/api/v1/categories/{id:[0-9]+}", s.writable(s.handleCategoryDelete())).
+		Methods("DELETE")
+	s.router.HandleFunc("/api/v1/suppliers", s.writable(s.idempotent(s.handleSupplierCreate()))).
+		Headers("Content-Type", "application/json").
+		Methods("POST")
+	s.router.HandleFunc("/api/v1/suppliers", s.handleSupplierList()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/suppliers/{id:[0-9]+}", s.handleSupplierGet()).
+		Methods("GET")
+	s.router.HandleFunc("/api/v1/suppliers/{id:[0-9]+}", s.writable(s.idempotent(s.handleSupplierUpdate()))).
+		Headers("Content-Type", "application/json").
+		Methods("PUT")
+	s.router.HandleFunc("/api/v1/suppliers/{id:[0-9]+}", s.writable(s.handleSupplierDelete())).
+		Methods("DELETE")
+	s.router.HandleFunc("/graphql", s.handleGraphQL()).
+		Methods("GET", "POST")
+}
+
+// adminRoutes adds the routes of the admin listener. They aren't exposed through the ingress.
+func (s *Service) adminRoutes() {
+	s.adminRouter.Use(s.withRequestID, s.logRequest)
+
+	s.adminRouter.HandleFunc("/admin/backup", s.handleBackup()).
+		Methods("POST")
+	s.adminRouter.HandleFunc("/admin/webhooks/dead-letters", s.handleDeadLetters()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/admin/products/aliases", s.handleProductAliases()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/admin/products/aliases", s.handleAddProductAlias()).
+		Methods("POST")
+	s.adminRouter.HandleFunc("/admin/products/aliases", s.handleDeleteProductAlias()).
+		Methods("DELETE")
+	s.adminRouter.HandleFunc("/admin/products/alias-suggestions", s.handleProductAliasSuggestions()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/admin/reviews/cache/purge", s.handleReviewCachePurge()).
+		Methods("POST")
+	s.adminRouter.HandleFunc("/admin/read-only", s.handleReadOnly()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/admin/read-only", s.handleSetReadOnly()).
+		Methods("PUT")
+	s.adminRouter.HandleFunc("/admin/log-level", s.handleLogLevel()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/admin/log-level", s.handleSetLogLevel()).
+		Methods("PUT")
+
+	s.adminRouter.HandleFunc("/debug/vars", s.handleVars()).
+		Methods("GET")
+	s.adminRouter.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.adminRouter.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.adminRouter.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.adminRouter.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// The index also serves the named profiles, e.g. /debug/pprof/heap
+	s.adminRouter.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
 }
@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchCacheControl allows shared caches to keep search results for a minute. After that, they
+// have to revalidate with the ETag or modification time.
+const searchCacheControl = "public, max-age=60"
+
+// handleOfferQuery returns an http.HandlerFunc for searching offers with query parameters
+//
+// It has the same semantics as handleOfferSearch, but responses can be cached and revalidated by
+// clients, CDNs and browsers, and links can be shared.
+func (s *Service) handleOfferQuery() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		request := offerSearchRequest{
+			ProductName: query.Get("product"),
+			Category:    query.Get("category"),
+		}
+		if request.ProductName == "" || request.Category == "" {
+			s.respondError(w, r, invalidRequest("the query parameters product and category are required"))
+			return
+		}
+
+		if value := query.Get("includeSubcategories"); value != "" {
+			include, err := strconv.ParseBool(value)
+			if err != nil {
+				s.respondError(w, r, invalidRequest("includeSubcategories must be true or false"))
+				return
+			}
+			request.IncludeSubcategories = include
+		}
+		if value := query.Get("includeUnavailable"); value != "" {
+			include, err := strconv.ParseBool(value)
+			if err != nil {
+				s.respondError(w, r, invalidRequest("includeUnavailable must be true or false"))
+				return
+			}
+			request.IncludeUnavailable = include
+		}
+		if value := query.Get("maxLeadTimeDays"); value != "" {
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 0 {
+				s.respondError(w, r, invalidRequest("maxLeadTimeDays must be a non-negative integer"))
+				return
+			}
+			request.MaxLeadTimeDays = &days
+		}
+
+		categories, err := s.searchCategories(r, request)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		// Read the update time before the offers. If an offer changes in between, the validators
+		// are older than the response and the next request fetches the new data.
+		db := s.offersFor(r)
+		var lastUpdated time.Time
+		for _, category := range categories {
+			updated, err := db.LastUpdated(r.Context(), request.ProductName, category)
+			if err != nil {
+				s.respondError(w, r, err)
+				return
+			}
+			if updated.After(lastUpdated) {
+				lastUpdated = updated
+			}
+		}
+
+		offers, err := s.getOffers(r, request, categories)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		crumbs, err := s.breadcrumbs(r, request.Category)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		// The number of offers is part of the ETag, so that withdrawn offers change it, too.
+		// It's weak since the review scores may change without a change to the offers.
+		etag := fmt.Sprintf(`W/"%x-%x%s"`, lastUpdated.UnixNano(), len(offers), categoryTag(categories, crumbs))
+
+		w.Header().Set("Cache-Control", searchCacheControl)
+		w.Header().Set("ETag", etag)
+		addVary(w.Header(), "Accept")
+		if !lastUpdated.IsZero() {
+			w.Header().Set("Last-Modified", lastUpdated.Format(http.TimeFormat))
+		}
+
+		if notModified(r, etag, lastUpdated) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		response, err := s.searchResponse(r, request, offers)
+		if err != nil {
+			s.respondError(w, r, reviewsUnavailable(err))
+			return
+		}
+		response.Breadcrumbs = crumbs
+
+		s.respond(w, r, response, http.StatusOK)
+	}
+}
+
+// notModified evaluates the conditional request headers as described in RFC 7232
+//
+// If-None-Match takes precedence over If-Modified-Since. ETags are compared weakly.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		// HTTP dates only have a resolution of seconds
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const offerQueryURL = "http://testsite.local/api/v1/offers?product=Towel&category=Must+Haves"
+
+// queryOffers sends a GET search request with the given headers to the handler
+func queryOffers(service *Service, url string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest("GET", url, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	service.handleOfferQuery()(w, req)
+	return w.Result()
+}
+
+func newSearchTestService() *Service {
+	service := NewService(1234)
+	service.SetDatabase(&mockDB{})
+	service.SetReviewer(&mockReviewer{})
+	return service
+}
+
+func TestOfferQuery(t *testing.T) {
+	service := newSearchTestService()
+	resp := queryOffers(service, offerQueryURL, nil)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	got := offerSearchResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same request to the POST endpoint returns the same response
+	w, req := prepareTestRequest(offerSearchBody)
+	service.handleOfferSearch()(w, req)
+	want := offerSearchResponse{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got response %v, want %v", got, want)
+	}
+
+	if resp.Header.Get("ETag") == "" || resp.Header.Get("Cache-Control") == "" {
+		t.Fatalf("Expected caching headers, got %v", resp.Header)
+	}
+
+	if got := resp.Header.Get("Last-Modified"); got != mockUpdatedAt.Format(http.TimeFormat) {
+		t.Fatalf("Got Last-Modified %q, want the time of the last update", got)
+	}
+}
+
+func TestOfferQuery_conditionalRequests(t *testing.T) {
+	service := newSearchTestService()
+	etag := queryOffers(service, offerQueryURL, nil).Header.Get("ETag")
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"one of several ETags", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"different ETag", map[string]string{"If-None-Match": `W/"other"`}, http.StatusOK},
+		{
+			"not modified since",
+			map[string]string{"If-Modified-Since": mockUpdatedAt.Format(http.TimeFormat)},
+			http.StatusNotModified,
+		},
+		{
+			"modified since",
+			map[string]string{"If-Modified-Since": mockUpdatedAt.Add(-time.Minute).Format(http.TimeFormat)},
+			http.StatusOK,
+		},
+		{
+			"ETag takes precedence",
+			map[string]string{
+				"If-None-Match":     `W/"other"`,
+				"If-Modified-Since": mockUpdatedAt.Format(http.TimeFormat),
+			},
+			http.StatusOK,
+		},
+	}
+
+	for _, testCase := range testCases {
+		resp := queryOffers(service, offerQueryURL, testCase.headers)
+		if resp.StatusCode != testCase.want {
+			t.Fatalf("%s: got status code %d, want %d", testCase.name, resp.StatusCode, testCase.want)
+		}
+		if resp.Header.Get("ETag") != etag {
+			t.Fatalf("%s: expected the ETag to be sent, got %q", testCase.name, resp.Header.Get("ETag"))
+		}
+	}
+}
+
+func TestOfferQuery_withMissingParameters(t *testing.T) {
+	service := newSearchTestService()
+	resp := queryOffers(service, "http://testsite.local/api/v1/offers?product=Towel", nil)
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestOfferQuery_withErrors(t *testing.T) {
+	service := newSearchTestService()
+	service.SetDatabase(&mockErrorDB{})
+	if resp := queryOffers(service, offerQueryURL, nil); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+
+	service = newSearchTestService()
+	service.SetReviewer(&mockErrorReviewer{})
+	if resp := queryOffers(service, offerQueryURL, nil); resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
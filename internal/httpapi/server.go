@@ -1,16 +1,25 @@
 package httpapi
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/muffix/relayr-challenge/internal/alerts"
+	"github.com/muffix/relayr-challenge/internal/backup"
+	"github.com/muffix/relayr-challenge/internal/changes"
 	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/graphqlapi"
 	"github.com/muffix/relayr-challenge/internal/review"
 )
 
@@ -25,8 +34,43 @@ type Service struct {
 	server *http.Server
 	router *mux.Router
 
+	// redirectServer redirects plain HTTP requests to HTTPS if TLS is enabled
+	redirectServer *http.Server
+
+	// adminServer serves the admin routes on their own port, which isn't exposed through the
+	// ingress. It's only started if a port has been set.
+	adminServer *http.Server
+	adminRouter *mux.Router
+
+	readOnly readOnlyMode
+	logLevel slog.LevelVar
+
 	offers   database.Offers
 	reviewer review.Reviewer
+
+	tenantsMu     sync.RWMutex
+	tenants       tenants
+	tenantMetrics *tenantMetrics
+
+	backups  *backup.Manager
+	webhooks database.Webhooks
+
+	alerts         database.Alerts
+	alertEvaluator *alerts.Evaluator
+
+	graphQL *graphqlapi.Executor
+	openAPI *openAPIValidator
+
+	idempotencyKeys *idempotencyStore
+
+	bodyLimits         bodyLimits
+	compressionMinSize int
+
+	changes         *changes.Log
+	streamHeartbeat time.Duration
+
+	readinessConfig ReadinessConfig
+	readinessCache  *checkCache
 }
 
 // NewService returns a new service struct.
@@ -39,16 +83,40 @@ func NewService(servicePort int) *Service {
 	service := &Service{
 		server: createServerWithRouter(router, servicePort),
 		router: router,
+
+		adminRouter: mux.NewRouter(),
+
+		tenants:       defaultTenants(),
+		tenantMetrics: newTenantMetrics(),
+
+		idempotencyKeys: newIdempotencyStore(DefaultIdempotencyRetention),
+
+		bodyLimits:         defaultBodyLimits(),
+		compressionMinSize: defaultCompressionMinSize,
+
+		changes:         changes.NewLog(changeLogCapacity),
+		streamHeartbeat: defaultStreamHeartbeat,
+
+		readinessConfig: DefaultReadinessConfig(),
+		readinessCache:  newCheckCache(DefaultReadinessConfig().CacheTTL),
 	}
 
 	service.routes()
+	service.adminRoutes()
 
 	return service
 }
 
 // SetDatabase is a setter for the database
+//
+// If the database reports changes to offers, they're published to the offer stream and counted
+// for the metrics of their tenants.
 func (s *Service) SetDatabase(db database.Offers) {
 	s.offers = db
+	if notifier, ok := db.(database.ChangeNotifier); ok {
+		notifier.AddChangeListener(s.changes.Publish)
+		notifier.AddChangeListener(s.tenantMetrics.countChanges)
+	}
 }
 
 // SetReviewer is a setter for a client of a reviews engine
@@ -56,6 +124,46 @@ func (s *Service) SetReviewer(r review.Reviewer) {
 	s.reviewer = r
 }
 
+// SetBackupManager is a setter for the manager taking backups of the database
+func (s *Service) SetBackupManager(m *backup.Manager) {
+	s.backups = m
+}
+
+// SetWebhookStore is a setter for the store of webhook subscriptions and their deliveries
+func (s *Service) SetWebhookStore(w database.Webhooks) {
+	s.webhooks = w
+}
+
+// SetAlerts is a setter for the store of price alerts and the evaluator which checks them
+//
+// The evaluator may be nil. Otherwise, new and changed alerts are evaluated right away.
+func (s *Service) SetAlerts(store database.Alerts, evaluator *alerts.Evaluator) {
+	s.alerts = store
+	s.alertEvaluator = evaluator
+}
+
+// SetGraphQLExecutor is a setter for the executor of GraphQL queries
+func (s *Service) SetGraphQLExecutor(e *graphqlapi.Executor) {
+	s.graphQL = e
+}
+
+// SetIdempotencyRetention sets how long responses to requests with an idempotency key are kept
+func (s *Service) SetIdempotencyRetention(retention time.Duration) {
+	s.idempotencyKeys.setRetention(retention)
+}
+
+// SetAdminPort makes the service serve the admin routes, such as pprof and the read-only mode, on
+// the port. It mustn't be exposed through the ingress.
+func (s *Service) SetAdminPort(port int) {
+	s.adminServer = &http.Server{
+		Addr:        ":" + strconv.Itoa(port),
+		Handler:     s.adminRouter,
+		ReadTimeout: 30 * time.Second,
+		// CPU profiles and traces take as long as they're requested for
+		WriteTimeout: 0,
+	}
+}
+
 func createServerWithRouter(router http.Handler, port int) *http.Server {
 	return &http.Server{
 		Addr:         ":" + strconv.Itoa(port),
@@ -66,14 +174,37 @@ func createServerWithRouter(router http.Handler, port int) *http.Server {
 }
 
 // Start starts the HTTP service
+//
+// It serves HTTPS if TLS has been set up.
 func (s *Service) Start() {
 	go func() {
 		log.Printf("Serving on port %s", s.server.Addr)
-		err := s.server.ListenAndServe()
+		lis, err := net.Listen("tcp", s.server.Addr)
+		if err == nil {
+			err = s.serve(lis)
+		}
 		if err != nil {
 			log.Fatalf("Error from router %s", err.Error())
 		}
 	}()
+	if s.redirectServer != nil {
+		go func() {
+			log.Printf("Redirecting to HTTPS on port %s", s.redirectServer.Addr)
+			err := s.redirectServer.ListenAndServe()
+			if err != nil {
+				log.Fatalf("Error from HTTPS redirect %s", err.Error())
+			}
+		}()
+	}
+	if s.adminServer != nil {
+		go func() {
+			log.Printf("Serving admin routes on port %s", s.adminServer.Addr)
+			err := s.adminServer.ListenAndServe()
+			if err != nil {
+				log.Fatalf("Error from admin router %s", err.Error())
+			}
+		}()
+	}
 	defer s.close()
 
 	// Handle interrupts
@@ -82,23 +213,55 @@ func (s *Service) Start() {
 	<-c
 }
 
+// serve serves requests on the listener, using TLS if it's configured
+func (s *Service) serve(lis net.Listener) error {
+	if s.server.TLSConfig != nil {
+		// The certificate is taken from the TLS config
+		return s.server.ServeTLS(lis, "", "")
+	}
+	return s.server.Serve(lis)
+}
+
 func (s *Service) close() {
 	_ = s.server.Shutdown(nil)
+	if s.redirectServer != nil {
+		_ = s.redirectServer.Shutdown(nil)
+	}
+	if s.adminServer != nil {
+		_ = s.adminServer.Shutdown(nil)
+	}
 }
 
 // respond is a helper function to create a response for an encodable struct. It sets the content
 // type and response code.
-func (s *Service) respond(w http.ResponseWriter, _ *http.Request, data interface{}, status int) {
+//
+// The format is negotiated with the Accept header. If the data can't be represented in any of the
+// accepted formats, the response is 406.
+func (s *Service) respond(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
 	if data == nil {
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+	addVary(w.Header(), "Accept")
 
-	err := json.NewEncoder(w).Encode(data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var body bytes.Buffer
+	for _, enc := range negotiate(r.Header.Get("Accept")) {
+		body.Reset()
+		err := enc.encode(&body, data)
+		if errors.Is(err, errNotRepresentable) {
+			continue
+		}
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", enc.contentType)
+		w.WriteHeader(status)
+		w.Write(body.Bytes())
+		return
 	}
+
+	s.respondError(w, r, notAcceptable())
 }
 
 // decode is a helper function that decodes request data into a struct
@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+)
+
+// offerStock is the optional stock of an offer in requests and responses
+type offerStock struct {
+	Quantity     *int   `json:"quantity,omitempty" xml:"quantity,omitempty"`
+	Availability string `json:"availability,omitempty" xml:"availability,omitempty"`
+	LeadTimeDays *int   `json:"leadTimeDays,omitempty" xml:"leadTimeDays,omitempty"`
+}
+
+// newOfferStock returns the stock of an offer for responses. The availability is derived from the
+// quantity if the supplier doesn't report it.
+func newOfferStock(stock database.Stock) offerStock {
+	return offerStock{
+		Quantity:     stock.Quantity,
+		Availability: string(stock.Status()),
+		LeadTimeDays: stock.LeadTimeDays,
+	}
+}
+
+// validate returns an error describing the first invalid field, or nil if the stock is valid
+func (s offerStock) validate() error {
+	if !database.Availability(s.Availability).Valid() {
+		return invalidRequest("availability must be in_stock, backorder or out_of_stock")
+	}
+	if s.Quantity != nil && *s.Quantity < 0 {
+		return invalidRequest("quantity must not be negative")
+	}
+	if s.LeadTimeDays != nil && *s.LeadTimeDays < 0 {
+		return invalidRequest("leadTimeDays must not be negative")
+	}
+	return nil
+}
+
+func (s offerStock) model() database.Stock {
+	return database.Stock{
+		Quantity:     s.Quantity,
+		Availability: database.Availability(s.Availability),
+		LeadTimeDays: s.LeadTimeDays,
+	}
+}
+
+// nullable is a field of a request which tells a missing value apart from null
+type nullable[T any] struct {
+	// set is whether the field is in the request
+	set   bool
+	value *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(data []byte) error {
+	n.set = true
+	if string(data) == "null" {
+		n.value = nil
+		return nil
+	}
+	n.value = new(T)
+	return json.Unmarshal(data, n.value)
+}
+
+// cleared returns whether the field is null in the request
+func (n nullable[T]) cleared() bool {
+	return n.set && n.value == nil
+}
+
+// stockUpdateRequest is the body of requests changing the stock of an offer. Only the fields which
+// are in the request are changed, and null clears them.
+type stockUpdateRequest struct {
+	Product      string           `json:"product"`
+	Category     string           `json:"category"`
+	Supplier     string           `json:"supplier"`
+	Quantity     nullable[int]    `json:"quantity"`
+	Availability nullable[string] `json:"availability"`
+	LeadTimeDays nullable[int]    `json:"leadTimeDays"`
+}
+
+// stock returns the values of the fields which are set
+func (r stockUpdateRequest) stock() offerStock {
+	stock := offerStock{Quantity: r.Quantity.value, LeadTimeDays: r.LeadTimeDays.value}
+	if r.Availability.value != nil {
+		stock.Availability = *r.Availability.value
+	}
+	return stock
+}
+
+// update returns the update of the stock described by the request
+func (r stockUpdateRequest) update() database.StockUpdate {
+	update := database.StockUpdate{Stock: r.stock().model()}
+	if r.Quantity.cleared() {
+		update.Clear = append(update.Clear, database.QuantityField)
+	}
+	if r.Availability.cleared() {
+		update.Clear = append(update.Clear, database.AvailabilityField)
+	}
+	if r.LeadTimeDays.cleared() {
+		update.Clear = append(update.Clear, database.LeadTimeDaysField)
+	}
+	return update
+}
+
+type stockUpdateResponse struct {
+	Product  string  `json:"product"`
+	Category string  `json:"category"`
+	Supplier string  `json:"supplier"`
+	Price    float32 `json:"price"`
+	offerStock
+}
+
+// deliverable returns the offers which match the availability filters of the search. Offers which
+// are out of stock are left out unless they're included, and offers without a lead time are kept
+// when filtering by lead time.
+func deliverable(offers []database.Offer, request offerSearchRequest) []database.Offer {
+	if !request.IncludeUnavailable {
+		offers = ranking.Available(offers)
+	}
+	if request.MaxLeadTimeDays == nil {
+		return offers
+	}
+
+	shown := make([]database.Offer, 0, len(offers))
+	for _, offer := range offers {
+		if offer.LeadTimeDays == nil || *offer.LeadTimeDays <= *request.MaxLeadTimeDays {
+			shown = append(shown, offer)
+		}
+	}
+	return shown
+}
+
+// handleOfferStock returns an http.HandlerFunc which changes the stock of an offer without its
+// price
+func (s *Service) handleOfferStock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updater, ok := s.offersFor(r).(database.StockUpdater)
+		if !ok {
+			s.respondError(w, r, notConfigured("Stock updates"))
+			return
+		}
+
+		request := stockUpdateRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.Product == "" || request.Category == "" || request.Supplier == "" {
+			s.respondError(w, r, invalidRequest("product, category and supplier are required"))
+			return
+		}
+		if err := request.stock().validate(); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		offer, err := updater.UpdateStock(r.Context(), request.Product, request.Category, request.Supplier, request.update())
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.respond(w, r, stockUpdateResponse{
+			Product:    offer.Product,
+			Category:   offer.Category,
+			Supplier:   offer.Supplier,
+			Price:      offer.Price,
+			offerStock: newOfferStock(offer.Stock),
+		}, http.StatusOK)
+	}
+}
@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOfferSearch_availability(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer/batch", `[
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42,"quantity":3},
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Knockoffs","price":40,"quantity":0},
+		{"product":"Towel","category":"Must Haves","supplier":"Megadodo","price":44,"availability":"backorder","leadTimeDays":14}
+	]`)
+
+	testCases := []struct {
+		name      string
+		url       string
+		suppliers []string
+	}{
+		{"available offers", offerQueryURL, []string{"Hitchhiker Essentials", "Megadodo"}},
+		{"unavailable offers", offerQueryURL + "&includeUnavailable=true", []string{"Hitchhiker Knockoffs", "Hitchhiker Essentials", "Megadodo"}},
+		{"lead time", offerQueryURL + "&maxLeadTimeDays=7", []string{"Hitchhiker Essentials"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := decodeSearch(t, serve(service, "GET", testCase.url, ""))
+			if len(got.Offers) != len(testCase.suppliers) {
+				t.Fatalf("Got offers %+v, want offers of %v", got.Offers, testCase.suppliers)
+			}
+			for i, offer := range got.Offers {
+				if offer.Supplier != testCase.suppliers[i] {
+					t.Fatalf("Got offers %+v, want offers of %v", got.Offers, testCase.suppliers)
+				}
+			}
+		})
+	}
+
+	got := decodeSearch(t, serve(service, "POST", "http://testsite.local/api/v1/offer/search",
+		`{"product":"Towel","category":"Must Haves","includeUnavailable":true}`))
+	if len(got.Offers) != 3 || got.Offers[0].Availability != "out_of_stock" || got.Offers[2].Availability != "backorder" {
+		t.Fatalf("Got offers %+v, want all offers with their availability", got.Offers)
+	}
+}
+
+func TestOfferStockHandler(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+	etag := serve(service, "GET", offerQueryURL, "").Header.Get("ETag")
+
+	resp := serve(service, "POST", "http://testsite.local/api/v1/offer/stock",
+		`{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","quantity":0,"leadTimeDays":2}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	updated := stockUpdateResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Price != 42 || updated.Availability != "out_of_stock" || *updated.LeadTimeDays != 2 {
+		t.Fatalf("Got offer %+v, want the offer out of stock at its price", updated)
+	}
+
+	// The offer is hidden now, which changes the validators of the search
+	resp = queryOffers(service, offerQueryURL, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d after the stock update, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := decodeSearch(t, resp); len(got.Offers) != 0 {
+		t.Fatalf("Got offers %+v, want none in stock", got.Offers)
+	}
+
+	// Updating the price keeps the stock
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+	got := decodeSearch(t, serve(service, "GET", offerQueryURL+"&includeUnavailable=true", ""))
+	if len(got.Offers) != 1 || got.Offers[0].Quantity == nil || *got.Offers[0].Quantity != 0 {
+		t.Fatalf("Got offers %+v, want the offer with its quantity", got.Offers)
+	}
+}
+
+func TestOfferStockHandler_transitions(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+
+	steps := []struct {
+		name         string
+		body         string
+		availability string
+		leadTimeDays bool
+	}{
+		{"reported out of stock", `"availability":"out_of_stock","leadTimeDays":3`, "out_of_stock", true},
+		{"restocked", `"quantity":10`, "in_stock", true},
+		{"sold out", `"quantity":0`, "out_of_stock", true},
+		{"lead time cleared", `"leadTimeDays":null`, "out_of_stock", false},
+		{"stock cleared", `"quantity":null`, "", false},
+	}
+
+	for _, step := range steps {
+		resp := serve(service, "POST", "http://testsite.local/api/v1/offer/stock",
+			`{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials",`+step.body+`}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got bad status code %d, want %d", step.name, resp.StatusCode, http.StatusOK)
+		}
+		updated := stockUpdateResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+			t.Fatal(err)
+		}
+		if updated.Availability != step.availability || (updated.LeadTimeDays != nil) != step.leadTimeDays {
+			t.Fatalf("%s: got offer %+v, want availability %q", step.name, updated, step.availability)
+		}
+	}
+
+	// Offers without a reported stock are shown
+	if got := decodeSearch(t, serve(service, "GET", offerQueryURL, "")); len(got.Offers) != 1 {
+		t.Fatalf("Got offers %+v, want the offer without a stock", got.Offers)
+	}
+}
+
+func TestOfferStockHandler_invalid(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+
+	testCases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"missing supplier", `{"product":"Towel","category":"Must Haves","quantity":1}`, http.StatusBadRequest},
+		{"negative quantity", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","quantity":-1}`, http.StatusBadRequest},
+		{"unknown availability", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","availability":"soon"}`, http.StatusBadRequest},
+		{"missing offer", `{"product":"Guide","category":"Must Haves","supplier":"Hitchhiker Essentials","quantity":1}`, http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			resp := serve(service, "POST", "http://testsite.local/api/v1/offer/stock", testCase.body)
+			if resp.StatusCode != testCase.status {
+				t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, testCase.status)
+			}
+		})
+	}
+
+	// Databases which can't update the stock can't be used
+	resp := serve(newSearchTestService(), "POST", "http://testsite.local/api/v1/offer/stock",
+		`{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","quantity":1}`)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d without stock updates, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
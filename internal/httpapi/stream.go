@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/changes"
+)
+
+const (
+	// changeLogCapacity is the number of changes kept for clients resuming a stream
+	changeLogCapacity = 1000
+	// streamBufferSize is the number of changes buffered per client. Clients which fall further
+	// behind are disconnected and can resume with the Last-Event-ID header.
+	streamBufferSize = 64
+	// defaultStreamHeartbeat is how often an idle stream sends a comment to keep the connection open
+	defaultStreamHeartbeat = 15 * time.Second
+	// streamRetry is the reconnection delay in milliseconds suggested to clients
+	streamRetry = 3000
+)
+
+// offerChangeEvent is the data of an event in the stream of offer changes
+type offerChangeEvent struct {
+	Type          string    `json:"type"`
+	Product       string    `json:"product"`
+	Category      string    `json:"category"`
+	Supplier      string    `json:"supplier"`
+	Price         float32   `json:"price"`
+	PreviousPrice float32   `json:"previousPrice,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// handleOfferStream returns an http.HandlerFunc which streams changes to offers as Server-Sent Events
+//
+// The optional query parameters product and category filter the changes. The product may be an
+// alias. Clients that reconnect
+// with the Last-Event-ID header receive the changes they missed, as long as they are still in the
+// change log. Otherwise, they receive a reset event and should search for the offers again.
+func (s *Service) handleOfferStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		product, err := s.resolveProduct(r, query.Get("product"))
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		filter := changes.Filter{
+			Tenant:   tenantOf(r.Context()).ID,
+			Product:  product,
+			Category: query.Get("category"),
+		}
+
+		var lastID uint64
+		if header := r.Header.Get("Last-Event-ID"); header != "" {
+			lastID, err = strconv.ParseUint(header, 10, 64)
+			if err != nil {
+				s.respondError(w, r, invalidRequest("invalid Last-Event-ID header"))
+				return
+			}
+		}
+
+		backlog, sub, complete := s.changes.Subscribe(lastID, streamBufferSize)
+		defer sub.Close()
+
+		// Streams are long-lived, so they mustn't be cut off by the server's write timeout
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Stop reverse proxies such as nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+		if !complete {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+
+		// sent is the ID of the last event the client knows about. skipped is the ID of the last
+		// event that didn't match the filter. The client is told about skipped events with the
+		// heartbeats, so that it doesn't fall behind the change log while nothing matches.
+		sent, skipped := lastID, lastID
+		send := func(event changes.Event) error {
+			if !filter.Matches(event) {
+				skipped = event.ID
+				return nil
+			}
+			sent = event.ID
+			return writeChangeEvent(w, event)
+		}
+
+		for _, event := range backlog {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(s.streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if skipped > sent {
+					sent = skipped
+					fmt.Fprintf(w, "id: %d\n", sent)
+				}
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case event, ok := <-sub.Events():
+				if !ok {
+					// The client didn't keep up. Closing the connection makes it reconnect and
+					// resume from the change log.
+					return
+				}
+				if err := send(event); err != nil {
+					return
+				}
+			}
+
+			if rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeChangeEvent writes the change as a Server-Sent Event
+func writeChangeEvent(w io.Writer, event changes.Event) error {
+	data, err := json.Marshal(offerChangeEvent{
+		Type:          string(event.Type),
+		Product:       event.Offer.Product,
+		Category:      event.Offer.Category,
+		Supplier:      event.Offer.Supplier,
+		Price:         event.Offer.Price,
+		PreviousPrice: event.PreviousPrice,
+		Time:          event.Time.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
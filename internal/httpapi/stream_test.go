@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// sseEvent is an event read from a stream
+type sseEvent struct {
+	id, event, data string
+	comment         bool
+}
+
+// openStream connects to the stream handler and returns a channel with the events it sends
+//
+// The stream is closed at the end of the test.
+func openStream(t *testing.T, service *Service, query, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	server := httptest.NewServer(service.handleOfferStream())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/offers/stream"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Got content type %q, want text/event-stream", got)
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		event := sseEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				events <- event
+				event = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				event.comment = true
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+
+	// The first message only sets the reconnection delay
+	nextEvent(t, events)
+
+	return events
+}
+
+// nextEvent returns the next event from the stream or fails the test after a timeout
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Stream was closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	return sseEvent{}
+}
+
+func towelChange(changeType database.ChangeType, supplier string, price float32) database.Change {
+	return database.Change{
+		Type:   changeType,
+		Tenant: database.DefaultTenant,
+		Offer:  database.Offer{Product: "Towel", Category: "Must Haves", Supplier: supplier, Price: price},
+		Time:   mockUpdatedAt,
+	}
+}
+
+func TestOfferStream(t *testing.T) {
+	service := NewService(1234)
+	events := openStream(t, service, "?product=Towel&category=Must+Haves", "")
+
+	service.changes.Publish([]database.Change{
+		towelChange(database.OfferInserted, "Hitchhiker Essentials", 42),
+		{
+			Type:   database.OfferInserted,
+			Tenant: database.DefaultTenant,
+			Offer:  database.Offer{Product: "Guide", Category: "Must Haves", Supplier: "Megadodo", Price: 30},
+			Time:   mockUpdatedAt,
+		},
+		towelChange(database.OfferWithdrawn, "Hitchhiker Knockoffs", 40),
+	})
+
+	first := nextEvent(t, events)
+	if first.id != "1" || first.event != "inserted" {
+		t.Fatalf("Got event %+v, want the insert with ID 1", first)
+	}
+
+	got := offerChangeEvent{}
+	if err := json.Unmarshal([]byte(first.data), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := offerChangeEvent{
+		Type:     "inserted",
+		Product:  "Towel",
+		Category: "Must Haves",
+		Supplier: "Hitchhiker Essentials",
+		Price:    42,
+		Time:     mockUpdatedAt,
+	}
+	if got != want {
+		t.Fatalf("Got event data %+v, want %+v", got, want)
+	}
+
+	// The offer for another product is filtered out
+	if second := nextEvent(t, events); second.id != "3" || second.event != "withdrawn" {
+		t.Fatalf("Got event %+v, want the withdrawal with ID 3", second)
+	}
+}
+
+func TestOfferStream_withAlias(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	if _, _, err := db.AddProductAlias(context.Background(), "Bath Towel", "Towel"); err != nil {
+		t.Fatal(err)
+	}
+	events := openStream(t, service, "?product=bath+towel", "")
+
+	if err := db.Insert(context.Background(), "Bath Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	event := nextEvent(t, events)
+	got := offerChangeEvent{}
+	if err := json.Unmarshal([]byte(event.data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if event.event != "inserted" || got.Product != "Towel" {
+		t.Fatalf("Got event %+v, want the insert of the towel", event)
+	}
+}
+
+func TestOfferStream_resumesFromLastEventID(t *testing.T) {
+	service := NewService(1234)
+	service.changes.Publish([]database.Change{
+		towelChange(database.OfferInserted, "Hitchhiker Essentials", 42),
+		towelChange(database.OfferUpdated, "Hitchhiker Essentials", 41),
+	})
+
+	events := openStream(t, service, "", "1")
+	if event := nextEvent(t, events); event.id != "2" || event.event != "updated" {
+		t.Fatalf("Got event %+v, want the missed update with ID 2", event)
+	}
+
+	service.changes.Publish([]database.Change{towelChange(database.OfferWithdrawn, "Hitchhiker Essentials", 41)})
+	if event := nextEvent(t, events); event.id != "3" {
+		t.Fatalf("Got event %+v, want the new event with ID 3", event)
+	}
+}
+
+func TestOfferStream_resetsWhenChangesWereDropped(t *testing.T) {
+	service := NewService(1234)
+
+	// Event IDs from before a restart are unknown
+	events := openStream(t, service, "", "42")
+	if event := nextEvent(t, events); event.event != "reset" {
+		t.Fatalf("Got event %+v, want a reset", event)
+	}
+}
+
+func TestOfferStream_sendsHeartbeats(t *testing.T) {
+	service := NewService(1234)
+	service.streamHeartbeat = 10 * time.Millisecond
+	events := openStream(t, service, "?product=Towel", "")
+
+	// A change that doesn't match the filter moves the client's position with the next heartbeat
+	service.changes.Publish([]database.Change{{Type: database.OfferInserted, Tenant: database.DefaultTenant, Offer: database.Offer{Product: "Guide"}}})
+
+	for {
+		event := nextEvent(t, events)
+		if !event.comment {
+			t.Fatalf("Got event %+v, want a heartbeat", event)
+		}
+		if event.id == "1" {
+			return
+		}
+	}
+}
+
+func TestOfferStream_withInvalidLastEventID(t *testing.T) {
+	service := NewService(1234)
+	req := httptest.NewRequest("GET", "http://testsite.local/api/v1/offers/stream", nil)
+	req.Header.Set("Last-Event-ID", "not a number")
+	w := httptest.NewRecorder()
+	service.handleOfferStream()(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Got bad status code %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// supplierRequest is the body of requests adding a supplier
+type supplierRequest struct {
+	// Name is the name of the supplier in its offers
+	Name string `json:"name"`
+	supplierUpdateRequest
+}
+
+// supplierUpdateRequest is the body of requests changing a supplier. It replaces everything but the
+// name of the supplier.
+type supplierUpdateRequest struct {
+	DisplayName string            `json:"displayName"`
+	Email       string            `json:"email"`
+	Phone       string            `json:"phone"`
+	Status      string            `json:"status"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+type supplierResponse struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"displayName"`
+	Email       string            `json:"email,omitempty"`
+	Phone       string            `json:"phone,omitempty"`
+	Status      string            `json:"status"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+func newSupplierResponse(supplier database.Supplier) supplierResponse {
+	return supplierResponse{
+		ID:          supplier.ID,
+		Name:        supplier.Name,
+		DisplayName: supplier.DisplayName,
+		Email:       supplier.Email,
+		Phone:       supplier.Phone,
+		Status:      string(supplier.Status),
+		Metadata:    supplier.Metadata,
+		CreatedAt:   supplier.CreatedAt,
+		UpdatedAt:   supplier.UpdatedAt,
+	}
+}
+
+// validate returns an error describing the first invalid field, or nil if the request is valid
+func (r supplierUpdateRequest) validate() error {
+	if r.Status != "" && !database.SupplierStatus(r.Status).Valid() {
+		return invalidRequest("status must be active or suspended")
+	}
+	if r.Email != "" {
+		if _, err := mail.ParseAddress(r.Email); err != nil {
+			return invalidRequest("email must be a valid email address")
+		}
+	}
+	return nil
+}
+
+// supplier returns the supplier described by the request
+func (r supplierUpdateRequest) supplier() database.Supplier {
+	return database.Supplier{
+		DisplayName: r.DisplayName,
+		Email:       r.Email,
+		Phone:       r.Phone,
+		Status:      database.SupplierStatus(r.Status),
+		Metadata:    r.Metadata,
+	}
+}
+
+// withSuppliers responds with 503 if the database doesn't keep supplier profiles
+func (s *Service) withSuppliers(h func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profiles, ok := s.offersFor(r).(database.SupplierProfiles)
+		if !ok {
+			s.respondError(w, r, notConfigured("Suppliers"))
+			return
+		}
+		h(w, r, profiles)
+	}
+}
+
+// handleSupplierCreate returns an http.HandlerFunc which adds the profile of a supplier
+func (s *Service) handleSupplierCreate() http.HandlerFunc {
+	return s.withSuppliers(func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles) {
+		request := supplierRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if request.Name == "" {
+			s.respondError(w, r, invalidRequest("a name is required"))
+			return
+		}
+		if err := request.validate(); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		supplier := request.supplier()
+		supplier.Name = request.Name
+		supplier, err := profiles.AddSupplier(r.Context(), supplier)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newSupplierResponse(supplier), http.StatusCreated)
+	})
+}
+
+// handleSupplierList returns an http.HandlerFunc which lists all suppliers
+func (s *Service) handleSupplierList() http.HandlerFunc {
+	return s.withSuppliers(func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles) {
+		suppliers, err := profiles.ListSuppliers(r.Context())
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]supplierResponse, len(suppliers))
+		for i, supplier := range suppliers {
+			response[i] = newSupplierResponse(supplier)
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// handleSupplierGet returns an http.HandlerFunc which describes one supplier
+func (s *Service) handleSupplierGet() http.HandlerFunc {
+	return s.withSuppliers(func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		supplier, err := profiles.Supplier(r.Context(), id)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newSupplierResponse(supplier), http.StatusOK)
+	})
+}
+
+// handleSupplierUpdate returns an http.HandlerFunc which replaces the profile of a supplier
+//
+// The name can't be changed, since offers refer to the supplier by name. Suspending a supplier hides
+// its offers from searches.
+func (s *Service) handleSupplierUpdate() http.HandlerFunc {
+	return s.withSuppliers(func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		request := supplierUpdateRequest{}
+		if err := s.decode(w, r, &request); err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+		if err := request.validate(); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		supplier := request.supplier()
+		supplier.ID = id
+		supplier, err := profiles.UpdateSupplier(r.Context(), supplier)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newSupplierResponse(supplier), http.StatusOK)
+	})
+}
+
+// handleSupplierDelete returns an http.HandlerFunc which deletes the profile of a supplier without
+// offers
+func (s *Service) handleSupplierDelete() http.HandlerFunc {
+	return s.withSuppliers(func(w http.ResponseWriter, r *http.Request, profiles database.SupplierProfiles) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := profiles.DeleteSupplier(r.Context(), id); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// decodeSupplier decodes the supplier in a response with the given status code
+func decodeSupplier(t *testing.T, resp *http.Response, status int) supplierResponse {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, status)
+	}
+	supplier := supplierResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&supplier); err != nil {
+		t.Fatal(err)
+	}
+	return supplier
+}
+
+func TestSupplierHandlers(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+
+	added := decodeSupplier(t, serve(service, "POST", "http://testsite.local/api/v1/suppliers",
+		`{"name":"Megadodo","email":"orders@megadodo.example","metadata":{"region":"EU"}}`), http.StatusCreated)
+	if added.DisplayName != "Megadodo" || added.Status != "active" || added.Metadata["region"] != "EU" {
+		t.Fatalf("Got supplier %+v, want an active supplier named Megadodo", added)
+	}
+
+	resp := serve(service, "GET", "http://testsite.local/api/v1/suppliers", "")
+	var list []supplierResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil || len(list) != 2 {
+		t.Fatalf("Got suppliers %+v and error %v, want the supplier of the offer and Megadodo", list, err)
+	}
+	if list[0].Name != "Hitchhiker Essentials" {
+		t.Fatalf("Got suppliers %+v, want Hitchhiker Essentials to have a profile", list)
+	}
+
+	updated := decodeSupplier(t, serve(service, "PUT", "http://testsite.local/api/v1/suppliers/2",
+		`{"displayName":"Megadodo Publications"}`), http.StatusOK)
+	if updated.Name != "Megadodo" || updated.DisplayName != "Megadodo Publications" || updated.Email != "" {
+		t.Fatalf("Got supplier %+v, want everything but the name replaced", updated)
+	}
+	if got := decodeSupplier(t, serve(service, "GET", "http://testsite.local/api/v1/suppliers/2", ""), http.StatusOK); got.DisplayName != updated.DisplayName {
+		t.Fatalf("Got supplier %+v, want %+v", got, updated)
+	}
+
+	if resp = serve(service, "DELETE", "http://testsite.local/api/v1/suppliers/2", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		if resp = serve(service, method, "http://testsite.local/api/v1/suppliers/2", ""); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: got bad status code %d, want %d", method, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestSupplierHandlers_invalid(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+
+	testCases := []struct {
+		method, url, body string
+		status            int
+	}{
+		{"POST", "/api/v1/suppliers", `{"name":""}`, http.StatusBadRequest},
+		{"POST", "/api/v1/suppliers", `{"name":"Megadodo","status":"closed"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/suppliers", `{"name":"Megadodo","email":"megadodo"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/suppliers", `{"name":"Hitchhiker Essentials"}`, http.StatusConflict},
+		{"PUT", "/api/v1/suppliers/1", `{"status":"closed"}`, http.StatusBadRequest},
+		{"PUT", "/api/v1/suppliers/42", `{}`, http.StatusNotFound},
+		{"DELETE", "/api/v1/suppliers/1", "", http.StatusConflict},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != testCase.status {
+			t.Fatalf("%s %s: got status code %d, want %d", testCase.method, testCase.url, resp.StatusCode, testCase.status)
+		}
+	}
+
+	// Databases without supplier profiles can't be used
+	resp := serve(newSearchTestService(), "GET", "http://testsite.local/api/v1/suppliers", "")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d without supplier profiles, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestOfferSearch_suspendedSuppliers(t *testing.T) {
+	service := newValidatingTestService(t)
+	serve(service, "POST", "http://testsite.local/api/v1/offer/batch", `[
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42},
+		{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Knockoffs","price":40}
+	]`)
+	serve(service, "PUT", "http://testsite.local/api/v1/suppliers/2", `{"status":"suspended"}`)
+
+	for _, resp := range []*http.Response{
+		serve(service, "GET", offerQueryURL, ""),
+		serve(service, "POST", "http://testsite.local/api/v1/offer/search", offerSearchBody),
+	} {
+		got := decodeSearch(t, resp)
+		if len(got.Offers) != 1 || got.Offers[0].Supplier != "Hitchhiker Essentials" {
+			t.Fatalf("Got offers %+v, want only the offer of the active supplier", got.Offers)
+		}
+	}
+
+	// Reactivated suppliers are shown again
+	serve(service, "PUT", "http://testsite.local/api/v1/suppliers/2", `{}`)
+	if got := decodeSearch(t, serve(service, "GET", offerQueryURL, "")); len(got.Offers) != 2 {
+		t.Fatalf("Got offers %+v, want the offers of both suppliers", got.Offers)
+	}
+
+	// Suspending another supplier changes the validators, although as many offers are shown
+	serve(service, "PUT", "http://testsite.local/api/v1/suppliers/2", `{"status":"suspended"}`)
+	etag := serve(service, "GET", offerQueryURL, "").Header.Get("ETag")
+	serve(service, "PUT", "http://testsite.local/api/v1/suppliers/2", `{}`)
+	serve(service, "PUT", "http://testsite.local/api/v1/suppliers/1", `{"status":"suspended"}`)
+
+	resp := queryOffers(service, offerQueryURL, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d after the suspension, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := decodeSearch(t, resp); len(got.Offers) != 1 || got.Offers[0].Supplier != "Hitchhiker Knockoffs" {
+		t.Fatalf("Got offers %+v, want only the offer of the active supplier", got.Offers)
+	}
+}
@@ -0,0 +1,330 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+	"github.com/pkg/errors"
+)
+
+const (
+	// apiKeyHeader is the header with the API key of a tenant. It takes precedence over the tenant
+	// header.
+	apiKeyHeader = "X-API-Key"
+	// tenantHeader is the header with the ID of a tenant which doesn't require an API key
+	tenantHeader = "X-Tenant-ID"
+)
+
+// untenantedRoutes are the paths of the routes which don't serve the data of a tenant. They're
+// served without resolving the tenant, so that probes don't need an API key.
+var untenantedRoutes = map[string]bool{
+	"/":          true,
+	"/version":   true,
+	"/liveness":  true,
+	"/readiness": true,
+}
+
+// defaultTenantRoutes are the prefixes of the paths of the routes which are only available to the
+// default tenant
+var defaultTenantRoutes = []string{"/api/v1/webhooks", "/api/v1/alerts"}
+
+// Tenant configures a storefront with its own catalog of offers
+type Tenant struct {
+	ID string `json:"id"`
+	// APIKeys authenticate requests of the tenant. If the tenant has none, it's selected with the
+	// X-Tenant-ID header instead.
+	APIKeys []string `json:"apiKeys,omitempty"`
+	// Currency is the ISO 4217 code of the currency of the tenant's prices, returned with searches
+	Currency string `json:"currency,omitempty"`
+	// Ranking is the order of the offers returned by searches. It defaults to ranking.ByPrice.
+	Ranking ranking.Strategy `json:"ranking,omitempty"`
+}
+
+// tenants is the configuration of the tenants, indexed for looking them up by ID and API key
+type tenants struct {
+	byID     map[string]Tenant
+	byAPIKey map[string]Tenant
+}
+
+// defaultTenants returns the configuration which serves every request as the default tenant
+func defaultTenants() tenants {
+	return tenants{
+		byID:     map[string]Tenant{database.DefaultTenant: {ID: database.DefaultTenant}},
+		byAPIKey: map[string]Tenant{},
+	}
+}
+
+// LoadTenants reads the configuration of the tenants from a JSON file with a list of tenants
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading tenants")
+	}
+
+	var config []Tenant
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "error decoding tenants")
+	}
+	return config, nil
+}
+
+// SetTenants configures the tenants whose offers are served. The default tenant is always
+// served. It can be configured like the others, e.g. to require an API key.
+//
+// Tenants are resolved from the X-API-Key header, or the X-Tenant-ID header for tenants without
+// API keys. Requests without either are served as the default tenant.
+func (s *Service) SetTenants(config []Tenant) error {
+	t := defaultTenants()
+	for _, tenant := range config {
+		if tenant.ID == "" {
+			return errors.New("tenants must have an ID")
+		}
+		if _, ok := t.byID[tenant.ID]; ok && tenant.ID != database.DefaultTenant {
+			return fmt.Errorf("tenant %s is configured twice", tenant.ID)
+		}
+		if !tenant.Ranking.Valid() {
+			return fmt.Errorf("tenant %s has the unknown ranking %q", tenant.ID, tenant.Ranking)
+		}
+		if tenant.Currency != "" && !validCurrency(tenant.Currency) {
+			return fmt.Errorf("tenant %s has the invalid currency %q", tenant.ID, tenant.Currency)
+		}
+		for _, key := range tenant.APIKeys {
+			if key == "" {
+				return fmt.Errorf("tenant %s has an empty API key", tenant.ID)
+			}
+			if _, ok := t.byAPIKey[key]; ok {
+				return fmt.Errorf("an API key of tenant %s is already used", tenant.ID)
+			}
+			t.byAPIKey[key] = tenant
+		}
+		t.byID[tenant.ID] = tenant
+	}
+
+	s.tenantsMu.Lock()
+	defer s.tenantsMu.Unlock()
+	s.tenants = t
+	return nil
+}
+
+// validCurrency checks that the currency looks like an ISO 4217 code
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// configured returns whether tenants other than the default one or API keys are configured
+func (t tenants) configured() bool {
+	return len(t.byID) > 1 || len(t.byAPIKey) > 0
+}
+
+// resolve returns the tenant of the request, or false if the request doesn't identify a
+// configured tenant it's allowed to access
+func (t tenants) resolve(r *http.Request) (Tenant, bool) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		tenant, ok := t.byAPIKey[key]
+		return tenant, ok
+	}
+
+	id := r.Header.Get(tenantHeader)
+	if id == "" {
+		id = database.DefaultTenant
+	}
+	tenant, ok := t.byID[id]
+	if !ok || len(tenant.APIKeys) > 0 {
+		return Tenant{}, false
+	}
+	return tenant, true
+}
+
+// unauthorized is returned when the request doesn't identify a tenant it may access
+func unauthorized() *apiError {
+	return &apiError{
+		status: http.StatusUnauthorized,
+		code:   codeUnauthorized,
+		title:  "Unauthorized",
+		detail: "The API key or tenant is missing or invalid.",
+	}
+}
+
+// defaultTenantOnly is returned when another tenant uses a feature of the default tenant
+func defaultTenantOnly() *apiError {
+	return &apiError{
+		status: http.StatusForbidden,
+		code:   codeDefaultTenantOnly,
+		title:  "Not available to the tenant",
+		detail: "Webhooks and alerts are only available to the default tenant.",
+	}
+}
+
+type tenantKey struct{}
+
+// withTenant is a middleware which adds the tenant of the request to its context
+//
+// Requests which don't identify a tenant they may access are rejected with 401 before they reach
+// a handler, so handlers only ever see the offers of the tenant in the context.
+func (s *Service) withTenant(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := routePath(r)
+		if untenantedRoutes[path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		s.tenantsMu.RLock()
+		tenant, ok := s.tenants.resolve(r)
+		configured := s.tenants.configured()
+		s.tenantsMu.RUnlock()
+
+		// Responses differ between tenants, so shared caches have to keep them apart
+		if configured {
+			addVary(w.Header(), apiKeyHeader)
+			addVary(w.Header(), tenantHeader)
+		}
+		if !ok {
+			s.respondError(w, r, unauthorized())
+			return
+		}
+
+		if tenant.ID != database.DefaultTenant {
+			if _, scoped := s.offers.(database.TenantScoper); !scoped {
+				s.respondError(w, r, notConfigured("Tenants"))
+				return
+			}
+			for _, prefix := range defaultTenantRoutes {
+				if strings.HasPrefix(path, prefix) {
+					s.respondError(w, r, defaultTenantOnly())
+					return
+				}
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), tenantKey{}, tenant)))
+		s.tenantMetrics.countResponse(tenant.ID, recorder.status)
+	})
+}
+
+// tenantOf returns the tenant of the request, or the default tenant if it has none
+func tenantOf(ctx context.Context) Tenant {
+	if tenant, ok := ctx.Value(tenantKey{}).(Tenant); ok {
+		return tenant
+	}
+	return Tenant{ID: database.DefaultTenant}
+}
+
+// offersFor returns the offers of the request's tenant
+//
+// withTenant only lets requests of other tenants than the default one through if the database
+// keeps tenants apart, so the unscoped database is only returned for the default tenant.
+func (s *Service) offersFor(r *http.Request) database.Offers {
+	tenant := tenantOf(r.Context())
+	if scoper, ok := s.offers.(database.TenantScoper); ok {
+		return scoper.ForTenant(tenant.ID)
+	}
+	return s.offers
+}
+
+// statusRecorder records the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streams
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// tenantStats are the statistics of a tenant published with the expvar variables
+type tenantStats struct {
+	Requests uint64 `json:"requests"`
+	// Responses counts the responses by the class of their status code, e.g. 2xx
+	Responses    map[string]uint64 `json:"responses"`
+	OfferChanges uint64            `json:"offerChanges"`
+}
+
+// tenantMetrics counts the requests and changes to offers of every tenant
+type tenantMetrics struct {
+	mu       sync.Mutex
+	byTenant map[string]*tenantStats
+}
+
+func newTenantMetrics() *tenantMetrics {
+	return &tenantMetrics{byTenant: make(map[string]*tenantStats)}
+}
+
+// get returns the statistics of the tenant. The caller must hold the lock.
+func (m *tenantMetrics) get(tenant string) *tenantStats {
+	stats, ok := m.byTenant[tenant]
+	if !ok {
+		stats = &tenantStats{Responses: make(map[string]uint64)}
+		m.byTenant[tenant] = stats
+	}
+	return stats
+}
+
+func (m *tenantMetrics) countResponse(tenant string, status int) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.get(tenant)
+	stats.Requests++
+	stats.Responses[fmt.Sprintf("%dxx", status/100)]++
+}
+
+// countChanges counts the changes to offers by tenant. Its signature matches
+// database.ChangeListener.
+func (m *tenantMetrics) countChanges(changes []database.Change) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, change := range changes {
+		m.get(change.Tenant).OfferChanges++
+	}
+}
+
+// snapshot returns a copy of the statistics of all tenants
+func (m *tenantMetrics) snapshot() map[string]tenantStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]tenantStats, len(m.byTenant))
+	for tenant, stats := range m.byTenant {
+		responses := make(map[string]uint64, len(stats.Responses))
+		for class, count := range stats.Responses {
+			responses[class] = count
+		}
+		result[tenant] = tenantStats{stats.Requests, responses, stats.OfferChanges}
+	}
+	return result
+}
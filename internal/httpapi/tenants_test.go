@@ -0,0 +1,256 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/ranking"
+)
+
+// serveAs sends a request through the router with the given headers
+func serveAs(service *Service, method, url, body string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func newTenantTestService(t *testing.T) *Service {
+	service := newValidatingTestService(t)
+	err := service.SetTenants([]Tenant{
+		{ID: "acme", APIKeys: []string{"acme-key"}, Currency: "EUR", Ranking: ranking.ByReviewScore},
+		{ID: "globex", Currency: "USD"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func decodeSearch(t *testing.T, resp *http.Response) offerSearchResponse {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	got := offerSearchResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestTenants_isolation(t *testing.T) {
+	service := newTenantTestService(t)
+	acme := map[string]string{apiKeyHeader: "acme-key"}
+	globex := map[string]string{tenantHeader: "globex"}
+
+	serve(service, "POST", "http://testsite.local/api/v1/offer", offerBody)
+	serveAs(service, "POST", "http://testsite.local/api/v1/offer", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":50}`, acme)
+	serveAs(service, "POST", "http://testsite.local/api/v1/offer", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Knockoffs","price":45}`, acme)
+
+	testCases := []struct {
+		headers   map[string]string
+		suppliers []string
+		prices    []float32
+	}{
+		{nil, []string{"Hitchhiker Essentials"}, []float32{42}},
+		// acme ranks by review score
+		{acme, []string{"Hitchhiker Essentials", "Hitchhiker Knockoffs"}, []float32{50, 45}},
+		{globex, nil, nil},
+	}
+
+	for _, testCase := range testCases {
+		got := decodeSearch(t, serveAs(service, "GET", offerQueryURL, "", testCase.headers))
+		if len(got.Offers) != len(testCase.suppliers) {
+			t.Fatalf("%v: got offers %+v, want %v", testCase.headers, got.Offers, testCase.suppliers)
+		}
+		for i, offer := range got.Offers {
+			if offer.Supplier != testCase.suppliers[i] || offer.Price != testCase.prices[i] {
+				t.Fatalf("%v: got offers %+v, want %v for %v", testCase.headers, got.Offers, testCase.suppliers, testCase.prices)
+			}
+		}
+	}
+
+	// Offers of other tenants can't be withdrawn
+	resp := serveAs(service, "POST", "http://testsite.local/api/v1/offer/withdraw",
+		`{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Knockoffs"}`, globex)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d withdrawing an offer of another tenant, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	resp = serveAs(service, "GET", "http://testsite.local/api/v1/offers/export?format=csv", "", globex)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "Towel") {
+		t.Fatalf("Got export %q, want none of the offers of other tenants", body)
+	}
+}
+
+func TestTenants_currency(t *testing.T) {
+	service := newTenantTestService(t)
+
+	got := decodeSearch(t, serveAs(service, "GET", offerQueryURL, "", map[string]string{apiKeyHeader: "acme-key"}))
+	if got.Currency != "EUR" {
+		t.Fatalf("Got currency %q, want EUR", got.Currency)
+	}
+	got = decodeSearch(t, serveAs(service, "GET", offerQueryURL, "", nil))
+	if got.Currency != "" {
+		t.Fatalf("Got currency %q for the default tenant, want none", got.Currency)
+	}
+}
+
+func TestTenants_rejected(t *testing.T) {
+	service := newTenantTestService(t)
+
+	testCases := []struct {
+		url     string
+		headers map[string]string
+		status  int
+		code    string
+	}{
+		{offerQueryURL, map[string]string{apiKeyHeader: "unknown"}, http.StatusUnauthorized, codeUnauthorized},
+		{offerQueryURL, map[string]string{tenantHeader: "unknown"}, http.StatusUnauthorized, codeUnauthorized},
+		// Tenants with API keys can't be selected with the tenant header
+		{offerQueryURL, map[string]string{tenantHeader: "acme"}, http.StatusUnauthorized, codeUnauthorized},
+		{"http://testsite.local/api/v1/webhooks", map[string]string{tenantHeader: "globex"}, http.StatusForbidden, codeDefaultTenantOnly},
+		{"http://testsite.local/api/v1/alerts", map[string]string{apiKeyHeader: "acme-key"}, http.StatusForbidden, codeDefaultTenantOnly},
+	}
+
+	for _, testCase := range testCases {
+		resp := serveAs(service, "GET", testCase.url, "", testCase.headers)
+		if resp.StatusCode != testCase.status {
+			t.Fatalf("%v: got status code %d, want %d", testCase.headers, resp.StatusCode, testCase.status)
+		}
+		got := problem{}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != testCase.code {
+			t.Fatalf("%v: got code %q, want %q", testCase.headers, got.Code, testCase.code)
+		}
+	}
+
+	// Probes don't belong to a tenant
+	if resp := serveAs(service, "GET", "http://testsite.local/version", "", map[string]string{apiKeyHeader: "unknown"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d for the version, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTenants_defaultTenantWithAPIKey(t *testing.T) {
+	service := newValidatingTestService(t)
+	if err := service.SetTenants([]Tenant{{ID: database.DefaultTenant, APIKeys: []string{"default-key"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := serveAs(service, "GET", offerQueryURL, "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Got bad status code %d without an API key, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp := serveAs(service, "GET", offerQueryURL, "", map[string]string{apiKeyHeader: "default-key"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d with the API key, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTenants_idempotencyKeys(t *testing.T) {
+	service := newTenantTestService(t)
+	testCases := []struct {
+		tenant string
+		header string
+		body   string
+	}{
+		{database.DefaultTenant, tenantHeader, offerBody},
+		{"globex", tenantHeader, `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":50}`},
+		{"acme-key", apiKeyHeader, offerBody},
+	}
+
+	// Every tenant gets its own response, whether or not the payload matches another tenant's
+	for _, testCase := range testCases {
+		resp := serveAs(service, "POST", "http://testsite.local/api/v1/offer", testCase.body,
+			map[string]string{testCase.header: testCase.tenant, idempotencyKeyHeader: "same-key"})
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(idempotencyReplayedHeader) != "" {
+			t.Fatalf("%s: got status code %d, replayed %q, want a new response with %d",
+				testCase.tenant, resp.StatusCode, resp.Header.Get(idempotencyReplayedHeader), http.StatusOK)
+		}
+
+		got := decodeSearch(t, serveAs(service, "GET", offerQueryURL, "", map[string]string{testCase.header: testCase.tenant}))
+		if len(got.Offers) != 1 {
+			t.Fatalf("%s: got offers %+v, want the offer of the tenant", testCase.tenant, got.Offers)
+		}
+	}
+
+	// Retries within a tenant are still replayed
+	resp := serveAs(service, "POST", "http://testsite.local/api/v1/offer", testCases[1].body,
+		map[string]string{tenantHeader: "globex", idempotencyKeyHeader: "same-key"})
+	if resp.Header.Get(idempotencyReplayedHeader) != "true" {
+		t.Fatalf("Got status code %d without a replay, want the stored response", resp.StatusCode)
+	}
+}
+
+func TestTenants_notScoped(t *testing.T) {
+	service := newSearchTestService()
+	if err := service.SetTenants([]Tenant{{ID: "globex"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serveAs(service, "GET", offerQueryURL, "", map[string]string{tenantHeader: "globex"})
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d with a database without tenants, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSetTenants(t *testing.T) {
+	testCases := []struct {
+		tenants []Tenant
+		valid   bool
+	}{
+		{[]Tenant{{ID: "acme", APIKeys: []string{"key"}, Currency: "EUR", Ranking: ranking.ByReviewScore}}, true},
+		{[]Tenant{{ID: database.DefaultTenant, Currency: "EUR"}}, true},
+		{[]Tenant{{ID: ""}}, false},
+		{[]Tenant{{ID: "acme"}, {ID: "acme"}}, false},
+		{[]Tenant{{ID: "acme", APIKeys: []string{"key"}}, {ID: "globex", APIKeys: []string{"key"}}}, false},
+		{[]Tenant{{ID: "acme", APIKeys: []string{""}}}, false},
+		{[]Tenant{{ID: "acme", Currency: "euro"}}, false},
+		{[]Tenant{{ID: "acme", Ranking: "popularity"}}, false},
+	}
+
+	for _, testCase := range testCases {
+		err := NewService(1234).SetTenants(testCase.tenants)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("%+v: got error %v, want valid %t", testCase.tenants, err, testCase.valid)
+		}
+	}
+}
+
+func TestTenantMetrics(t *testing.T) {
+	service := newTenantTestService(t)
+
+	serveAs(service, "POST", "http://testsite.local/api/v1/offer", offerBody, map[string]string{apiKeyHeader: "acme-key"})
+	serveAs(service, "GET", offerQueryURL, "", map[string]string{apiKeyHeader: "acme-key"})
+	serveAs(service, "GET", "http://testsite.local/api/v1/offers", "", map[string]string{apiKeyHeader: "acme-key"})
+
+	got := service.tenantMetrics.snapshot()["acme"]
+	if got.Requests != 3 || got.Responses["2xx"] != 2 || got.Responses["4xx"] != 1 {
+		t.Fatalf("Got requests %+v, want 3 with 2 successful ones", got)
+	}
+	if got.OfferChanges != 1 {
+		t.Fatalf("Got %d offer changes, want 1", got.OfferChanges)
+	}
+	if _, ok := service.tenantMetrics.snapshot()["globex"]; ok {
+		t.Fatal("Got statistics of globex, want none")
+	}
+}
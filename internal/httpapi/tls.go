@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TLSConfig configures serving HTTPS
+type TLSConfig struct {
+	// CertFile and KeyFile are the paths of the PEM-encoded certificate chain and private key. They're
+	// reloaded when they change, so rotated certificates are used without a restart.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile is the path of the PEM-encoded CAs which client certificates are verified with. If
+	// it's set, clients without a valid certificate are rejected. If it's empty, clients aren't asked
+	// for certificates.
+	ClientCAFile string
+	// ClientCertOptional accepts clients without a certificate, e.g. while clients are migrated to
+	// mutual TLS. Certificates are only verified if they're presented. They aren't used to authorize
+	// requests, so clients without one can still do everything.
+	ClientCertOptional bool
+
+	// RedirectPort is the port of a plain HTTP listener redirecting to HTTPS. It's disabled if it's 0.
+	RedirectPort int
+}
+
+// certReloader loads a key pair and reloads it whenever the files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.certificate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// certificate returns the current key pair, reloading it if one of the files changed
+//
+// If a changed key pair can't be loaded, e.g. because only one of the files has been replaced yet,
+// the previous one is used until the next attempt.
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if r.cert != nil {
+				return r.cert, nil
+			}
+			return nil, errors.Wrap(err, "error reading certificate")
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			log.Printf("Error reloading TLS certificate, using the previous one: %v", err)
+			return r.cert, nil
+		}
+		return nil, errors.Wrap(err, "error loading certificate")
+	}
+
+	if r.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert, r.modTimes = &cert, modTimes
+	return r.cert, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// newTLSConfig creates the configuration of the HTTPS server
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	reloader, err := newCertReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if config.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading client CAs")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.ClientCAFile)
+		}
+
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if config.ClientCertOptional {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	} else if config.ClientCertOptional {
+		return nil, errors.New("client certificates can only be optional with client CAs")
+	}
+
+	return tlsConfig, nil
+}
+
+// SetTLS makes the service serve HTTPS with HTTP/2 instead of plain HTTP
+//
+// The certificate is loaded right away, so that configuration errors are reported at startup.
+func (s *Service) SetTLS(config TLSConfig) error {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return err
+	}
+	s.server.TLSConfig = tlsConfig
+
+	if config.RedirectPort != 0 {
+		s.redirectServer = &http.Server{
+			Addr:         ":" + strconv.Itoa(config.RedirectPort),
+			Handler:      httpsRedirect(s.server.Addr),
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		}
+	}
+	return nil
+}
+
+// httpsRedirect returns a handler which redirects requests to the same URL on the HTTPS address
+func httpsRedirect(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
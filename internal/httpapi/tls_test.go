@@ -0,0 +1,290 @@
+package httpapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority issuing certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM-encoded certificate and key for localhost
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTLSService serves the service with TLS on a random port and returns its URL
+func startTLSService(t *testing.T, config TLSConfig) string {
+	t.Helper()
+
+	service := NewService(0)
+	if err := service.SetTLS(config); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go service.serve(lis)
+	t.Cleanup(func() { service.server.Close() })
+
+	return "https://" + lis.Addr().String()
+}
+
+// tlsClient returns a client trusting the CA which doesn't reuse connections
+func tlsClient(ca *testCA, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestTLS_reloadsCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, time.Now().Add(-time.Minute))
+	writeFile(t, keyFile, key, time.Now().Add(-time.Minute))
+
+	url := startTLSService(t, TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	client := tlsClient(ca)
+
+	resp, err := client.Get(url + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Got protocol %s, want HTTP/2", resp.Proto)
+	}
+	if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 10 {
+		t.Fatalf("Got certificate %d, want 10", got)
+	}
+
+	// Rotate the certificate
+	cert, key = ca.issue(t, 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, time.Now())
+	writeFile(t, keyFile, key, time.Now())
+
+	resp, err = client.Get(url + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 11 {
+		t.Fatalf("Got certificate %d after the rotation, want 11", got)
+	}
+}
+
+func TestTLS_clientCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, time.Now())
+	writeFile(t, keyFile, key, time.Now())
+	writeFile(t, caFile, ca.pem, time.Now())
+
+	// Client certificates are required by default
+	url := startTLSService(t, TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	if resp, err := tlsClient(ca).Get(url + "/version"); err == nil {
+		resp.Body.Close()
+		t.Fatal("Got a response without a client certificate, want an error")
+	}
+
+	// Certificates of other CAs are rejected
+	otherCert, otherKey := newTestCA(t).issue(t, 20, x509.ExtKeyUsageClientAuth)
+	other, err := tls.X509KeyPair(otherCert, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := tlsClient(ca, other).Get(url + "/version"); err == nil {
+		resp.Body.Close()
+		t.Fatal("Got a response with an untrusted client certificate, want an error")
+	}
+
+	clientCert, clientKey := ca.issue(t, 21, x509.ExtKeyUsageClientAuth)
+	client, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tlsClient(ca, client).Get(url + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestTLS_optionalClientCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, time.Now())
+	writeFile(t, keyFile, key, time.Now())
+	writeFile(t, caFile, ca.pem, time.Now())
+
+	url := startTLSService(t, TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientCertOptional: true})
+
+	resp, err := tlsClient(ca).Get(url + "/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d without a client certificate, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Presented certificates are still verified
+	otherCert, otherKey := newTestCA(t).issue(t, 20, x509.ExtKeyUsageClientAuth)
+	other, err := tls.X509KeyPair(otherCert, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := tlsClient(ca, other).Get(url + "/version"); err == nil {
+		resp.Body.Close()
+		t.Fatal("Got a response with an untrusted client certificate, want an error")
+	}
+}
+
+func TestSetTLS_invalidConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	cert, key := ca.issue(t, 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert, time.Now())
+	writeFile(t, keyFile, key, time.Now())
+
+	testCases := []struct {
+		name   string
+		config TLSConfig
+	}{
+		{"missing certificate", TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}},
+		{"key as certificate", TLSConfig{CertFile: keyFile, KeyFile: keyFile}},
+		{"missing client CAs", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(dir, "ca.crt")}},
+		{"no client CA certificates", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}},
+		{"optional client certificates without CAs", TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCertOptional: true}},
+	}
+
+	for _, tc := range testCases {
+		if err := NewService(0).SetTLS(tc.config); err == nil {
+			t.Fatalf("%s: got no error, want one", tc.name)
+		}
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	testCases := []struct {
+		httpsAddr string
+		host      string
+		want      string
+	}{
+		{":8443", "example.com:8080", "https://example.com:8443/api/v1/offers?product=Towel"},
+		{":443", "example.com", "https://example.com/api/v1/offers?product=Towel"},
+		{":8443", "[::1]:8080", "https://[::1]:8443/api/v1/offers?product=Towel"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "http://"+tc.host+"/api/v1/offers?product=Towel", nil)
+		w := httptest.NewRecorder()
+		httpsRedirect(tc.httpsAddr).ServeHTTP(w, req)
+
+		resp := w.Result()
+		if resp.StatusCode != http.StatusPermanentRedirect {
+			t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusPermanentRedirect)
+		}
+		if got := resp.Header.Get("Location"); got != tc.want {
+			t.Fatalf("Got redirect to %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package httpapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// openAPIValidator validates requests and, optionally, responses against the OpenAPI document
+type openAPIValidator struct {
+	router            routers.Router
+	validateResponses bool
+	// invalidResponse is called with responses which don't match the document
+	invalidResponse func(r *http.Request, err error)
+}
+
+// SetOpenAPIValidation validates requests against the OpenAPI document before they're handled
+//
+// Requests which don't match the document are rejected with a 400 response. If validateResponses
+// is set, responses are checked as well and mismatches are logged. That's meant for tests and
+// staging environments, since every response is copied for the check. Routes which aren't in the
+// document, such as the admin routes, aren't validated.
+func (s *Service) SetOpenAPIValidation(spec []byte, validateResponses bool) error {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return pkgerrors.Wrap(err, "error loading OpenAPI document")
+	}
+	if err = doc.Validate(loader.Context); err != nil {
+		return pkgerrors.Wrap(err, "invalid OpenAPI document")
+	}
+
+	// The servers in the document are examples, so requests are matched by their path only
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return pkgerrors.Wrap(err, "error creating router for the OpenAPI document")
+	}
+
+	s.openAPI = &openAPIValidator{
+		router:            router,
+		validateResponses: validateResponses,
+		invalidResponse: func(r *http.Request, err error) {
+			log.Printf("Request %s: response to %s %s doesn't match the OpenAPI document: %v",
+				requestID(r.Context()), r.Method, r.URL.Path, err)
+		},
+	}
+	return nil
+}
+
+// validateOpenAPI is a middleware which validates requests and responses if it's enabled
+func (s *Service) validateOpenAPI(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := s.openAPI
+		if v == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		route, pathParams, err := v.router.FindRoute(r)
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+		}
+		if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			var tooLarge *http.MaxBytesError
+			if !errors.As(err, &tooLarge) {
+				err = invalidRequest(validationDetail(err))
+			}
+			s.respondError(w, r, err)
+			return
+		}
+
+		if !v.validateResponses {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		capture := &capturingResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(capture, r)
+
+		// Only JSON bodies are checked against their schemas. Other formats, such as exports and
+		// streams, only need to have a documented content type.
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 capture.status,
+			Header:                 w.Header(),
+			Body:                   ioutil.NopCloser(bytes.NewReader(capture.body.Bytes())),
+			Options: &openapi3filter.Options{
+				IncludeResponseStatus: true,
+				ExcludeResponseBody:   !isJSON,
+			},
+		})
+		if err != nil {
+			v.invalidResponse(r, err)
+		}
+	})
+}
+
+// validationDetail describes why a request is invalid without the schema dumps of the validator
+func validationDetail(err error) string {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return "the request doesn't match the API documentation"
+	}
+
+	detail := "request body"
+	if requestErr.Parameter != nil {
+		detail = fmt.Sprintf("%s parameter %q", requestErr.Parameter.In, requestErr.Parameter.Name)
+	}
+
+	var schemaErr *openapi3.SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			detail += " at /" + strings.Join(pointer, "/")
+		}
+		return detail + ": " + schemaErr.Reason
+	case requestErr.Reason != "":
+		return detail + ": " + requestErr.Reason
+	default:
+		return detail + " is invalid"
+	}
+}
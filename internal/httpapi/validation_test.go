@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/api"
+	"github.com/muffix/relayr-challenge/internal/graphqlapi"
+)
+
+// newValidatingTestService returns a service with a temporary database which validates requests and
+// fails the test for every response that doesn't match the OpenAPI document
+func newValidatingTestService(t *testing.T) *Service {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	service.SetReviewer(&mockReviewer{db: db})
+	service.SetAlerts(db, nil)
+
+	executor, err := graphqlapi.NewExecutor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.SetGraphQLExecutor(executor)
+
+	if err = service.SetOpenAPIValidation(api.OpenAPI, true); err != nil {
+		t.Fatal(err)
+	}
+	service.openAPI.invalidResponse = func(r *http.Request, err error) {
+		t.Errorf("Response to %s %s doesn't match the OpenAPI document: %v", r.Method, r.URL, err)
+	}
+	return service
+}
+
+func TestOpenAPIValidation_responsesMatchDocument(t *testing.T) {
+	service := newValidatingTestService(t)
+	offer := `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":42}`
+
+	testCases := []struct {
+		method, url, body string
+		wantStatus        int
+	}{
+		{"GET", "/", "", http.StatusOK},
+		{"GET", "/version", "", http.StatusOK},
+		{"GET", "/liveness", "", http.StatusOK},
+		{"GET", "/readiness", "", http.StatusOK},
+		{"POST", "/api/v1/offer", offer, http.StatusOK},
+		{"POST", "/api/v1/offer/batch", "[" + offer + "]", http.StatusOK},
+		{"POST", "/api/v1/offer/search", `{"product":"Towel","category":"Must Haves"}`, http.StatusOK},
+		{"POST", "/api/v1/offer/search", `{"product":"Hat","category":"Must Haves"}`, http.StatusOK},
+		{"POST", "/api/v1/offer/search", `{"product":"Towel","category":""}`, http.StatusBadRequest},
+		{"GET", "/api/v1/offers?product=Towel&category=Must+Haves", "", http.StatusOK},
+		{"GET", "/api/v1/offers?product=Towel", "", http.StatusBadRequest},
+		{"GET", "/api/v1/offers/export?format=csv", "", http.StatusOK},
+		{"GET", "/api/v1/offers/export?format=ndjson", "", http.StatusOK},
+		{"POST", "/api/v1/offer/withdraw", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials"}`, http.StatusOK},
+		{"POST", "/api/v1/offer/withdraw", `{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials"}`, http.StatusNotFound},
+		{"POST", "/api/v1/webhooks", `{"url":"https://example.com/hook","secret":"s3cr3t"}`, http.StatusCreated},
+		{"GET", "/api/v1/webhooks", "", http.StatusOK},
+		{"GET", "/api/v1/webhooks/1", "", http.StatusOK},
+		{"GET", "/api/v1/webhooks/1/deliveries", "", http.StatusOK},
+		{"DELETE", "/api/v1/webhooks/1", "", http.StatusNoContent},
+		{"GET", "/api/v1/webhooks/1", "", http.StatusNotFound},
+		{"POST", "/api/v1/alerts", `{"product":"Towel","category":"Must Haves","targetPrice":40}`, http.StatusCreated},
+		{"GET", "/api/v1/alerts", "", http.StatusOK},
+		{"PUT", "/api/v1/alerts/1", `{"targetPrice":35}`, http.StatusOK},
+		{"GET", "/api/v1/alerts/1", "", http.StatusOK},
+		{"DELETE", "/api/v1/alerts/1", "", http.StatusNoContent},
+		{"DELETE", "/api/v1/alerts/1", "", http.StatusNotFound},
+		{"POST", "/graphql", `{"query":"{ category(name: \"Must Haves\") { name } }"}`, http.StatusOK},
+		{"POST", "/graphql", `{"query":"{ category { name } }"}`, http.StatusBadRequest},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != testCase.wantStatus {
+			t.Fatalf("%s %s: got status code %d, want %d", testCase.method, testCase.url, resp.StatusCode, testCase.wantStatus)
+		}
+	}
+}
+
+func TestOpenAPIValidation_rejectsInvalidRequests(t *testing.T) {
+	service := newValidatingTestService(t)
+
+	testCases := []struct {
+		name, method, url, body string
+		wantDetail              string
+	}{
+		{"price isn't a number", "POST", "/api/v1/offer",
+			`{"product":"Towel","category":"Must Haves","supplier":"Hitchhiker Essentials","price":"cheap"}`,
+			"request body at /price: value must be a number"},
+		{"missing property", "POST", "/api/v1/offer/search", `{"product":"Towel"}`,
+			`request body at /category: property "category" is missing`},
+		{"invalid query parameter", "GET", "/api/v1/offers/export?updatedSince=yesterday", "",
+			`query parameter "updatedSince"`},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: got status code %d, want %d", testCase.name, resp.StatusCode, http.StatusBadRequest)
+		}
+
+		got := problem{}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != codeInvalidRequest || !strings.HasPrefix(got.Detail, testCase.wantDetail) {
+			t.Fatalf("%s: got problem %+v, want code %q and detail %q", testCase.name, got, codeInvalidRequest, testCase.wantDetail)
+		}
+	}
+}
+
+func TestSetOpenAPIValidation_withInvalidDocument(t *testing.T) {
+	service := NewService(1234)
+	if err := service.SetOpenAPIValidation([]byte("openapi: 3.0.0\npaths: nope"), false); err == nil {
+		t.Fatal("Expected an error for an invalid document")
+	}
+}
@@ -18,7 +18,7 @@ var (
 type versionResponse struct {
 	Revision   string `json:"revision"`
 	PipelineID string `json:"pipelineId"`
-	BuildDate  string `json:"buildDate"`
+	BuildDate  string `json:"buildDate,omitempty"`
 	LaunchDate string `json:"launchDate"`
 }
 
@@ -0,0 +1,270 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/webhooks"
+)
+
+const (
+	// defaultListLimit is the number of deliveries and dead letters returned if no limit is given
+	defaultListLimit = 100
+	// maxListLimit is the maximum number of deliveries and dead letters returned at once
+	maxListLimit = 1000
+)
+
+type webhookRequest struct {
+	URL      string `json:"url"`
+	Product  string `json:"product"`
+	Category string `json:"category"`
+	Secret   string `json:"secret"`
+}
+
+// webhookResponse describes a webhook. The secret is never returned.
+type webhookResponse struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Product   string    `json:"product"`
+	Category  string    `json:"category"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type webhookDeliveryResponse struct {
+	ID         int64     `json:"id"`
+	EventID    string    `json:"eventId"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	Time       time.Time `json:"time"`
+}
+
+type deadLetterResponse struct {
+	ID        int64           `json:"id"`
+	WebhookID int64           `json:"webhookId"`
+	EventID   string          `json:"eventId"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError"`
+	Time      time.Time       `json:"time"`
+}
+
+func newWebhookResponse(webhook database.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Product:   webhook.Product,
+		Category:  webhook.Category,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+// withWebhooks responds with 503 if no webhook store has been set
+func (s *Service) withWebhooks(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.webhooks == nil {
+			s.respondError(w, r, notConfigured("Webhooks"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleWebhookCreate returns an http.HandlerFunc which subscribes a webhook to new cheapest offers
+func (s *Service) handleWebhookCreate() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		request := webhookRequest{}
+		err := s.decode(w, r, &request)
+		if err != nil {
+			s.respondError(w, r, malformedBody(err))
+			return
+		}
+
+		target, err := url.Parse(request.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			s.respondError(w, r, invalidRequest("url must be an absolute HTTP or HTTPS URL"))
+			return
+		}
+		if err = webhooks.CheckURL(r.Context(), net.DefaultResolver, request.URL); err != nil {
+			s.respondError(w, r, invalidRequest("url must not point to a loopback, link-local or private address"))
+			return
+		}
+		if request.Secret == "" {
+			s.respondError(w, r, invalidRequest("a secret for signing payloads is required"))
+			return
+		}
+
+		// Changes are reported for the product, so the webhook has to be for the product, too
+		product, err := s.resolveProduct(r, request.Product)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		webhook, err := s.webhooks.AddWebhook(database.Webhook{
+			URL:      request.URL,
+			Product:  product,
+			Category: request.Category,
+			Secret:   request.Secret,
+		})
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		s.respond(w, r, newWebhookResponse(webhook), http.StatusCreated)
+	})
+}
+
+// handleWebhookList returns an http.HandlerFunc which lists all webhooks
+func (s *Service) handleWebhookList() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		webhooks, err := s.webhooks.ListWebhooks()
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]webhookResponse, len(webhooks))
+		for i, webhook := range webhooks {
+			response[i] = newWebhookResponse(webhook)
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// handleWebhookGet returns an http.HandlerFunc which describes one webhook
+func (s *Service) handleWebhookGet() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		webhook, err := s.webhooks.Webhook(id)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		s.respond(w, r, newWebhookResponse(webhook), http.StatusOK)
+	})
+}
+
+// handleWebhookDelete returns an http.HandlerFunc which unsubscribes a webhook
+func (s *Service) handleWebhookDelete() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.webhooks.DeleteWebhook(id); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// handleWebhookDeliveries returns an http.HandlerFunc which lists the latest delivery attempts of a
+// webhook, newest first
+func (s *Service) handleWebhookDeliveries() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := s.pathID(w, r)
+		if !ok {
+			return
+		}
+		limit, ok := s.listLimit(w, r)
+		if !ok {
+			return
+		}
+
+		if _, err := s.webhooks.Webhook(id); err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		deliveries, err := s.webhooks.Deliveries(id, limit)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]webhookDeliveryResponse, len(deliveries))
+		for i, delivery := range deliveries {
+			response[i] = webhookDeliveryResponse{
+				ID:         delivery.ID,
+				EventID:    delivery.EventID,
+				Attempt:    delivery.Attempt,
+				StatusCode: delivery.StatusCode,
+				Error:      delivery.Error,
+				DurationMs: delivery.Duration.Milliseconds(),
+				Time:       delivery.Time,
+			}
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// handleDeadLetters returns an http.HandlerFunc which lists the latest events that couldn't be
+// delivered, newest first
+func (s *Service) handleDeadLetters() http.HandlerFunc {
+	return s.withWebhooks(func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := s.listLimit(w, r)
+		if !ok {
+			return
+		}
+
+		letters, err := s.webhooks.DeadLetters(limit)
+		if err != nil {
+			s.respondError(w, r, err)
+			return
+		}
+
+		response := make([]deadLetterResponse, len(letters))
+		for i, letter := range letters {
+			response[i] = deadLetterResponse{
+				ID:        letter.ID,
+				WebhookID: letter.WebhookID,
+				EventID:   letter.EventID,
+				Payload:   letter.Payload,
+				Attempts:  letter.Attempts,
+				LastError: letter.LastError,
+				Time:      letter.Time,
+			}
+		}
+		s.respond(w, r, response, http.StatusOK)
+	})
+}
+
+// pathID parses the ID in the path. It responds with 400 and returns false if it's invalid.
+func (s *Service) pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.respondError(w, r, invalidRequest("invalid ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// listLimit parses the limit query parameter. It responds with 400 and returns false if it's invalid.
+func (s *Service) listLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	param := r.URL.Query().Get("limit")
+	if param == "" {
+		return defaultListLimit, true
+	}
+
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		s.respondError(w, r, invalidRequest("limit must be between 1 and "+strconv.Itoa(maxListLimit)))
+		return 0, false
+	}
+	return limit, true
+}
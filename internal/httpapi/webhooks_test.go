@@ -0,0 +1,148 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// newDatabaseTestService returns a service and a temporary database which is used as the webhook store
+func newDatabaseTestService(t *testing.T) (*Service, *database.OffersSQLiteDatabase) {
+	db, err := database.InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	service := NewService(1234)
+	service.SetWebhookStore(db)
+	return service, db
+}
+
+// serve sends a request through the router
+func serve(service *Service, method, url, body string) *http.Response {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	service.router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+
+	resp := serve(service, "POST", "http://testsite.local/api/v1/webhooks",
+		`{"url":"https://example.com/hook","product":"Towel","category":"Must Haves","secret":"s3cr3t"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	var raw map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["secret"]; ok {
+		t.Fatal("Expected the secret not to be returned")
+	}
+
+	webhooks, err := db.ListWebhooks()
+	if err != nil || len(webhooks) != 1 || webhooks[0].Secret != "s3cr3t" {
+		t.Fatalf("Got webhooks %+v and error %v, want the new one", webhooks, err)
+	}
+
+	resp = serve(service, "GET", "http://testsite.local/api/v1/webhooks", "")
+	var list []webhookResponse
+	if err = json.NewDecoder(resp.Body).Decode(&list); err != nil || len(list) != 1 || list[0].Product != "Towel" {
+		t.Fatalf("Got webhooks %+v and error %v, want the new one", list, err)
+	}
+
+	if resp = serve(service, "GET", "http://testsite.local/api/v1/webhooks/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	resp = serve(service, "GET", "http://testsite.local/api/v1/webhooks/1/deliveries?limit=10", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if resp = serve(service, "DELETE", "http://testsite.local/api/v1/webhooks/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if resp = serve(service, "GET", "http://testsite.local/api/v1/webhooks/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	if resp = serve(service, "DELETE", "http://testsite.local/api/v1/webhooks/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+
+	if resp = serveAdmin(service, "GET", "http://testsite.local/admin/webhooks/dead-letters", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestWebhookHandlers_withAlias(t *testing.T) {
+	service, db := newDatabaseTestService(t)
+	service.SetDatabase(db)
+	if _, _, err := db.AddProductAlias(context.Background(), "Bath Towel", "Towel"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := serve(service, "POST", "http://testsite.local/api/v1/webhooks",
+		`{"url":"https://example.com/hook","product":"bath towel","secret":"s3cr3t"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	created := webhookResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Product != "Towel" {
+		t.Fatalf("Got webhook %+v, want it for the product of the alias", created)
+	}
+
+	if webhooks, err := db.MatchingWebhooks("Towel", "Must Haves"); err != nil || len(webhooks) != 1 {
+		t.Fatalf("Got webhooks %+v and error %v for changes to the product, want the webhook", webhooks, err)
+	}
+}
+
+func TestWebhookHandlers_withInvalidRequests(t *testing.T) {
+	service, _ := newDatabaseTestService(t)
+
+	testCases := []struct {
+		name, method, url, body string
+	}{
+		{"not JSON", "POST", "/api/v1/webhooks", "I'm not JSON"},
+		{"relative URL", "POST", "/api/v1/webhooks", `{"url":"/hook","secret":"s3cr3t"}`},
+		{"unsupported scheme", "POST", "/api/v1/webhooks", `{"url":"ftp://example.com","secret":"s3cr3t"}`},
+		{"missing secret", "POST", "/api/v1/webhooks", `{"url":"https://example.com/hook"}`},
+		{"loopback address", "POST", "/api/v1/webhooks", `{"url":"http://localhost:8080/hook","secret":"s3cr3t"}`},
+		{"private address", "POST", "/api/v1/webhooks", `{"url":"http://10.0.0.1/hook","secret":"s3cr3t"}`},
+		{"link-local address", "POST", "/api/v1/webhooks", `{"url":"http://169.254.169.254/latest/meta-data","secret":"s3cr3t"}`},
+	}
+
+	for _, testCase := range testCases {
+		resp := serve(service, testCase.method, "http://testsite.local"+testCase.url, testCase.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: got status code %d, want %d", testCase.name, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	resp := serveAdmin(service, "GET", "http://testsite.local/admin/webhooks/dead-letters?limit=0", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid limit: got status code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestWebhookHandlers_withoutStore(t *testing.T) {
+	service := NewService(1234)
+	if resp := serve(service, "GET", "http://testsite.local/api/v1/webhooks", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Got bad status code %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,160 @@
+// Package ranking ranks the offers for a product by price and the review scores of their suppliers
+package ranking
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+	"github.com/muffix/relayr-challenge/internal/review"
+)
+
+// Strategy is the order in which offers are ranked
+type Strategy string
+
+// The strategies offers can be ranked by
+const (
+	// ByPrice ranks the cheapest offers first. Offers with the same price are ranked by review score.
+	ByPrice Strategy = "price"
+	// ByReviewScore ranks the offers of the best reviewed suppliers first. Offers with the same
+	// review score are ranked by price.
+	ByReviewScore Strategy = "reviewScore"
+)
+
+// Valid returns whether the strategy is known. The empty strategy is valid and ranks by price.
+func (s Strategy) Valid() bool {
+	switch s {
+	case "", ByPrice, ByReviewScore:
+		return true
+	}
+	return false
+}
+
+// RankedOffer is an offer with the review score of its supplier
+type RankedOffer struct {
+	Supplier string
+	// Category is the category of the offer, which differs between offers from subcategories
+	Category    string
+	ReviewScore float32
+	Price       float32
+	// Stock is the stock of the offer if the supplier reports it
+	Stock database.Stock
+}
+
+// ReviewerFor returns a reviewer which looks up the review scores of the suppliers in the database
+// by their stable IDs, if the database keeps supplier profiles
+func ReviewerFor(ctx context.Context, offers database.Offers, reviewer review.Reviewer) review.Reviewer {
+	profiles, ok := offers.(database.SupplierProfiles)
+	if !ok {
+		return reviewer
+	}
+
+	return review.ByID(reviewer, func(supplierNames []string) (map[string]string, error) {
+		suppliers, err := profiles.SuppliersByName(ctx, supplierNames)
+		if err != nil {
+			return nil, err
+		}
+		ids := make(map[string]string, len(suppliers))
+		for name, supplier := range suppliers {
+			ids[name] = strconv.FormatInt(supplier.ID, 10)
+		}
+		return ids, nil
+	})
+}
+
+// WithoutSuspended removes the offers of suspended suppliers from the offers found in the
+// database. Databases without supplier profiles don't suspend suppliers.
+//
+// Changes to offers are filtered with database.Change.Suspended instead.
+func WithoutSuspended(ctx context.Context, db database.Offers, offers []database.Offer) ([]database.Offer, error) {
+	profiles, ok := db.(database.SupplierProfiles)
+	if !ok || len(offers) == 0 {
+		return offers, nil
+	}
+
+	names := make([]string, len(offers))
+	for i, offer := range offers {
+		names[i] = offer.Supplier
+	}
+	suppliers, err := profiles.SuppliersByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	shown := make([]database.Offer, 0, len(offers))
+	for _, offer := range offers {
+		if suppliers[offer.Supplier].Status != database.SupplierSuspended {
+			shown = append(shown, offer)
+		}
+	}
+	return shown, nil
+}
+
+// Available returns the offers which aren't out of stock
+func Available(offers []database.Offer) []database.Offer {
+	available := make([]database.Offer, 0, len(offers))
+	for _, offer := range offers {
+		if offer.Available() {
+			available = append(available, offer)
+		}
+	}
+	return available
+}
+
+// Orderable returns the offers which customers can order. Offers of suspended suppliers and offers
+// which are out of stock are left out.
+func Orderable(ctx context.Context, db database.Offers, offers []database.Offer) ([]database.Offer, error) {
+	shown, err := WithoutSuspended(ctx, db, offers)
+	if err != nil {
+		return nil, err
+	}
+	return Available(shown), nil
+}
+
+// Rank adds the review scores to the offers and sorts them by price first, then by review score
+func Rank(reviewer review.Reviewer, offers []database.Offer) ([]RankedOffer, error) {
+	return RankBy(reviewer, offers, ByPrice)
+}
+
+// RankBy adds the review scores to the offers and sorts them with the strategy
+func RankBy(reviewer review.Reviewer, offers []database.Offer, strategy Strategy) ([]RankedOffer, error) {
+	suppliers := make([]string, len(offers))
+	for i, offer := range offers {
+		suppliers[i] = offer.Supplier
+	}
+	reviewScores, err := reviewer.Suppliers(suppliers)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]RankedOffer, len(offers))
+	for i, offer := range offers {
+		ranked[i] = RankedOffer{
+			Supplier:    offer.Supplier,
+			Category:    offer.Category,
+			ReviewScore: reviewScores[offer.Supplier],
+			Price:       offer.Price,
+			Stock:       offer.Stock,
+		}
+	}
+
+	if strategy == ByReviewScore {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if ranked[i].ReviewScore == ranked[j].ReviewScore {
+				return ranked[i].Price < ranked[j].Price
+			}
+			return ranked[i].ReviewScore > ranked[j].ReviewScore
+		})
+		return ranked, nil
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Price == ranked[j].Price {
+			return ranked[i].ReviewScore >= ranked[j].ReviewScore
+		}
+		return ranked[i].Price < ranked[j].Price
+	})
+
+	return ranked, nil
+}
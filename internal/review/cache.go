@@ -0,0 +1,115 @@
+package review
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Purger is implemented by reviewers which cache review scores
+type Purger interface {
+	// Purge drops all cached scores and returns how many there were
+	Purge() int
+}
+
+// CacheStats are the statistics of a cache of review scores
+type CacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// cachedScore is a review score and the time it expires
+type cachedScore struct {
+	score   float32
+	expires time.Time
+}
+
+// Cache is a reviewer which caches the scores of another reviewer
+//
+// Scores are kept for the TTL, so that searches for the same suppliers don't hit the reviews
+// engine every time.
+type Cache struct {
+	reviewer Reviewer
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu     sync.Mutex
+	scores map[string]cachedScore
+	hits   uint64
+	misses uint64
+}
+
+// NewCache returns a reviewer which caches the scores of the reviewer for the TTL
+func NewCache(reviewer Reviewer, ttl time.Duration) *Cache {
+	return &Cache{
+		reviewer: reviewer,
+		ttl:      ttl,
+		now:      time.Now,
+		scores:   make(map[string]cachedScore),
+	}
+}
+
+// Suppliers returns the review scores of the suppliers with the IDs. Only the ones which aren't
+// cached are requested from the reviewer.
+func (c *Cache) Suppliers(supplierIDs []string) (map[string]float32, error) {
+	reviews := make(map[string]float32, len(supplierIDs))
+	var missing []string
+
+	c.mu.Lock()
+	now := c.now()
+	for _, supplier := range supplierIDs {
+		if cached, ok := c.scores[supplier]; ok && now.Before(cached.expires) {
+			reviews[supplier] = cached.score
+			c.hits++
+			continue
+		}
+		missing = append(missing, supplier)
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return reviews, nil
+	}
+
+	fetched, err := c.reviewer.Suppliers(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	for supplier, score := range fetched {
+		c.scores[supplier] = cachedScore{score, expires}
+		reviews[supplier] = score
+	}
+	return reviews, nil
+}
+
+// Ping checks that the reviews engine behind the cache is reachable
+func (c *Cache) Ping(ctx context.Context) error {
+	if pinger, ok := c.reviewer.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := c.reviewer.Suppliers([]string{})
+	return err
+}
+
+// Purge drops all cached scores, so that they're requested from the reviews engine again
+func (c *Cache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := len(c.scores)
+	c.scores = make(map[string]cachedScore)
+	return purged
+}
+
+// Stats returns the number of cached scores and how often they were used
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Entries: len(c.scores), Hits: c.hits, Misses: c.misses}
+}
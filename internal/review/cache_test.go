@@ -0,0 +1,91 @@
+package review
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingReviewer scores every supplier 4 and counts the suppliers it was asked for
+type countingReviewer struct {
+	requested []string
+	err       error
+}
+
+func (r *countingReviewer) Suppliers(supplierNames []string) (map[string]float32, error) {
+	r.requested = append(r.requested, supplierNames...)
+	if r.err != nil {
+		return nil, r.err
+	}
+	reviews := make(map[string]float32)
+	for _, supplier := range supplierNames {
+		reviews[supplier] = 4
+	}
+	return reviews, nil
+}
+
+func TestCache_Suppliers(t *testing.T) {
+	reviewer := &countingReviewer{}
+	cache := NewCache(reviewer, time.Minute)
+	now := time.Date(2020, 10, 9, 18, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials"}); err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	reviews, err := cache.Suppliers([]string{"Hitchhiker Essentials", "Hitchhiker Knockoffs"})
+	if err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if len(reviews) != 2 {
+		t.Fatalf("Expected 2 reviews, got %d", len(reviews))
+	}
+	if len(reviewer.requested) != 2 {
+		t.Fatalf("Expected only uncached suppliers to be requested, got %v", reviewer.requested)
+	}
+
+	// Expired scores are requested again
+	now = now.Add(time.Minute)
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials"}); err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if len(reviewer.requested) != 3 {
+		t.Fatalf("Expected the expired score to be requested, got %v", reviewer.requested)
+	}
+
+	want := CacheStats{Entries: 2, Hits: 1, Misses: 3}
+	if got := cache.Stats(); got != want {
+		t.Fatalf("Expected stats %+v, got %+v", want, got)
+	}
+}
+
+func TestCache_Purge(t *testing.T) {
+	reviewer := &countingReviewer{}
+	cache := NewCache(reviewer, time.Hour)
+
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials", "Hitchhiker Knockoffs"}); err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if purged := cache.Purge(); purged != 2 {
+		t.Fatalf("Expected 2 purged scores, got %d", purged)
+	}
+
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials"}); err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if len(reviewer.requested) != 3 {
+		t.Fatalf("Expected purged scores to be requested again, got %v", reviewer.requested)
+	}
+}
+
+func TestCache_doesNotCacheErrors(t *testing.T) {
+	reviewer := &countingReviewer{err: errors.New("reviews engine unavailable")}
+	cache := NewCache(reviewer, time.Hour)
+
+	if _, err := cache.Suppliers([]string{"Hitchhiker Essentials"}); err == nil {
+		t.Fatal("Expected an error retrieving reviews, got none")
+	}
+	if entries := cache.Stats().Entries; entries != 0 {
+		t.Fatalf("Expected no cached scores, got %d", entries)
+	}
+}
@@ -1,13 +1,24 @@
 package review
 
 import (
+	"context"
 	"math"
 	"math/rand"
 )
 
 // Reviewer is an interface for fetching reviews for suppliers
+//
+// Suppliers are identified by their stable IDs, so that their reviews are kept if their names
+// change. Suppliers without an ID, e.g. with databases that don't keep supplier profiles, are
+// identified by their names. Use ByID to look up the reviews of named suppliers. It prefixes the names
+// of suppliers without an ID with NameKeyPrefix, so that they aren't mistaken for IDs.
 type Reviewer interface {
-	Suppliers(supplierNames []string) (map[string]float32, error)
+	Suppliers(supplierIDs []string) (map[string]float32, error)
+}
+
+// Pinger is implemented by reviewers which can check that the reviews engine is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
 }
 
 // Client is a reviews client
@@ -22,11 +33,18 @@ func (c *Client) randomScore() float32 {
 	return float32(math.Round((6-(float64(rand.Intn(100))/20.0))*100) / 100)
 }
 
-// Suppliers returns the review score for the given suppliers
-func (c *Client) Suppliers(supplierNames []string) (map[string]float32, error) {
+// Suppliers returns the review score for the suppliers with the IDs
+func (c *Client) Suppliers(supplierIDs []string) (map[string]float32, error) {
 	reviews := make(map[string]float32)
-	for _, supplier := range supplierNames {
+	for _, supplier := range supplierIDs {
 		reviews[supplier] = c.randomScore()
 	}
 	return reviews, nil
 }
+
+// Ping checks that the reviews engine is reachable
+//
+// The dummy engine is always reachable, so this only fails if the context is done.
+func (c *Client) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,53 @@
+package review
+
+// SupplierIDs looks up the stable IDs of the suppliers with the names, by name. Suppliers without
+// an ID are left out.
+type SupplierIDs func(supplierNames []string) (map[string]string, error)
+
+// NameKeyPrefix is prepended to the names of suppliers without an ID when ByID looks them up, so
+// that a supplier named like the ID of another supplier doesn't get its reviews
+const NameKeyPrefix = "name:"
+
+// byID is a reviewer which looks up the scores of named suppliers by their IDs
+type byID struct {
+	reviewer Reviewer
+	ids      SupplierIDs
+}
+
+// ByID returns a reviewer which takes the names of suppliers and looks up their scores with the
+// reviewer by their IDs. Suppliers without an ID are looked up by name, prefixed with NameKeyPrefix.
+//
+// The scores are returned by name, so callers which identify suppliers by name don't need to know
+// about their IDs.
+func ByID(reviewer Reviewer, ids SupplierIDs) Reviewer {
+	return &byID{reviewer: reviewer, ids: ids}
+}
+
+// Suppliers returns the review scores of the suppliers with the names
+func (b *byID) Suppliers(supplierNames []string) (map[string]float32, error) {
+	ids, err := b.ids(supplierNames)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(supplierNames))
+	for i, name := range supplierNames {
+		keys[i] = NameKeyPrefix + name
+		if id, ok := ids[name]; ok {
+			keys[i] = id
+		}
+	}
+
+	scores, err := b.reviewer.Suppliers(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make(map[string]float32, len(supplierNames))
+	for i, name := range supplierNames {
+		if score, ok := scores[keys[i]]; ok {
+			reviews[name] = score
+		}
+	}
+	return reviews, nil
+}
@@ -0,0 +1,68 @@
+package review
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestByID_Suppliers(t *testing.T) {
+	reviewer := &countingReviewer{}
+	ids := func(names []string) (map[string]string, error) {
+		return map[string]string{"Hitchhiker Essentials": "1"}, nil
+	}
+
+	reviews, err := ByID(reviewer, ids).Suppliers([]string{"Hitchhiker Essentials", "Hitchhiker Knockoffs"})
+	if err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if len(reviews) != 2 || reviews["Hitchhiker Essentials"] != 4 || reviews["Hitchhiker Knockoffs"] != 4 {
+		t.Fatalf("Expected the scores of both suppliers by name, got %v", reviews)
+	}
+	// Suppliers without an ID are looked up by name
+	if len(reviewer.requested) != 2 || reviewer.requested[0] != "1" || reviewer.requested[1] != "name:Hitchhiker Knockoffs" {
+		t.Fatalf("Expected the supplier with an ID to be looked up by ID, got %v", reviewer.requested)
+	}
+}
+
+// scoresReviewer returns the scores it has for the requested suppliers
+type scoresReviewer map[string]float32
+
+func (r scoresReviewer) Suppliers(supplierIDs []string) (map[string]float32, error) {
+	reviews := make(map[string]float32)
+	for _, id := range supplierIDs {
+		if score, ok := r[id]; ok {
+			reviews[id] = score
+		}
+	}
+	return reviews, nil
+}
+
+func TestByID_Suppliers_nameLikeID(t *testing.T) {
+	reviewer := scoresReviewer{"42": 5, "name:42": 2}
+	ids := func(names []string) (map[string]string, error) {
+		return map[string]string{"Hitchhiker Essentials": "42"}, nil
+	}
+
+	// The supplier named 42 has no ID, so it mustn't get the scores of the supplier with the ID 42
+	reviews, err := ByID(reviewer, ids).Suppliers([]string{"42", "Hitchhiker Essentials"})
+	if err != nil {
+		t.Fatalf("Expected no error retrieving reviews, got %v", err)
+	}
+	if reviews["42"] != 2 || reviews["Hitchhiker Essentials"] != 5 {
+		t.Fatalf("Expected separate scores for the name and the ID, got %v", reviews)
+	}
+}
+
+func TestByID_Suppliers_lookupError(t *testing.T) {
+	reviewer := &countingReviewer{}
+	ids := func(names []string) (map[string]string, error) {
+		return nil, errors.New("database unavailable")
+	}
+
+	if _, err := ByID(reviewer, ids).Suppliers([]string{"Hitchhiker Essentials"}); err == nil {
+		t.Fatal("Expected an error if the IDs can't be looked up")
+	}
+	if len(reviewer.requested) != 0 {
+		t.Fatalf("Expected no request to the reviewer, got %v", reviewer.requested)
+	}
+}
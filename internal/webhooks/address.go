@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// ErrInternalAddress is returned for webhooks which point to an address in the internal network
+var ErrInternalAddress = errors.New("webhooks can't be sent to loopback, link-local or private addresses")
+
+// publicIP returns whether webhooks may be sent to the IP. Loopback, link-local, private and
+// unspecified addresses are refused, so that webhooks can't be used to reach internal services
+// such as the cloud metadata endpoint.
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsPrivate() && !ip.IsUnspecified()
+}
+
+// CheckURL returns ErrInternalAddress if the host of the webhook URL is, or resolves to, an
+// internal address
+//
+// Hosts which can't be resolved are accepted, since they may be resolvable by the time events are
+// sent. The dispatcher checks the address of every connection anyway, so that hosts which resolve
+// to another address later are refused, too.
+func CheckURL(ctx context.Context, resolver *net.Resolver, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return ErrInternalAddress
+		}
+		return nil
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrInternalAddress
+		}
+	}
+	return nil
+}
+
+// refuseInternalAddresses is the Control function of the dialer of deliveries. It's called with the
+// resolved address of every connection, including those of redirects.
+func refuseInternalAddresses(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return ErrInternalAddress
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package webhooks
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	testCases := []struct {
+		url  string
+		want error
+	}{
+		{"https://93.184.216.34/hook", nil},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hook", nil},
+		{"http://127.0.0.1:8080/hook", ErrInternalAddress},
+		{"http://[::1]/hook", ErrInternalAddress},
+		{"http://localhost/hook", ErrInternalAddress},
+		{"http://10.0.0.1/hook", ErrInternalAddress},
+		{"http://192.168.1.1/hook", ErrInternalAddress},
+		{"http://[fd00::1]/hook", ErrInternalAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrInternalAddress},
+		{"http://[fe80::1]/hook", ErrInternalAddress},
+		{"http://0.0.0.0/hook", ErrInternalAddress},
+		{"http://[::ffff:127.0.0.1]/hook", ErrInternalAddress},
+	}
+
+	for _, testCase := range testCases {
+		if got := CheckURL(context.Background(), net.DefaultResolver, testCase.url); got != testCase.want {
+			t.Fatalf("%s: got error %v, want %v", testCase.url, got, testCase.want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+// Package webhooks notifies webhook subscribers when a product gets a new cheapest offer
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+// EventCheapestOffer is the event sent when an inserted or updated offer is the new cheapest offer
+// for a product in a category
+const EventCheapestOffer = "offer.cheapest"
+
+// errStopped is recorded for deliveries which were cancelled because the dispatcher stopped
+const errStopped = "dispatcher stopped"
+
+// Store is where webhook subscriptions are looked up and deliveries are recorded
+type Store interface {
+	MatchingWebhooks(productName, categoryName string) ([]database.Webhook, error)
+	LogDelivery(delivery database.WebhookDelivery) error
+	AddDeadLetter(letter database.DeadLetter) error
+}
+
+// Config configures how events are delivered
+type Config struct {
+	// MaxAttempts is the number of times a delivery is attempted before it's dead-lettered
+	MaxAttempts int
+	// InitialBackoff is the time before the first retry. It doubles with every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time between retries
+	MaxBackoff time.Duration
+	// Timeout is the time a receiver has to respond
+	Timeout time.Duration
+	// Workers is the number of deliveries which run concurrently
+	Workers int
+	// QueueSize is the number of deliveries which can wait for a worker. Deliveries that don't fit
+	// are dead-lettered immediately.
+	QueueSize int
+}
+
+// DefaultConfig returns the configuration used by the service
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Timeout:        10 * time.Second,
+		Workers:        4,
+		QueueSize:      1000,
+	}
+}
+
+// Payload is the JSON body sent to webhooks
+type Payload struct {
+	ID       string    `json:"id"`
+	Event    string    `json:"event"`
+	Product  string    `json:"product"`
+	Category string    `json:"category"`
+	Supplier string    `json:"supplier"`
+	Price    float32   `json:"price"`
+	Time     time.Time `json:"time"`
+}
+
+// delivery is an event waiting to be sent to a webhook
+type delivery struct {
+	webhook database.Webhook
+	eventID string
+	body    []byte
+}
+
+// product identifies a product in a category
+type product struct {
+	name, category string
+}
+
+// Dispatcher sends events to webhooks in the background
+type Dispatcher struct {
+	store  Store
+	config Config
+	client *http.Client
+	queue  chan delivery
+
+	// pending holds the latest new cheapest offer of every product whose webhooks haven't been
+	// looked up yet. Changes to the same product are coalesced.
+	mu      sync.Mutex
+	pending map[product]database.Change
+	wake    chan struct{}
+}
+
+// NewDispatcher returns a dispatcher which finds webhooks in the store and delivers events to them
+func NewDispatcher(store Store, config Config) (*Dispatcher, error) {
+	if config.MaxAttempts < 1 {
+		return nil, fmt.Errorf("webhooks need at least one delivery attempt, got %d", config.MaxAttempts)
+	}
+	if config.InitialBackoff <= 0 || config.MaxBackoff < config.InitialBackoff {
+		return nil, fmt.Errorf("invalid webhook backoff between %s and %s", config.InitialBackoff, config.MaxBackoff)
+	}
+	if config.Workers < 1 || config.QueueSize < 0 {
+		return nil, fmt.Errorf("invalid number of webhook workers (%d) or queue size (%d)", config.Workers, config.QueueSize)
+	}
+
+	return &Dispatcher{
+		store:   store,
+		config:  config,
+		client:  newClient(config.Timeout),
+		queue:   make(chan delivery, config.QueueSize),
+		pending: make(map[product]database.Change),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// newClient returns the client of deliveries. It connects to the receivers directly rather than
+// through a proxy, and refuses to connect to internal addresses.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refuseInternalAddresses}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Notify schedules an event for every new cheapest offer
+//
+// Its signature matches database.ChangeListener, so it can be registered with the database to be
+// called after writes have been committed. It neither looks up webhooks nor waits for the
+// deliveries, which is left to the workers. If a product gets another cheapest offer before its
+// webhooks have been looked up, only the latest one is sent. Webhooks are only available to the
+// default tenant, so the offers of other tenants are ignored.
+func (d *Dispatcher) Notify(changes []database.Change) {
+	scheduled := false
+	d.mu.Lock()
+	for _, change := range changes {
+		if !change.Cheapest || change.Tenant != database.DefaultTenant {
+			continue
+		}
+		d.pending[product{change.Offer.Product, change.Offer.Category}] = change
+		scheduled = true
+	}
+	d.mu.Unlock()
+
+	if !scheduled {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run looks up the webhooks for scheduled events and delivers them until the context is cancelled
+//
+// Events which are still scheduled, queued or waiting for a retry when the context is cancelled are
+// dead-lettered.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < d.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-d.wake:
+					d.match()
+				case job := <-d.queue:
+					d.deliver(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	d.match()
+	for {
+		select {
+		case job := <-d.queue:
+			d.deadLetter(job, 0, errStopped)
+		default:
+			return
+		}
+	}
+}
+
+// match queues a delivery of every scheduled event to every webhook matching it
+func (d *Dispatcher) match() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[product]database.Change)
+	d.mu.Unlock()
+
+	for _, change := range pending {
+		webhooks, err := d.store.MatchingWebhooks(change.Offer.Product, change.Offer.Category)
+		if err != nil {
+			log.Printf("Error looking up webhooks: %v", err)
+			continue
+		}
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		payload := Payload{
+			ID:       newEventID(),
+			Event:    EventCheapestOffer,
+			Product:  change.Offer.Product,
+			Category: change.Offer.Category,
+			Supplier: change.Offer.Supplier,
+			Price:    change.Offer.Price,
+			Time:     change.Time.UTC(),
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("Error encoding webhook payload: %v", err)
+			continue
+		}
+
+		for _, webhook := range webhooks {
+			job := delivery{webhook: webhook, eventID: payload.ID, body: body}
+			select {
+			case d.queue <- job:
+			default:
+				d.deadLetter(job, 0, "delivery queue full")
+			}
+		}
+	}
+}
+
+// deliver sends the event to the webhook, retrying with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, job delivery) {
+	var lastErr string
+	for attempt := 1; attempt <= d.config.MaxAttempts; attempt++ {
+		record := d.send(ctx, job, attempt)
+		if err := d.store.LogDelivery(record); err != nil {
+			log.Printf("Error logging webhook delivery: %v", err)
+		}
+		if record.Error == "" {
+			return
+		}
+		lastErr = record.Error
+
+		if attempt == d.config.MaxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.deadLetter(job, attempt, errStopped)
+			return
+		case <-timer.C:
+		}
+	}
+
+	d.deadLetter(job, d.config.MaxAttempts, lastErr)
+}
+
+// send makes one attempt to deliver the event and returns its record for the delivery log
+func (d *Dispatcher) send(ctx context.Context, job delivery, attempt int) database.WebhookDelivery {
+	record := database.WebhookDelivery{
+		WebhookID: job.webhook.ID,
+		EventID:   job.eventID,
+		Attempt:   attempt,
+		Time:      time.Now().UTC(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", job.webhook.URL, bytes.NewReader(job.body))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, job.eventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.webhook.Secret, timestamp, job.body))
+
+	resp, err := d.client.Do(req)
+	record.Duration = time.Since(record.Time)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	resp.Body.Close()
+
+	record.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		record.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+	}
+	return record
+}
+
+// backoff returns the time to wait after the failed attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.config.InitialBackoff
+	for i := 1; i < attempt && backoff < d.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.config.MaxBackoff {
+		return d.config.MaxBackoff
+	}
+	return backoff
+}
+
+// deadLetter stores a delivery which has been given up on
+func (d *Dispatcher) deadLetter(job delivery, attempts int, lastErr string) {
+	err := d.store.AddDeadLetter(database.DeadLetter{
+		WebhookID: job.webhook.ID,
+		EventID:   job.eventID,
+		Payload:   job.body,
+		Attempts:  attempts,
+		LastError: lastErr,
+		Time:      time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Error storing undeliverable webhook event %s: %v", job.eventID, err)
+	}
+}
+
+// newEventID returns a random ID for an event
+func newEventID() string {
+	id := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,279 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/muffix/relayr-challenge/internal/database"
+)
+
+const testSecret = "don't panic"
+
+// receiver is a local webhook endpoint which verifies signatures and records the payloads
+type receiver struct {
+	mu       sync.Mutex
+	payloads []Payload
+	// failures is the number of requests to fail before succeeding
+	failures int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := Verify(testSecret, r.Header, body, time.Minute); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.failures != 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	payload := Payload{}
+	if err := json.Unmarshal(body, &payload); err != nil || r.Header.Get(HeaderID) != payload.ID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.payloads = append(rc.payloads, payload)
+}
+
+func (rc *receiver) received() []Payload {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Payload(nil), rc.payloads...)
+}
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.MaxAttempts = 3
+	config.InitialBackoff = time.Millisecond
+	config.MaxBackoff = 5 * time.Millisecond
+	return config
+}
+
+// setup returns a database with a webhook for towels pointing to the receiver and a running
+// dispatcher listening to changes
+func setup(t *testing.T, rc *receiver, config Config) *database.OffersSQLiteDatabase {
+	db, err := database.InitSQLiteDatabase(filepath.Join(t.TempDir(), "offers.db"))
+	if err != nil {
+		t.Fatalf("Expected no error creating the database, got %v", err)
+	}
+
+	server := httptest.NewServer(rc)
+	_, err = db.AddWebhook(database.Webhook{URL: server.URL, Product: "Towel", Category: "Must Haves", Secret: testSecret})
+	if err != nil {
+		t.Fatalf("Expected no error adding a webhook, got %v", err)
+	}
+
+	dispatcher, err := NewDispatcher(db, config)
+	if err != nil {
+		t.Fatalf("Expected no error creating the dispatcher, got %v", err)
+	}
+	// The receiver listens on a loopback address, which the client of the dispatcher refuses
+	dispatcher.client = &http.Client{Timeout: config.Timeout}
+	db.AddChangeListener(dispatcher.Notify)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(stopped)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		server.Close()
+		db.Close()
+	})
+	return db
+}
+
+// eventually fails the test if the condition isn't met within a few seconds
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	rc := &receiver{}
+	db := setup(t, rc, testConfig())
+
+	// Only new cheapest offers for towels are sent
+	err := db.InsertMultiple(context.Background(), []database.Offer{
+		{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42},
+		{Product: "Guide", Category: "Must Haves", Supplier: "Megadodo", Price: 30},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+	// Later offers would be coalesced with the first one while its webhooks are looked up
+	eventually(t, "the first delivery", func() bool { return len(rc.received()) == 1 })
+
+	err = db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs", 50)
+	if err == nil {
+		err = db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Knockoffs", 40)
+	}
+	if err != nil {
+		t.Fatalf("Expected no error inserting offers, got %v", err)
+	}
+
+	var deliveries []database.WebhookDelivery
+	eventually(t, "two deliveries", func() bool {
+		deliveries, _ = db.Deliveries(1, 10)
+		return len(deliveries) == 2
+	})
+
+	// Deliveries run concurrently, so they may arrive in any order
+	payloads := rc.received()
+	sort.Slice(payloads, func(i, j int) bool { return payloads[i].Time.Before(payloads[j].Time) })
+	if payloads[0].Supplier != "Hitchhiker Essentials" || payloads[1].Supplier != "Hitchhiker Knockoffs" ||
+		payloads[1].Price != 40 || payloads[1].Event != EventCheapestOffer {
+		t.Fatalf("Got payloads %+v, want the two new cheapest offers", payloads)
+	}
+
+	for _, delivery := range deliveries {
+		if delivery.StatusCode != http.StatusOK || delivery.Error != "" {
+			t.Fatalf("Got delivery log %+v, want two successful deliveries", deliveries)
+		}
+	}
+}
+
+func TestDispatcher_Notify(t *testing.T) {
+	// Without a store, looking up webhooks in Notify would panic
+	dispatcher, err := NewDispatcher(nil, testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	towel := database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Essentials", Price: 42}
+	cheaper := database.Offer{Product: "Towel", Category: "Must Haves", Supplier: "Hitchhiker Knockoffs", Price: 40}
+	dispatcher.Notify([]database.Change{{Type: database.OfferInserted, Tenant: database.DefaultTenant, Offer: towel, Cheapest: true}})
+	dispatcher.Notify([]database.Change{
+		{Type: database.OfferInserted, Tenant: database.DefaultTenant, Offer: cheaper, Cheapest: true},
+		{Type: database.OfferInserted, Tenant: "acme", Offer: database.Offer{Product: "Guide", Category: "Must Haves"}, Cheapest: true},
+		{Type: database.OfferInserted, Tenant: database.DefaultTenant, Offer: database.Offer{Product: "Guide", Category: "Must Haves"}},
+	})
+
+	// Only the latest cheapest offer of the default tenant is scheduled
+	if len(dispatcher.pending) != 1 || dispatcher.pending[product{"Towel", "Must Haves"}].Offer != cheaper {
+		t.Fatalf("Got pending events %+v, want the cheaper towel", dispatcher.pending)
+	}
+}
+
+func TestDispatcher_retries(t *testing.T) {
+	rc := &receiver{failures: 2}
+	db := setup(t, rc, testConfig())
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	var deliveries []database.WebhookDelivery
+	eventually(t, "three attempts", func() bool {
+		deliveries, _ = db.Deliveries(1, 10)
+		return len(deliveries) == 3
+	})
+
+	if len(rc.received()) != 1 {
+		t.Fatalf("Got payloads %+v, want one", rc.received())
+	}
+	// The log is sorted newest first
+	if deliveries[2].StatusCode != http.StatusInternalServerError || deliveries[2].Attempt != 1 ||
+		deliveries[0].StatusCode != http.StatusOK || deliveries[0].Attempt != 3 ||
+		deliveries[0].EventID != deliveries[2].EventID {
+		t.Fatalf("Got delivery log %+v, want two failures and a success", deliveries)
+	}
+}
+
+func TestDispatcher_deadLetters(t *testing.T) {
+	rc := &receiver{failures: 100}
+	db := setup(t, rc, testConfig())
+
+	if err := db.Insert(context.Background(), "Towel", "Must Haves", "Hitchhiker Essentials", 42); err != nil {
+		t.Fatalf("Expected no error inserting an offer, got %v", err)
+	}
+
+	var letters []database.DeadLetter
+	eventually(t, "the dead letter", func() bool {
+		letters, _ = db.DeadLetters(10)
+		return len(letters) == 1
+	})
+
+	payload := Payload{}
+	if err := json.Unmarshal(letters[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if letters[0].Attempts != 3 || letters[0].LastError == "" || payload.ID != letters[0].EventID {
+		t.Fatalf("Got dead letter %+v, want the payload after three attempts", letters[0])
+	}
+}
+
+func TestDispatcher_refusesInternalAddresses(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, err := NewDispatcher(nil, testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := delivery{webhook: database.Webhook{ID: 1, URL: server.URL, Secret: testSecret}, eventID: "1", body: []byte("{}")}
+	record := dispatcher.send(context.Background(), job, 1)
+	if !strings.Contains(record.Error, ErrInternalAddress.Error()) {
+		t.Fatalf("Got delivery %+v, want it refused", record)
+	}
+	if len(rc.received()) != 0 {
+		t.Fatalf("Got payloads %+v, want none", rc.received())
+	}
+}
+
+func TestDispatcher_backoff(t *testing.T) {
+	dispatcher, err := NewDispatcher(nil, Config{
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Workers:        1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, backoff := range want {
+		if got := dispatcher.backoff(i + 1); got != backoff {
+			t.Fatalf("Got backoff %s after attempt %d, want %s", got, i+1, backoff)
+		}
+	}
+}
+
+func TestNewDispatcher_withInvalidConfig(t *testing.T) {
+	for _, config := range []Config{
+		{MaxAttempts: 0, InitialBackoff: time.Second, MaxBackoff: time.Second, Workers: 1},
+		{MaxAttempts: 1, InitialBackoff: time.Minute, MaxBackoff: time.Second, Workers: 1},
+		{MaxAttempts: 1, InitialBackoff: time.Second, MaxBackoff: time.Second, Workers: 0},
+	} {
+		if _, err := NewDispatcher(nil, config); err == nil {
+			t.Fatalf("Expected an error for config %+v", config)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers sent with every delivery
+const (
+	// HeaderID is the ID of the event. It's the same for all attempts, so receivers can use it to
+	// ignore duplicates.
+	HeaderID = "Webhook-Id"
+	// HeaderTimestamp is the time of the attempt in seconds since the Unix epoch
+	HeaderTimestamp = "Webhook-Timestamp"
+	// HeaderSignature is the HMAC-SHA256 of the timestamp and the body, keyed with the webhook's secret
+	HeaderSignature = "Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of a delivery
+//
+// The signed message is the timestamp, a dot and the body. Including the timestamp lets receivers
+// reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery and that it was sent within the tolerance
+//
+// It's meant for receivers written in Go and for tests.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header", HeaderTimestamp)
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp is outside the tolerance of %s", tolerance)
+	}
+
+	signature := header.Get(HeaderSignature)
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
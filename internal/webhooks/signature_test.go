@@ -0,0 +1,40 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"product":"Towel"}`)
+	now := time.Now().Unix()
+
+	header := func(timestamp int64, signature string) http.Header {
+		h := http.Header{}
+		h.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		h.Set(HeaderSignature, signature)
+		return h
+	}
+
+	testCases := []struct {
+		name    string
+		header  http.Header
+		body    []byte
+		wantErr bool
+	}{
+		{"valid", header(now, Sign(testSecret, now, body)), body, false},
+		{"other secret", header(now, Sign("other", now, body)), body, true},
+		{"tampered body", header(now, Sign(testSecret, now, body)), []byte(`{"product":"Guide"}`), true},
+		{"replayed", header(now-3600, Sign(testSecret, now-3600, body)), body, true},
+		{"missing timestamp", http.Header{HeaderSignature: {Sign(testSecret, now, body)}}, body, true},
+	}
+
+	for _, testCase := range testCases {
+		err := Verify(testSecret, testCase.header, testCase.body, time.Minute)
+		if (err != nil) != testCase.wantErr {
+			t.Fatalf("%s: got error %v, want error: %t", testCase.name, err, testCase.wantErr)
+		}
+	}
+}